@@ -11,6 +11,7 @@ require (
 	github.com/darrenoakey/daz-golang-gio v0.0.5
 	github.com/zalando/go-keyring v0.2.6
 	golang.org/x/image v0.26.0
+	golang.org/x/text v0.24.0
 )
 
 require (
@@ -23,5 +24,4 @@ require (
 	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b // indirect
 	golang.org/x/exp/shiny v0.0.0-20250408133849-7e4ce0ab07d0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
-	golang.org/x/text v0.24.0 // indirect
 )
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"prompt-grid/src/emulator"
+	"prompt-grid/src/gui"
+	"prompt-grid/src/ipc"
+)
+
+// Exit codes for CLI subcommands
+const (
+	exitOK    = 0
+	exitError = 1 // The command failed (daemon error, session not found, timeout)
+	exitUsage = 2 // Bad arguments
+)
+
+// waitPollInterval is how often wait checks the session's prompt state
+var waitPollInterval = 250 * time.Millisecond
+
+// runCLI runs one invocation's subcommand and returns its exit code
+func runCLI(args []string, out, errOut io.Writer) int {
+	var err error
+	// The command words are ipc.ReservedNames, which sessions can't take
+	switch args[0] {
+	case "help", "-h", "--help":
+		printUsage(out)
+		return exitOK
+	case "search":
+		if len(args) < 2 {
+			return usageError(errOut, "search requires a query")
+		}
+		return runSearch(strings.Join(args[1:], " "), out, errOut)
+	case "ls":
+		err = runList(args[1:], out)
+	case "send":
+		err = runSend(args[1:])
+	case "capture":
+		err = runCapture(args[1:], out)
+	case "close":
+		if len(args) != 2 {
+			return usageError(errOut, "close requires a session name")
+		}
+		err = ipc.NewClient().Close(args[1])
+	case "rename":
+		if len(args) != 3 {
+			return usageError(errOut, "rename requires a session name and a new name")
+		}
+		err = ipc.NewClient().Rename(args[1], args[2])
+	case "claude", "codex":
+		err = runAgent(args[0], args[1:])
+	case "wait":
+		err = runWait(args[1:])
+	case "ssh":
+		if len(args) < 2 || len(args) > 3 {
+			return usageError(errOut, "ssh requires a host argument")
+		}
+		open := ipc.OpenArgs{Name: args[1], SSHHost: args[1]}
+		if len(args) == 3 {
+			open.Name = args[2]
+		}
+		err = runOpen(open)
+	default:
+		if len(args) != 1 {
+			return usageError(errOut, fmt.Sprintf("unknown command %q", args[0]))
+		}
+		err = runOpen(ipc.OpenArgs{Name: args[0]})
+	}
+
+	var usage usageErr
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usage):
+		return usageError(errOut, usage.msg)
+	case errors.Is(err, ipc.ErrNoDaemon):
+		fmt.Fprintln(errOut, "Error: prompt-grid is not running (start it with: prompt-grid <session-name>)")
+	default:
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+	}
+	return exitError
+}
+
+// usageErr is an argument error returned by a subcommand; runCLI prints
+// the usage and exits with exitUsage
+type usageErr struct {
+	msg string
+}
+
+func (e usageErr) Error() string { return e.msg }
+
+func usageError(errOut io.Writer, msg string) int {
+	fmt.Fprintf(errOut, "Error: %s\n\n", msg)
+	printUsage(errOut)
+	return exitUsage
+}
+
+// newFlagSet returns a flag set that leaves error reporting to runCLI
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// the positional arguments, which it returns.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageErr{fmt.Sprintf("%s: %v", fs.Name(), err)}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// ensureDaemon starts the daemon if it isn't running and waits for its socket
+func ensureDaemon() error {
+	client := ipc.NewClient()
+	if client.Running() {
+		return nil
+	}
+	spawnDaemon()
+	for i := 0; i < 50; i++ { // Try for up to 5 seconds
+		if client.Running() {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("failed to connect to daemon")
+}
+
+// runOpen opens (or focuses) a session, spawning the daemon if needed
+func runOpen(args ipc.OpenArgs) error {
+	if err := ensureDaemon(); err != nil {
+		return err
+	}
+	return ipc.NewClient().Open(args)
+}
+
+// runAgent creates a claude or codex session in a directory, named after
+// it unless a name is given.
+func runAgent(kind string, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageErr{kind + " requires a directory"}
+	}
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", args[0])
+	}
+	name := filepath.Base(dir)
+	if len(args) == 2 {
+		name = args[1]
+	}
+	if err := ensureDaemon(); err != nil {
+		return err
+	}
+	return ipc.NewClient().New(ipc.NewArgs{Name: name, Type: kind, WorkDir: dir})
+}
+
+// runList prints the daemon's sessions as a table, or as JSON with --json
+func runList(args []string, out io.Writer) error {
+	fs := newFlagSet("ls")
+	asJSON := fs.Bool("json", false, "print JSON")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageErr{"ls takes no arguments"}
+	}
+
+	sessions, err := ipc.NewClient().List()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if sessions == nil {
+			sessions = []ipc.SessionInfo{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sessions)
+	}
+
+	home, _ := os.UserHomeDir()
+	now := time.Now()
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tSTATUS\tCWD\tLAST ACTIVITY")
+	for _, s := range sessions {
+		cwd := s.WorkDir
+		if s.SSHHost != "" {
+			cwd = s.SSHHost
+		} else if home != "" && (cwd == home || strings.HasPrefix(cwd, home+"/")) {
+			cwd = "~" + strings.TrimPrefix(cwd, home)
+		}
+		if cwd == "" {
+			cwd = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Type, sessionStatus(s), cwd, formatAgo(now, s.LastActivity))
+	}
+	return tw.Flush()
+}
+
+// sessionStatus describes whether a session is waiting for input
+func sessionStatus(s ipc.SessionInfo) string {
+	switch s.Prompt {
+	case "shell":
+		return "idle"
+	case "claude":
+		return "waiting"
+	}
+	return "busy"
+}
+
+// formatAgo formats how long before now t was, e.g. "5m ago"
+func formatAgo(now, t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
+
+// runSend types text into a session and presses Enter unless --no-enter
+func runSend(args []string) error {
+	fs := newFlagSet("send")
+	noEnter := fs.Bool("no-enter", false, "don't press Enter after the text")
+	if err := fs.Parse(args); err != nil {
+		return usageErr{fmt.Sprintf("send: %v", err)}
+	}
+	if fs.NArg() < 2 {
+		return usageErr{"send requires a session name and text"}
+	}
+	text := strings.Join(fs.Args()[1:], " ")
+	return ipc.NewClient().SendText(fs.Arg(0), text, !*noEnter)
+}
+
+// runCapture writes a session's screen to out as text, ANSI or PNG
+func runCapture(args []string, out io.Writer) error {
+	fs := newFlagSet("capture")
+	png := fs.Bool("png", false, "capture a PNG image")
+	ansi := fs.Bool("ansi", false, "keep colors and attributes as escape sequences")
+	scrollback := fs.Int("scrollback", 0, "include up to `N` scrollback lines")
+	since := fs.String("since", "", "include scrollback that arrived from `TIME` on (15:04, 2006-01-02 15:04 or -90m)")
+	until := fs.String("until", "", "include scrollback that arrived before `TIME`, and not the screen")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageErr{"capture requires a session name"}
+	}
+
+	req := ipc.CaptureArgs{Name: positional[0], Format: ipc.FormatText, Scrollback: *scrollback}
+	now := time.Now()
+	for _, f := range []struct {
+		flag, value string
+		t           **time.Time
+	}{{"since", *since, &req.Since}, {"until", *until, &req.Until}} {
+		if f.value == "" {
+			continue
+		}
+		t, ok := gui.ParseTime(f.value, now)
+		if !ok {
+			return usageErr{fmt.Sprintf("capture: can't read --%s time %q", f.flag, f.value)}
+		}
+		*f.t = &t
+	}
+	switch {
+	case *png && *ansi:
+		return usageErr{"capture: --png and --ansi are mutually exclusive"}
+	case *png && (*scrollback > 0 || *since != "" || *until != ""):
+		return usageErr{"capture: --scrollback, --since and --until can't be used with --png"}
+	case *png:
+		req.Format = ipc.FormatPNG
+	case *ansi:
+		req.Format = ipc.FormatANSI
+	}
+
+	res, err := ipc.NewClient().Capture(req)
+	if err != nil {
+		return err
+	}
+	if req.Format == ipc.FormatPNG {
+		_, err = out.Write(res.PNG)
+	} else {
+		_, err = io.WriteString(out, res.Text)
+	}
+	return err
+}
+
+// runWait blocks until a session is waiting at a prompt. A session that was
+// sent input is only done once it reaches a new prompt after it, not the
+// one it was at when the input was sent.
+func runWait(args []string) error {
+	fs := newFlagSet("wait")
+	forPrompt := fs.Bool("for-prompt", false, "wait until the session is waiting for input")
+	timeout := fs.Duration("timeout", 10*time.Minute, "give up after this long")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || !*forPrompt {
+		return usageErr{"wait requires a session name and --for-prompt"}
+	}
+	name := positional[0]
+
+	client := ipc.NewClient()
+	deadline := time.Now().Add(*timeout)
+	for {
+		sessions, err := client.List()
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, s := range sessions {
+			if s.Name == name {
+				if s.Prompt != "" && !s.InputPending {
+					return nil
+				}
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("session %q not found", name)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v waiting for %q", *timeout, name)
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
+
+// runSearch prints matches from every session's persisted scrollback
+func runSearch(query string, out, errOut io.Writer) int {
+	pattern, isRegex := gui.ParseSearchQuery(query)
+	searcher, err := emulator.NewSearcher(pattern, isRegex)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return exitError
+	}
+	hits, err := searcher.SearchSessions(emulator.SessionsDir(), 50)
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n", err)
+		return exitError
+	}
+	if len(hits) == 0 {
+		fmt.Fprintln(errOut, "No matches")
+		return exitError
+	}
+	for _, hit := range hits {
+		fmt.Fprintf(out, "%s:%d: %s\n", hit.Session, hit.Line+1, hit.Snippet)
+	}
+	return exitOK
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, `prompt-grid - Terminal emulator with multi-view support
+
+Usage:
+  prompt-grid <session-name>              Create a new local session
+  prompt-grid ssh <host> [session-name]   Create an SSH session
+  prompt-grid claude <dir> [session-name] Start Claude Code in a directory
+  prompt-grid codex <dir> [session-name]  Start Codex in a directory
+  prompt-grid search <query>              Search every session's history
+                                          (use /pattern/ for a regex)
+
+Scripting (requires a running daemon):
+  prompt-grid ls [--json]                 List sessions with status, type,
+                                          cwd and last activity
+  prompt-grid send [--no-enter] <session> <text>
+                                          Type text into a session, then Enter
+  prompt-grid capture <session> [--png|--ansi] [--scrollback N]
+                                [--since TIME] [--until TIME]
+                                          Print the screen (PNG bytes with --png)
+                                          and scrollback, by count or arrival time
+  prompt-grid close <session>             Close a session
+  prompt-grid rename <session> <new-name> Rename a session
+  prompt-grid wait <session> --for-prompt [--timeout 10m]
+                                          Wait until the session is back at a
+                                          prompt after what was last sent
+
+Commands exit 0 on success, 1 on failure and 2 on bad arguments. The
+command words above (help, search, ls, send, capture, close, rename,
+claude, codex, wait, ssh) can't be used as session names.
+
+Examples:
+  prompt-grid "My Project"
+  prompt-grid ssh user@host "Remote Work"
+  prompt-grid ssh myserver
+  prompt-grid search "panic: runtime error"
+  prompt-grid send build "make test" && prompt-grid wait build --for-prompt
+  prompt-grid capture build --scrollback 200 > build.log
+  prompt-grid capture build --since 03:00 --until 04:00`)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"prompt-grid/src/ipc"
+	"prompt-grid/src/tmux"
+)
+
+// TestMain isolates the daemon socket in a realm of its own
+func TestMain(m *testing.M) {
+	os.Setenv(tmux.RealmEnvVar, fmt.Sprintf("cli-test-%d-%d", os.Getpid(), time.Now().UnixNano()))
+	waitPollInterval = 10 * time.Millisecond
+	code := m.Run()
+	os.RemoveAll(tmux.GetSocketDir())
+	os.Exit(code)
+}
+
+// fakeDaemon serves a fixed set of sessions and records what it is sent
+type fakeDaemon struct {
+	sessions []ipc.SessionInfo
+	calls    []string
+}
+
+func (f *fakeDaemon) session(name string) error {
+	for _, s := range f.sessions {
+		if s.Name == name {
+			return nil
+		}
+	}
+	return ipc.Errorf(ipc.CodeNotFound, "session %q not found", name)
+}
+
+func (f *fakeDaemon) Open(a ipc.OpenArgs) error {
+	f.calls = append(f.calls, "open "+a.Name)
+	return nil
+}
+func (f *fakeDaemon) List() ([]ipc.SessionInfo, error) {
+	f.calls = append(f.calls, "list")
+	return f.sessions, nil
+}
+func (f *fakeDaemon) New(a ipc.NewArgs) error {
+	f.calls = append(f.calls, fmt.Sprintf("new %s %s %s", a.Name, a.Type, a.WorkDir))
+	return nil
+}
+func (f *fakeDaemon) Close(a ipc.SessionArgs) error {
+	f.calls = append(f.calls, "close "+a.Name)
+	return f.session(a.Name)
+}
+func (f *fakeDaemon) Rename(a ipc.RenameArgs) error {
+	f.calls = append(f.calls, "rename "+a.Name+" "+a.NewName)
+	return f.session(a.Name)
+}
+func (f *fakeDaemon) Recolor(a ipc.SessionArgs) error   { return f.session(a.Name) }
+func (f *fakeDaemon) SendKeys(a ipc.SendKeysArgs) error { return f.session(a.Name) }
+func (f *fakeDaemon) PopOut(a ipc.SessionArgs) error    { return f.session(a.Name) }
+func (f *fakeDaemon) Focus(a ipc.SessionArgs) error     { return f.session(a.Name) }
+func (f *fakeDaemon) SendText(a ipc.SendTextArgs) error {
+	f.calls = append(f.calls, fmt.Sprintf("send %s %q %v", a.Name, a.Text, a.Enter))
+	return f.session(a.Name)
+}
+func (f *fakeDaemon) Capture(a ipc.CaptureArgs) (*ipc.CaptureResult, error) {
+	f.calls = append(f.calls, fmt.Sprintf("capture %s %s %d", a.Name, a.Format, a.Scrollback))
+	if err := f.session(a.Name); err != nil {
+		return nil, err
+	}
+	if a.Format == ipc.FormatPNG {
+		return &ipc.CaptureResult{Format: a.Format, PNG: []byte("\x89PNG")}, nil
+	}
+	return &ipc.CaptureResult{Format: a.Format, Text: "$ make\n"}, nil
+}
+
+func startDaemon(t *testing.T, sessions ...ipc.SessionInfo) *fakeDaemon {
+	t.Helper()
+	daemon := &fakeDaemon{sessions: sessions}
+	server, err := ipc.NewServer(daemon)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	go server.Run()
+	t.Cleanup(func() { server.Close() })
+	return daemon
+}
+
+func runArgs(args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = runCLI(args, &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestCLICommands(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	daemon := startDaemon(t,
+		ipc.SessionInfo{Name: "build", Type: ipc.TypeShell, WorkDir: home + "/src/app", Prompt: "shell",
+			LastActivity: time.Now().Add(-5 * time.Minute)},
+		ipc.SessionInfo{Name: "agent", Type: ipc.TypeClaude, WorkDir: "/tmp", LastActivity: time.Now()},
+		// Sent a command, and the prompt it was sent at hasn't been replaced yet
+		ipc.SessionInfo{Name: "sent", Type: ipc.TypeShell, Prompt: "shell", InputPending: true},
+	)
+	dir := t.TempDir()
+
+	tests := []struct {
+		args []string
+		code int
+		out  string
+		call string
+	}{
+		{[]string{"send", "build", "make", "test"}, exitOK, "", `send build "make test" true`},
+		{[]string{"send", "--no-enter", "build", "y"}, exitOK, "", `send build "y" false`},
+		{[]string{"capture", "build"}, exitOK, "$ make\n", "capture build text 0"},
+		{[]string{"capture", "build", "--ansi", "--scrollback", "50"}, exitOK, "$ make\n", "capture build ansi 50"},
+		{[]string{"capture", "--png", "build"}, exitOK, "\x89PNG", "capture build png 0"},
+		{[]string{"capture", "build", "--since", "2026-10-16 03:00", "--until", "2026-10-16 04:00"}, exitOK, "$ make\n", "capture build text 0"},
+		{[]string{"capture", "missing"}, exitError, "", "capture missing text 0"},
+		{[]string{"close", "build"}, exitOK, "", "close build"},
+		{[]string{"close", "missing"}, exitError, "", "close missing"},
+		{[]string{"rename", "build", "ci"}, exitOK, "", "rename build ci"},
+		{[]string{"claude", dir}, exitOK, "", "new " + dir[strings.LastIndex(dir, "/")+1:] + " claude " + dir},
+		{[]string{"codex", dir, "review"}, exitOK, "", "new review codex " + dir},
+		{[]string{"wait", "build", "--for-prompt"}, exitOK, "", "list"},
+		{[]string{"wait", "missing", "--for-prompt"}, exitError, "", "list"},
+		{[]string{"wait", "agent", "--for-prompt", "--timeout", "30ms"}, exitError, "", "list"},
+		{[]string{"wait", "sent", "--for-prompt", "--timeout", "30ms"}, exitError, "", "list"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			daemon.calls = nil
+			code, out, _ := runArgs(tt.args...)
+			if code != tt.code || out != tt.out {
+				t.Errorf("exit %d, output %q; want %d, %q", code, out, tt.code, tt.out)
+			}
+			if len(daemon.calls) == 0 || daemon.calls[0] != tt.call {
+				t.Errorf("daemon calls = %q, want %q first", daemon.calls, tt.call)
+			}
+		})
+	}
+}
+
+// TestCLIReservedNames verifies every reserved name is a command, so
+// none of them can be shadowed by a session or shadow one.
+func TestCLIReservedNames(t *testing.T) {
+	daemon := startDaemon(t)
+	for _, name := range ipc.ReservedNames {
+		daemon.calls = nil
+		runArgs(name)
+		if slices.Contains(daemon.calls, "open "+name) {
+			t.Errorf("prompt-grid %s opened a session", name)
+		}
+	}
+	daemon.calls = nil
+	if code, _, _ := runArgs("build"); code != exitOK || !slices.Equal(daemon.calls, []string{"open build"}) {
+		t.Errorf("prompt-grid build: exit %d, daemon calls %q", code, daemon.calls)
+	}
+}
+
+func TestCLIList(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	startDaemon(t,
+		ipc.SessionInfo{Name: "build", Type: ipc.TypeShell, WorkDir: home + "/src/app", Prompt: "shell",
+			LastActivity: time.Now().Add(-5 * time.Minute)},
+		ipc.SessionInfo{Name: "box", Type: ipc.TypeSSH, SSHHost: "user@box", LastActivity: time.Now()},
+	)
+
+	code, out, _ := runArgs("ls")
+	want := "NAME   TYPE   STATUS  CWD        LAST ACTIVITY\n" +
+		"build  shell  idle    ~/src/app  5m ago\n" +
+		"box    ssh    busy    user@box   just now\n"
+	if code != exitOK || out != want {
+		t.Errorf("ls = %d\n%s\nwant\n%s", code, out, want)
+	}
+
+	code, out, _ = runArgs("ls", "--json")
+	var sessions []ipc.SessionInfo
+	if err := json.Unmarshal([]byte(out), &sessions); code != exitOK || err != nil {
+		t.Fatalf("ls --json = %d, %v\n%s", code, err, out)
+	}
+	if len(sessions) != 2 || sessions[0].Prompt != "shell" || sessions[1].SSHHost != "user@box" {
+		t.Errorf("ls --json sessions = %+v", sessions)
+	}
+}
+
+func TestCLIErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		code int
+		err  string
+	}{
+		{[]string{"send", "build"}, exitUsage, "send requires a session name and text"},
+		{[]string{"capture"}, exitUsage, "capture requires a session name"},
+		{[]string{"capture", "a", "--png", "--ansi"}, exitUsage, "mutually exclusive"},
+		{[]string{"capture", "a", "--bogus"}, exitUsage, "flag provided but not defined"},
+		{[]string{"capture", "a", "--since", "soon"}, exitUsage, `can't read --since time "soon"`},
+		{[]string{"capture", "a", "--png", "--until", "03:00"}, exitUsage, "can't be used with --png"},
+		{[]string{"wait", "a"}, exitUsage, "--for-prompt"},
+		{[]string{"rename", "a"}, exitUsage, "rename requires"},
+		{[]string{"claude"}, exitUsage, "claude requires a directory"},
+		{[]string{"a", "b"}, exitUsage, `unknown command "a"`},
+		{[]string{"ls"}, exitError, "not running"},
+		{[]string{"send", "a", "text"}, exitError, "not running"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			code, _, errOut := runArgs(tt.args...)
+			if code != tt.code || !strings.Contains(errOut, tt.err) {
+				t.Errorf("exit %d, stderr %q; want %d containing %q", code, errOut, tt.code, tt.err)
+			}
+		})
+	}
+}
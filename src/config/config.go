@@ -11,7 +11,12 @@ type SessionInfo struct {
 	Type         string `json:"type"` // "shell", "ssh", "claude"
 	WorkDir      string `json:"work_dir,omitempty"`
 	SSHHost      string `json:"ssh_host,omitempty"`
+	RemoteHost   string `json:"remote_host,omitempty"`   // Host of RemoteDir, as reported by the remote shell
+	RemoteDir    string `json:"remote_dir,omitempty"`    // Last working directory of an SSH session's remote shell
 	LastActivity int64  `json:"last_activity,omitempty"` // Unix timestamp of last PTY output
+	Clipboard    string `json:"clipboard,omitempty"`     // OSC 52 policy overriding ClipboardSettings.Policy
+
+	Scrollback *ScrollbackSettings `json:"scrollback,omitempty"` // Retention limits overriding Config.Scrollback
 }
 
 // ClaudeSettings holds Claude-aware behavior settings
@@ -19,9 +24,41 @@ type ClaudeSettings struct {
 	AutoMenu *bool `json:"auto_menu,omitempty"` // Auto-answer numbered menus (default: true)
 }
 
+// OSC 52 clipboard policies
+const (
+	ClipboardAllow = "allow" // Programs may set and read the clipboard
+	ClipboardDeny  = "deny"  // Requests are ignored
+	ClipboardAsk   = "ask"   // Each request is confirmed in the terminal
+)
+
+// DefaultClipboardMaxBytes caps the text a program can copy via OSC 52
+const DefaultClipboardMaxBytes = 1 << 20
+
+// ClipboardSettings controls clipboard access by programs in sessions (OSC 52)
+type ClipboardSettings struct {
+	Policy   string `json:"policy,omitempty"`    // ClipboardAllow, ClipboardDeny or ClipboardAsk (default)
+	MaxBytes int    `json:"max_bytes,omitempty"` // Largest copy or read accepted (default: 1 MiB)
+}
+
+// DefaultScrollbackMaxBytes caps a session's history on disk, archives
+// included, when no byte limit is configured
+const DefaultScrollbackMaxBytes = 64 << 20
+
+// ScrollbackSettings limits how much history a session keeps. Output that
+// scrolls off is archived compressed and deleted, oldest first, once any
+// limit is exceeded. Zero leaves a limit unset.
+type ScrollbackSettings struct {
+	MaxLines   int   `json:"max_lines,omitempty"`    // Lines kept (default: unlimited)
+	MaxBytes   int64 `json:"max_bytes,omitempty"`    // Bytes kept on disk (default: 64 MiB)
+	MaxAgeDays int   `json:"max_age_days,omitempty"` // Days archived history is kept (default: unlimited)
+}
+
 // UISettings holds UI behavior settings
 type UISettings struct {
-	CollapseInactive *bool `json:"collapse_inactive,omitempty"` // Hide sessions inactive >2h (default: false)
+	CollapseInactive *bool  `json:"collapse_inactive,omitempty"` // Hide sessions inactive >2h (default: false)
+	Editor           string `json:"editor,omitempty"`            // Command for Cmd-clicked file:line links, e.g. "code -g {file}:{line}:{col}"
+	ShellIntegration bool   `json:"shell_integration,omitempty"` // Load OSC 133 prompt and OSC 7 directory hooks into new shells (default: false)
+	Timestamps       bool   `json:"timestamps,omitempty"`        // Show when each scrollback line arrived (default: false)
 }
 
 // Config holds application configuration
@@ -29,6 +66,8 @@ type Config struct {
 	Discord           DiscordConfig          `json:"discord"`
 	Claude            ClaudeSettings         `json:"claude,omitempty"`
 	UI                UISettings             `json:"ui,omitempty"`
+	Clipboard         ClipboardSettings      `json:"clipboard,omitempty"`
+	Scrollback        ScrollbackSettings     `json:"scrollback,omitempty"`
 	SessionColors     map[string]int         `json:"session_colors,omitempty"`
 	WindowSizes       map[string][2]int      `json:"window_sizes,omitempty"`
 	Sessions          map[string]SessionInfo `json:"sessions,omitempty"`
@@ -279,6 +318,75 @@ func (c *Config) SetCollapseInactive(enabled bool) {
 	c.UI.CollapseInactive = &enabled
 }
 
+// GetEditor returns the editor command for file links ("" = system default app)
+func (c *Config) GetEditor() string {
+	return c.UI.Editor
+}
+
+// GetShellIntegration returns whether new shells get the OSC 133 prompt hooks
+func (c *Config) GetShellIntegration() bool {
+	return c.UI.ShellIntegration
+}
+
+// GetShowTimestamps returns whether terminals show the time gutter
+func (c *Config) GetShowTimestamps() bool {
+	return c.UI.Timestamps
+}
+
+// SetShowTimestamps sets whether terminals show the time gutter
+func (c *Config) SetShowTimestamps(show bool) {
+	c.UI.Timestamps = show
+}
+
+// GetClipboardPolicy returns the OSC 52 policy for a session: its own
+// policy if set, else the global one (default: ask)
+func (c *Config) GetClipboardPolicy(name string) string {
+	if info, ok := c.GetSessionInfo(name); ok && info.Clipboard != "" {
+		return info.Clipboard
+	}
+	if c.Clipboard.Policy != "" {
+		return c.Clipboard.Policy
+	}
+	return ClipboardAsk
+}
+
+// SetSessionClipboardPolicy sets a session's OSC 52 policy
+func (c *Config) SetSessionClipboardPolicy(name, policy string) {
+	if info, ok := c.GetSessionInfo(name); ok {
+		info.Clipboard = policy
+		c.SetSessionInfo(name, info)
+	}
+}
+
+// GetClipboardMaxBytes returns the OSC 52 size cap
+func (c *Config) GetClipboardMaxBytes() int {
+	if c.Clipboard.MaxBytes <= 0 {
+		return DefaultClipboardMaxBytes
+	}
+	return c.Clipboard.MaxBytes
+}
+
+// GetScrollbackRetention returns a session's history limits: each one the
+// session sets, else the global one
+func (c *Config) GetScrollbackRetention(name string) ScrollbackSettings {
+	r := c.Scrollback
+	if info, ok := c.GetSessionInfo(name); ok && info.Scrollback != nil {
+		if info.Scrollback.MaxLines > 0 {
+			r.MaxLines = info.Scrollback.MaxLines
+		}
+		if info.Scrollback.MaxBytes > 0 {
+			r.MaxBytes = info.Scrollback.MaxBytes
+		}
+		if info.Scrollback.MaxAgeDays > 0 {
+			r.MaxAgeDays = info.Scrollback.MaxAgeDays
+		}
+	}
+	if r.MaxBytes <= 0 {
+		r.MaxBytes = DefaultScrollbackMaxBytes
+	}
+	return r
+}
+
 // LoadDefault loads configuration from the default path
 func LoadDefault() (*Config, error) {
 	return Load(DefaultConfigPath())
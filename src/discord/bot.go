@@ -464,6 +464,19 @@ func (b *Bot) registerCommands() {
 						},
 					},
 				},
+				{
+					Name:        "search",
+					Description: "Search every session's history",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "query",
+							Description: "Text to find, or /pattern/ for a regex",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+						},
+					},
+				},
 				{
 					Name:        "new",
 					Description: "Create a new terminal session",
@@ -563,6 +576,9 @@ func (b *Bot) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCr
 	case "close":
 		discordLog.Printf("Handling close command")
 		handler.HandleClose(subCmd.Options)
+	case "search":
+		discordLog.Printf("Handling search command")
+		handler.HandleSearch(subCmd.Options)
 	case "new":
 		discordLog.Printf("Handling new command")
 		handler.HandleNew(subCmd.Options)
@@ -1091,13 +1107,26 @@ func (b *Bot) sendWorking(channelID string) error {
 }
 
 // SendSessionInput sends message content to a session as literal key presses
-// with an Enter key at the end of each line.
+// with an Enter key at the end of each line. Multi-line content is sent as a
+// single bracketed paste followed by Enter when the app has enabled it.
 func (b *Bot) SendSessionInput(sessionName, content string) error {
 	lines := discordContentToInputLines(content)
 	if len(lines) == 0 {
 		return nil
 	}
 
+	// Multi-line messages go in as one bracketed paste when the app supports
+	// it, so shells and Claude see a single submission instead of one per line.
+	if len(lines) > 1 && b.app != nil {
+		if state := b.app.GetSession(sessionName); state != nil && state.BracketedPaste() {
+			if err := state.Paste([]byte(strings.Join(lines, "\n"))); err != nil {
+				return err
+			}
+			_, err := state.PTY().Write([]byte{'\r'})
+			return err
+		}
+	}
+
 	for _, line := range lines {
 		keyArgs := lineToKeyArgs(line)
 		if len(keyArgs) > 0 {
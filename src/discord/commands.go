@@ -8,6 +8,7 @@ import (
 
 	"github.com/bwmarrin/discordgo"
 
+	"prompt-grid/src/emulator"
 	"prompt-grid/src/gui"
 	"prompt-grid/src/render"
 )
@@ -336,15 +337,12 @@ func (h *CommandHandler) HandleFocus(options []*discordgo.ApplicationCommandInte
 		return
 	}
 
-	state := h.bot.App().GetSession(name)
-	if state == nil {
+	if err := h.bot.App().FocusSession(name); err != nil {
 		h.respond(fmt.Sprintf("Session '%s' not found.", name), true)
 		return
 	}
 
-	// Note: Bringing window to front requires platform-specific code
-	// For now, just acknowledge
-	h.respond(fmt.Sprintf("Requested focus for **%s** (platform-dependent).", name), false)
+	h.respond(fmt.Sprintf("Focused **%s**.", name), false)
 }
 
 // HandleClose handles the /term close command
@@ -393,3 +391,44 @@ func (h *CommandHandler) HandleNew(options []*discordgo.ApplicationCommandIntera
 		h.respond(fmt.Sprintf("Created session **%s**", name), false)
 	}
 }
+
+// maxSearchResults caps the hits listed by /term search to stay within
+// Discord's message size limit
+const maxSearchResults = 15
+
+// HandleSearch handles the /term search command
+func (h *CommandHandler) HandleSearch(options []*discordgo.ApplicationCommandInteractionDataOption) {
+	query := getOption(options, "query")
+	if query == "" {
+		h.respond("Search query is required.", true)
+		return
+	}
+
+	pattern, isRegex := gui.ParseSearchQuery(query)
+	hits, err := h.bot.App().SearchHistory(pattern, isRegex)
+	if err != nil {
+		h.respond(fmt.Sprintf("Invalid search: %v", err), true)
+		return
+	}
+	if len(hits) == 0 {
+		h.respond(fmt.Sprintf("No matches for `%s`.", query), true)
+		return
+	}
+
+	h.respond(formatSearchHits(query, hits), true)
+}
+
+// formatSearchHits renders the best hits as a Discord message
+func formatSearchHits(query string, hits []emulator.SessionHit) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**%d matches for** `%s`:\n", len(hits), strings.ReplaceAll(query, "`", "'")))
+	for i, hit := range hits {
+		if i == maxSearchResults {
+			sb.WriteString(fmt.Sprintf("…and %d more\n", len(hits)-i))
+			break
+		}
+		snippet := strings.ReplaceAll(hit.Snippet, "`", "'")
+		sb.WriteString(fmt.Sprintf("• **%s**:%d `%s`\n", hit.Session, hit.Line+1, snippet))
+	}
+	return sb.String()
+}
@@ -0,0 +1,27 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+
+	"prompt-grid/src/emulator"
+)
+
+func TestFormatSearchHits(t *testing.T) {
+	hits := make([]emulator.SessionHit, maxSearchResults+3)
+	for i := range hits {
+		hits[i] = emulator.SessionHit{Session: "build", Line: i, Snippet: "run `make`"}
+	}
+
+	got := formatSearchHits("make", hits)
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != maxSearchResults+2 { // Header, hits, "and N more"
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), maxSearchResults+2, got)
+	}
+	if lines[1] != "• **build**:1 `run 'make'`" {
+		t.Errorf("first hit = %q", lines[1])
+	}
+	if lines[len(lines)-1] != "…and 3 more" {
+		t.Errorf("last line = %q, want %q", lines[len(lines)-1], "…and 3 more")
+	}
+}
@@ -333,16 +333,11 @@ func (s *Streamer) captureSnapshot() []string {
 
 	lines := make([]string, rows)
 	for y := 0; y < rows; y++ {
-		row := make([]rune, cols)
+		var row strings.Builder
 		for x := 0; x < cols; x++ {
-			cell := screen.Cell(x, y)
-			if cell.Rune == 0 {
-				row[x] = ' '
-			} else {
-				row[x] = cell.Rune
-			}
+			row.WriteString(screen.Cell(x, y).Text())
 		}
-		lines[y] = strings.TrimRight(string(row), " ")
+		lines[y] = strings.TrimRight(row.String(), " ")
 	}
 	s.state.UnlockScreen()
 
@@ -0,0 +1,129 @@
+package emulator
+
+// savedCursor is the state DECSC saves and DECRC restores. Each screen
+// keeps its own, so a full-screen app saving the cursor doesn't clobber
+// the shell's.
+type savedCursor struct {
+	cursor     Cursor
+	attrs      Cell
+	originMode bool
+	charsets   [4]Charset
+	gl         int
+	ok         bool
+}
+
+// SaveCursor saves the cursor position, drawing attributes, origin mode
+// and charsets (DECSC)
+func (s *Screen) SaveCursor() {
+	s.saved = savedCursor{
+		cursor:     s.cursor,
+		attrs:      s.attrs,
+		originMode: s.originMode,
+		charsets:   s.charsets,
+		gl:         s.gl,
+		ok:         true,
+	}
+}
+
+// RestoreCursor restores the state saved by SaveCursor (DECRC). With
+// nothing saved the cursor goes home with default attributes, as in xterm.
+func (s *Screen) RestoreCursor() {
+	saved := s.saved
+	if !saved.ok {
+		saved = savedCursor{cursor: s.cursor, attrs: DefaultCell()}
+		saved.cursor.X, saved.cursor.Y = 0, 0
+	}
+	s.cursor.X = clamp(saved.cursor.X, 0, s.cols)
+	s.cursor.Y = clamp(saved.cursor.Y, 0, s.rows-1)
+	s.attrs = saved.attrs
+	s.originMode = saved.originMode
+	s.charsets = saved.charsets
+	s.gl = saved.gl
+	s.marginWrap = false
+}
+
+// adopt carries the state that belongs to the terminal rather than to a
+// buffer (cursor, drawing attributes, link, modes, margins, tab stops and
+// charsets) over from the screen being switched away from.
+func (s *Screen) adopt(from *Screen) {
+	s.cursor = from.cursor
+	s.cursor.X = clamp(s.cursor.X, 0, s.cols)
+	s.cursor.Y = clamp(s.cursor.Y, 0, s.rows-1)
+	s.attrs = from.attrs
+	s.link = from.link
+	s.scrollTop, s.scrollBot = from.scrollTop, from.scrollBot
+	s.originMode = from.originMode
+	s.autoWrap = from.autoWrap
+	s.insertMode = from.insertMode
+	s.lrMargins = from.lrMargins
+	s.marginLeft, s.marginRight = from.marginLeft, from.marginRight
+	s.marginWrap, s.marginWrapY = from.marginWrap, from.marginWrapY
+	s.tabStops = append([]bool(nil), from.tabStops...)
+	s.charsets, s.gl = from.charsets, from.gl
+	s.MarkAllDirty()
+}
+
+// AltScreen returns true while the alternate screen is active
+func (p *Parser) AltScreen() bool {
+	return p.altScreen
+}
+
+// useAltScreen switches to the alternate screen or back to the main one.
+// The alternate screen has no scrollback and is kept between uses unless
+// the mode asks for it to be cleared.
+func (p *Parser) useAltScreen(on bool) {
+	if on == p.altScreen {
+		return
+	}
+	next := p.main
+	if on {
+		cols, rows := p.main.Size()
+		if p.alt == nil {
+			p.alt = NewScreen(cols, rows)
+			p.alt.images = p.main.images
+			p.alt.links = p.main.links
+		} else if c, r := p.alt.Size(); c != cols || r != rows {
+			p.alt.Resize(cols, rows)
+		}
+		next = p.alt
+	}
+	next.adopt(p.screen)
+	p.screen = next
+	p.altScreen = on
+	// A full-screen program may have replaced the shell or still be its
+	// child; the shell's next mark says where it is
+	p.shellState = ShellUnknown
+}
+
+// altScreenMode handles the DEC private modes that switch screens:
+// 47 switches, 1047 also clears the alternate screen when leaving it,
+// 1048 saves/restores the cursor, and 1049 does both around a switch to a
+// cleared alternate screen.
+func (p *Parser) altScreenMode(mode int, set bool) {
+	switch mode {
+	case 47:
+		p.useAltScreen(set)
+	case 1047:
+		if !set && p.altScreen {
+			p.screen.Clear()
+		}
+		p.useAltScreen(set)
+	case 1048:
+		if set {
+			p.screen.SaveCursor()
+		} else {
+			p.screen.RestoreCursor()
+		}
+	case 1049:
+		if set && !p.altScreen {
+			p.main.SaveCursor()
+			p.useAltScreen(true)
+			cursor := p.screen.cursor
+			p.screen.Clear()
+			p.screen.cursor = cursor
+		} else if !set && p.altScreen {
+			p.useAltScreen(false)
+			p.screen.RestoreCursor()
+		}
+	}
+}
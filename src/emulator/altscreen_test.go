@@ -0,0 +1,134 @@
+package emulator
+
+import "testing"
+
+// TestAltScreenRestoresMain verifies quitting a full-screen app (1049)
+// brings back the shell's screen and cursor, and none of the app's lines
+// reach the scrollback.
+func TestAltScreenRestoresMain(t *testing.T) {
+	p := NewParser(NewScreen(20, 4), NewScrollback())
+	p.Parse([]byte("$ less file\r\n"))
+	p.Parse([]byte("\x1b[?1049h"))
+	if !p.AltScreen() || rowText(p.Screen(), 0) != "" {
+		t.Fatalf("alternate screen not entered blank: %q", rowText(p.Screen(), 0))
+	}
+	for i := 0; i < 10; i++ {
+		p.Parse([]byte("page line\r\n"))
+	}
+	p.Parse([]byte("\x1b[S\x1b[1;1Hmoved"))
+	p.Parse([]byte("\x1b[?1049l"))
+
+	if p.AltScreen() || rowText(p.Screen(), 0) != "$ less file" {
+		t.Errorf("main screen row 0 = %q after leaving", rowText(p.Screen(), 0))
+	}
+	if c := p.Screen().Cursor(); c.X != 0 || c.Y != 1 {
+		t.Errorf("cursor = (%d,%d), want (0,1)", c.X, c.Y)
+	}
+	if n := p.Scrollback().Count(); n != 0 {
+		t.Errorf("alternate screen leaked %d lines into the scrollback", n)
+	}
+}
+
+func TestAltScreenModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		enter string
+		leave string
+		want  string // Row 0 of the alternate screen when re-entered
+	}{
+		{"47 keeps the alternate screen", "\x1b[?47h", "\x1b[?47l", "app"},
+		{"1047 clears it on leaving", "\x1b[?1047h", "\x1b[?1047l", ""},
+		{"1049 clears it on entering", "\x1b[?1049h", "\x1b[?1049l", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(NewScreen(20, 4), NewScrollback())
+			p.Parse([]byte("shell"))
+			p.Parse([]byte(tt.enter + "\x1b[H\x1b[2Japp" + tt.leave))
+			if got := rowText(p.Screen(), 0); got != "shell" {
+				t.Errorf("main row 0 = %q, want shell", got)
+			}
+			p.Parse([]byte(tt.enter))
+			if got := rowText(p.Screen(), 0); got != tt.want {
+				t.Errorf("alternate row 0 = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSavedCursorPerScreen verifies DECSC/DECRC and CSI s/u keep separate
+// state on each screen, and 1048 saves like DECSC.
+func TestSavedCursorPerScreen(t *testing.T) {
+	p := NewParser(NewScreen(20, 10), NewScrollback())
+	cursorAt := func(x, y int, after string) {
+		t.Helper()
+		if c := p.Screen().Cursor(); c.X != x || c.Y != y {
+			t.Errorf("after %s: cursor = (%d,%d), want (%d,%d)", after, c.X, c.Y, x, y)
+		}
+	}
+
+	p.Parse([]byte("\x1b[2;3H\x1b[1m\x1b7\x1b[m\x1b[5;5H\x1b8"))
+	cursorAt(2, 1, "DECRC")
+	if p.Screen().Attrs().Attrs&AttrBold == 0 {
+		t.Error("DECRC didn't restore bold")
+	}
+
+	p.Parse([]byte("\x1b[?47h\x1b[6;6H\x1b[s\x1b[H\x1b[u"))
+	cursorAt(5, 5, "CSI u on the alternate screen")
+	p.Parse([]byte("\x1b[?47l\x1b[H\x1b8"))
+	cursorAt(2, 1, "DECRC back on the main screen")
+
+	p.Parse([]byte("\x1b[4;8H\x1b[?1048h\x1b[H\x1b[?1048l"))
+	cursorAt(7, 3, "1048 restore")
+
+	// Nothing saved: DECRC homes the cursor
+	p = NewParser(NewScreen(20, 10), NewScrollback())
+	p.Parse([]byte("\x1b[3;3H\x1b8"))
+	cursorAt(0, 0, "DECRC with nothing saved")
+}
+
+// TestAltScreenResize verifies the main screen is resized (and re-wrapped)
+// while a full-screen app runs, and the shell's cursor follows its line.
+func TestAltScreenResize(t *testing.T) {
+	p := NewParser(NewScreen(10, 4), NewScrollback())
+	p.Parse([]byte("0123456789abc\r\n$ "))
+	p.Parse([]byte("\x1b[?1049h"))
+	p.Resize(5, 6)
+	if c, r := p.Screen().Size(); c != 5 || r != 6 {
+		t.Fatalf("alternate screen size = %dx%d", c, r)
+	}
+	p.Parse([]byte("\x1b[?1049l"))
+	want := []string{"01234", "56789", "abc", "$"}
+	for y, w := range want {
+		if got := rowText(p.Screen(), y); got != w {
+			t.Errorf("row %d = %q, want %q", y, got, w)
+		}
+	}
+	if c := p.Screen().Cursor(); c.X != 2 || c.Y != 3 {
+		t.Errorf("cursor = (%d,%d), want (2,3)", c.X, c.Y)
+	}
+}
+
+func TestParserTitleStack(t *testing.T) {
+	p := newTestParser()
+	var titles []string
+	p.SetOnTitle(func(title string) { titles = append(titles, title) })
+	p.Parse([]byte("\x1b]2;shell\x07\x1b[22;0t\x1b]2;vim\x07\x1b[23;0t"))
+	if p.Title() != "shell" || len(titles) != 3 {
+		t.Errorf("title = %q, callbacks %q; want shell restored", p.Title(), titles)
+	}
+
+	// Popping an empty stack changes nothing
+	p.Parse([]byte("\x1b[23t"))
+	if p.Title() != "shell" {
+		t.Errorf("title = %q after popping an empty stack", p.Title())
+	}
+
+	// The stack is capped: the oldest titles are dropped
+	for i := 0; i < maxTitleStack+5; i++ {
+		p.Parse([]byte("\x1b[22t"))
+	}
+	if len(p.titleStack) != maxTitleStack {
+		t.Errorf("title stack holds %d, want %d", len(p.titleStack), maxTitleStack)
+	}
+}
@@ -0,0 +1,357 @@
+package emulator
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// History that rolls out of the live segment is sealed into archives in
+// <name>.archive/: the segment data of the rolled-out lines, gzip
+// compressed, one file per roll-over. Files are named <sequence>-<lines>.gz
+// so the line counts are known without opening them. Archives are only
+// deleted by the retention policy.
+
+// Retention limits the history a disk-backed scrollback keeps, archives
+// included. Zero fields are unlimited. Whole archives are deleted, oldest
+// first, while a limit is exceeded; if the live segment alone grows well
+// past the line or byte limit its oldest lines are dropped too. The age of
+// an archive is the time it was sealed; the live segment is never too old.
+type Retention struct {
+	MaxLines int
+	MaxBytes int64
+	MaxAge   time.Duration
+}
+
+const archiveExt = ".gz"
+
+// archiveFile is one sealed archive on disk
+type archiveFile struct {
+	path  string
+	seq   int
+	lines int
+	size  int64
+	mod   time.Time
+}
+
+// archive is an archive decompressed for reading: its segment data and
+// the offset of each record
+type archive struct {
+	seq  int
+	data []byte
+	offs []int
+}
+
+// archiveDir returns the directory holding a scrollback's archives
+func archiveDir(path string) string {
+	return strings.TrimSuffix(path, scrollbackExt) + ".archive"
+}
+
+// listArchives returns the archives in dir, oldest first
+func listArchives(dir string) []archiveFile {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []archiveFile
+	for _, e := range entries {
+		var a archiveFile
+		if n, _ := fmt.Sscanf(e.Name(), "%d-%d"+archiveExt, &a.seq, &a.lines); n != 2 || e.Name() != archiveName(a.seq, a.lines) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		a.path, a.size, a.mod = filepath.Join(dir, e.Name()), info.Size(), info.ModTime()
+		files = append(files, a)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].seq < files[j].seq })
+	return files
+}
+
+func archiveName(seq, lines int) string {
+	return fmt.Sprintf("%08d-%d%s", seq, lines, archiveExt)
+}
+
+// writeArchive seals the first n lines of g into archive seq in dir. The
+// archive is written under a temporary name and renamed into place, so a
+// crash leaves either no archive or a whole one.
+func writeArchive(dir string, seq int, g *segment, n int) (archiveFile, error) {
+	if err := g.flush(); err != nil {
+		return archiveFile{}, err
+	}
+	offs, err := g.offsets(n, n)
+	if err != nil {
+		return archiveFile{}, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return archiveFile{}, err
+	}
+	a := archiveFile{path: filepath.Join(dir, archiveName(seq, n)), seq: seq, lines: n}
+	tmp := a.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return archiveFile{}, err
+	}
+	zw, _ := gzip.NewWriterLevel(f, gzip.BestSpeed)
+	_, err = io.Copy(zw, io.NewSectionReader(g.data, 0, offs[0]))
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, a.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return archiveFile{}, err
+	}
+	if info, err := os.Stat(a.path); err == nil {
+		a.size, a.mod = info.Size(), info.ModTime()
+	}
+	return a, nil
+}
+
+// loadArchive decompresses an archive and indexes its records
+func loadArchive(a archiveFile) (*archive, error) {
+	f, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	if !checkHeader(data) {
+		return nil, errBadRecord
+	}
+	arch := &archive{seq: a.seq, data: data, offs: make([]int, 0, a.lines)}
+	for off := segmentHeader; off < len(data); {
+		length, k := binary.Uvarint(data[off:])
+		if k <= 0 || uint64(len(data)-off-k) < length {
+			break
+		}
+		arch.offs = append(arch.offs, off)
+		off += k + int(length)
+	}
+	return arch, nil
+}
+
+// line returns line i of the archive and its arrival time, or nil if the
+// archive has fewer lines. Its links are interned in table.
+func (a *archive) line(i int, table *LinkTable) ([]Cell, int64) {
+	if i < 0 || i >= len(a.offs) {
+		return nil, 0
+	}
+	length, k := binary.Uvarint(a.data[a.offs[i]:])
+	start := a.offs[i] + k
+	line, at, _ := decodeRecord(a.data[start:start+int(length)], table)
+	return line, at
+}
+
+// historyFile is an archive or live segment opened for a scan. Open files
+// stay readable when a roll-over or the retention policy renames or
+// deletes them, so a scan reads the history as it was when it began.
+type historyFile struct {
+	f     *os.File // nil if it couldn't be opened
+	lines int      // Lines to read from it; -1 for all it has
+	gz    bool     // An archive
+}
+
+// openHistory opens a scrollback's archives and its live segment
+func openHistory(path string) []historyFile {
+	var files []historyFile
+	for _, a := range listArchives(archiveDir(path)) {
+		f, _ := os.Open(a.path)
+		files = append(files, historyFile{f: f, lines: a.lines, gz: true})
+	}
+	f, _ := os.Open(path)
+	return append(files, historyFile{f: f, lines: -1})
+}
+
+// scanHistory streams a scrollback's archived lines and then its live
+// segment, oldest first, until fn returns false. Their links are interned
+// in table; nil leaves them out for a reader after the text alone.
+func scanHistory(path string, table *LinkTable, fn func(i int, line []Cell) bool) {
+	scanHistoryFiles(openHistory(path), table, fn)
+}
+
+// scanHistoryFiles streams files, oldest first, numbering their lines on
+// from one to the next, until fn returns false, and closes them. A file
+// that can't be read still counts the lines it holds, so the lines after
+// it keep their indices. Links are interned in table.
+func scanHistoryFiles(files []historyFile, table *LinkTable, fn func(i int, line []Cell) bool) {
+	defer func() {
+		for _, h := range files {
+			if h.f != nil {
+				h.f.Close()
+			}
+		}
+	}()
+	base := 0
+	for _, h := range files {
+		stopped := false
+		if h.f != nil {
+			var r io.Reader = h.f
+			if h.gz {
+				zr, err := gzip.NewReader(bufio.NewReader(h.f))
+				if err != nil {
+					r = nil
+				} else {
+					r = zr
+				}
+			}
+			if r != nil {
+				scanScrollback(r, table, func(i int, line []Cell) bool {
+					if h.lines >= 0 && i >= h.lines {
+						return false
+					}
+					stopped = !fn(base+i, line)
+					return !stopped
+				})
+			}
+		}
+		if stopped || h.lines < 0 {
+			return
+		}
+		base += h.lines
+	}
+}
+
+// archiveLocked rolls the oldest lines of the live segment into a new
+// archive, keeping about diskTrimTo bytes and at least the ring live.
+// Line indices don't change. Caller must hold s.mu.
+func (s *Scrollback) archiveLocked() {
+	if s.seg.flush() != nil {
+		return
+	}
+	n := min(s.seg.lineAt(s.seg.size-diskTrimTo), s.seg.lines-s.ringFill)
+	if n <= 0 {
+		return
+	}
+	seq := 1
+	if len(s.archives) > 0 {
+		seq = s.archives[len(s.archives)-1].seq + 1
+	}
+	a, err := writeArchive(archiveDir(s.path), seq, s.seg, n)
+	if err != nil {
+		return
+	}
+	if err := s.seg.dropFront(n); err != nil {
+		// The lines are in the archive and the live segment both; drop
+		// the archive rather than show them twice
+		os.Remove(a.path)
+		return
+	}
+	s.archives = append(s.archives, a)
+	s.archived += n
+	s.archBytes += a.size
+}
+
+// retentionDueLocked reports whether the retention policy has a limit
+// crossed, so Push doesn't walk the archives on every line. The age limit
+// is checked at most every ageCheckGap. Caller must hold s.mu.
+func (s *Scrollback) retentionDueLocked() bool {
+	r := s.retention
+	if s.frozen || r == (Retention{}) {
+		return false
+	}
+	archived := len(s.archives) > 0
+	if r.MaxLines > 0 && (archived && s.archived+s.seg.lines > r.MaxLines || s.seg.lines > r.MaxLines+r.MaxLines/4) {
+		return true
+	}
+	if r.MaxBytes > 0 && (archived && s.archBytes+s.seg.size > r.MaxBytes || s.seg.size > r.MaxBytes+r.MaxBytes/4) {
+		return true
+	}
+	if r.MaxAge > 0 && archived && s.now().Sub(s.ageCheck) >= ageCheckGap {
+		s.ageCheck = s.now()
+		return true
+	}
+	return false
+}
+
+// applyRetentionLocked deletes the history the retention policy no longer
+// allows. Skipped when frozen, since it shifts line indices under the
+// user's view. Caller must hold s.mu.
+func (s *Scrollback) applyRetentionLocked() {
+	r := s.retention
+	if s.frozen || s.seg == nil || r == (Retention{}) {
+		return
+	}
+	dropped := 0
+	for len(s.archives) > 0 {
+		a := s.archives[0]
+		lines := s.archived + s.seg.lines
+		overLines := r.MaxLines > 0 && lines > r.MaxLines
+		overBytes := r.MaxBytes > 0 && s.archBytes+s.seg.size > r.MaxBytes
+		tooOld := r.MaxAge > 0 && time.Since(a.mod) > r.MaxAge
+		if !overLines && !overBytes && !tooOld {
+			break
+		}
+		os.Remove(a.path)
+		s.archives = s.archives[1:]
+		s.archived -= a.lines
+		s.archBytes -= a.size
+		dropped += a.lines
+		if s.arch != nil && s.arch.seq == a.seq {
+			s.arch = nil
+		}
+	}
+
+	// The live segment alone may be over the line or byte limit. Dropping
+	// its front rewrites the file, so wait until it is a quarter over and
+	// then cut back to the limit, rather than rewriting on every line.
+	n := 0
+	if r.MaxLines > 0 && s.seg.lines > r.MaxLines+r.MaxLines/4 {
+		n = s.seg.lines - r.MaxLines
+	}
+	if r.MaxBytes > 0 && s.seg.size > r.MaxBytes+r.MaxBytes/4 {
+		n = max(n, s.seg.lineAt(s.seg.size-r.MaxBytes))
+	}
+	n = min(n, s.seg.lines-s.ringFill)
+	if n > 0 && s.seg.dropFront(n) == nil {
+		dropped += n
+	}
+
+	if dropped > 0 {
+		s.total -= dropped
+		s.dropped += dropped
+		s.cache = nil
+	}
+}
+
+// archiveLineLocked returns archived line i and its arrival time,
+// decompressing its archive if it isn't the one already loaded. Caller
+// must hold s.mu.
+func (s *Scrollback) archiveLineLocked(i int) ([]Cell, int64) {
+	base := 0
+	for _, a := range s.archives {
+		if i < base+a.lines {
+			if s.arch == nil || s.arch.seq != a.seq {
+				arch, err := loadArchive(a)
+				if err != nil {
+					return nil, 0
+				}
+				s.arch = arch
+			}
+			return s.arch.line(i-base, s.links)
+		}
+		base += a.lines
+	}
+	return nil, 0
+}
@@ -0,0 +1,277 @@
+package emulator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// longLine returns a line of about 1KB starting with its number, so a
+// few thousand of them roll the live segment over
+func longLine(i int) []Cell {
+	return textLine(fmt.Sprintf("line %d %s", i, strings.Repeat("x", 1000)))
+}
+
+// lineNumber returns the number longLine put at the start of a line
+func lineNumber(line []Cell) int {
+	n := -1
+	fmt.Sscanf(rowString(line), "line %d", &n)
+	return n
+}
+
+// pushArchived fills a disk-backed scrollback until it has rolled over
+// into at least two archives, returning it and the lines pushed
+func pushArchived(t *testing.T, path string) (*Scrollback, int) {
+	t.Helper()
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	n := 0
+	for len(sb.archives) < 2 {
+		sb.Push(longLine(n))
+		n++
+	}
+	return sb, n
+}
+
+func TestScrollbackArchiveRollOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s"+scrollbackExt)
+	sb, n := pushArchived(t, path)
+
+	if sb.Count() != n {
+		t.Fatalf("Count = %d, want %d: rolling over must not lose lines", sb.Count(), n)
+	}
+	if sb.seg.size >= diskMaxBytes {
+		t.Errorf("live segment is %d bytes after rolling over", sb.seg.size)
+	}
+	files := listArchives(archiveDir(path))
+	if len(files) != 2 || files[0].lines+files[1].lines != sb.archived {
+		t.Fatalf("archives = %+v, want two holding %d lines", files, sb.archived)
+	}
+	for _, f := range files {
+		if f.size >= int64(f.lines)*1000/10 {
+			t.Errorf("archive %s is %d bytes for %d lines; want it compressed", f.path, f.size, f.lines)
+		}
+	}
+
+	// Line reads across both archives and the live segment, in any order
+	for _, i := range []int{0, n - 1, files[0].lines, files[0].lines - 1, sb.archived, sb.archived - 1, n / 2, 1} {
+		if got := lineNumber(sb.Line(i)); got != i {
+			t.Errorf("Line(%d) = line %d", i, got)
+		}
+	}
+	lines := sb.Lines(files[0].lines-5, sb.archived+5)
+	for k, line := range lines {
+		if want := files[0].lines - 5 + k; lineNumber(line) != want {
+			t.Fatalf("Lines[%d] = line %d, want %d", k, lineNumber(line), want)
+		}
+	}
+
+	next := 0
+	sb.ScanLines(n, func(i int, line []Cell) bool {
+		if i != next || lineNumber(line) != i {
+			t.Fatalf("ScanLines gave line %d (%d) at %d", i, lineNumber(line), next)
+		}
+		next++
+		return true
+	})
+	if next != n {
+		t.Errorf("ScanLines stopped at %d, want %d", next, n)
+	}
+
+	// Search reaches archived lines
+	s, err := NewSearcher("line 3 ", false)
+	if err != nil {
+		t.Fatalf("NewSearcher: %v", err)
+	}
+	hits := s.searchFile(path, "s", time.Now(), 0)
+	if len(hits) != 1 || hits[0].Line != 3 {
+		t.Errorf("searchFile hits = %+v, want line 3", hits)
+	}
+
+	// Reopening finds the archives again
+	sb.Close()
+	sb, err = NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer sb.Close()
+	if sb.Count() != n {
+		t.Fatalf("reopened Count = %d, want %d", sb.Count(), n)
+	}
+	for _, i := range []int{0, files[1].lines, n - 1} {
+		if got := lineNumber(sb.Line(i)); got != i {
+			t.Errorf("reopened Line(%d) = line %d", i, got)
+		}
+	}
+}
+
+func TestScrollbackRetention(t *testing.T) {
+	tests := []struct {
+		name string
+		// retention builds the policy from the first archive's line count
+		// and the lines pushed
+		retention func(first, n int) Retention
+		setup     func(files []archiveFile)
+		// dropped is how many archives the policy deletes
+		dropped int
+	}{
+		{
+			name:      "unlimited",
+			retention: func(first, n int) Retention { return Retention{} },
+		},
+		{
+			name: "lines",
+			retention: func(first, n int) Retention {
+				return Retention{MaxLines: n - first}
+			},
+			dropped: 1,
+		},
+		{
+			name:      "bytes",
+			retention: func(first, n int) Retention { return Retention{MaxBytes: 1} },
+			dropped:   2,
+		},
+		{
+			name:      "age",
+			retention: func(first, n int) Retention { return Retention{MaxAge: time.Hour} },
+			setup: func(files []archiveFile) {
+				old := time.Now().Add(-2 * time.Hour)
+				os.Chtimes(files[0].path, old, old)
+			},
+			dropped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "s"+scrollbackExt)
+			sb, n := pushArchived(t, path)
+			files := listArchives(archiveDir(path))
+			if tt.setup != nil {
+				tt.setup(files)
+				sb.Close()
+				sb, _ = NewScrollbackWithPath(path)
+			}
+			defer sb.Close()
+
+			sb.SetRetention(tt.retention(files[0].lines, n))
+			left := listArchives(archiveDir(path))
+			if len(left) != len(files)-tt.dropped {
+				t.Fatalf("%d archives left, want %d", len(left), len(files)-tt.dropped)
+			}
+			first := 0
+			for _, f := range files[:tt.dropped] {
+				first += f.lines
+			}
+			if tt.dropped < len(files) && sb.Count() != n-first {
+				t.Errorf("Count = %d, want %d", sb.Count(), n-first)
+			}
+			// Indices shift down with the dropped lines
+			if got := lineNumber(sb.Line(0)); got < first {
+				t.Errorf("Line(0) = line %d, want at least %d", got, first)
+			}
+			if got := lineNumber(sb.Line(sb.Count() - 1)); got != n-1 {
+				t.Errorf("last line = line %d, want %d", got, n-1)
+			}
+		})
+	}
+}
+
+func TestScrollbackRetentionLiveSegment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s"+scrollbackExt)
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	defer sb.Close()
+	sb.SetRetention(Retention{MaxLines: 400})
+
+	for i := 0; i < 1000; i++ {
+		sb.Push(textLine(fmt.Sprintf("line %d", i)))
+	}
+	// Trimmed back to the limit once a quarter over it
+	if c := sb.Count(); c < 400 || c > 500 {
+		t.Errorf("Count = %d, want between 400 and 500", c)
+	}
+	if got := lineNumber(sb.Line(sb.Count() - 1)); got != 999 {
+		t.Errorf("last line = line %d, want 999", got)
+	}
+
+	// Frozen, nothing is dropped
+	sb.SetFrozen(true)
+	for i := 1000; i < 1200; i++ {
+		sb.Push(textLine(fmt.Sprintf("line %d", i)))
+	}
+	frozen := sb.Count()
+	if got := lineNumber(sb.Line(0)); got != 1200-frozen {
+		t.Errorf("frozen Line(0) = line %d, want %d", got, 1200-frozen)
+	}
+	if frozen <= 500 {
+		t.Errorf("frozen Count = %d, want lines kept while frozen", frozen)
+	}
+}
+
+func TestScrollbackScanDuringRollOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s"+scrollbackExt)
+	sb, n := pushArchived(t, path)
+	defer sb.Close()
+	first := sb.archives[0].lines
+
+	// Roll another archive over and drop the first while the scan runs
+	want := 0
+	shift := sb.ScanLines(n, func(i int, line []Cell) bool {
+		if i == 0 {
+			for m := n; len(sb.archives) < 3; m++ {
+				sb.Push(longLine(m))
+			}
+			sb.SetRetention(Retention{MaxLines: sb.Count() - first})
+		}
+		if i != want || lineNumber(line) != i {
+			t.Fatalf("line %d is line %d, want %d", i, lineNumber(line), want)
+		}
+		want++
+		return true
+	})
+	if want != n {
+		t.Errorf("scanned %d lines, want %d", want, n)
+	}
+	if shift != first {
+		t.Errorf("shift = %d, want %d", shift, first)
+	}
+}
+
+func TestScrollbackRetentionDue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s"+scrollbackExt)
+	sb, n := pushArchived(t, path)
+	defer sb.Close()
+	now := time.Now()
+	sb.now = func() time.Time { return now }
+
+	tests := []struct {
+		name      string
+		retention Retention
+		after     time.Duration
+		want      bool
+	}{
+		{"unlimited", Retention{}, 0, false},
+		{"under the line limit", Retention{MaxLines: n}, 0, false},
+		{"over the line limit", Retention{MaxLines: n - 1}, 0, true},
+		{"under the byte limit", Retention{MaxBytes: 1 << 40}, 0, false},
+		{"over the byte limit", Retention{MaxBytes: 1}, 0, true},
+		{"age, first check", Retention{MaxAge: time.Hour}, 0, true},
+		{"age, checked just now", Retention{MaxAge: time.Hour}, time.Second, false},
+		{"age, checked a while ago", Retention{MaxAge: time.Hour}, ageCheckGap, true},
+	}
+	for _, tt := range tests {
+		now = now.Add(tt.after)
+		sb.retention = tt.retention
+		if got := sb.retentionDueLocked(); got != tt.want {
+			t.Errorf("%s: due = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
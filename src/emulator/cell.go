@@ -16,6 +16,63 @@ const (
 	AttrStrikethrough
 )
 
+// UnderlineStyle is the shape of an underline, set with SGR 4:n
+type UnderlineStyle uint8
+
+const (
+	UnderlineSingle UnderlineStyle = iota
+	UnderlineDouble
+	UnderlineCurly
+	UnderlineDotted
+	UnderlineDashed
+)
+
+// Decoration packs the attributes AttrFlags has no room for into one
+// word: the underline's style and color, and overline. The color sits in
+// the low 26 bits as packColor lays it out.
+type Decoration uint32
+
+const (
+	decorationColorMask  = 1<<26 - 1
+	decorationStyleShift = 26
+	decorationStyleMask  = 7 << decorationStyleShift
+	decorationOverline   = 1 << 29
+)
+
+// UnderlineStyle returns the shape drawn when AttrUnderline is set
+func (d Decoration) UnderlineStyle() UnderlineStyle {
+	return UnderlineStyle(d & decorationStyleMask >> decorationStyleShift)
+}
+
+// WithUnderlineStyle returns d with the underline shape set to s
+func (d Decoration) WithUnderlineStyle(s UnderlineStyle) Decoration {
+	return d&^decorationStyleMask | Decoration(s)<<decorationStyleShift&decorationStyleMask
+}
+
+// UnderlineColor returns the underline color (SGR 58). ColorDefault means
+// the underline takes the text color.
+func (d Decoration) UnderlineColor() Color {
+	return unpackColor(int64(d & decorationColorMask))
+}
+
+// WithUnderlineColor returns d with the underline color set to c
+func (d Decoration) WithUnderlineColor(c Color) Decoration {
+	return d&^decorationColorMask | Decoration(packColor(c))
+}
+
+// Overline reports whether a line is drawn above the text (SGR 53)
+func (d Decoration) Overline() bool {
+	return d&decorationOverline != 0
+}
+
+// WithOverline returns d with overline turned on or off
+func (d Decoration) WithOverline(on bool) Decoration {
+	if on {
+		return d | decorationOverline
+	}
+	return d &^ decorationOverline
+}
+
 // ColorType indicates how a color should be interpreted
 type ColorType uint8
 
@@ -62,12 +119,60 @@ func (c Color) ToNRGBA(defaultColor color.NRGBA) color.NRGBA {
 	}
 }
 
-// Cell represents a single character cell in the terminal
+// CellWidth describes how a cell participates in a double-width glyph
+type CellWidth uint8
+
+const (
+	WidthNormal       CellWidth = iota // Single-column glyph
+	WidthWide                          // Left half of a double-width glyph
+	WidthContinuation                  // Right half of a double-width glyph (no glyph of its own)
+)
+
+// Cell represents a single character cell in the terminal. Every screen
+// and scrollback row is a slice of these, so its size is what a row costs:
+// 48 bytes, of which the glyph, colors and flags take the first 16 and the
+// rarely set fields below them the rest. TestCellLayout pins it.
 type Cell struct {
-	Rune  rune
-	FG    Color
-	BG    Color
-	Attrs AttrFlags
+	Rune       rune
+	FG         Color
+	BG         Color
+	Attrs      AttrFlags
+	Width      CellWidth
+	Combining  string     // Zero-width runes (combining marks, ZWJ sequences) attached to Rune
+	Wrapped    bool       // Set on a row's last cell when the text soft-wraps onto the next row
+	Mark       Mark       // Shell integration marks, kept on a row's first cell
+	Decoration Decoration // Underline style and color and overline
+	Link       LinkID     // OSC 8 hyperlink target (see links.go); 0 when the cell isn't linked
+}
+
+// LineWrapped reports whether a row of cells soft-wraps onto the next row
+func LineWrapped(line []Cell) bool {
+	return len(line) > 0 && line[len(line)-1].Wrapped
+}
+
+// IsWide reports whether the cell holds the left half of a double-width glyph
+func (c Cell) IsWide() bool {
+	return c.Width == WidthWide
+}
+
+// IsContinuation reports whether the cell is the right half of a double-width glyph
+func (c Cell) IsContinuation() bool {
+	return c.Width == WidthContinuation
+}
+
+// Text returns the grapheme drawn in this cell. Continuation cells
+// return an empty string; empty cells and image tiles return a space.
+func (c Cell) Text() string {
+	if c.Width == WidthContinuation {
+		return ""
+	}
+	if c.Rune == 0 || c.Rune == ImageRune {
+		return " "
+	}
+	if c.Combining == "" {
+		return string(c.Rune)
+	}
+	return string(c.Rune) + c.Combining
 }
 
 // DefaultCell returns an empty cell with default attributes
@@ -3,6 +3,7 @@ package emulator
 import (
 	"image/color"
 	"testing"
+	"unsafe"
 )
 
 func TestDefaultCell(t *testing.T) {
@@ -133,3 +134,38 @@ func TestAttrFlags(t *testing.T) {
 		t.Error("AttrItalic should still be set")
 	}
 }
+
+// TestDecoration verifies the packed fields don't disturb each other.
+func TestDecoration(t *testing.T) {
+	var d Decoration
+	d = d.WithUnderlineStyle(UnderlineCurly).WithUnderlineColor(RGBColor(1, 2, 3)).WithOverline(true)
+	if d.UnderlineStyle() != UnderlineCurly || d.UnderlineColor() != RGBColor(1, 2, 3) || !d.Overline() {
+		t.Errorf("decoration = %#x", uint32(d))
+	}
+	d = d.WithUnderlineColor(IndexedColor(200)).WithOverline(false)
+	if d.UnderlineStyle() != UnderlineCurly || d.UnderlineColor() != IndexedColor(200) || d.Overline() {
+		t.Errorf("after changes = %#x", uint32(d))
+	}
+	if got := (Decoration(0)).UnderlineColor(); got != DefaultFG {
+		t.Errorf("zero UnderlineColor = %v, want default", got)
+	}
+}
+
+// TestCellLayout pins Cell's size, which every row of the screen and the
+// in-memory scrollback pays per column. A new field must fit the padding
+// or justify growing every row.
+func TestCellLayout(t *testing.T) {
+	var c Cell
+	if size := unsafe.Sizeof(c); size != 48 {
+		t.Errorf("Cell is %d bytes, want 48", size)
+	}
+	// The glyph, colors and flags share the first 16 bytes, as they did
+	// before the rarely set fields were added after them
+	if off := unsafe.Offsetof(c.Combining); off != 16 {
+		t.Errorf("Combining at offset %d, want 16", off)
+	}
+	// Wrapped, Mark, Decoration and Link fill the last 16 bytes with 4 to spare
+	if end := unsafe.Offsetof(c.Link) + unsafe.Sizeof(c.Link); end != 44 {
+		t.Errorf("Link ends at %d, want 44", end)
+	}
+}
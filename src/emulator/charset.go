@@ -0,0 +1,51 @@
+package emulator
+
+// Charset is a character set designated into G0-G3 by SCS (ESC ( F and
+// friends), named by its final byte.
+type Charset byte
+
+const (
+	CharsetASCII      Charset = 'B' // US ASCII (the default)
+	CharsetUK         Charset = 'A' // United Kingdom: '#' is '£'
+	CharsetDECSpecial Charset = '0' // DEC Special Graphics: line drawing
+)
+
+// decSpecialGraphics maps 0x5f-0x7e to the DEC Special Graphics glyphs
+// curses uses for boxes and borders.
+var decSpecialGraphics = [...]rune{
+	' ',                                    // _ blank
+	'◆', '▒', '␉', '␌', '␍', '␊', '°', '±', // ` a b c d e f g
+	'␤', '␋', '┘', '┐', '┌', '└', '┼', '⎺', // h i j k l m n o
+	'⎻', '─', '⎼', '⎽', '├', '┤', '┴', '┬', // p q r s t u v w
+	'│', '≤', '≥', 'π', '≠', '£', '·', // x y z { | } ~
+}
+
+// DesignateCharset designates charset c into G0-G3 (g = 0-3)
+func (s *Screen) DesignateCharset(g int, c Charset) {
+	if g >= 0 && g < len(s.charsets) {
+		s.charsets[g] = c
+	}
+}
+
+// InvokeCharset invokes G0-G3 into GL, the set printable ASCII is drawn
+// from (SI, SO, LS2 and LS3)
+func (s *Screen) InvokeCharset(g int) {
+	if g >= 0 && g < len(s.charsets) {
+		s.gl = g
+	}
+}
+
+// translate maps a printable ASCII rune through the charset in GL
+func (s *Screen) translate(r rune) rune {
+	switch s.charsets[s.gl] {
+	case CharsetDECSpecial:
+		if r >= '_' && r <= '~' {
+			return decSpecialGraphics[r-'_']
+		}
+	case CharsetUK:
+		if r == '#' {
+			return '£'
+		}
+	}
+	return r
+}
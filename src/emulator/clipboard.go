@@ -0,0 +1,50 @@
+package emulator
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// ClipboardRequest is an OSC 52 request from the application to copy text
+// to the clipboard or to read it back.
+type ClipboardRequest struct {
+	Selection string // Selection targets as sent (e.g. "c", "p", "s0"); empty means the default
+	Text      string // Decoded text to copy; empty for a query
+	Query     bool   // The application asked to read the clipboard
+}
+
+// SetOnClipboard sets the handler for OSC 52 clipboard requests. The
+// handler decides whether to honour them; queries are answered by writing
+// ClipboardReply back to the application.
+func (p *Parser) SetOnClipboard(fn func(ClipboardRequest)) {
+	p.onClipboard = fn
+}
+
+// clipboardRequest decodes the "selection;data" body of an OSC 52 sequence.
+// Malformed requests, and requests replayed from a log, are dropped.
+func (p *Parser) clipboardRequest(body string) {
+	if p.onClipboard == nil || p.replaying {
+		return
+	}
+	selection, data, ok := strings.Cut(body, ";")
+	if !ok {
+		return
+	}
+	if data == "?" {
+		p.onClipboard(ClipboardRequest{Selection: selection, Query: true})
+		return
+	}
+	text, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(text) == 0 {
+		return
+	}
+	p.onClipboard(ClipboardRequest{Selection: selection, Text: string(text)})
+}
+
+// ClipboardReply encodes the answer to an OSC 52 query for selection
+func ClipboardReply(selection, text string) []byte {
+	if selection == "" {
+		selection = "c"
+	}
+	return []byte("\x1b]52;" + selection + ";" + base64.StdEncoding.EncodeToString([]byte(text)) + "\x1b\\")
+}
@@ -0,0 +1,44 @@
+package emulator
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+)
+
+func TestParserClipboard(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []ClipboardRequest
+	}{
+		{"set", "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte("hello")) + "\x07", []ClipboardRequest{{Selection: "c", Text: "hello"}}},
+		{"set with ST", "\x1b]52;;aGk=\x1b\\", []ClipboardRequest{{Text: "hi"}}},
+		{"query", "\x1b]52;c;?\x07", []ClipboardRequest{{Selection: "c", Query: true}}},
+		{"bad base64", "\x1b]52;c;!!!\x07", nil},
+		{"no data", "\x1b]52;c\x07", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser()
+			var got []ClipboardRequest
+			p.SetOnClipboard(func(r ClipboardRequest) { got = append(got, r) })
+			p.Parse([]byte(tt.input))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("requests = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Requests replayed from a log are stale and dropped
+	p := newTestParser()
+	p.SetOnClipboard(func(r ClipboardRequest) { t.Errorf("replayed request %+v", r) })
+	p.SetReplayMode(true)
+	p.Parse([]byte("\x1b]52;c;aGk=\x07"))
+}
+
+func TestClipboardReply(t *testing.T) {
+	if got := string(ClipboardReply("", "hi")); got != "\x1b]52;c;aGk=\x1b\\" {
+		t.Errorf("ClipboardReply = %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package emulator
+
+import "testing"
+
+// TestConformance checks the parser against VT100/xterm behavior, in the
+// manner of vttest and esctest: each case feeds a sequence to a fresh
+// terminal and compares the screen and cursor.
+func TestConformance(t *testing.T) {
+	tests := []struct {
+		name       string
+		cols, rows int
+		input      string
+		want       []string // Rows from the top, trailing blanks trimmed
+		x, y       int      // Cursor as CPR reports it, 0-based
+	}{
+		// Character sets
+		{"DEC special graphics box", 10, 4, "\x1b(0lqk\r\nx x\r\nmqj\x1b(Bq", []string{"┌─┐", "│ │", "└─┘q"}, 4, 2},
+		{"SO and SI switch G1 and G0", 10, 2, "\x1b)0a\x0eq\x0fq", []string{"a─q"}, 3, 0},
+		{"G2 by LS2", 10, 2, "\x1b*0\x1bnx\x0fx", []string{"│x"}, 2, 0},
+		{"UK charset", 10, 2, "\x1b(A#\x1b(B#", []string{"£#"}, 2, 0},
+		{"DECSC saves the charsets", 10, 2, "\x1b(0\x1b7\x1b(Bq\x1b8q", []string{"─"}, 1, 0},
+		{"RIS resets the charsets", 10, 2, "\x1b(0\x1bcq", []string{"q"}, 1, 0},
+
+		// Tab stops
+		{"default tab stops", 20, 2, "\tA\tB", []string{"        A       B"}, 17, 0},
+		{"HTS after clearing all", 20, 2, "\x1b[3g\x1b[4G\x1bH\r\tA\tB", []string{"   A               B"}, 19, 0},
+		{"TBC at the cursor", 20, 2, "\x1b[9G\x1b[g\r\tA", []string{"                A"}, 17, 0},
+		{"CHT and CBT", 40, 2, "\x1b[3I\x1b[2ZA", []string{"        A"}, 9, 0},
+		{"tab stops at the right margin", 20, 2, "\x1b[5I", nil, 19, 0},
+		{"CBT stops at column 1", 20, 2, "\x1b[5G\x1b[3Z", nil, 0, 0},
+
+		// Autowrap (DECAWM)
+		{"autowrap", 10, 3, "0123456789AB", []string{"0123456789", "AB"}, 2, 1},
+		{"DECAWM off overwrites the last column", 10, 3, "\x1b[?7l0123456789AB", []string{"012345678B"}, 9, 0},
+		{"DECAWM off and on again", 10, 3, "\x1b[?7l0123456789A\x1b[?7hB", []string{"012345678A", "B"}, 1, 1},
+
+		// Insert mode (IRM)
+		{"IRM inserts", 10, 2, "abcdef\r\x1b[4hXY\x1b[4lZ", []string{"XYZbcdef"}, 3, 0},
+		{"IRM drops text pushed off the line", 10, 2, "0123456789\r\x1b[4hAB", []string{"AB01234567"}, 2, 0},
+
+		// Origin mode (DECOM)
+		{"DECOM addresses the scroll region", 10, 6, "\x1b[2;5r\x1b[?6h\x1b[1;1HX\x1b[10;1HY", []string{"", "X", "", "", "Y"}, 1, 3},
+		{"DECSTBM homes to the origin", 10, 6, "\x1b[?6h\x1b[3;5rX", []string{"", "", "X"}, 1, 0},
+		{"DECOM off homes to the corner", 10, 6, "\x1b[2;5r\x1b[?6h\x1b[?6lZ", []string{"Z"}, 1, 0},
+		{"VPA in origin mode", 10, 6, "\x1b[3;6r\x1b[?6h\x1b[2dV", []string{"", "", "", "V"}, 1, 1},
+		{"DECSC saves origin mode", 10, 6, "\x1b[2;5r\x1b[?6h\x1b7\x1b[?6l\x1b8\x1b[HO", []string{"", "O"}, 1, 0},
+
+		// Left/right margins (DECLRMM, DECSLRM)
+		{"DECSLRM wraps within the margins", 10, 3, "\x1b[?69h\x1b[3;6s\x1b[1;3Habcdefg", []string{"  abcd", "  efg"}, 5, 1},
+		{"DECSLRM scrolls within the margins", 10, 3,
+			"AAAAAAAAAA\r\nBBBBBBBBBB\r\nCCCCCCCCCC\x1b[?69h\x1b[3;6s\x1b[3;3H\n",
+			[]string{"AABBBBAAAA", "BBCCCCBBBB", "CC    CCCC"}, 2, 2},
+		{"ICH within the margins", 10, 2, "abcdefgh\x1b[?69h\x1b[1;5s\x1b[1;2H\x1b[2@", []string{"a  bcfgh"}, 1, 0},
+		{"DCH within the margins", 10, 2, "abcdefgh\x1b[?69h\x1b[2;6s\x1b[1;2H\x1b[2P", []string{"adef  gh"}, 1, 0},
+		{"IL outside the margins does nothing", 10, 3, "X\x1b[?69h\x1b[3;6s\x1b[1;1H\x1b[L", []string{"X"}, 0, 0},
+		{"DL within the margins", 10, 3, "AAAAAAAAAA\r\nBBBBBBBBBB\x1b[?69h\x1b[3;6s\x1b[1;4H\x1b[M", []string{"AABBBBAAAA", "BB    BBBB"}, 2, 0},
+		{"CUF stops at the right margin", 10, 2, "\x1b[?69h\x1b[2;5s\x1b[1;3H\x1b[10CX", []string{"    X"}, 4, 0},
+		{"CR returns to the left margin", 10, 2, "\x1b[?69h\x1b[3;6s\x1b[1;4Habc\rX", []string{"  Xabc"}, 3, 0},
+		{"BS stops at the left margin", 10, 2, "\x1b[?69h\x1b[3;6s\x1b[1;3H\b\bX", []string{"  X"}, 3, 0},
+		{"DECOM with left/right margins", 10, 4, "\x1b[?69h\x1b[3;6s\x1b[2;3r\x1b[?6h\x1b[1;1HX", []string{"", "  X"}, 1, 0},
+		{"CSI s saves the cursor without DECLRMM", 10, 3, "\x1b[2;3H\x1b[s\x1b[H\x1b[uX", []string{"", "  X"}, 3, 1},
+		{"DECLRMM off restores full margins", 10, 2, "\x1b[?69h\x1b[3;6s\x1b[?69l\x1b[1;3Habcdefg", []string{"  abcdefg"}, 9, 0},
+
+		// REP
+		{"REP", 10, 2, "a\x1b[3b", []string{"aaaa"}, 4, 0},
+		{"REP default count", 10, 2, "ab\x1b[b", []string{"abb"}, 3, 0},
+		{"REP repeats line drawing", 10, 2, "\x1b(0q\x1b[4b", []string{"─────"}, 5, 0},
+		{"REP wraps", 4, 2, "x\x1b[5b", []string{"xxxx", "xx"}, 2, 1},
+
+		// DECALN
+		{"DECALN", 4, 2, "ab\x1b[2;2r\x1b#8", []string{"EEEE", "EEEE"}, 0, 0},
+		{"DECALN resets the margins", 4, 3, "\x1b[2;3r\x1b#8\x1b[3;1H\nX", []string{"EEEE", "EEEE", "X"}, 1, 2},
+
+		// DECSTR
+		{"DECSTR", 10, 3, "abc\x1b[4h\x1b(0\x1b[?7l\x1b[!p\rq", []string{"qbc"}, 1, 0},
+		{"DECSTR resets the margins", 10, 4, "top\x1b[2;3r\x1b[!p\x1b[4;1H\nX", []string{"", "", "", "X"}, 1, 3},
+		{"DECSTR keeps the screen and cursor", 10, 3, "ab\x1b[!p", []string{"ab"}, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(NewScreen(tt.cols, tt.rows), NewScrollback())
+			p.Parse([]byte(tt.input))
+			s := p.Screen()
+			for y := 0; y < tt.rows; y++ {
+				want := ""
+				if y < len(tt.want) {
+					want = tt.want[y]
+				}
+				if got := rowText(s, y); got != want {
+					t.Errorf("row %d = %q, want %q", y, got, want)
+				}
+			}
+			if x, y := s.OriginCursor(); x != tt.x || y != tt.y {
+				t.Errorf("cursor = %d,%d, want %d,%d", x, y, tt.x, tt.y)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package emulator
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SetOnCWD sets the callback for working directory reports (OSC 7). host
+// is the machine the path is on, as reported by the shell (often empty for
+// the local machine).
+func (p *Parser) SetOnCWD(fn func(host, path string)) {
+	p.onCWD = fn
+}
+
+// CWD returns the host and path of the last working directory reported
+func (p *Parser) CWD() (host, path string) {
+	return p.cwdHost, p.cwdPath
+}
+
+// reportCWD handles the file://host/path body of an OSC 7 sequence.
+// Reports replayed from a log update the state without the callback.
+func (p *Parser) reportCWD(body string) {
+	host, path, ok := ParseCWD(body)
+	if !ok {
+		return
+	}
+	p.cwdHost, p.cwdPath = host, path
+	if p.onCWD != nil && !p.replaying {
+		p.onCWD(host, path)
+	}
+}
+
+// ParseCWD splits an OSC 7 working directory URL (file://host/path, with
+// the path percent-encoded) into its host and path. tmux keeps the last one
+// a pane reported as #{pane_path}.
+func ParseCWD(uri string) (host, path string, ok bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || !strings.HasPrefix(u.Path, "/") {
+		return "", "", false
+	}
+	return u.Host, u.Path, true
+}
@@ -0,0 +1,41 @@
+package emulator
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParserCWD(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string // "host:path" per callback
+	}{
+		{"local", "\x1b]7;file:///home/me\x07", []string{":/home/me"}},
+		{"remote with ST", "\x1b]7;file://web1/var/www\x1b\\", []string{"web1:/var/www"}},
+		{"percent-encoded", "\x1b]7;file://mac.local/Users/me/My%20Docs\x07", []string{"mac.local:/Users/me/My Docs"}},
+		{"two reports", "\x1b]7;file:///a\x07\x1b]7;file:///b\x07", []string{":/a", ":/b"}},
+		{"not a file URL", "\x1b]7;https://example.com/x\x07", nil},
+		{"relative path", "\x1b]7;file:tmp\x07", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser()
+			var got []string
+			p.SetOnCWD(func(host, path string) { got = append(got, host+":"+path) })
+			p.Parse([]byte(tt.input))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("reports = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Reports replayed from a log update the state but not the callback
+	p := newTestParser()
+	p.SetOnCWD(func(host, path string) { t.Errorf("replayed report %s:%s", host, path) })
+	p.SetReplayMode(true)
+	p.Parse([]byte("\x1b]7;file://web1/srv\x07"))
+	if host, path := p.CWD(); host != "web1" || path != "/srv" {
+		t.Errorf("CWD = %q, %q after replay", host, path)
+	}
+}
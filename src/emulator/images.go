@@ -0,0 +1,543 @@
+package emulator
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	maxImagePayload = 16 << 20 // Encoded bytes accepted for one image (Sixel, kitty chunks, OSC 1337)
+	maxImageSide    = 8192     // Widest or tallest image accepted, in pixels
+	maxImagePixels  = 8 << 20  // Largest image accepted (32MB decoded)
+	maxImageMemory  = 64 << 20 // Decoded image bytes a session keeps in memory
+	maxImageDisk    = 64 << 20 // Image bytes a session keeps on disk with its scrollback
+
+	// Cell size images are laid out with until the GUI sets the real one
+	defaultCellPixelW = 10
+	defaultCellPixelH = 20
+)
+
+// ImageRune fills the cells an inline image covers, as in kitty's Unicode
+// placeholders. The cell's FG holds the image ID and its Combining the
+// tile's column and row, so tiles travel with the text through scrolling,
+// erasing, reflow and the scrollback file.
+const ImageRune rune = 0x10EEEE
+
+// imageCoordBase offsets tile coordinates into a private use plane so they
+// are valid runes
+const imageCoordBase = 0xF0000
+
+// ImageCell returns the cell showing tile (col, row) of image id
+func ImageCell(id uint32, col, row int) Cell {
+	return Cell{
+		Rune:      ImageRune,
+		FG:        RGBColor(uint8(id>>16), uint8(id>>8), uint8(id)),
+		Combining: string(rune(imageCoordBase+col)) + string(rune(imageCoordBase+row)),
+	}
+}
+
+// ImageTile reports the image and the tile of it a cell shows
+func (c Cell) ImageTile() (id uint32, col, row int, ok bool) {
+	if c.Rune != ImageRune || c.FG.Type != ColorRGB {
+		return 0, 0, 0, false
+	}
+	x, n := utf8.DecodeRuneInString(c.Combining)
+	y, _ := utf8.DecodeRuneInString(c.Combining[n:])
+	if x < imageCoordBase || y < imageCoordBase {
+		return 0, 0, 0, false
+	}
+	id = uint32(c.FG.R)<<16 | uint32(c.FG.G)<<8 | uint32(c.FG.B)
+	return id, int(x - imageCoordBase), int(y - imageCoordBase), true
+}
+
+// Image is a decoded inline image laid out over a grid of cells. Its
+// pixels are padded to whole cells, so every tile is the same size.
+type Image struct {
+	ID         uint32
+	Cols, Rows int // Cells covered
+	Pixels     *image.NRGBA
+	used       uint64 // Store clock at last use, for eviction
+}
+
+// TileRect returns the pixels of the image shown in cell (col, row)
+func (img *Image) TileRect(col, row int) image.Rectangle {
+	b := img.Pixels.Bounds()
+	w, h := b.Dx()/img.Cols, b.Dy()/img.Rows
+	return image.Rect(col*w, row*h, (col+1)*w, (row+1)*h).Add(b.Min)
+}
+
+// ImageStore holds a session's inline images. Decoded images are kept in
+// memory up to maxImageMemory, least recently used first out. A store
+// backed by a directory also saves each image there as a PNG, so images
+// in the persisted scrollback come back after a restart and evicted ones
+// can be reloaded. Images are saved by a background writer, so adding one
+// from the parser doesn't wait on PNG encoding or the disk.
+type ImageStore struct {
+	mu        sync.Mutex
+	dir       string
+	images    map[uint32]*Image
+	bytes     int                  // Decoded bytes in images
+	clock     uint64               // Bumped on every use
+	next      uint32               // Next ID to hand out
+	saved     map[uint32]imageFile // Images saved in dir
+	diskBytes int64                // Bytes of the saved images
+	pending   []*Image             // Images waiting to be saved, oldest first
+	writing   bool                 // A writer is saving pending
+
+	// ioMu serializes saves and changes to the directory, and is held
+	// without mu while a save is on disk
+	ioMu sync.Mutex
+}
+
+// NewImageStore creates a store saving images in dir, or only in memory
+// when dir is empty. IDs continue after any images already in dir.
+func NewImageStore(dir string) *ImageStore {
+	s := &ImageStore{dir: dir, images: map[uint32]*Image{}, next: 1}
+	s.indexLocked()
+	for id := range s.saved {
+		if id >= s.next {
+			s.next = id + 1
+		}
+	}
+	return s
+}
+
+// indexLocked reads which images are saved in the store's directory.
+// Caller must hold s.mu (or own s exclusively).
+func (s *ImageStore) indexLocked() {
+	s.saved, s.diskBytes = map[uint32]imageFile{}, 0
+	for _, f := range s.files() {
+		s.saved[f.id] = f
+		s.diskBytes += f.size
+	}
+}
+
+// imageFile is an image saved in a store's directory
+type imageFile struct {
+	id         uint32
+	cols, rows int
+	path       string
+	size       int64
+}
+
+// imageFileName returns the name an image is saved under: its ID and the
+// cells it covers
+func imageFileName(img *Image) string {
+	return fmt.Sprintf("%d_%dx%d.png", img.ID, img.Cols, img.Rows)
+}
+
+// files lists the images saved in the store's directory, oldest first
+func (s *ImageStore) files() []imageFile {
+	if s.dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	var files []imageFile
+	for _, e := range entries {
+		var f imageFile
+		if _, err := fmt.Sscanf(e.Name(), "%d_%dx%d.png", &f.id, &f.cols, &f.rows); err != nil || f.cols <= 0 || f.rows <= 0 {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			f.size = info.Size()
+		}
+		f.path = filepath.Join(s.dir, e.Name())
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].id < files[j].id })
+	return files
+}
+
+// Add stores pixels as a new image covering cols x rows cells and returns
+// it, queueing it to be saved. IDs wrap within the 24 bits a cell can hold.
+func (s *ImageStore) Add(pixels *image.NRGBA, cols, rows int) *Image {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img := &Image{ID: s.next, Cols: cols, Rows: rows, Pixels: pixels}
+	s.next = s.next%(1<<24-1) + 1
+	s.keepLocked(img)
+	if s.dir != "" {
+		s.pending = append(s.pending, img)
+		if !s.writing {
+			s.writing = true
+			go func() {
+				for s.saveNext() {
+				}
+			}()
+		}
+	}
+	return img
+}
+
+// Get returns image id, reloading it from disk if it was evicted. Nil if
+// the image is gone. The PNG is decoded without holding the store.
+func (s *ImageStore) Get(id uint32) *Image {
+	s.mu.Lock()
+	if img, ok := s.images[id]; ok {
+		s.clock++
+		img.used = s.clock
+		s.mu.Unlock()
+		return img
+	}
+	for _, img := range s.pending {
+		if img.ID == id {
+			// Evicted before it was saved
+			s.keepLocked(img)
+			s.mu.Unlock()
+			return img
+		}
+	}
+	f, ok := s.saved[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	pixels, err := loadPNG(f.path)
+	if err != nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if img, ok := s.images[id]; ok {
+		return img // Reloaded meanwhile
+	}
+	img := &Image{ID: id, Cols: f.cols, Rows: f.rows, Pixels: pixels}
+	s.keepLocked(img)
+	return img
+}
+
+// setDir points a store at the directory its images were moved to. A save
+// that raced the move may have made the old directory again; its images
+// are moved over too.
+func (s *ImageStore) setDir(dir string) {
+	s.ioMu.Lock()
+	defer s.ioMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.dir
+	s.dir = dir
+	if old != dir && old != "" {
+		if entries, err := os.ReadDir(old); err == nil {
+			os.MkdirAll(dir, 0755)
+			for _, e := range entries {
+				os.Rename(filepath.Join(old, e.Name()), filepath.Join(dir, e.Name()))
+			}
+			os.Remove(old)
+		}
+	}
+	s.indexLocked()
+}
+
+// Clear drops every image from memory and disk, and any not yet saved
+func (s *ImageStore) Clear() {
+	s.ioMu.Lock()
+	defer s.ioMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.images = map[uint32]*Image{}
+	s.bytes = 0
+	s.pending = nil
+	for _, f := range s.files() {
+		os.Remove(f.path)
+	}
+	s.saved, s.diskBytes = map[uint32]imageFile{}, 0
+}
+
+// flush waits until every image added so far is saved
+func (s *ImageStore) flush() {
+	for s.saveNext() {
+	}
+}
+
+// MemoryBytes returns the decoded bytes held in memory
+func (s *ImageStore) MemoryBytes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytes
+}
+
+// keepLocked adds img to the in-memory set, evicting the least recently
+// used images beyond maxImageMemory. Caller must hold s.mu.
+func (s *ImageStore) keepLocked(img *Image) {
+	s.clock++
+	img.used = s.clock
+	s.images[img.ID] = img
+	s.bytes += len(img.Pixels.Pix)
+	for s.bytes > maxImageMemory && len(s.images) > 1 {
+		var oldest *Image
+		for _, m := range s.images {
+			if m != img && (oldest == nil || m.used < oldest.used) {
+				oldest = m
+			}
+		}
+		delete(s.images, oldest.ID)
+		s.bytes -= len(oldest.Pixels.Pix)
+	}
+}
+
+// saveNext saves the oldest pending image, then deletes the oldest files
+// beyond maxImageDisk. Returns false, ending the writer, when nothing is
+// pending. mu is only held to take the image and record the file.
+func (s *ImageStore) saveNext() bool {
+	s.ioMu.Lock()
+	defer s.ioMu.Unlock()
+
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.writing = false
+		s.mu.Unlock()
+		return false
+	}
+	img, dir := s.pending[0], s.dir
+	s.mu.Unlock()
+
+	f, err := savePNG(dir, img)
+
+	s.mu.Lock()
+	if len(s.pending) > 0 && s.pending[0] == img {
+		s.pending = s.pending[1:]
+	}
+	var drop []string
+	if err == nil {
+		s.saved[f.id] = f
+		s.diskBytes += f.size
+		if s.diskBytes > maxImageDisk {
+			ids := make([]uint32, 0, len(s.saved))
+			for id := range s.saved {
+				ids = append(ids, id)
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			for _, id := range ids {
+				if s.diskBytes <= maxImageDisk || id == img.ID {
+					break
+				}
+				drop = append(drop, s.saved[id].path)
+				s.diskBytes -= s.saved[id].size
+				delete(s.saved, id)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, path := range drop {
+		os.Remove(path)
+	}
+	return true
+}
+
+// savePNG writes img to dir
+func savePNG(dir string, img *Image) (imageFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return imageFile{}, err
+	}
+	path := filepath.Join(dir, imageFileName(img))
+	f, err := os.Create(path)
+	if err != nil {
+		return imageFile{}, err
+	}
+	err = png.Encode(f, img.Pixels)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return imageFile{}, err
+	}
+	saved := imageFile{id: img.ID, cols: img.Cols, rows: img.Rows, path: path}
+	if info, err := os.Stat(path); err == nil {
+		saved.size = info.Size()
+	}
+	return saved, nil
+}
+
+// loadPNG decodes a saved image
+func loadPNG(path string) (*image.NRGBA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return toNRGBA(img), nil
+}
+
+// toNRGBA returns img as an NRGBA image, converting it if needed
+func toNRGBA(img image.Image) *image.NRGBA {
+	if n, ok := img.(*image.NRGBA); ok {
+		return n
+	}
+	n := image.NewNRGBA(img.Bounds())
+	draw.Draw(n, n.Bounds(), img, img.Bounds().Min, draw.Src)
+	return n
+}
+
+// imagesDir returns the directory holding the images of the scrollback
+// file at path
+func imagesDir(path string) string {
+	return strings.TrimSuffix(path, scrollbackExt) + ".images"
+}
+
+// checkImageSize reports whether a w x h pixel image is within the caps
+func checkImageSize(w, h int) error {
+	if w <= 0 || h <= 0 {
+		return fmt.Errorf("empty image")
+	}
+	if w > maxImageSide || h > maxImageSide || w*h > maxImagePixels {
+		return fmt.Errorf("image too large: %dx%d", w, h)
+	}
+	return nil
+}
+
+// imageCellSize returns the pixel size of a cell that images are laid
+// out with
+func (p *Parser) imageCellSize() (w, h int) {
+	if p.cellPixelW <= 0 || p.cellPixelH <= 0 {
+		return defaultCellPixelW, defaultCellPixelH
+	}
+	return p.cellPixelW, p.cellPixelH
+}
+
+// imageLayout is how an image is to be placed: its size on screen in
+// pixels, or in cells where the program asked for a number of cells
+type imageLayout struct {
+	width, height int  // Pixels; zero takes the other's aspect, or the image's size
+	cols, rows    int  // Cells, overriding width and height; zero when unset
+	keepAspect    bool // Fit within the size rather than stretching to it
+}
+
+// addImage scales src as layout asks, pads it to whole cells and adds it
+// to the image store. Returns nil if the result would be over the caps.
+func (p *Parser) addImage(src image.Image, layout imageLayout) *Image {
+	cw, ch := p.imageCellSize()
+	b := src.Bounds()
+	w, h := layout.width, layout.height
+	if layout.cols > 0 {
+		w = layout.cols * cw
+	}
+	if layout.rows > 0 {
+		h = layout.rows * ch
+	}
+	switch {
+	case w <= 0 && h <= 0:
+		w, h = b.Dx(), b.Dy()
+	case w <= 0:
+		w = max(1, b.Dx()*h/b.Dy())
+	case h <= 0:
+		h = max(1, b.Dy()*w/b.Dx())
+	case layout.keepAspect:
+		if b.Dx()*h > b.Dy()*w {
+			h = max(1, b.Dy()*w/b.Dx())
+		} else {
+			w = max(1, b.Dx()*h/b.Dy())
+		}
+	}
+	cols, rows := (w+cw-1)/cw, (h+ch-1)/ch
+	if checkImageSize(cols*cw, rows*ch) != nil {
+		return nil
+	}
+
+	// Scale into the top left of a transparent canvas of whole cells
+	pixels := image.NewNRGBA(image.Rect(0, 0, cols*cw, rows*ch))
+	dst := image.Rect(0, 0, w, h)
+	if dst.Size() == b.Size() {
+		draw.Draw(pixels, dst, src, b.Min, draw.Src)
+	} else {
+		xdraw.ApproxBiLinear.Scale(pixels, dst, src, b, draw.Src, nil)
+	}
+	return p.scrollback.Images().Add(pixels, cols, rows)
+}
+
+// placeImage fills the cells img covers with its tiles, starting at the
+// cursor and scrolling as needed. The cursor is left on the image's last
+// row, just right of it. Returns the column the image starts in.
+func (p *Parser) placeImage(img *Image) int {
+	s := p.screen
+	if s.cursor.X >= s.cols { // Pending wrap
+		s.CarriageReturn()
+		p.lineFeed()
+	}
+	x0 := s.cursor.X
+	for row := 0; row < img.Rows; row++ {
+		if row > 0 {
+			p.lineFeed()
+		}
+		y := s.cursor.Y
+		end := min(x0+img.Cols, s.cols)
+		s.splitWide(y, x0)
+		s.splitWide(y, end)
+		for x := x0; x < end; x++ {
+			c := ImageCell(img.ID, x-x0, row)
+			c.Mark = s.cells[y][x].Mark
+			s.cells[y][x] = c
+		}
+		s.dirty[y] = true
+	}
+	s.cursor.X = min(x0+img.Cols, s.cols)
+	s.marginWrap = false
+	return x0
+}
+
+// Image returns inline image id for drawing the screen's image cells, or
+// nil if it is gone or the screen has no image store.
+func (s *Screen) Image(id uint32) *Image {
+	if s.images == nil {
+		return nil
+	}
+	return s.images.Get(id)
+}
+
+// clearImages blanks the screen's cells showing any of ids, or all image
+// cells when ids is nil
+func (s *Screen) clearImages(ids map[uint32]bool) {
+	for y, line := range s.cells {
+		for x, c := range line {
+			if id, _, _, ok := c.ImageTile(); ok && (ids == nil || ids[id]) {
+				line[x] = DefaultCell()
+				line[x].Mark = c.Mark
+				s.dirty[y] = true
+			}
+		}
+	}
+}
+
+// imageParams parses the "key=value" pairs separated by sep that configure
+// an image (OSC 1337 File= arguments, kitty graphics control data)
+func imageParams(s string, sep string) map[string]string {
+	params := map[string]string{}
+	for _, kv := range strings.Split(s, sep) {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			params[k] = v
+		}
+	}
+	return params
+}
+
+// atoi parses a non-negative decimal, returning 0 when it isn't one
+func atoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,194 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImageCell(t *testing.T) {
+	c := ImageCell(0xABCDEF, 3, 12)
+	id, col, row, ok := c.ImageTile()
+	if !ok || id != 0xABCDEF || col != 3 || row != 12 {
+		t.Errorf("ImageTile = %#x (%d,%d) %v", id, col, row, ok)
+	}
+	if c.Text() != " " {
+		t.Errorf("Text = %q, want a space", c.Text())
+	}
+	if _, _, _, ok := (Cell{Rune: ImageRune}).ImageTile(); ok {
+		t.Error("bare ImageRune cell reported as a tile")
+	}
+}
+
+func TestImageStoreEviction(t *testing.T) {
+	s := NewImageStore("")
+	side := 2048 // 16MB each
+	var ids []uint32
+	for i := 0; i < 5; i++ {
+		ids = append(ids, s.Add(image.NewNRGBA(image.Rect(0, 0, side, side)), 1, 1).ID)
+	}
+	if got := s.MemoryBytes(); got > maxImageMemory {
+		t.Errorf("MemoryBytes = %d, over the %d cap", got, maxImageMemory)
+	}
+	if s.Get(ids[0]) != nil {
+		t.Error("oldest image not evicted")
+	}
+	if s.Get(ids[4]) == nil {
+		t.Error("newest image evicted")
+	}
+}
+
+func TestImageStorePersistence(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "s.images")
+	s := NewImageStore(dir)
+	px := image.NewNRGBA(image.Rect(0, 0, 4, 6))
+	px.SetNRGBA(1, 2, color.NRGBA{10, 20, 30, 255})
+	img := s.Add(px, 2, 3)
+	s.flush()
+
+	reopened := NewImageStore(dir)
+	got := reopened.Get(img.ID)
+	if got == nil || got.Cols != 2 || got.Rows != 3 {
+		t.Fatalf("reloaded image = %+v", got)
+	}
+	if c := got.Pixels.NRGBAAt(1, 2); c != (color.NRGBA{10, 20, 30, 255}) {
+		t.Errorf("reloaded pixel = %v", c)
+	}
+	if next := reopened.Add(px, 2, 3); next.ID <= img.ID {
+		t.Errorf("ID after reopen = %d, want after %d", next.ID, img.ID)
+	}
+
+	reopened.Clear()
+	if NewImageStore(dir).Get(img.ID) != nil {
+		t.Error("image survived Clear")
+	}
+}
+
+func TestImageStoreSavesInBackground(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "s.images")
+	s := NewImageStore(dir)
+	px := image.NewNRGBA(image.Rect(0, 0, 4, 6))
+
+	// Add doesn't wait on a save that is on disk
+	s.ioMu.Lock()
+	added := make(chan *Image)
+	go func() { added <- s.Add(px, 2, 3) }()
+	var img *Image
+	select {
+	case img = <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Add waited for the disk")
+	}
+	if got := s.Get(img.ID); got != img {
+		t.Errorf("Get before the save = %+v", got)
+	}
+	s.ioMu.Unlock()
+
+	s.flush()
+	if got := NewImageStore(dir).Get(img.ID); got == nil || got.Cols != 2 || got.Rows != 3 {
+		t.Errorf("saved image = %+v", got)
+	}
+}
+
+func TestImageTileRect(t *testing.T) {
+	img := &Image{Cols: 2, Rows: 3, Pixels: image.NewNRGBA(image.Rect(0, 0, 20, 60))}
+	if got, want := img.TileRect(1, 2), image.Rect(10, 40, 20, 60); got != want {
+		t.Errorf("TileRect = %v, want %v", got, want)
+	}
+}
+
+func TestScrollbackImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.scrollback")
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	p := NewParser(NewScreen(10, 2), sb)
+	p.SetCellPixelSize(2, 4)
+
+	// Scroll a one-cell image off the screen
+	p.Parse([]byte("\x1bPq#1;2;0;100;0!2~\x1b\\x\r\n\r\n\r\n"))
+	if sb.Count() < 1 {
+		t.Fatalf("Count = %d", sb.Count())
+	}
+	sb.Close()
+
+	sb, err = NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer sb.Close()
+	id, col, row, ok := sb.Line(0)[0].ImageTile()
+	if !ok || col != 0 || row != 0 {
+		t.Fatalf("Line(0)[0] = %+v", sb.Line(0)[0])
+	}
+	img := sb.Images().Get(id)
+	if img == nil {
+		t.Fatal("image not reloaded with the scrollback")
+	}
+	if c := img.Pixels.NRGBAAt(1, 3); c != (color.NRGBA{0, 255, 0, 255}) {
+		t.Errorf("pixel = %v", c)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), "test.images")); err != nil {
+		t.Errorf("images dir: %v", err)
+	}
+}
+
+func TestITermFile(t *testing.T) {
+	px := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := range px.Pix {
+		px.Pix[i] = 255
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, px)
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	tests := []struct {
+		name       string
+		args       string
+		cols, rows int
+	}{
+		{"natural size", "inline=1", 4, 2},
+		{"cells", "inline=1;width=2;height=1;preserveAspectRatio=0", 2, 1},
+		{"pixels keep aspect", "inline=1;width=16px;height=4px", 2, 1},
+		{"percent", "inline=1;width=50%", 5, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(NewScreen(10, 6), NewScrollback())
+			p.SetCellPixelSize(2, 4)
+			p.Parse([]byte("\x1b]1337;File=name=eC5wbmc=;" + tt.args + ":" + data + "\x07z"))
+			s := p.Screen()
+			id, _, _, ok := s.Cell(0, 0).ImageTile()
+			img := s.Image(id)
+			if !ok || img == nil || img.Cols != tt.cols || img.Rows != tt.rows {
+				t.Fatalf("image = %+v", img)
+			}
+			// The cursor follows the image on its last row
+			if got := s.Cell(tt.cols, tt.rows-1).Rune; got != 'z' {
+				t.Errorf("cell after image = %q, want 'z'", got)
+			}
+		})
+	}
+
+	// Auto size shrinks to fit the screen width
+	p := NewParser(NewScreen(2, 6), NewScrollback())
+	p.SetCellPixelSize(2, 4)
+	p.Parse([]byte("\x1b]1337;File=inline=1;width=auto:" + data + "\x07"))
+	id, _, _, _ := p.Screen().Cell(0, 0).ImageTile()
+	if img := p.Screen().Image(id); img == nil || img.Cols != 2 || img.Rows != 1 {
+		t.Errorf("fitted image = %+v", img)
+	}
+
+	p = NewParser(NewScreen(10, 6), NewScrollback())
+	p.Parse([]byte("\x1b]1337;File=name=eC5wbmc=:" + data + "\x07"))
+	if _, _, _, ok := p.Screen().Cell(0, 0).ImageTile(); ok {
+		t.Error("download (inline=0) shown inline")
+	}
+}
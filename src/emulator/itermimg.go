@@ -0,0 +1,59 @@
+package emulator
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif" // Formats OSC 1337 File= accepts
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+)
+
+// iTermFile shows an iTerm2 inline image: OSC 1337 ; File=args : base64.
+// Only inline=1 files are shown; iTerm2 downloads the others. width and
+// height are cells (N), pixels (Npx), a percentage of the screen (N%) or
+// auto, which keeps the image's own size but fits it to the screen width.
+func (p *Parser) iTermFile(body string) {
+	args, data, ok := strings.Cut(strings.TrimPrefix(body, "File="), ":")
+	params := imageParams(args, ";")
+	if !ok || params["inline"] != "1" {
+		return
+	}
+	raw, err := decodeBase64(data)
+	if err != nil {
+		return
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || checkImageSize(cfg.Width, cfg.Height) != nil {
+		return
+	}
+	src, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return
+	}
+
+	cw, ch := p.imageCellSize()
+	cols, rows := p.screen.Size()
+	layout := imageLayout{keepAspect: params["preserveAspectRatio"] != "0"}
+	layout.width, layout.cols = iTermDimension(params["width"], cols, cw)
+	layout.height, layout.rows = iTermDimension(params["height"], rows, ch)
+	if layout.width == 0 && layout.cols == 0 && cfg.Width > cols*cw {
+		layout.width = cols * cw
+	}
+	if img := p.addImage(src, layout); img != nil {
+		p.placeImage(img)
+	}
+}
+
+// iTermDimension parses an OSC 1337 File= width or height into pixels or
+// cells; both are zero for auto
+func iTermDimension(v string, cells, cellPixels int) (pixels, n int) {
+	switch {
+	case strings.HasSuffix(v, "px"):
+		return atoi(strings.TrimSuffix(v, "px")), 0
+	case strings.HasSuffix(v, "%"):
+		return cells * cellPixels * min(atoi(strings.TrimSuffix(v, "%")), 100) / 100, 0
+	default: // N cells, or auto
+		return 0, atoi(v)
+	}
+}
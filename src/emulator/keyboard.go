@@ -0,0 +1,105 @@
+package emulator
+
+// maxKeyboardStack caps how many flag sets CSI > u can save; pushing onto
+// a full stack drops the oldest
+const maxKeyboardStack = 16
+
+// allKeyboardFlags are the kitty keyboard enhancements this terminal knows
+const allKeyboardFlags = 0x1f
+
+// keyboardFlags is one screen's kitty keyboard protocol state: the
+// enhancements in effect and the ones CSI > u pushed them over
+type keyboardFlags struct {
+	flags int
+	stack []int
+}
+
+// keyboardState returns the active screen's kitty keyboard state; the
+// main and alternate screens each keep their own.
+func (p *Parser) keyboardState() *keyboardFlags {
+	if p.altScreen {
+		return &p.keyboard[1]
+	}
+	return &p.keyboard[0]
+}
+
+// KeyboardFlags returns the kitty keyboard protocol enhancements the
+// application has enabled on the active screen, 0 for legacy keys
+func (p *Parser) KeyboardFlags() int {
+	return p.keyboardState().flags
+}
+
+// maxModifyOtherKeys is the highest xterm modifyOtherKeys level known
+const maxModifyOtherKeys = 2
+
+// ModifyOtherKeys returns the xterm modifyOtherKeys level (0-2) set with
+// CSI > 4 ; level m. tmux asks for it when extended-keys is on, and it is
+// how Shift+Enter or Ctrl+I reach a program running inside tmux.
+func (p *Parser) ModifyOtherKeys() int {
+	return p.modifyOtherKeys
+}
+
+// modifyKeys handles xterm's key modifier options: set (CSI > Pp ; Pv m),
+// reset (CSI > Pp n) and query (CSI ? Pp m). Only modifyOtherKeys (4) is
+// kept; the others set how cursor and function keys are modified, which
+// this terminal fixes at xterm's defaults.
+func (p *Parser) modifyKeys(final byte) {
+	if len(p.params) == 0 || p.params[0] != 4 {
+		return
+	}
+	switch {
+	case p.intermediate == ">" && final == 'm':
+		level := 0
+		if len(p.params) > 1 {
+			level = min(p.params[1], maxModifyOtherKeys)
+		}
+		p.modifyOtherKeys = level
+	case p.intermediate == ">" && final == 'n':
+		p.modifyOtherKeys = 0
+	case p.intermediate == "?" && final == 'm':
+		p.respond("\x1b[>4;%dm", p.modifyOtherKeys)
+	}
+}
+
+// keyboardMode handles the kitty keyboard protocol's CSI u sequences:
+// push (CSI > flags u), pop (CSI < n u), set (CSI = flags ; mode u) and
+// query (CSI ? u).
+func (p *Parser) keyboardMode() {
+	param := func(i, def int) int {
+		if i < len(p.params) && p.params[i] > 0 {
+			return p.params[i]
+		}
+		return def
+	}
+	k := p.keyboardState()
+	switch p.intermediate {
+	case ">":
+		if len(k.stack) >= maxKeyboardStack {
+			k.stack = append(k.stack[:0], k.stack[1:]...)
+		}
+		k.stack = append(k.stack, k.flags)
+		k.flags = param(0, 0) & allKeyboardFlags
+	case "<":
+		for n := param(0, 1); n > 0; n-- {
+			if len(k.stack) == 0 {
+				// Popping past the bottom resets everything
+				k.flags = 0
+				break
+			}
+			k.flags = k.stack[len(k.stack)-1]
+			k.stack = k.stack[:len(k.stack)-1]
+		}
+	case "=":
+		flags := param(0, 0) & allKeyboardFlags
+		switch param(1, 1) {
+		case 1: // Set exactly these
+			k.flags = flags
+		case 2: // Add them
+			k.flags |= flags
+		case 3: // Remove them
+			k.flags &^= flags
+		}
+	case "?":
+		p.respond("\x1b[?%du", k.flags)
+	}
+}
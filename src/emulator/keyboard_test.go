@@ -0,0 +1,127 @@
+package emulator
+
+import "testing"
+
+func TestKeyboardFlagsStack(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"default", "", 0},
+		{"push", "\x1b[>1u", 1},
+		{"push twice", "\x1b[>1u\x1b[>3u", 3},
+		{"pop", "\x1b[>1u\x1b[>3u\x1b[<u", 1},
+		{"pop several", "\x1b[>1u\x1b[>3u\x1b[<2u", 0},
+		{"pop past the bottom", "\x1b[>1u\x1b[<5u", 0},
+		{"unknown flags dropped", "\x1b[>255u", 31},
+		{"set", "\x1b[>1u\x1b[=6u", 6},
+		{"add", "\x1b[>1u\x1b[=8;2u", 9},
+		{"remove", "\x1b[>11u\x1b[=2;3u", 9},
+		{"set without push", "\x1b[=4u", 4},
+		{"RIS resets", "\x1b[>1u\x1bc", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(NewScreen(10, 2), NewScrollback())
+			p.Parse([]byte(tt.input + "x"))
+			if got := p.KeyboardFlags(); got != tt.want {
+				t.Errorf("KeyboardFlags = %d, want %d", got, tt.want)
+			}
+			if got := rowText(p.Screen(), 0); got != "x" {
+				t.Errorf("row = %q, sequence leaked onto the screen", got)
+			}
+		})
+	}
+}
+
+func TestKeyboardFlagsQuery(t *testing.T) {
+	p := NewParser(NewScreen(10, 2), NewScrollback())
+	var reply string
+	p.SetOnResponse(func(data []byte) { reply = string(data) })
+	p.Parse([]byte("\x1b[?u"))
+	if reply != "\x1b[?0u" {
+		t.Errorf("reply = %q", reply)
+	}
+	p.Parse([]byte("\x1b[>5u\x1b[?u"))
+	if reply != "\x1b[?5u" {
+		t.Errorf("reply = %q", reply)
+	}
+}
+
+func TestKeyboardFlagsPerScreen(t *testing.T) {
+	p := NewParser(NewScreen(10, 2), NewScrollback())
+	p.Parse([]byte("\x1b[>1u\x1b[?1049h"))
+	if got := p.KeyboardFlags(); got != 0 {
+		t.Errorf("alt screen flags = %d, want its own empty stack", got)
+	}
+	p.Parse([]byte("\x1b[>15u\x1b[?1049l"))
+	if got := p.KeyboardFlags(); got != 1 {
+		t.Errorf("main screen flags = %d, want 1", got)
+	}
+	p.Parse([]byte("\x1b[?1049h"))
+	if got := p.KeyboardFlags(); got != 15 {
+		t.Errorf("alt screen flags = %d, want 15 kept", got)
+	}
+}
+
+func TestKeyboardFlagsStackCap(t *testing.T) {
+	p := NewParser(NewScreen(10, 2), NewScrollback())
+	for i := 0; i < maxKeyboardStack+4; i++ {
+		p.Parse([]byte("\x1b[>1u"))
+	}
+	if got := len(p.keyboardState().stack); got != maxKeyboardStack {
+		t.Errorf("stack depth = %d, want %d", got, maxKeyboardStack)
+	}
+}
+
+func TestModifyOtherKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"default", "", 0},
+		{"level 1", "\x1b[>4;1m", 1},
+		{"level 2", "\x1b[>4;2m", 2},
+		{"capped", "\x1b[>4;9m", 2},
+		{"reset by no value", "\x1b[>4;1m\x1b[>4m", 0},
+		{"reset", "\x1b[>4;2m\x1b[>4n", 0},
+		{"other resource", "\x1b[>1;2m", 0},
+		{"RIS resets", "\x1b[>4;1m\x1bc", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(NewScreen(10, 2), NewScrollback())
+			p.Parse([]byte(tt.input + "x"))
+			if got := p.ModifyOtherKeys(); got != tt.want {
+				t.Errorf("ModifyOtherKeys = %d, want %d", got, tt.want)
+			}
+			if got := p.Screen().Cell(0, 0).Attrs; got != 0 {
+				t.Errorf("attrs = %v, XTMODKEYS applied as SGR", got)
+			}
+		})
+	}
+}
+
+func TestModifyOtherKeysNotSGR(t *testing.T) {
+	p := NewParser(NewScreen(10, 2), NewScrollback())
+	// As SGR, 4 would underline and 1 bold
+	p.Parse([]byte("\x1b[>4;1mx\x1b[?4mx"))
+	if got := p.Screen().Cell(0, 0); got.Attrs != 0 {
+		t.Errorf("attrs = %v after CSI > 4;1 m, want no SGR", got.Attrs)
+	}
+	if got := p.Screen().Cell(1, 0); got.Attrs != 0 {
+		t.Errorf("attrs = %v after CSI ? 4 m, want no SGR", got.Attrs)
+	}
+}
+
+func TestModifyOtherKeysQuery(t *testing.T) {
+	p := NewParser(NewScreen(10, 2), NewScrollback())
+	var reply string
+	p.SetOnResponse(func(data []byte) { reply = string(data) })
+	p.Parse([]byte("\x1b[>4;2m\x1b[?4m"))
+	if reply != "\x1b[>4;2m" {
+		t.Errorf("reply = %q", reply)
+	}
+}
@@ -0,0 +1,305 @@
+package emulator
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// kittyImage is an image transmitted with the kitty graphics protocol,
+// kept for later placements
+type kittyImage struct {
+	pixels *image.NRGBA
+	number uint32   // I= image number the program sent it with, if any
+	placed []uint32 // Store IDs of its placements, for deletion
+}
+
+// kittyGraphics holds the images a program transmitted with the kitty
+// graphics protocol, up to maxImageMemory, oldest first out
+type kittyGraphics struct {
+	images map[uint32]*kittyImage
+	order  []uint32 // IDs in transmission order
+	bytes  int
+	nextID uint32 // IDs handed out to images sent with only a number
+
+	// Chunked transmission in progress (m=1)
+	pending map[string]string // Control data of the first chunk
+	payload []byte            // Base64 received so far
+}
+
+// kittyGraphics handles an APC G command: control data, then an optional
+// payload after ';'.
+func (p *Parser) kittyGraphics(data []byte) {
+	if p.kitty == nil {
+		p.kitty = &kittyGraphics{images: map[uint32]*kittyImage{}, nextID: 1 << 31}
+	}
+	k := p.kitty
+	ctrl, payload, _ := bytes.Cut(data, []byte(";"))
+	params := imageParams(string(ctrl), ",")
+
+	// Chunks after the first carry only m= (and maybe q=) and more payload
+	if k.pending != nil {
+		k.payload = append(k.payload, payload...)
+		if len(k.payload) > maxImagePayload*4/3 {
+			k.pending, k.payload = nil, nil
+			return
+		}
+		if params["m"] == "1" {
+			return
+		}
+		params, payload = k.pending, k.payload
+		k.pending, k.payload = nil, nil
+	} else if params["m"] == "1" {
+		k.pending = params
+		k.payload = append([]byte(nil), payload...)
+		return
+	}
+
+	id, number := uint32(atoi(params["i"])), uint32(atoi(params["I"]))
+	var err error
+	switch params["a"] {
+	case "", "t", "T", "q":
+		id, err = p.kittyTransmit(params, payload, id, number)
+	case "p":
+		err = p.kittyPlace(params, id, number)
+	case "d":
+		p.kittyDelete(params, id, number)
+		return
+	default:
+		return
+	}
+	p.kittyReply(params, id, number, err)
+}
+
+// kittyTransmit decodes a transmitted image and stores it under id, or a
+// new ID when the program sent only a number. a=T places it as well; a=q
+// only checks it. Returns the image's ID.
+func (p *Parser) kittyTransmit(params map[string]string, payload []byte, id, number uint32) (uint32, error) {
+	pixels, err := decodeKittyImage(params, payload)
+	if err != nil || params["a"] == "q" {
+		return id, err
+	}
+	k := p.kitty
+	if id == 0 && number != 0 {
+		id = k.nextID
+		k.nextID++
+	}
+	img := &kittyImage{pixels: pixels, number: number}
+	if id != 0 {
+		k.add(id, img)
+	}
+	if params["a"] == "T" {
+		return id, p.kittyShow(img, params)
+	}
+	return id, nil
+}
+
+// kittyPlace shows a stored image, by ID or by number
+func (p *Parser) kittyPlace(params map[string]string, id, number uint32) error {
+	if id == 0 {
+		id = p.kitty.byNumber(number)
+	}
+	img, ok := p.kitty.images[id]
+	if !ok {
+		return errors.New("ENOENT:no such image")
+	}
+	return p.kittyShow(img, params)
+}
+
+// kittyShow places img at the cursor, cropped to the x, y, w, h source
+// rectangle and scaled to c columns and r rows when given. C=1 leaves the
+// cursor where it was.
+func (p *Parser) kittyShow(img *kittyImage, params map[string]string) error {
+	b := img.pixels.Bounds()
+	src := image.Rect(atoi(params["x"]), atoi(params["y"]), b.Dx(), b.Dy())
+	if w := atoi(params["w"]); w > 0 {
+		src.Max.X = src.Min.X + w
+	}
+	if h := atoi(params["h"]); h > 0 {
+		src.Max.Y = src.Min.Y + h
+	}
+	src = src.Add(b.Min).Intersect(b)
+	if src.Empty() {
+		return errors.New("EINVAL:source rectangle outside the image")
+	}
+	placed := p.addImage(img.pixels.SubImage(src), imageLayout{cols: atoi(params["c"]), rows: atoi(params["r"])})
+	if placed == nil {
+		return errors.New("EINVAL:image too large")
+	}
+	cursor := p.screen.cursor
+	p.placeImage(placed)
+	if params["C"] == "1" {
+		p.screen.cursor = cursor
+	}
+	img.placed = append(img.placed, placed.ID)
+	return nil
+}
+
+// kittyDelete handles a=d. d=a or none clears every image on screen, d=i
+// those of an image ID and d=n those of an image number; the upper case
+// forms free the image data as well.
+func (p *Parser) kittyDelete(params map[string]string, id, number uint32) {
+	k := p.kitty
+	d := params["d"]
+	switch d {
+	case "", "a", "A":
+		p.screen.clearImages(nil)
+		if d == "A" {
+			k.images, k.order, k.bytes = map[uint32]*kittyImage{}, nil, 0
+		}
+	case "i", "I", "n", "N":
+		if d == "n" || d == "N" {
+			id = k.byNumber(number)
+		}
+		img, ok := k.images[id]
+		if !ok {
+			return
+		}
+		ids := map[uint32]bool{}
+		for _, placed := range img.placed {
+			ids[placed] = true
+		}
+		p.screen.clearImages(ids)
+		img.placed = nil
+		if d == "I" || d == "N" {
+			k.remove(id)
+		}
+	}
+}
+
+// kittyReply answers a command that named an image with OK or an error.
+// q=1 suppresses OK and q=2 errors as well.
+func (p *Parser) kittyReply(params map[string]string, id, number uint32, err error) {
+	quiet := atoi(params["q"])
+	if id == 0 && number == 0 || err == nil && quiet >= 1 || quiet >= 2 {
+		return
+	}
+	msg := "OK"
+	if err != nil {
+		msg = err.Error()
+	}
+	keys := fmt.Sprintf("i=%d", id)
+	if number != 0 {
+		keys += fmt.Sprintf(",I=%d", number)
+	}
+	p.respond("\x1b_G%s;%s\x1b\\", keys, msg)
+}
+
+// add stores img under id, replacing any image with that ID and evicting
+// the oldest beyond maxImageMemory
+func (k *kittyGraphics) add(id uint32, img *kittyImage) {
+	k.remove(id)
+	k.images[id] = img
+	k.order = append(k.order, id)
+	k.bytes += len(img.pixels.Pix)
+	for k.bytes > maxImageMemory && len(k.order) > 1 {
+		k.remove(k.order[0])
+	}
+}
+
+// remove frees image id's data
+func (k *kittyGraphics) remove(id uint32) {
+	img, ok := k.images[id]
+	if !ok {
+		return
+	}
+	delete(k.images, id)
+	k.bytes -= len(img.pixels.Pix)
+	for i, o := range k.order {
+		if o == id {
+			k.order = append(k.order[:i], k.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// byNumber returns the ID of the newest image sent with number, or 0
+func (k *kittyGraphics) byNumber(number uint32) uint32 {
+	if number == 0 {
+		return 0
+	}
+	for i := len(k.order) - 1; i >= 0; i-- {
+		if k.images[k.order[i]].number == number {
+			return k.order[i]
+		}
+	}
+	return 0
+}
+
+// decodeKittyImage decodes a transmitted image: base64 data sent directly
+// (t=d), optionally zlib compressed (o=z), as 24 or 32 bit pixels of size
+// s x v (f=24, f=32) or as PNG (f=100)
+func decodeKittyImage(params map[string]string, payload []byte) (*image.NRGBA, error) {
+	if t := params["t"]; t != "" && t != "d" {
+		return nil, errors.New("EINVAL:only direct transmission is supported")
+	}
+	data, err := decodeBase64(string(payload))
+	if err != nil {
+		return nil, errors.New("EINVAL:bad base64 data")
+	}
+	if params["o"] == "z" {
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.New("EINVAL:bad zlib data")
+		}
+		data, err = io.ReadAll(io.LimitReader(r, maxImagePixels*4+1))
+		if err != nil {
+			return nil, errors.New("EINVAL:bad zlib data")
+		}
+		if len(data) > maxImagePixels*4 {
+			return nil, errors.New("EINVAL:image too large")
+		}
+	}
+
+	format := params["f"]
+	if format == "100" {
+		cfg, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.New("EINVAL:bad PNG data")
+		}
+		if err := checkImageSize(cfg.Width, cfg.Height); err != nil {
+			return nil, errors.New("EINVAL:" + err.Error())
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.New("EINVAL:bad PNG data")
+		}
+		return toNRGBA(img), nil
+	}
+
+	bpp := 4
+	switch format {
+	case "", "32":
+	case "24":
+		bpp = 3
+	default:
+		return nil, errors.New("EINVAL:unsupported format")
+	}
+	w, h := atoi(params["s"]), atoi(params["v"])
+	if err := checkImageSize(w, h); err != nil {
+		return nil, errors.New("EINVAL:" + err.Error())
+	}
+	if len(data) < w*h*bpp {
+		return nil, errors.New("ENODATA:insufficient image data")
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if bpp == 4 {
+		copy(img.Pix, data)
+	} else {
+		for i, j := 0, 0; i < len(img.Pix); i, j = i+4, j+3 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = data[j], data[j+1], data[j+2], 255
+		}
+	}
+	return img, nil
+}
+
+// decodeBase64 decodes base64 with or without padding
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
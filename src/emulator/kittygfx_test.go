@@ -0,0 +1,142 @@
+package emulator
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"image/color"
+	"testing"
+)
+
+// kittyRGB returns base64 of a w x h f=24 image filled with one color
+func kittyRGB(w, h int, c color.NRGBA) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{c.R, c.G, c.B}, w*h))
+}
+
+func TestKittyGraphicsReplies(t *testing.T) {
+	red := kittyRGB(2, 4, color.NRGBA{255, 0, 0, 255})
+	var zbuf bytes.Buffer
+	zw := zlib.NewWriter(&zbuf)
+	zw.Write(bytes.Repeat([]byte{0, 255, 0}, 8))
+	zw.Close()
+	green := base64.StdEncoding.EncodeToString(zbuf.Bytes())
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"transmit", "\x1b_Ga=t,f=24,s=2,v=4,i=7;" + red + "\x1b\\", []string{"\x1b_Gi=7;OK\x1b\\"}},
+		{"chunked", "\x1b_Ga=t,f=24,s=2,v=4,i=7,m=1;" + red[:8] + "\x1b\\\x1b_Gm=1;" + red[8:16] + "\x1b\\\x1b_Gm=0;" + red[16:] + "\x1b\\", []string{"\x1b_Gi=7;OK\x1b\\"}},
+		{"zlib", "\x1b_Ga=t,f=24,o=z,s=2,v=4,i=7;" + green + "\x1b\\", []string{"\x1b_Gi=7;OK\x1b\\"}},
+		{"number", "\x1b_Ga=t,f=24,s=2,v=4,I=3;" + red + "\x1b\\", []string{"\x1b_Gi=2147483648,I=3;OK\x1b\\"}},
+		{"no ID no reply", "\x1b_Ga=t,f=24,s=2,v=4;" + red + "\x1b\\", nil},
+		{"quiet", "\x1b_Ga=t,f=24,s=2,v=4,i=7,q=1;" + red + "\x1b\\", nil},
+		{"short data", "\x1b_Ga=t,f=24,s=4,v=4,i=7;" + red + "\x1b\\", []string{"\x1b_Gi=7;ENODATA:insufficient image data\x1b\\"}},
+		{"quiet errors", "\x1b_Ga=t,f=24,s=4,v=4,i=7,q=2;" + red + "\x1b\\", nil},
+		{"file medium", "\x1b_Ga=t,t=f,i=7;L3RtcC94\x1b\\", []string{"\x1b_Gi=7;EINVAL:only direct transmission is supported\x1b\\"}},
+		{"place missing", "\x1b_Ga=p,i=9\x1b\\", []string{"\x1b_Gi=9;ENOENT:no such image\x1b\\"}},
+		{"query", "\x1b_Ga=q,f=24,s=2,v=4,i=31;" + red + "\x1b\\", []string{"\x1b_Gi=31;OK\x1b\\"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(NewScreen(10, 4), NewScrollback())
+			var got []string
+			p.SetOnResponse(func(data []byte) { got = append(got, string(data)) })
+			p.Parse([]byte(tt.input))
+			if len(got) != len(tt.want) {
+				t.Fatalf("replies = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("reply %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+			if rowText(p.Screen(), 0) != "" {
+				t.Errorf("graphics command printed %q", rowText(p.Screen(), 0))
+			}
+		})
+	}
+}
+
+func TestKittyGraphicsPlacement(t *testing.T) {
+	p := NewParser(NewScreen(10, 4), NewScrollback())
+	p.SetCellPixelSize(2, 4)
+	s := p.Screen()
+	red := kittyRGB(4, 4, color.NRGBA{255, 0, 0, 255})
+
+	// Transmit and display: 4x4 pixels cover 2x1 cells
+	p.Parse([]byte("a\x1b_Ga=T,f=24,s=4,v=4,i=1;" + red + "\x1b\\b"))
+	if _, col, _, ok := s.Cell(2, 0).ImageTile(); !ok || col != 1 {
+		t.Errorf("cell (2,0) = %+v", s.Cell(2, 0))
+	}
+	if got := rowText(s, 0); got != "a  b" {
+		t.Errorf("row 0 = %q, want %q", got, "a  b")
+	}
+
+	// Place again, scaled to 3x2 cells, leaving the cursor alone
+	p.Parse([]byte("\r\n\x1b_Ga=p,i=1,c=3,r=2,C=1\x1b\\c"))
+	id, col, row, ok := s.Cell(2, 2).ImageTile()
+	if !ok || col != 2 || row != 1 {
+		t.Errorf("cell (2,2) = tile (%d,%d) %v", col, row, ok)
+	}
+	if img := s.Image(id); img == nil || img.Cols != 3 || img.Rows != 2 {
+		t.Errorf("placed image = %+v", img)
+	}
+	if got := rowText(s, 1); got[0] != 'c' {
+		t.Errorf("row 1 = %q, want the cursor left at its start", got)
+	}
+
+	// Deleting by ID clears both placements
+	p.Parse([]byte("\x1b_Ga=d,d=i,i=1\x1b\\"))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 10; x++ {
+			if _, _, _, ok := s.Cell(x, y).ImageTile(); ok {
+				t.Fatalf("cell (%d,%d) still shows an image", x, y)
+			}
+		}
+	}
+	if got := rowText(s, 0); got != "a  b" {
+		t.Errorf("row 0 after delete = %q", got)
+	}
+
+	// d=I frees the data too
+	p.Parse([]byte("\x1b_Ga=d,d=I,i=1\x1b\\"))
+	var reply string
+	p.SetOnResponse(func(data []byte) { reply = string(data) })
+	p.Parse([]byte("\x1b_Ga=p,i=1\x1b\\"))
+	if reply != "\x1b_Gi=1;ENOENT:no such image\x1b\\" {
+		t.Errorf("place after d=I = %q", reply)
+	}
+}
+
+func TestKittyGraphicsSourceRect(t *testing.T) {
+	p := NewParser(NewScreen(10, 4), NewScrollback())
+	p.SetCellPixelSize(2, 4)
+	data := make([]byte, 0, 4*4*3)
+	for i := 0; i < 4*4; i++ {
+		if i%4 < 2 {
+			data = append(data, 255, 0, 0)
+		} else {
+			data = append(data, 0, 0, 255)
+		}
+	}
+	p.Parse([]byte("\x1b_Ga=T,f=24,s=4,v=4,x=2,w=2;" + base64.StdEncoding.EncodeToString(data) + "\x1b\\"))
+
+	id, _, _, ok := p.Screen().Cell(0, 0).ImageTile()
+	img := p.Screen().Image(id)
+	if !ok || img == nil || img.Cols != 1 {
+		t.Fatalf("image = %+v", img)
+	}
+	if got := img.Pixels.NRGBAAt(0, 0); got != (color.NRGBA{0, 0, 255, 255}) {
+		t.Errorf("pixel = %v, want the blue right half", got)
+	}
+}
+
+func TestKittyGraphicsSkipsOtherStrings(t *testing.T) {
+	p := NewParser(NewScreen(10, 2), NewScrollback())
+	p.Parse([]byte("a\x1b_xyz\x1b\\b\x1bXsos\x1b\\c\x1b^pm\x1b\\d"))
+	if got := rowText(p.Screen(), 0); got != "abcd" {
+		t.Errorf("row = %q, want %q", got, "abcd")
+	}
+}
@@ -0,0 +1,184 @@
+package emulator
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LinkID names an OSC 8 hyperlink target in a session's LinkTable, so a
+// cell holds four bytes rather than a string. 0 is no link.
+type LinkID uint32
+
+// maxLinks bounds a link table. Past it new targets aren't interned and
+// their cells come out unlinked, though URL detection still finds them.
+const maxLinks = 1 << 20
+
+// LinkTable interns the hyperlink targets of one session: its scrollback
+// and both screens share it, so cells keep their IDs as they move between
+// them, and it goes when the session does. The disk formats store the
+// URIs themselves and intern them again when lines are read back.
+type LinkTable struct {
+	mu   sync.RWMutex
+	ids  map[string]LinkID
+	uris []string // uris[id-1] is the target of id
+}
+
+// intern returns the ID of uri, adding it to the table if it's new. The
+// empty URI is no link, and so is every URI for a nil table, which
+// readers that only want a line's text pass.
+func (t *LinkTable) intern(uri string) LinkID {
+	if uri == "" || t == nil {
+		return 0
+	}
+	t.mu.RLock()
+	id, ok := t.ids[uri]
+	t.mu.RUnlock()
+	if ok {
+		return id
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id, ok := t.ids[uri]; ok {
+		return id
+	}
+	if len(t.uris) >= maxLinks {
+		return 0
+	}
+	if t.ids == nil {
+		t.ids = map[string]LinkID{}
+	}
+	t.uris = append(t.uris, uri)
+	id = LinkID(len(t.uris))
+	t.ids[uri] = id
+	return id
+}
+
+// URI returns the target of id, or "" for no link
+func (t *LinkTable) URI(id LinkID) string {
+	if id == 0 || t == nil {
+		return ""
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if int(id) > len(t.uris) {
+		return ""
+	}
+	return t.uris[id-1]
+}
+
+// LinkKind tells how a link is opened
+type LinkKind uint8
+
+const (
+	LinkURL  LinkKind = iota // Opened in the browser
+	LinkFile                 // A file location, opened in the editor
+)
+
+// LinkSpan is a clickable link covering cells [Start, End) of a row
+type LinkSpan struct {
+	Kind       LinkKind
+	Start, End int
+	Target     string // URL, or the file path as printed (may be relative)
+	Line, Col  int    // File location, 0 when not given
+}
+
+var (
+	// urlPattern matches plain-text URLs up to whitespace or a quote
+	urlPattern = regexp.MustCompile(`\b(?:https?|ftp|file)://[^\s<>"'` + "`" + `]+`)
+
+	// fileLinePattern matches path/to/file.ext:line[:col] as printed by
+	// compilers, test runners and grep -n
+	fileLinePattern = regexp.MustCompile(`((?:~|\.{1,2})?/?(?:[\w.@+-]+/)*[\w@+-][\w.@+-]*\.[A-Za-z0-9]+):(\d+)(?::(\d+))?`)
+)
+
+// FindLinks returns the links in a row: OSC 8 hyperlinks, resolved in
+// the session's link table, then URLs and file:line locations detected in
+// its text that don't overlap them.
+func FindLinks(cells []Cell, table *LinkTable) []LinkSpan {
+	var links []LinkSpan
+	for x := 0; x < len(cells); {
+		id := cells[x].Link
+		if id == 0 {
+			x++
+			continue
+		}
+		start := x
+		for x < len(cells) && cells[x].Link == id {
+			x++
+		}
+		if uri := table.URI(id); uri != "" {
+			links = append(links, hyperlinkSpan(uri, start, x))
+		}
+	}
+
+	text, cols := lineText(cells)
+	free := func(start, end int) bool {
+		for _, l := range links {
+			if start < l.End && end > l.Start {
+				return false
+			}
+		}
+		return true
+	}
+	for _, loc := range urlPattern.FindAllStringIndex(text, -1) {
+		target := trimURL(text[loc[0]:loc[1]])
+		start, end := cols[loc[0]], cols[loc[0]+len(target)]
+		if free(start, end) {
+			links = append(links, LinkSpan{Kind: LinkURL, Start: start, End: end, Target: target})
+		}
+	}
+	for _, m := range fileLinePattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := cols[m[0]], cols[m[1]]
+		if !free(start, end) || m[0] > 0 && text[m[0]-1] == ':' {
+			continue // Inside a URL, or host:port-like text
+		}
+		link := LinkSpan{Kind: LinkFile, Start: start, End: end, Target: text[m[2]:m[3]]}
+		link.Line, _ = strconv.Atoi(text[m[4]:m[5]])
+		if m[6] >= 0 {
+			link.Col, _ = strconv.Atoi(text[m[6]:m[7]])
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// LinkAt returns the link covering column x of a row
+func LinkAt(cells []Cell, x int, table *LinkTable) (LinkSpan, bool) {
+	for _, l := range FindLinks(cells, table) {
+		if x >= l.Start && x < l.End {
+			return l, true
+		}
+	}
+	return LinkSpan{}, false
+}
+
+// hyperlinkSpan describes an OSC 8 link; file:// URIs open in the editor
+func hyperlinkSpan(uri string, start, end int) LinkSpan {
+	link := LinkSpan{Kind: LinkURL, Start: start, End: end, Target: uri}
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" && u.Path != "" {
+		link.Kind = LinkFile
+		link.Target = u.Path
+	}
+	return link
+}
+
+// trimURL drops trailing punctuation that ends the sentence rather than
+// the URL, keeping a closing parenthesis that balances one in the URL.
+func trimURL(s string) string {
+	for s != "" {
+		last := s[len(s)-1]
+		switch {
+		case strings.IndexByte(".,;:!?", last) >= 0:
+		case last == ')' && strings.Count(s, "(") < strings.Count(s, ")"):
+		case last == ']' && strings.Count(s, "[") < strings.Count(s, "]"):
+		default:
+			return s
+		}
+		s = s[:len(s)-1]
+	}
+	return s
+}
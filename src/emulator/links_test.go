@@ -0,0 +1,126 @@
+package emulator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func cellsOf(text string) []Cell {
+	var line []Cell
+	for _, r := range text {
+		line = append(line, Cell{Rune: r})
+	}
+	return line
+}
+
+func TestFindLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string // kind start-end target line:col
+	}{
+		{"url", "see https://example.com/a?b=1 now", []string{"url 4-29 https://example.com/a?b=1 0:0"}},
+		{"trailing punctuation", "docs (https://go.dev/doc).", []string{"url 6-24 https://go.dev/doc 0:0"}},
+		{"balanced parens", "https://en.wikipedia.org/wiki/Go_(language)", []string{"url 0-43 https://en.wikipedia.org/wiki/Go_(language) 0:0"}},
+		{"go test", "    widget_test.go:42: boom", []string{"file 4-21 widget_test.go 42:0"}},
+		{"gcc", "src/main.c:12:5: error: x", []string{"file 0-15 src/main.c 12:5"}},
+		{"relative and home", "../a/b.rs:3 ~/x.py:9", []string{"file 0-11 ../a/b.rs 3:0", "file 12-20 ~/x.py 9:0"}},
+		{"file inside url", "http://host/x.go:12", []string{"url 0-19 http://host/x.go:12 0:0"}},
+		{"no extension", "localhost:8080 and main:12", nil},
+		{"plain text", "nothing to see here", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, l := range FindLinks(cellsOf(tt.text), nil) {
+				kind := "url"
+				if l.Kind == LinkFile {
+					kind = "file"
+				}
+				got = append(got, fmt.Sprintf("%s %d-%d %s %d:%d", kind, l.Start, l.End, l.Target, l.Line, l.Col))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("FindLinks(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParserHyperlinks(t *testing.T) {
+	p := newTestParser()
+	p.Parse([]byte("a \x1b]8;id=1;https://example.com\x1b\\link\x1b]8;;\x1b\\ b"))
+	p.Parse([]byte("\r\n\x1b]8;;file:///tmp/x.go\x07日\x1b]8;;\x07"))
+	links := p.scrollback.Links()
+
+	line := p.screen.Line(0)
+	for x, want := range []string{"", "", "https://example.com", "https://example.com", "https://example.com", "https://example.com", "", ""} {
+		if got := links.URI(line[x].Link); got != want {
+			t.Errorf("cell %d link = %q, want %q", x, got, want)
+		}
+	}
+	if l, ok := LinkAt(line, 3, links); !ok || l.Kind != LinkURL || l.Start != 2 || l.End != 6 {
+		t.Errorf("LinkAt(3) = %+v, %v", l, ok)
+	}
+
+	// Both halves of a wide glyph carry the link; file:// opens in the editor
+	wide := p.screen.Line(1)
+	if got := links.URI(wide[1].Link); got != "file:///tmp/x.go" {
+		t.Errorf("continuation link = %q", got)
+	}
+	if l, ok := LinkAt(wide, 1, links); !ok || l.Kind != LinkFile || l.Target != "/tmp/x.go" {
+		t.Errorf("LinkAt(file) = %+v, %v", l, ok)
+	}
+}
+
+func TestInternLink(t *testing.T) {
+	var table LinkTable
+	a := table.intern("https://intern.example/a")
+	b := table.intern("https://intern.example/b")
+	if a == 0 || b == 0 || a == b {
+		t.Fatalf("IDs = %d, %d; want two distinct links", a, b)
+	}
+	if again := table.intern("https://intern.example/a"); again != a {
+		t.Errorf("interning again = %d, want %d", again, a)
+	}
+	if got := table.URI(a); got != "https://intern.example/a" {
+		t.Errorf("URI = %q", got)
+	}
+	if table.intern("") != 0 || table.URI(0) != "" {
+		t.Error("the empty URI should be no link")
+	}
+
+	// Each session has its own table, and a nil one links nothing
+	var other LinkTable
+	if got := other.URI(a); got != "" {
+		t.Errorf("another table resolved %d to %q", a, got)
+	}
+	var none *LinkTable
+	if none.intern("https://intern.example/a") != 0 || none.URI(a) != "" {
+		t.Error("a nil table should link nothing")
+	}
+}
+
+// TestLinkTablePerSession verifies sessions don't share link targets, so
+// a closed session's links go with it.
+func TestLinkTablePerSession(t *testing.T) {
+	p1, p2 := newTestParser(), newTestParser()
+	p1.Parse([]byte("\x1b]8;;https://one.example\x1b\\x\x1b]8;;\x1b\\"))
+	p2.Parse([]byte("\x1b]8;;https://two.example\x1b\\x\x1b]8;;\x1b\\"))
+	id1, id2 := p1.screen.Line(0)[0].Link, p2.screen.Line(0)[0].Link
+	if got := p1.scrollback.Links().URI(id1); got != "https://one.example" {
+		t.Errorf("session 1 link = %q", got)
+	}
+	if got := p2.scrollback.Links().URI(id2); got != "https://two.example" {
+		t.Errorf("session 2 link = %q", got)
+	}
+	if n := len(p1.scrollback.Links().uris); n != 1 {
+		t.Errorf("session 1 table holds %d targets, want 1", n)
+	}
+
+	// Lines scrolled into the scrollback keep resolving
+	p1.Parse([]byte(strings.Repeat("\r\n", 24)))
+	if l, ok := LinkAt(p1.scrollback.Line(0), 0, p1.scrollback.Links()); !ok || l.Target != "https://one.example" {
+		t.Errorf("scrolled off link = %+v, %v", l, ok)
+	}
+}
@@ -0,0 +1,97 @@
+package emulator
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MarkFlags are the shell integration (OSC 133) marks recorded on a row
+type MarkFlags uint8
+
+const (
+	MarkPrompt MarkFlags = 1 << iota // A: the prompt starts on this row
+	MarkInput                        // B: the prompt ends and command input starts
+	MarkOutput                       // C: the command's output starts
+	MarkEnd                          // D: the command finished (see Mark.ExitCode)
+)
+
+// Mark holds a row's shell integration marks. It lives on the row's first
+// cell, so it scrolls into the scrollback and persists with the line.
+type Mark struct {
+	Flags    MarkFlags
+	ExitCode uint8 // Exit status reported with MarkEnd
+}
+
+// Has reports whether all of flags are set
+func (m Mark) Has(flags MarkFlags) bool {
+	return m.Flags&flags == flags
+}
+
+// merge adds the marks of o, whose exit code wins if it ended a command
+func (m Mark) merge(o Mark) Mark {
+	if o.Has(MarkEnd) {
+		m.ExitCode = o.ExitCode
+	}
+	m.Flags |= o.Flags
+	return m
+}
+
+// LineMark returns the shell integration marks of a row of cells
+func LineMark(line []Cell) Mark {
+	if len(line) == 0 {
+		return Mark{}
+	}
+	return line[0].Mark
+}
+
+// ShellState is where the shell is in its prompt/command cycle, as told by
+// its OSC 133 marks.
+type ShellState uint8
+
+const (
+	ShellUnknown ShellState = iota // No marks seen: the shell doesn't emit them
+	ShellPrompt                    // At the prompt, waiting for a command
+	ShellRunning                   // A command is running
+)
+
+// AddMark records marks on the cursor's row
+func (s *Screen) AddMark(m Mark) {
+	y := s.cursor.Y
+	s.cells[y][0].Mark = s.cells[y][0].Mark.merge(m)
+	s.dirty[y] = true
+}
+
+// ShellState returns the shell's state from the marks seen so far
+func (p *Parser) ShellState() ShellState {
+	return p.shellState
+}
+
+// semanticPrompt handles the body of an OSC 133 mark: a letter, then
+// ";"-separated options (D carries the exit status first).
+func (p *Parser) semanticPrompt(body string) {
+	kind, opts, _ := strings.Cut(body, ";")
+	switch kind {
+	case "A":
+		p.screen.AddMark(Mark{Flags: MarkPrompt})
+		p.shellState = ShellPrompt
+	case "B":
+		p.screen.AddMark(Mark{Flags: MarkInput})
+		p.shellState = ShellPrompt
+	case "C":
+		p.screen.AddMark(Mark{Flags: MarkOutput})
+		p.shellState = ShellRunning
+	case "D":
+		status, _, _ := strings.Cut(opts, ";")
+		code, _ := strconv.Atoi(status)
+		p.screen.AddMark(Mark{Flags: MarkEnd, ExitCode: uint8(clamp(code, 0, 255))})
+		p.shellState = ShellPrompt
+	}
+}
+
+// iTermMark handles the OSC 1337 commands that mark the prompt. iTerm2's
+// SetMark marks the cursor's row for navigation like a prompt.
+func (p *Parser) iTermMark(body string) {
+	if body == "SetMark" {
+		p.screen.AddMark(Mark{Flags: MarkPrompt})
+	}
+}
@@ -0,0 +1,145 @@
+package emulator
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestParserMarks(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Mark // Marks of the first rows
+		state ShellState
+	}{
+		{"none", "$ ls\r\n", []Mark{{}, {}}, ShellUnknown},
+		{"prompt", "\x1b]133;A\x07$ \x1b]133;B\x07ls", []Mark{{Flags: MarkPrompt | MarkInput}}, ShellPrompt},
+		{
+			"command",
+			"\x1b]133;A\x07$ \x1b]133;B\x07false\r\n\x1b]133;C\x07oops",
+			[]Mark{{Flags: MarkPrompt | MarkInput}, {Flags: MarkOutput}},
+			ShellRunning,
+		},
+		{
+			"finished",
+			"$ false\r\n\x1b]133;C\x07oops\r\n\x1b]133;D;1\x1b\\\x1b]133;A;aid=7\x07$ ",
+			[]Mark{{}, {Flags: MarkOutput}, {Flags: MarkEnd | MarkPrompt, ExitCode: 1}},
+			ShellPrompt,
+		},
+		{"no exit code", "\x1b]133;D\x07$ ", []Mark{{Flags: MarkEnd}}, ShellPrompt},
+		{"exit code clamped", "\x1b]133;D;300\x07$ ", []Mark{{Flags: MarkEnd, ExitCode: 255}}, ShellPrompt},
+		{"iTerm2 SetMark", "\x1b]1337;SetMark\x07$ ", []Mark{{Flags: MarkPrompt}}, ShellUnknown},
+		{"marks go on the cursor row", "\x1b]133;A\x07\r\n$ ", []Mark{{Flags: MarkPrompt}, {}}, ShellPrompt},
+		{"mark on a blank row", "$ ls\r\n\x1b]133;D;0\x07", []Mark{{}, {Flags: MarkEnd}}, ShellPrompt},
+		{"unknown kind", "\x1b]133;Z\x07$ ", []Mark{{}}, ShellUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser()
+			p.Parse([]byte(tt.input))
+			var got []Mark
+			for y := range tt.want {
+				got = append(got, p.screen.Cell(0, y).Mark)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("marks = %+v, want %+v", got, tt.want)
+			}
+			if s := p.ShellState(); s != tt.state {
+				t.Errorf("ShellState = %d, want %d", s, tt.state)
+			}
+		})
+	}
+}
+
+// TestShellStateReset verifies what the shell said last is forgotten when
+// the terminal is reset or a full-screen program takes over.
+func TestShellStateReset(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		state ShellState
+	}{
+		{"RIS", "\x1b]133;A\x07$ \x1bc", ShellUnknown},
+		{"alt screen", "\x1b]133;C\x07\x1b[?1049h", ShellUnknown},
+		{"marks after the alt screen", "\x1b[?1049h\x1b[?1049l\x1b]133;D;0\x07", ShellPrompt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser()
+			p.Parse([]byte(tt.input))
+			if s := p.ShellState(); s != tt.state {
+				t.Errorf("ShellState = %d, want %d", s, tt.state)
+			}
+		})
+	}
+
+	// A restored session hasn't heard from its shell yet
+	p := newTestParser()
+	p.Parse([]byte("\x1b]133;A\x07$ "))
+	data, err := p.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	q := newTestParser()
+	if err := q.Restore(data); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if s := q.ShellState(); s != ShellUnknown {
+		t.Errorf("restored ShellState = %d", s)
+	}
+	if !q.Screen().Cell(0, 0).Mark.Has(MarkPrompt) {
+		t.Error("restored screen lost its mark")
+	}
+}
+
+// TestMarksSurviveRedraw verifies a row keeps its marks while the shell
+// redraws the prompt over it, and loses them when the screen is cleared.
+func TestMarksSurviveRedraw(t *testing.T) {
+	p := newTestParser()
+	p.Parse([]byte("\x1b]133;A\x07$ ls"))
+	for _, redraw := range []string{"\r\x1b[K$ ", "\r\x1b[2K$ ls -l", "\r\x1b[1K", "\r\x1b[2P", "\r\x1b[3@", "\r\x1b[4X", "\r漢字"} {
+		p.Parse([]byte(redraw))
+		if !p.screen.Cell(0, 0).Mark.Has(MarkPrompt) {
+			t.Fatalf("mark lost after %q", redraw)
+		}
+	}
+	p.Parse([]byte("\x1b[2J"))
+	if m := p.screen.Cell(0, 0).Mark; m != (Mark{}) {
+		t.Errorf("mark after clear = %+v", m)
+	}
+}
+
+// TestMarksScrollback verifies marks scroll into the scrollback, survive
+// the disk round trip (even on an otherwise blank line) and a reflow.
+func TestMarksScrollback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.scrollback")
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	p := NewParser(NewScreen(10, 2), sb)
+	p.Parse([]byte("\x1b]133;A\x07$ make all -j\r\n\x1b]133;C\x07ok\r\n\x1b]133;D;2\x07 \r\x1b[K\r\n\r\n"))
+	sb.Close()
+
+	sb, err = NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer sb.Close()
+	want := []Mark{{Flags: MarkPrompt}, {}, {Flags: MarkOutput}, {Flags: MarkEnd, ExitCode: 2}}
+	for i, w := range want {
+		if got := LineMark(sb.Line(i)); got != w {
+			t.Errorf("line %d mark = %+v, want %+v", i, got, w)
+		}
+	}
+
+	// The prompt row wrapped; after a reflow the mark is on its first row only
+	sb.Reflow(4)
+	if got := LineMark(sb.Line(0)); got != want[0] {
+		t.Errorf("reflowed line 0 mark = %+v", got)
+	}
+	if got := LineMark(sb.Line(1)); got != (Mark{}) {
+		t.Errorf("reflowed line 1 mark = %+v", got)
+	}
+}
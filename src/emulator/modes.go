@@ -0,0 +1,147 @@
+package emulator
+
+// horizontalMargins returns the left and right margins that apply at the
+// cursor: DECSLRM's when it's between them, otherwise the screen edges.
+func (s *Screen) horizontalMargins() (left, right int) {
+	x := min(s.cursor.X, s.cols-1)
+	if s.lrMargins && x >= s.marginLeft && x <= s.marginRight {
+		return s.marginLeft, s.marginRight
+	}
+	return 0, s.cols - 1
+}
+
+// partialWidth reports whether left/right margins narrower than the screen
+// are in effect, so scrolling moves only part of each row.
+func (s *Screen) partialWidth() bool {
+	return s.lrMargins && (s.marginLeft > 0 || s.marginRight < s.cols-1)
+}
+
+// outsideMargins reports whether the cursor is left or right of the
+// margins, where line and character insertion and deletion do nothing.
+func (s *Screen) outsideMargins() bool {
+	return s.lrMargins && (s.cursor.X < s.marginLeft || s.cursor.X > s.marginRight)
+}
+
+// SetOriginMode sets DECOM, which makes cursor addressing relative to the
+// margins and confines the cursor to them, and homes the cursor.
+func (s *Screen) SetOriginMode(on bool) {
+	s.originMode = on
+	s.MoveTo(0, 0)
+}
+
+// SetAutoWrap sets DECAWM: when off, writing at the right margin
+// overwrites the last column instead of wrapping.
+func (s *Screen) SetAutoWrap(on bool) {
+	s.autoWrap = on
+	s.marginWrap = false
+}
+
+// SetInsertMode sets IRM: written characters push the rest of the line
+// right instead of overwriting it.
+func (s *Screen) SetInsertMode(on bool) {
+	s.insertMode = on
+}
+
+// SetLeftRightMarginMode sets DECLRMM, enabling DECSLRM margins. Turning
+// it off restores full-width margins.
+func (s *Screen) SetLeftRightMarginMode(on bool) {
+	s.lrMargins = on
+	s.marginLeft, s.marginRight = 0, s.cols-1
+}
+
+// SetLeftRightMargins sets the left and right margins (DECSLRM, 0-based
+// and inclusive) and homes the cursor. Ignored unless DECLRMM is on or if
+// the margins are less than two columns apart.
+func (s *Screen) SetLeftRightMargins(left, right int) {
+	left = clamp(left, 0, s.cols-1)
+	right = clamp(right, 0, s.cols-1)
+	if !s.lrMargins || left >= right {
+		return
+	}
+	s.marginLeft, s.marginRight = left, right
+	s.MoveTo(0, 0)
+}
+
+// MoveTo moves the cursor to column x, row y (0-based). In origin mode
+// they count from the top-left margin and the cursor stays within the
+// margins (CUP, HVP, VPA, CHA).
+func (s *Screen) MoveTo(x, y int) {
+	top, bottom, left, right := 0, s.rows-1, 0, s.cols-1
+	if s.originMode {
+		top, bottom = s.scrollTop, s.scrollBot
+		if s.lrMargins {
+			left, right = s.marginLeft, s.marginRight
+		}
+		x += left
+		y += top
+	}
+	s.cursor.X = clamp(x, left, right)
+	s.cursor.Y = clamp(y, top, bottom)
+	s.marginWrap = false
+}
+
+// OriginCursor returns the cursor position as MoveTo takes it (and CPR
+// reports it): relative to the top-left margin in origin mode. A pending
+// wrap reports the last column.
+func (s *Screen) OriginCursor() (x, y int) {
+	x, y = min(s.cursor.X, s.cols-1), s.cursor.Y
+	if s.originMode {
+		y -= s.scrollTop
+		if s.lrMargins {
+			x -= s.marginLeft
+		}
+	}
+	return x, y
+}
+
+// CarriageReturn moves the cursor to the left margin, or to the first
+// column from left of the margins.
+func (s *Screen) CarriageReturn() {
+	if s.lrMargins && (s.cursor.X >= s.marginLeft || s.originMode) {
+		s.cursor.X = s.marginLeft
+	} else {
+		s.cursor.X = 0
+	}
+	s.marginWrap = false
+}
+
+// AlignmentTest fills the screen with 'E', resets the margins and homes
+// the cursor (DECALN).
+func (s *Screen) AlignmentTest() {
+	for y := range s.cells {
+		for x := range s.cells[y] {
+			s.cells[y][x] = DefaultCell()
+			s.cells[y][x].Rune = 'E'
+		}
+		s.dirty[y] = true
+	}
+	s.scrollTop, s.scrollBot = 0, s.rows-1
+	s.marginLeft, s.marginRight = 0, s.cols-1
+	s.cursor.X, s.cursor.Y = 0, 0
+	s.marginWrap = false
+}
+
+// SoftReset restores the modes DECSTR resets, leaving the screen contents
+// and cursor position alone: insert and origin modes off, autowrap on, full
+// margins, ASCII charsets, default attributes and a visible cursor.
+func (s *Screen) SoftReset() {
+	s.insertMode = false
+	s.originMode = false
+	s.autoWrap = true
+	s.marginWrap = false
+	s.scrollTop, s.scrollBot = 0, s.rows-1
+	s.marginLeft, s.marginRight = 0, s.cols-1
+	s.charsets = [4]Charset{}
+	s.gl = 0
+	s.attrs = DefaultCell()
+	s.cursor.Visible = true
+	s.saved = savedCursor{}
+}
+
+// fitModes resets the margins and fits the tab stops after a size change
+func (s *Screen) fitModes() {
+	s.scrollTop, s.scrollBot = 0, s.rows-1
+	s.marginLeft, s.marginRight = 0, s.cols-1
+	s.marginWrap = false
+	s.resizeTabStops(s.cols)
+}
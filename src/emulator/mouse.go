@@ -0,0 +1,140 @@
+package emulator
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// MouseMode is the xterm mouse tracking mode requested by the application
+type MouseMode uint8
+
+const (
+	MouseNone        MouseMode = iota // No reporting - pointer is local selection
+	MouseX10                          // ?9: button presses only
+	MouseNormal                       // ?1000: presses, releases and wheel
+	MouseButtonEvent                  // ?1002: plus motion while a button is held
+	MouseAnyEvent                     // ?1003: plus all motion
+)
+
+// MouseEncoding is how mouse reports are serialized
+type MouseEncoding uint8
+
+const (
+	MouseEncodingDefault MouseEncoding = iota // CSI M Cb Cx Cy, one byte per value
+	MouseEncodingUTF8                         // ?1005: values as UTF-8 code points
+	MouseEncodingSGR                          // ?1006: CSI < Cb ; x ; y M/m
+	MouseEncodingURXVT                        // ?1015: CSI Cb ; x ; y M
+)
+
+// MouseButton identifies the button in a mouse event
+type MouseButton uint8
+
+const (
+	MouseButtonLeft MouseButton = iota
+	MouseButtonMiddle
+	MouseButtonRight
+	MouseButtonNone // Motion with no button held
+	MouseWheelUp
+	MouseWheelDown
+)
+
+// MouseEventKind distinguishes presses, releases and motion
+type MouseEventKind uint8
+
+const (
+	MousePress MouseEventKind = iota
+	MouseRelease
+	MouseMotion
+)
+
+// MouseEvent is a pointer event in cell coordinates (0-based)
+type MouseEvent struct {
+	Kind   MouseEventKind
+	Button MouseButton
+	X, Y   int
+	Shift  bool
+	Alt    bool
+	Ctrl   bool
+}
+
+// EncodeMouse returns the bytes reporting ev to the application, or nil
+// if the mode doesn't report this kind of event or the position can't be
+// represented in the encoding.
+func EncodeMouse(mode MouseMode, enc MouseEncoding, ev MouseEvent) []byte {
+	wheel := ev.Button == MouseWheelUp || ev.Button == MouseWheelDown
+	switch mode {
+	case MouseNone:
+		return nil
+	case MouseX10:
+		if ev.Kind != MousePress || wheel {
+			return nil
+		}
+	case MouseNormal:
+		if ev.Kind == MouseMotion {
+			return nil
+		}
+	case MouseButtonEvent:
+		if ev.Kind == MouseMotion && ev.Button == MouseButtonNone {
+			return nil
+		}
+	}
+	if wheel && ev.Kind == MouseRelease {
+		// Wheel "buttons" have no release
+		return nil
+	}
+
+	var cb int
+	switch ev.Button {
+	case MouseWheelUp:
+		cb = 64
+	case MouseWheelDown:
+		cb = 65
+	default:
+		cb = int(ev.Button)
+	}
+	// Legacy encodings can't say which button was released
+	if ev.Kind == MouseRelease && enc != MouseEncodingSGR {
+		cb = 3
+	}
+	if mode != MouseX10 {
+		if ev.Shift {
+			cb |= 4
+		}
+		if ev.Alt {
+			cb |= 8
+		}
+		if ev.Ctrl {
+			cb |= 16
+		}
+	}
+	if ev.Kind == MouseMotion {
+		cb |= 32
+	}
+
+	x, y := ev.X+1, ev.Y+1
+	switch enc {
+	case MouseEncodingSGR:
+		final := 'M'
+		if ev.Kind == MouseRelease {
+			final = 'm'
+		}
+		return fmt.Appendf(nil, "\x1b[<%d;%d;%d%c", cb, x, y, final)
+	case MouseEncodingURXVT:
+		return fmt.Appendf(nil, "\x1b[%d;%d;%dM", cb+32, x, y)
+	case MouseEncodingUTF8:
+		const maxUTF8 = 2047 - 32
+		if x > maxUTF8 || y > maxUTF8 {
+			return nil
+		}
+		buf := []byte{0x1b, '[', 'M', byte(cb + 32)}
+		buf = utf8.AppendRune(buf, rune(x+32))
+		buf = utf8.AppendRune(buf, rune(y+32))
+		return buf
+	default:
+		const maxByte = 255 - 32
+		if x > maxByte || y > maxByte {
+			return nil
+		}
+		return []byte{0x1b, '[', 'M', byte(cb + 32), byte(x + 32), byte(y + 32)}
+	}
+}
@@ -0,0 +1,73 @@
+package emulator
+
+import "testing"
+
+func TestEncodeMouse(t *testing.T) {
+	press := MouseEvent{Kind: MousePress, Button: MouseButtonLeft, X: 4, Y: 9}
+	release := MouseEvent{Kind: MouseRelease, Button: MouseButtonRight, X: 4, Y: 9}
+	drag := MouseEvent{Kind: MouseMotion, Button: MouseButtonLeft, X: 5, Y: 9}
+	hover := MouseEvent{Kind: MouseMotion, Button: MouseButtonNone, X: 5, Y: 9}
+	wheel := MouseEvent{Kind: MousePress, Button: MouseWheelUp, X: 0, Y: 0}
+	ctrlPress := MouseEvent{Kind: MousePress, Button: MouseButtonMiddle, X: 0, Y: 0, Ctrl: true, Alt: true}
+	far := MouseEvent{Kind: MousePress, Button: MouseButtonLeft, X: 300, Y: 0}
+
+	tests := []struct {
+		name string
+		mode MouseMode
+		enc  MouseEncoding
+		ev   MouseEvent
+		want string
+	}{
+		{"none", MouseNone, MouseEncodingSGR, press, ""},
+		{"x10 press", MouseX10, MouseEncodingDefault, press, "\x1b[M %*"},
+		{"x10 release ignored", MouseX10, MouseEncodingDefault, release, ""},
+		{"x10 drops modifiers", MouseX10, MouseEncodingDefault, ctrlPress, "\x1b[M!!!"},
+		{"normal release", MouseNormal, MouseEncodingDefault, release, "\x1b[M#%*"},
+		{"normal drag ignored", MouseNormal, MouseEncodingDefault, drag, ""},
+		{"normal wheel", MouseNormal, MouseEncodingDefault, wheel, "\x1b[M`!!"},
+		{"normal modifiers", MouseNormal, MouseEncodingDefault, ctrlPress, "\x1b[M9!!"},
+		{"button-event drag", MouseButtonEvent, MouseEncodingDefault, drag, "\x1b[M@&*"},
+		{"button-event hover ignored", MouseButtonEvent, MouseEncodingDefault, hover, ""},
+		{"any-event hover", MouseAnyEvent, MouseEncodingSGR, hover, "\x1b[<35;6;10M"},
+		{"sgr press", MouseNormal, MouseEncodingSGR, press, "\x1b[<0;5;10M"},
+		{"sgr release keeps button", MouseNormal, MouseEncodingSGR, release, "\x1b[<2;5;10m"},
+		{"sgr far column", MouseNormal, MouseEncodingSGR, far, "\x1b[<0;301;1M"},
+		{"default far column dropped", MouseNormal, MouseEncodingDefault, far, ""},
+		{"utf8 far column", MouseNormal, MouseEncodingUTF8, far, "\x1b[M ō!"},
+		{"urxvt press", MouseNormal, MouseEncodingURXVT, press, "\x1b[32;5;10M"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(EncodeMouse(tt.mode, tt.enc, tt.ev))
+			if got != tt.want {
+				t.Errorf("EncodeMouse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParserMouseModes(t *testing.T) {
+	p := newTestParser()
+
+	p.Parse([]byte("\x1b[?1002h\x1b[?1006h"))
+	if p.MouseMode() != MouseButtonEvent || p.MouseEncoding() != MouseEncodingSGR {
+		t.Fatalf("mode/encoding = %d/%d, want button-event/SGR", p.MouseMode(), p.MouseEncoding())
+	}
+
+	// Resetting a mode that isn't active leaves the active one alone
+	p.Parse([]byte("\x1b[?1000l"))
+	if p.MouseMode() != MouseButtonEvent {
+		t.Errorf("MouseMode() = %d after ?1000l, want button-event", p.MouseMode())
+	}
+
+	p.Parse([]byte("\x1b[?1002l\x1b[?1006l"))
+	if p.MouseMode() != MouseNone || p.MouseEncoding() != MouseEncodingDefault {
+		t.Errorf("mode/encoding = %d/%d after reset, want none/default", p.MouseMode(), p.MouseEncoding())
+	}
+
+	// RIS clears mouse tracking
+	p.Parse([]byte("\x1b[?1003h\x1bc"))
+	if p.MouseMode() != MouseNone {
+		t.Errorf("MouseMode() = %d after RIS, want none", p.MouseMode())
+	}
+}
@@ -7,8 +7,9 @@ import (
 )
 
 const (
-	maxIntermediateLen = 64        // Cap intermediate string at 64 bytes
-	maxOSCStringLen    = 64 * 1024 // Cap OSC string at 64KB
+	maxIntermediateLen = 64              // Cap intermediate string at 64 bytes
+	maxOSCStringLen    = 4 * 1024 * 1024 // Cap OSC string at 4MB (OSC 52 carries base64 clipboard text)
+	maxTitleStack      = 10              // Titles kept by XTWINOPS 22, as in xterm
 )
 
 // ParserState represents the parser state machine state
@@ -25,37 +26,75 @@ const (
 	StateOSCString
 	StateDCS
 	StateDCSString
+	StateAPCString // APC, SOS or PM string
 )
 
 // Parser is an ANSI/xterm escape sequence parser
 type Parser struct {
 	state        ParserState
-	screen       *Screen
+	screen       *Screen // Active screen: main or alt
+	main         *Screen // Main screen, whose lines scroll into the scrollback
+	alt          *Screen // Alternate screen, created on first use
 	scrollback   *Scrollback
 	altScreen    bool // true when on alternate screen buffer
 	params       []int
+	subParam     []bool // subParam[i]: params[i] followed a colon, a sub-parameter of the one before
 	intermediate string
 	oscString    strings.Builder
+	dcsFinal     byte           // Final byte of the DCS being collected
+	strKind      byte           // Introducer of the APC, SOS or PM string being collected
+	strData      []byte         // DCS or APC payload
+	strDrop      bool           // Payload went over maxImagePayload and is discarded
+	kitty        *kittyGraphics // Kitty graphics images, created on first use
+	sync         syncState      // Synchronized update holding output back (DECSET 2026)
 	title        string
+	titleStack   []string // Titles saved by XTWINOPS 22
 	onTitle      func(string)
 	utf8Buf      [4]byte // Buffer for UTF-8 multi-byte sequences
 	utf8Len      int     // Current bytes in utf8Buf
 	utf8Need     int     // Total bytes needed for current sequence
 
-	// DECSC/DECRC saved cursor state
-	savedCursor Cursor
-	savedAttrs  Cell
-	hasSaved    bool
+	// Mouse reporting requested by the application
+	mouseMode     MouseMode
+	mouseEncoding MouseEncoding
+
+	bracketedPaste bool // DECSET 2004: wrap pastes in ESC[200~ / ESC[201~
+
+	appCursorKeys bool // DECCKM: cursor keys send SS3 sequences
+	appKeypad     bool // DECKPAM: keypad sends application sequences
+
+	keyboard        [2]keyboardFlags // Kitty keyboard enhancements of the main and alternate screens
+	modifyOtherKeys int              // xterm modifyOtherKeys level (CSI > 4 ; level m)
+
+	shellState ShellState // Prompt/command cycle from OSC 133 marks
+	lastRune   rune       // Last printed character, repeated by REP
+
+	// Working directory reported by the shell (OSC 7)
+	cwdHost string
+	cwdPath string
+	onCWD   func(host, path string)
+
+	// Replies to terminal queries (DSR, DA, DECRQM, ...)
+	onResponse  func([]byte)
+	onClipboard func(ClipboardRequest) // OSC 52 clipboard set/query
+	replaying   bool                   // Suppress replies while replaying a log
+	cellPixelW  int                    // Cell size reported to CSI 14/16 t
+	cellPixelH  int
 }
 
 // NewParser creates a new parser connected to a screen and scrollback
 func NewParser(screen *Screen, scrollback *Scrollback) *Parser {
-	return &Parser{
+	p := &Parser{
 		state:      StateGround,
 		screen:     screen,
+		main:       screen,
 		scrollback: scrollback,
 		params:     make([]int, 0, 16),
+		subParam:   make([]bool, 0, 16),
 	}
+	screen.images = scrollback.Images()
+	screen.links = scrollback.Links()
+	return p
 }
 
 // SetOnTitle sets the callback for window title changes
@@ -68,7 +107,28 @@ func (p *Parser) Title() string {
 	return p.title
 }
 
-// Screen returns the parser's screen
+// pushTitle saves the window title on the title stack (XTWINOPS 22)
+func (p *Parser) pushTitle() {
+	if len(p.titleStack) >= maxTitleStack {
+		p.titleStack = p.titleStack[1:]
+	}
+	p.titleStack = append(p.titleStack, p.title)
+}
+
+// popTitle restores the most recently saved window title (XTWINOPS 23)
+func (p *Parser) popTitle() {
+	n := len(p.titleStack)
+	if n == 0 {
+		return
+	}
+	p.title = p.titleStack[n-1]
+	p.titleStack = p.titleStack[:n-1]
+	if p.onTitle != nil {
+		p.onTitle(p.title)
+	}
+}
+
+// Screen returns the parser's active screen
 func (p *Parser) Screen() *Screen {
 	return p.screen
 }
@@ -78,15 +138,62 @@ func (p *Parser) Scrollback() *Scrollback {
 	return p.scrollback
 }
 
-// Resize resizes the current screen.
+// MouseMode returns the active mouse tracking mode
+func (p *Parser) MouseMode() MouseMode {
+	return p.mouseMode
+}
+
+// MouseEncoding returns the active mouse report encoding
+func (p *Parser) MouseEncoding() MouseEncoding {
+	return p.mouseEncoding
+}
+
+// BracketedPaste returns true when the application has enabled bracketed paste
+func (p *Parser) BracketedPaste() bool {
+	return p.bracketedPaste
+}
+
+// AppCursorKeys returns true when DECCKM (application cursor keys) is set
+func (p *Parser) AppCursorKeys() bool {
+	return p.appCursorKeys
+}
+
+// AppKeypad returns true when DECKPAM (application keypad) is set
+func (p *Parser) AppKeypad() bool {
+	return p.appKeypad
+}
+
+// Resize resizes both screens. A width change re-wraps soft-wrapped lines
+// in the main screen and the in-memory scrollback; the alternate screen is
+// cropped since its app redraws.
 func (p *Parser) Resize(cols, rows int) {
-	p.screen.Resize(cols, rows)
+	if p.alt != nil {
+		p.alt.Resize(cols, rows)
+	}
+	oldCols, _ := p.main.Size()
+	if cols == oldCols {
+		p.main.Resize(cols, rows)
+		return
+	}
+	p.scrollback.Reflow(cols)
+	for _, line := range p.main.Reflow(cols, rows) {
+		p.scrollback.Push(line)
+	}
 }
 
 // Parse processes a byte slice through the parser
 func (p *Parser) Parse(data []byte) {
-	for _, b := range data {
-		p.parseByte(b)
+	for len(data) > 0 {
+		if p.sync.on {
+			data = p.holdSync(data)
+			continue
+		}
+		i := 0
+		for i < len(data) && !p.sync.on {
+			p.parseByte(data[i])
+			i++
+		}
+		data = data[i:]
 	}
 }
 
@@ -108,6 +215,12 @@ func (p *Parser) parseByte(b byte) {
 		p.parseOSC(b)
 	case StateOSCString:
 		p.parseOSCString(b)
+	case StateDCS:
+		p.parseDCS(b)
+	case StateDCSString:
+		p.parseDCSString(b)
+	case StateAPCString:
+		p.parseAPCString(b)
 	default:
 		p.state = StateGround
 		p.parseGround(b)
@@ -124,7 +237,7 @@ func (p *Parser) parseGround(b byte) {
 				// Complete sequence - decode and write
 				r, _ := utf8.DecodeRune(p.utf8Buf[:p.utf8Len])
 				if r != utf8.RuneError {
-					p.screen.Write(r)
+					p.print(r)
 				}
 				p.utf8Need = 0
 				p.utf8Len = 0
@@ -148,21 +261,21 @@ func (p *Parser) parseGroundByte(b byte) {
 	case b == 0x07: // BEL
 		// Bell - ignore for now
 	case b == 0x08: // BS
-		if p.screen.cursor.X > 0 {
+		if left, _ := p.screen.horizontalMargins(); p.screen.cursor.X > left {
 			p.screen.cursor.X--
 		}
 	case b == 0x09: // HT (tab)
-		p.screen.cursor.X = (p.screen.cursor.X + 8) &^ 7
-		cols, _ := p.screen.Size()
-		if p.screen.cursor.X >= cols {
-			p.screen.cursor.X = cols - 1
-		}
+		p.screen.Tab(1)
 	case b == 0x0a, b == 0x0b, b == 0x0c: // LF, VT, FF
 		p.lineFeed()
 	case b == 0x0d: // CR
-		p.screen.cursor.X = 0
+		p.screen.CarriageReturn()
+	case b == 0x0e: // SO - Shift Out: G1 into GL
+		p.screen.InvokeCharset(1)
+	case b == 0x0f: // SI - Shift In: G0 into GL
+		p.screen.InvokeCharset(0)
 	case b >= 0x20 && b < 0x7f: // Printable ASCII
-		p.screen.Write(rune(b))
+		p.print(rune(b))
 	case b >= 0xC0 && b < 0xE0: // 2-byte UTF-8 start
 		p.utf8Buf[0] = b
 		p.utf8Len = 1
@@ -178,45 +291,90 @@ func (p *Parser) parseGroundByte(b byte) {
 	}
 }
 
+// print writes a printable character, remembering it for REP
+func (p *Parser) print(r rune) {
+	p.lastRune = r
+	p.pushScrolled(p.screen.Write(r))
+}
+
 func (p *Parser) lineFeed() {
-	scrollTop, scrollBot := p.screen.ScrollRegion()
-	_, rows := p.screen.Size()
+	_, scrollBot := p.screen.ScrollRegion()
 	if p.screen.cursor.Y >= scrollBot {
-		scrolled := p.screen.ScrollUp(1)
-		// Only push to scrollback when the scroll region is the full screen.
-		// Sub-region scrolls (e.g., tmux pane) discard the scrolled-off lines.
-		if scrollTop == 0 && scrollBot == rows-1 {
-			for _, line := range scrolled {
-				p.scrollback.Push(line)
-			}
-		}
+		p.pushScrolled(p.screen.ScrollUp(1))
 	} else {
 		p.screen.cursor.Y++
 	}
 }
 
+// pushScrolled saves lines scrolled off the top into the scrollback.
+// Only full-screen scrolls of the main screen are kept: sub-region scrolls
+// (e.g., tmux pane) and the alternate screen discard the scrolled-off lines.
+func (p *Parser) pushScrolled(lines [][]Cell) {
+	scrollTop, scrollBot := p.screen.ScrollRegion()
+	_, rows := p.screen.Size()
+	if p.altScreen || scrollTop != 0 || scrollBot != rows-1 {
+		return
+	}
+	for _, line := range lines {
+		p.scrollback.Push(line)
+	}
+}
+
+// reset returns the terminal to its initial state (RIS): main screen
+// cleared, default modes, margins and tab stops. The title is kept.
+func (p *Parser) reset() {
+	p.useAltScreen(false)
+	p.alt = nil
+	p.screen.Clear()
+	p.screen.SoftReset()
+	p.screen.SetLeftRightMarginMode(false)
+	p.screen.resetTabStops()
+	p.titleStack = nil
+	p.lastRune = 0
+	p.screen.ResetAttrs()
+	p.screen.SetLink("")
+	p.mouseMode = MouseNone
+	p.mouseEncoding = MouseEncodingDefault
+	p.bracketedPaste = false
+	p.appCursorKeys = false
+	p.appKeypad = false
+	p.keyboard = [2]keyboardFlags{}
+	p.modifyOtherKeys = 0
+	p.kitty = nil
+	p.shellState = ShellUnknown
+	p.sync = syncState{}
+}
+
 func (p *Parser) parseEscape(b byte) {
 	switch {
 	case b == '[': // CSI
 		p.state = StateCSI
 		p.params = p.params[:0]
+		p.subParam = p.subParam[:0]
 		p.intermediate = ""
 	case b == ']': // OSC
 		p.state = StateOSC
 		p.oscString.Reset()
 	case b == 'P': // DCS
 		p.state = StateDCS
+		p.params = p.params[:0]
+		p.subParam = p.subParam[:0]
+		p.intermediate = ""
+	case b == '_', b == 'X', b == '^': // APC, SOS, PM
+		p.state = StateAPCString
+		p.strKind = b
+		p.strData = p.strData[:0]
+		p.strDrop = false
 	case b == '\\': // ST
 		p.state = StateGround
 	case b == 'c': // RIS - Reset
-		p.screen.Clear()
-		p.screen.ResetAttrs()
+		p.reset()
 		p.state = StateGround
 	case b == 'D': // IND - Index (line feed)
 		p.lineFeed()
 		p.state = StateGround
 	case b == 'E': // NEL - Next Line
-		p.screen.cursor.X = 0
+		p.screen.CarriageReturn()
 		p.lineFeed()
 		p.state = StateGround
 	case b == 'M': // RI - Reverse Index
@@ -228,19 +386,25 @@ func (p *Parser) parseEscape(b byte) {
 		}
 		p.state = StateGround
 	case b == '7': // DECSC - Save cursor position and attributes
-		p.savedCursor = p.screen.cursor
-		p.savedAttrs = p.screen.attrs
-		p.hasSaved = true
+		p.screen.SaveCursor()
 		p.state = StateGround
 	case b == '8': // DECRC - Restore cursor position and attributes
-		if p.hasSaved {
-			p.screen.cursor = p.savedCursor
-			p.screen.attrs = p.savedAttrs
-		}
+		p.screen.RestoreCursor()
+		p.state = StateGround
+	case b == 'H': // HTS - Set a tab stop at the cursor
+		p.screen.SetTabStop()
+		p.state = StateGround
+	case b == 'n': // LS2 - G2 into GL
+		p.screen.InvokeCharset(2)
+		p.state = StateGround
+	case b == 'o': // LS3 - G3 into GL
+		p.screen.InvokeCharset(3)
 		p.state = StateGround
 	case b == '=': // DECKPAM
+		p.appKeypad = true
 		p.state = StateGround
 	case b == '>': // DECKPNM
+		p.appKeypad = false
 		p.state = StateGround
 	case b >= 0x20 && b <= 0x2f: // Intermediate
 		p.intermediate = string(b)
@@ -260,20 +424,35 @@ func (p *Parser) parseEscapeIntermediate(b byte) {
 		p.intermediate += string(b)
 	} else if b >= 0x30 && b <= 0x7e {
 		// Final byte - handle sequence
+		p.executeEscapeIntermediate(b)
 		p.state = StateGround
 	} else {
 		p.state = StateGround
 	}
 }
 
+// executeEscapeIntermediate handles escape sequences with an intermediate
+// byte: charset designation and DECALN.
+func (p *Parser) executeEscapeIntermediate(final byte) {
+	switch p.intermediate {
+	case "(", ")", "*", "+": // SCS - Designate G0, G1, G2 or G3
+		p.screen.DesignateCharset(int(p.intermediate[0]-'('), Charset(final))
+	case "#":
+		if final == '8' { // DECALN - Screen alignment test
+			p.screen.AlignmentTest()
+		}
+	}
+}
+
 func (p *Parser) parseCSI(b byte) {
 	if b >= '0' && b <= '9' {
-		p.params = append(p.params, int(b-'0'))
+		p.addParam(false)
+		p.params[len(p.params)-1] = int(b - '0')
 		p.state = StateCSIParam
 	} else if b == ';' {
-		p.params = append(p.params, 0)
+		p.addParam(false)
 		p.state = StateCSIParam
-	} else if b == '?' || b == '>' || b == '!' {
+	} else if b == '?' || b == '>' || b == '<' || b == '=' || b == '!' {
 		p.intermediate = string(b)
 		p.state = StateCSIParam
 	} else if b >= 0x40 && b <= 0x7e {
@@ -287,21 +466,15 @@ func (p *Parser) parseCSI(b byte) {
 func (p *Parser) parseCSIParam(b byte) {
 	if b >= '0' && b <= '9' {
 		if len(p.params) == 0 {
-			p.params = append(p.params, 0)
+			p.addParam(false)
 		}
 		p.params[len(p.params)-1] = p.params[len(p.params)-1]*10 + int(b-'0')
-	} else if b == ';' {
-		if len(p.params) == 0 {
-			p.params = append(p.params, 0)
-		}
-		p.params = append(p.params, 0)
-	} else if b == ':' {
-		// Subparameter separator - used in SGR for underline styles
-		// For now, treat like ';'
+	} else if b == ';' || b == ':' {
 		if len(p.params) == 0 {
-			p.params = append(p.params, 0)
+			p.addParam(false)
 		}
-		p.params = append(p.params, 0)
+		// A colon starts a sub-parameter, e.g. SGR 4:3 or 38:2::r:g:b
+		p.addParam(b == ':')
 	} else if b >= 0x20 && b <= 0x2f {
 		if len(p.intermediate) >= maxIntermediateLen {
 			p.intermediate = ""
@@ -318,6 +491,22 @@ func (p *Parser) parseCSIParam(b byte) {
 	}
 }
 
+// addParam starts a new CSI parameter, sub marking it as a colon-separated
+// sub-parameter of the one before.
+func (p *Parser) addParam(sub bool) {
+	p.params = append(p.params, 0)
+	p.subParam = append(p.subParam, sub)
+}
+
+// subParams returns the sub-parameters following params[i]
+func (p *Parser) subParams(i int) []int {
+	j := i + 1
+	for j < len(p.params) && p.subParam[j] {
+		j++
+	}
+	return p.params[i+1 : j]
+}
+
 func (p *Parser) parseCSIIntermediate(b byte) {
 	if b >= 0x20 && b <= 0x2f {
 		if len(p.intermediate) >= maxIntermediateLen {
@@ -360,7 +549,11 @@ func (p *Parser) parseOSCString(b byte) {
 		p.state = StateEscape
 		p.executeOSC()
 	} else {
-		if p.oscString.Len() >= maxOSCStringLen {
+		limit := maxOSCStringLen
+		if strings.HasPrefix(p.oscString.String(), "1337;File=") {
+			limit = maxImagePayload * 4 / 3 // Base64 inline image
+		}
+		if p.oscString.Len() >= limit {
 			p.oscString.Reset()
 			p.state = StateGround
 			return
@@ -369,6 +562,111 @@ func (p *Parser) parseOSCString(b byte) {
 	}
 }
 
+func (p *Parser) parseDCS(b byte) {
+	switch {
+	case b >= '0' && b <= '9':
+		if len(p.params) == 0 {
+			p.addParam(false)
+		}
+		p.params[len(p.params)-1] = p.params[len(p.params)-1]*10 + int(b-'0')
+	case b == ';' || b == ':':
+		if len(p.params) == 0 {
+			p.addParam(false)
+		}
+		p.addParam(b == ':')
+	case b >= 0x20 && b <= 0x2f, b >= 0x3c && b <= 0x3f: // Intermediate or private marker
+		if len(p.intermediate) >= maxIntermediateLen {
+			p.intermediate = ""
+			p.state = StateGround
+			return
+		}
+		p.intermediate += string(b)
+	case b >= 0x40 && b <= 0x7e:
+		p.dcsFinal = b
+		p.strData = p.strData[:0]
+		p.strDrop = false
+		p.state = StateDCSString
+	case b == 0x1b:
+		p.state = StateEscape
+	case b == 0x18 || b == 0x1a: // CAN, SUB
+		p.state = StateGround
+	}
+}
+
+func (p *Parser) parseDCSString(b byte) {
+	switch b {
+	case 0x1b: // ESC, normally starting ST
+		p.state = StateEscape
+		p.executeDCS()
+		p.strData = nil
+	case 0x18, 0x1a: // CAN, SUB abort the string
+		p.state = StateGround
+		p.strData = nil
+	default:
+		p.collect(b)
+	}
+}
+
+func (p *Parser) parseAPCString(b byte) {
+	switch b {
+	case 0x1b: // ESC, normally starting ST
+		p.state = StateEscape
+		if p.strKind == '_' {
+			p.executeAPC()
+		}
+		p.strData = nil
+	case 0x18, 0x1a: // CAN, SUB abort the string
+		p.state = StateGround
+		p.strData = nil
+	default:
+		if p.strKind == '_' {
+			p.collect(b)
+		}
+	}
+}
+
+// collect adds a byte to the DCS or APC payload, discarding the payload
+// once it passes maxImagePayload
+func (p *Parser) collect(b byte) {
+	if p.strDrop {
+		return
+	}
+	if len(p.strData) >= maxImagePayload {
+		p.strData = nil
+		p.strDrop = true
+		return
+	}
+	p.strData = append(p.strData, b)
+}
+
+// executeDCS handles a complete DCS string
+func (p *Parser) executeDCS() {
+	if p.strDrop {
+		return
+	}
+	switch {
+	case p.dcsFinal == 'q' && p.intermediate == "": // Sixel image
+		p.sixel()
+	case p.dcsFinal == 's' && p.intermediate == "=" && len(p.params) == 1: // Synchronized update, as tmux sends it
+		switch p.params[0] {
+		case 1:
+			p.beginSync()
+		case 2:
+			p.sync.on = false
+		}
+	}
+}
+
+// executeAPC handles a complete APC string
+func (p *Parser) executeAPC() {
+	if p.strDrop {
+		return
+	}
+	if len(p.strData) > 0 && p.strData[0] == 'G' { // Kitty graphics
+		p.kittyGraphics(p.strData[1:])
+	}
+}
+
 func (p *Parser) executeCSI(final byte) {
 	// Get parameters with defaults
 	param := func(i, def int) int {
@@ -399,32 +697,55 @@ func (p *Parser) executeCSI(final byte) {
 		}
 		p.screen.cursor.Y = min(maxY, p.screen.cursor.Y+n)
 
-	case 'C': // CUF - Cursor Forward
+	case 'C': // CUF - Cursor Forward (stops at the right margin)
 		n := param(0, 1)
-		p.screen.cursor.X = min(cols-1, p.screen.cursor.X+n)
+		_, right := p.screen.horizontalMargins()
+		p.screen.cursor.X = min(right, p.screen.cursor.X+n)
 
-	case 'D': // CUB - Cursor Back
+	case 'D': // CUB - Cursor Back (stops at the left margin)
 		n := param(0, 1)
-		p.screen.cursor.X = max(0, p.screen.cursor.X-n)
+		left, _ := p.screen.horizontalMargins()
+		p.screen.cursor.X = max(left, min(p.screen.cursor.X, cols-1)-n)
 
 	case 'E': // CNL - Cursor Next Line
 		n := param(0, 1)
-		p.screen.cursor.X = 0
+		p.screen.CarriageReturn()
 		p.screen.cursor.Y = min(rows-1, p.screen.cursor.Y+n)
 
 	case 'F': // CPL - Cursor Previous Line
 		n := param(0, 1)
-		p.screen.cursor.X = 0
+		p.screen.CarriageReturn()
 		p.screen.cursor.Y = max(0, p.screen.cursor.Y-n)
 
-	case 'G': // CHA - Cursor Horizontal Absolute
-		n := param(0, 1)
-		p.screen.cursor.X = clamp(n-1, 0, cols-1)
+	case 'G', '`': // CHA, HPA - Cursor Horizontal Absolute
+		_, y := p.screen.OriginCursor()
+		p.screen.MoveTo(param(0, 1)-1, y)
 
 	case 'H', 'f': // CUP - Cursor Position
 		row := param(0, 1)
 		col := param(1, 1)
-		p.screen.SetCursor(col-1, row-1)
+		p.screen.MoveTo(col-1, row-1)
+
+	case 'I': // CHT - Cursor Forward Tabulation
+		p.screen.Tab(param(0, 1))
+
+	case 'Z': // CBT - Cursor Backward Tabulation
+		p.screen.BackTab(param(0, 1))
+
+	case 'g': // TBC - Tab Clear (0: at the cursor, 3: all)
+		switch param(0, 0) {
+		case 0:
+			p.screen.ClearTabStop(false)
+		case 3:
+			p.screen.ClearTabStop(true)
+		}
+
+	case 'b': // REP - Repeat the last printed character
+		if p.lastRune != 0 {
+			for n := min(param(0, 1), cols*rows); n > 0; n-- {
+				p.print(p.lastRune)
+			}
+		}
 
 	case 'J': // ED - Erase in Display
 		mode := param(0, 0)
@@ -448,28 +769,22 @@ func (p *Parser) executeCSI(final byte) {
 
 	case 'S': // SU - Scroll Up
 		n := param(0, 1)
-		scrolled := p.screen.ScrollUp(n)
-		for _, line := range scrolled {
-			p.scrollback.Push(line)
-		}
+		p.pushScrolled(p.screen.ScrollUp(n))
 
 	case 'T': // SD - Scroll Down
 		n := param(0, 1)
 		p.screen.ScrollDown(n)
 
 	case 'X': // ECH - Erase Characters
-		n := param(0, 1)
-		for i := 0; i < n && p.screen.cursor.X+i < cols; i++ {
-			p.screen.SetCell(p.screen.cursor.X+i, p.screen.cursor.Y, DefaultCell())
-		}
+		p.screen.EraseChars(param(0, 1))
 
 	case '@': // ICH - Insert Characters
 		n := param(0, 1)
 		p.screen.InsertChars(n)
 
 	case 'd': // VPA - Vertical Position Absolute
-		n := param(0, 1)
-		p.screen.cursor.Y = clamp(n-1, 0, rows-1)
+		x, _ := p.screen.OriginCursor()
+		p.screen.MoveTo(x, param(0, 1)-1)
 
 	case 'h': // SM - Set Mode
 		p.setMode(true)
@@ -477,31 +792,72 @@ func (p *Parser) executeCSI(final byte) {
 	case 'l': // RM - Reset Mode
 		p.setMode(false)
 
-	case 'm': // SGR - Select Graphic Rendition
-		p.executeSGR()
+	case 'm':
+		switch p.intermediate {
+		case "": // SGR - Select Graphic Rendition
+			p.executeSGR()
+		case ">", "?": // XTMODKEYS / XTQMODKEYS
+			p.modifyKeys(final)
+		}
 
-	case 'n': // DSR - Device Status Report
-		// Ignore for now
+	case 'n':
+		if p.intermediate == ">" { // XTMODKEYS reset
+			p.modifyKeys(final)
+			break
+		}
+		p.deviceStatusReport(param(0, 0)) // DSR - Device Status Report
 
 	case 'r': // DECSTBM - Set Scrolling Region
 		top := param(0, 1)
 		bottom := param(1, rows)
 		p.screen.SetScrollRegion(top-1, bottom-1)
-		p.screen.SetCursor(0, 0)
+		p.screen.MoveTo(0, 0)
 
-	case 's': // SCP - Save Cursor Position
-		// TODO: implement
+	case 's':
+		if p.intermediate != "" {
+			break
+		}
+		if p.screen.lrMargins { // DECSLRM - Set Left and Right Margins
+			p.screen.SetLeftRightMargins(param(0, 1)-1, param(1, cols)-1)
+		} else { // SCOSC - Save Cursor Position (same as DECSC)
+			p.screen.SaveCursor()
+		}
 
-	case 'u': // RCP - Restore Cursor Position
-		// TODO: implement
+	case 'u':
+		switch p.intermediate {
+		case "": // SCORC - Restore Cursor Position (same as DECRC)
+			p.screen.RestoreCursor()
+		case ">", "<", "=", "?": // Kitty keyboard protocol
+			p.keyboardMode()
+		}
 
-	case 't': // Window manipulation
-		// Ignore
+	case 't': // XTWINOPS - Window manipulation (size reports and title stack)
+		switch op := param(0, 0); {
+		case op == 22 && param(1, 0) != 1: // Save the window title (1 is the icon title alone)
+			p.pushTitle()
+		case op == 23 && param(1, 0) != 1: // Restore the window title
+			p.popTitle()
+		default:
+			p.windowReport(op)
+		}
 
 	case 'c': // DA - Device Attributes
-		// Ignore
+		p.deviceAttributes()
+
+	case 'p':
+		if p.intermediate == "$" || p.intermediate == "?$" { // DECRQM - Request Mode
+			p.requestMode()
+		}
+		if p.intermediate == "!" { // DECSTR - Soft terminal reset
+			p.screen.SoftReset()
+			p.appCursorKeys = false
+			p.appKeypad = false
+		}
 
 	case 'q': // DECSCUSR - Set Cursor Style
+		if p.intermediate == ">" { // XTVERSION
+			p.terminalVersion()
+		}
 		if p.intermediate == " " {
 			style := param(0, 1)
 			switch style {
@@ -517,29 +873,77 @@ func (p *Parser) executeCSI(final byte) {
 }
 
 func (p *Parser) setMode(set bool) {
+	if p.intermediate == "" {
+		// ANSI modes
+		for _, mode := range p.params {
+			switch mode {
+			case 4: // IRM - Insert mode
+				p.screen.SetInsertMode(set)
+			}
+		}
+	}
 	if len(p.intermediate) > 0 && p.intermediate[0] == '?' {
 		// DEC private modes
 		for _, mode := range p.params {
 			switch mode {
+			case 1: // DECCKM - Application cursor keys
+				p.appCursorKeys = set
+			case 6: // DECOM - Origin mode
+				p.screen.SetOriginMode(set)
+			case 7: // DECAWM - Autowrap
+				p.screen.SetAutoWrap(set)
+			case 69: // DECLRMM - Left/right margin mode
+				p.screen.SetLeftRightMarginMode(set)
 			case 25: // DECTCEM - Cursor visible
 				p.screen.SetCursorVisible(set)
-			case 1049: // Alternate screen buffer (xterm)
-				if set && !p.altScreen {
-					// Enter alternate screen: blank screen, no save.
-					cols, rows := p.screen.Size()
-					p.screen = NewScreen(cols, rows)
-					p.altScreen = true
-				} else if !set && p.altScreen {
-					// Leave alternate screen: blank screen, no restore.
-					cols, rows := p.screen.Size()
-					p.screen = NewScreen(cols, rows)
-					p.altScreen = false
+			case 9: // X10 mouse reporting
+				p.setMouseMode(MouseX10, set)
+			case 1000: // Normal mouse tracking
+				p.setMouseMode(MouseNormal, set)
+			case 1002: // Button-event tracking
+				p.setMouseMode(MouseButtonEvent, set)
+			case 1003: // Any-event tracking
+				p.setMouseMode(MouseAnyEvent, set)
+			case 1005: // UTF-8 mouse encoding
+				p.setMouseEncoding(MouseEncodingUTF8, set)
+			case 1006: // SGR mouse encoding
+				p.setMouseEncoding(MouseEncodingSGR, set)
+			case 1015: // urxvt mouse encoding
+				p.setMouseEncoding(MouseEncodingURXVT, set)
+			case 2004: // Bracketed paste
+				p.bracketedPaste = set
+			case 2026: // Synchronized update
+				if set {
+					p.beginSync()
+				} else {
+					p.sync.on = false
 				}
+			case 47, 1047, 1048, 1049: // Alternate screen buffer and saved cursor
+				p.altScreenMode(mode, set)
 			}
 		}
 	}
 }
 
+// setMouseMode enables a tracking mode, or disables it if it is the active one
+func (p *Parser) setMouseMode(mode MouseMode, set bool) {
+	if set {
+		p.mouseMode = mode
+	} else if p.mouseMode == mode {
+		p.mouseMode = MouseNone
+	}
+}
+
+// setMouseEncoding selects a report encoding, or reverts to the default
+// if the reset encoding is the active one
+func (p *Parser) setMouseEncoding(enc MouseEncoding, set bool) {
+	if set {
+		p.mouseEncoding = enc
+	} else if p.mouseEncoding == enc {
+		p.mouseEncoding = MouseEncodingDefault
+	}
+}
+
 func (p *Parser) executeSGR() {
 	if len(p.params) == 0 {
 		p.screen.ResetAttrs()
@@ -550,7 +954,8 @@ func (p *Parser) executeSGR() {
 	i := 0
 	for i < len(p.params) {
 		param := p.params[i]
-		i++
+		sub := p.subParams(i)
+		i += 1 + len(sub)
 
 		switch param {
 		case 0: // Reset
@@ -565,8 +970,18 @@ func (p *Parser) executeSGR() {
 		case 3: // Italic
 			attrs.Attrs |= AttrItalic
 
-		case 4: // Underline
-			attrs.Attrs |= AttrUnderline
+		case 4: // Underline; 4:n sets its style (0 off, 1 single, 2 double, 3 curly, 4 dotted, 5 dashed)
+			style := 1
+			if len(sub) > 0 {
+				style = sub[0]
+			}
+			switch {
+			case style == 0:
+				attrs.Attrs &^= AttrUnderline
+			case style <= int(UnderlineDashed)+1:
+				attrs.Attrs |= AttrUnderline
+				attrs.Decoration = attrs.Decoration.WithUnderlineStyle(UnderlineStyle(style - 1))
+			}
 
 		case 5: // Blink
 			attrs.Attrs |= AttrBlink
@@ -580,8 +995,9 @@ func (p *Parser) executeSGR() {
 		case 9: // Strikethrough
 			attrs.Attrs |= AttrStrikethrough
 
-		case 21: // Double underline (treat as underline)
+		case 21: // Double underline
 			attrs.Attrs |= AttrUnderline
+			attrs.Decoration = attrs.Decoration.WithUnderlineStyle(UnderlineDouble)
 
 		case 22: // Not bold, not dim
 			attrs.Attrs &^= (AttrBold | AttrDim)
@@ -608,7 +1024,7 @@ func (p *Parser) executeSGR() {
 			attrs.FG = IndexedColor(uint8(param - 30))
 
 		case 38: // Extended FG color
-			i = p.parseExtendedColor(&attrs.FG, i)
+			i = p.parseSGRColor(&attrs.FG, sub, i)
 
 		case 39: // Default FG
 			attrs.FG = DefaultFG
@@ -617,11 +1033,25 @@ func (p *Parser) executeSGR() {
 			attrs.BG = IndexedColor(uint8(param - 40))
 
 		case 48: // Extended BG color
-			i = p.parseExtendedColor(&attrs.BG, i)
+			i = p.parseSGRColor(&attrs.BG, sub, i)
 
 		case 49: // Default BG
 			attrs.BG = DefaultBG
 
+		case 53: // Overline
+			attrs.Decoration = attrs.Decoration.WithOverline(true)
+
+		case 55: // Not overlined
+			attrs.Decoration = attrs.Decoration.WithOverline(false)
+
+		case 58: // Underline color
+			c := attrs.Decoration.UnderlineColor()
+			i = p.parseSGRColor(&c, sub, i)
+			attrs.Decoration = attrs.Decoration.WithUnderlineColor(c)
+
+		case 59: // Default underline color
+			attrs.Decoration = attrs.Decoration.WithUnderlineColor(Color{})
+
 		case 90, 91, 92, 93, 94, 95, 96, 97: // Bright FG colors
 			attrs.FG = IndexedColor(uint8(param - 90 + 8))
 
@@ -632,6 +1062,30 @@ func (p *Parser) executeSGR() {
 	p.screen.SetAttrs(attrs)
 }
 
+// parseSGRColor reads the color selected by SGR 38, 48 or 58 into c, either
+// from its colon sub-parameters (38:5:n, 38:2::r:g:b) or, when there are
+// none, from the semicolon-separated parameters starting at i (38;5;n,
+// 38;2;r;g;b). It returns the index of the next unread parameter.
+func (p *Parser) parseSGRColor(c *Color, sub []int, i int) int {
+	if len(sub) == 0 {
+		return p.parseExtendedColor(c, i)
+	}
+	switch sub[0] {
+	case 5: // 256-color
+		if len(sub) > 1 {
+			*c = IndexedColor(uint8(sub[1]))
+		}
+	case 2: // RGB, after a color space ID that is often left out
+		switch {
+		case len(sub) > 4:
+			*c = RGBColor(uint8(sub[2]), uint8(sub[3]), uint8(sub[4]))
+		case len(sub) == 4:
+			*c = RGBColor(uint8(sub[1]), uint8(sub[2]), uint8(sub[3]))
+		}
+	}
+	return i
+}
+
 func (p *Parser) parseExtendedColor(c *Color, i int) int {
 	if i >= len(p.params) {
 		return i
@@ -679,6 +1133,22 @@ func (p *Parser) executeOSC() {
 		if p.onTitle != nil {
 			p.onTitle(p.title)
 		}
+	case 7: // Working directory: OSC 7 ; file://host/path
+		p.reportCWD(parts[1])
+	case 8: // Hyperlink: OSC 8 ; params ; URI (an empty URI ends the link)
+		if _, uri, ok := strings.Cut(parts[1], ";"); ok {
+			p.screen.SetLink(uri)
+		}
+	case 52: // Clipboard: OSC 52 ; selection ; base64 data or "?"
+		p.clipboardRequest(parts[1])
+	case 133: // Shell integration: OSC 133 ; A|B|C|D [; options]
+		p.semanticPrompt(parts[1])
+	case 1337: // iTerm2 extensions: OSC 1337 ; SetMark or File=args:data
+		if strings.HasPrefix(parts[1], "File=") {
+			p.iTermFile(parts[1])
+		} else {
+			p.iTermMark(parts[1])
+		}
 	}
 }
 
@@ -191,6 +191,68 @@ func TestParserSGRReset(t *testing.T) {
 	}
 }
 
+func TestParserSGRDecoration(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		underline bool
+		style     UnderlineStyle
+		ulColor   Color
+		overline  bool
+	}{
+		{"underline", "\x1b[4m", true, UnderlineSingle, Color{}, false},
+		{"double", "\x1b[21m", true, UnderlineDouble, Color{}, false},
+		{"curly", "\x1b[4:3m", true, UnderlineCurly, Color{}, false},
+		{"dotted", "\x1b[4:4m", true, UnderlineDotted, Color{}, false},
+		{"dashed", "\x1b[4:5m", true, UnderlineDashed, Color{}, false},
+		{"style off", "\x1b[4:3m\x1b[4:0m", false, UnderlineCurly, Color{}, false},
+		{"unknown style ignored", "\x1b[4:9m", false, UnderlineSingle, Color{}, false},
+		{"not underlined", "\x1b[4:2m\x1b[24m", false, UnderlineDouble, Color{}, false},
+		{"color colon RGB", "\x1b[58:2::10:20:30m", false, UnderlineSingle, RGBColor(10, 20, 30), false},
+		{"color colon RGB without color space", "\x1b[58:2:10:20:30m", false, UnderlineSingle, RGBColor(10, 20, 30), false},
+		{"color colon indexed", "\x1b[58:5:196m", false, UnderlineSingle, IndexedColor(196), false},
+		{"color semicolons", "\x1b[58;2;10;20;30;4m", true, UnderlineSingle, RGBColor(10, 20, 30), false},
+		{"default color", "\x1b[58;5;1m\x1b[59m", false, UnderlineSingle, Color{}, false},
+		{"overline", "\x1b[53m", false, UnderlineSingle, Color{}, true},
+		{"not overlined", "\x1b[53m\x1b[55m", false, UnderlineSingle, Color{}, false},
+		{"neovim diagnostic", "\x1b[0;4:3;58:2::255:0:0m", true, UnderlineCurly, RGBColor(255, 0, 0), false},
+		{"reset", "\x1b[4:3;53;58:5:1m\x1b[m", false, UnderlineSingle, Color{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser()
+			p.Parse([]byte(tt.input + "X"))
+			c := p.screen.Cell(0, 0)
+			if got := c.Attrs&AttrUnderline != 0; got != tt.underline {
+				t.Errorf("underline = %v, want %v", got, tt.underline)
+			}
+			if got := c.Decoration.UnderlineStyle(); got != tt.style {
+				t.Errorf("style = %d, want %d", got, tt.style)
+			}
+			if got := c.Decoration.UnderlineColor(); got != tt.ulColor {
+				t.Errorf("underline color = %v, want %v", got, tt.ulColor)
+			}
+			if got := c.Decoration.Overline(); got != tt.overline {
+				t.Errorf("overline = %v, want %v", got, tt.overline)
+			}
+		})
+	}
+}
+
+// TestParserSGRColonColors verifies colon sub-parameters select a color
+// without being read as further SGR parameters.
+func TestParserSGRColonColors(t *testing.T) {
+	p := newTestParser()
+	p.Parse([]byte("\x1b[38:2::1:2:3;48:5:4;1mX"))
+	c := p.screen.Cell(0, 0)
+	if c.FG != RGBColor(1, 2, 3) || c.BG != IndexedColor(4) {
+		t.Errorf("FG, BG = %v, %v", c.FG, c.BG)
+	}
+	if c.Attrs != AttrBold {
+		t.Errorf("Attrs = %b, want bold only", c.Attrs)
+	}
+}
+
 func TestParserScrollRegion(t *testing.T) {
 	p := newTestParser()
 
@@ -284,10 +346,10 @@ func TestParserUTF8(t *testing.T) {
 	// Test various UTF-8 characters:
 	// - Box drawing: │ (U+2502, 3 bytes: E2 94 82)
 	// - Emoji: ✓ (U+2713, 3 bytes: E2 9C 93)
-	// - Japanese: 日 (U+65E5, 3 bytes: E6 97 A5)
+	// - Japanese: 日 (U+65E5, 3 bytes: E6 97 A5), double width
 	p.Parse([]byte("A│B✓C日D"))
 
-	expected := []rune{'A', '│', 'B', '✓', 'C', '日', 'D'}
+	expected := []rune{'A', '│', 'B', '✓', 'C', '日', 0, 'D'}
 	for i, want := range expected {
 		got := p.screen.Cell(i, 0).Rune
 		if got != want {
@@ -295,8 +357,29 @@ func TestParserUTF8(t *testing.T) {
 		}
 	}
 
-	if p.screen.cursor.X != 7 {
-		t.Errorf("cursor.X = %d, want 7", p.screen.cursor.X)
+	if !p.screen.Cell(6, 0).IsContinuation() {
+		t.Error("Cell(6,0) should continue the wide 日")
+	}
+	if p.screen.cursor.X != 8 {
+		t.Errorf("cursor.X = %d, want 8", p.screen.cursor.X)
+	}
+}
+
+func TestParserEmojiSequences(t *testing.T) {
+	p := newTestParser()
+
+	// Family (ZWJ sequence), thumbs up + skin tone, and a flag each fill
+	// one double-width cell
+	p.Parse([]byte("👨\u200d👩\u200d👧|👍🏽|🇯🇵|"))
+
+	want := []string{"👨\u200d👩\u200d👧", "", "|", "👍🏽", "", "|", "🇯🇵", "", "|"}
+	for i, w := range want {
+		if got := p.screen.Cell(i, 0).Text(); got != w {
+			t.Errorf("Cell(%d,0).Text() = %q, want %q", i, got, w)
+		}
+	}
+	if p.screen.cursor.X != len(want) {
+		t.Errorf("cursor.X = %d, want %d", p.screen.cursor.X, len(want))
 	}
 }
 
@@ -313,3 +396,45 @@ func TestParserUTF8Split(t *testing.T) {
 		t.Errorf("Cell(0,0) = %q, want '│'", p.screen.Cell(0, 0).Rune)
 	}
 }
+
+func TestParserKeyModes(t *testing.T) {
+	p := newTestParser()
+
+	p.Parse([]byte("\x1b[?1h\x1b="))
+	if !p.AppCursorKeys() || !p.AppKeypad() {
+		t.Errorf("AppCursorKeys/AppKeypad = %v/%v, want true/true", p.AppCursorKeys(), p.AppKeypad())
+	}
+
+	p.Parse([]byte("\x1b[?1l\x1b>"))
+	if p.AppCursorKeys() || p.AppKeypad() {
+		t.Errorf("AppCursorKeys/AppKeypad = %v/%v after reset, want false/false", p.AppCursorKeys(), p.AppKeypad())
+	}
+}
+
+// TestParserWrapPushesScrollback verifies rows scrolled off by autowrap at
+// the bottom reach the scrollback with their wrap flag, and that resizing
+// re-wraps them together with the screen.
+func TestParserWrapPushesScrollback(t *testing.T) {
+	p := NewParser(NewScreen(4, 2), NewScrollback())
+	p.Parse([]byte("abcdefghij"))
+
+	sb := p.Scrollback()
+	if sb.Count() != 1 {
+		t.Fatalf("scrollback Count() = %d, want 1", sb.Count())
+	}
+	if !LineWrapped(sb.Line(0)) {
+		t.Error("scrolled-off row should keep its wrap flag")
+	}
+
+	// Widening rejoins the scrollback row; the screen rows rejoin separately
+	p.Resize(8, 2)
+	if sb.Count() != 1 {
+		t.Fatalf("after resize Count() = %d, want 1", sb.Count())
+	}
+	if got := rowText(p.Screen(), 0); got != "efghij" {
+		t.Errorf("row 0 = %q, want %q", got, "efghij")
+	}
+	if cur := p.Screen().Cursor(); cur.X != 6 || cur.Y != 0 {
+		t.Errorf("cursor = (%d,%d), want (6,0)", cur.X, cur.Y)
+	}
+}
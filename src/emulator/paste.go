@@ -0,0 +1,28 @@
+package emulator
+
+import "bytes"
+
+var (
+	pasteStart = []byte("\x1b[200~")
+	pasteEnd   = []byte("\x1b[201~")
+)
+
+// EncodePaste prepares clipboard data for writing to the PTY. When the
+// application has enabled bracketed paste (mode 2004) the data is wrapped
+// in ESC[200~ / ESC[201~ so it arrives as a single paste rather than typed
+// input. Any markers embedded in the data are stripped first, so pasted
+// text can't end the bracket early and inject keystrokes.
+func EncodePaste(data []byte, bracketed bool) []byte {
+	if !bracketed {
+		return data
+	}
+	for bytes.Contains(data, pasteEnd) || bytes.Contains(data, pasteStart) {
+		data = bytes.ReplaceAll(data, pasteEnd, nil)
+		data = bytes.ReplaceAll(data, pasteStart, nil)
+	}
+	out := make([]byte, 0, len(data)+len(pasteStart)+len(pasteEnd))
+	out = append(out, pasteStart...)
+	out = append(out, data...)
+	out = append(out, pasteEnd...)
+	return out
+}
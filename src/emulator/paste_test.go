@@ -0,0 +1,40 @@
+package emulator
+
+import "testing"
+
+func TestEncodePaste(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		bracketed bool
+		want      string
+	}{
+		{"plain", "a\nb", false, "a\nb"},
+		{"bracketed", "a\nb", true, "\x1b[200~a\nb\x1b[201~"},
+		{"strips end marker", "a\x1b[201~rm -rf ~\n", true, "\x1b[200~arm -rf ~\n\x1b[201~"},
+		{"strips nested markers", "\x1b[20\x1b[201~1~x", true, "\x1b[200~x\x1b[201~"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(EncodePaste([]byte(tt.data), tt.bracketed))
+			if got != tt.want {
+				t.Errorf("EncodePaste(%q, %v) = %q, want %q", tt.data, tt.bracketed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParserBracketedPasteMode(t *testing.T) {
+	p := newTestParser()
+	if p.BracketedPaste() {
+		t.Fatal("bracketed paste should be off by default")
+	}
+	p.Parse([]byte("\x1b[?2004h"))
+	if !p.BracketedPaste() {
+		t.Error("BracketedPaste() = false after ?2004h")
+	}
+	p.Parse([]byte("\x1b[?2004l"))
+	if p.BracketedPaste() {
+		t.Error("BracketedPaste() = true after ?2004l")
+	}
+}
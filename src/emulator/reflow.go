@@ -0,0 +1,103 @@
+package emulator
+
+// reflow joins soft-wrapped rows into logical lines and re-splits them at
+// cols. The cursor at (curX, curY) in rows is tracked to its new position;
+// pass curY < 0 when there is no cursor to follow. A trailing line that is
+// still wrapped (it continues somewhere not in rows) keeps its flag.
+func reflow(rows [][]Cell, cols, curX, curY int) (out [][]Cell, newX, newY int) {
+	newX, newY = -1, -1
+	for i := 0; i < len(rows); {
+		// Gather one logical line
+		var line []Cell
+		var mark Mark
+		cursorOff := -1
+		wrapped := false
+		for i < len(rows) {
+			row := rows[i]
+			wrapped = LineWrapped(row)
+			mark = mark.merge(LineMark(row))
+			keep := len(row)
+			if i == curY {
+				cursorOff = len(line) + curX
+			}
+			if !wrapped {
+				keep = trimmedLen(row)
+				if i == curY && keep < curX {
+					keep = min(curX, len(row))
+				}
+			}
+			for _, c := range row[:keep] {
+				c.Wrapped = false
+				c.Mark = Mark{}
+				line = append(line, c)
+			}
+			i++
+			if !wrapped {
+				break
+			}
+		}
+
+		// Re-split at the new width; the marks go on the first row
+		first := len(out)
+		cur := blankRow(cols)
+		x := 0
+		for j := 0; j < len(line); j++ {
+			c := line[j]
+			w := 1
+			if c.IsWide() && j+1 < len(line) && line[j+1].IsContinuation() && cols >= 2 {
+				w = 2
+			} else if c.IsWide() || c.IsContinuation() {
+				c = blankFrom(c) // Orphaned half of a pair
+			}
+			if x+w > cols {
+				cur[cols-1].Wrapped = true
+				out = append(out, cur)
+				cur = blankRow(cols)
+				x = 0
+			}
+			if cursorOff == j || (w == 2 && cursorOff == j+1) {
+				newX, newY = x, len(out)
+			}
+			cur[x] = c
+			if w == 2 {
+				cur[x+1] = line[j+1]
+				j++
+			}
+			x += w
+		}
+		if cursorOff >= len(line) {
+			// Cursor just past the text (or in the pending-wrap column)
+			newX, newY = x, len(out)
+		}
+		if wrapped {
+			cur[cols-1].Wrapped = true
+		}
+		out = append(out, cur)
+		out[first][0].Mark = mark
+	}
+	return out, newX, newY
+}
+
+// trimmedLen returns the length of row without trailing default blanks
+func trimmedLen(row []Cell) int {
+	n := len(row)
+	for n > 0 && isBlankCell(row[n-1]) {
+		n--
+	}
+	return n
+}
+
+// isBlankCell reports whether c is an unstyled empty cell
+func isBlankCell(c Cell) bool {
+	return (c.Rune == ' ' || c.Rune == 0) && c.Width == WidthNormal &&
+		c.FG.Type == ColorDefault && c.BG.Type == ColorDefault && c.Attrs == 0 && c.Decoration == 0
+}
+
+// blankRow returns a row of default cells
+func blankRow(cols int) []Cell {
+	row := make([]Cell, cols)
+	for i := range row {
+		row[i] = DefaultCell()
+	}
+	return row
+}
@@ -0,0 +1,172 @@
+package emulator
+
+import "fmt"
+
+const (
+	// xtVersion is reported in reply to XTVERSION (CSI > q)
+	xtVersion = "prompt-grid"
+
+	// Device attributes: VT220-class terminal with Sixel graphics and ANSI color
+	primaryDA   = "\x1b[?62;4;22c"
+	secondaryDA = "\x1b[>1;10;0c"
+)
+
+// DECRQM mode states (DECRPM replies)
+const (
+	modeNotRecognized    = 0
+	modeSet              = 1
+	modeReset            = 2
+	modePermanentlySet   = 3
+	modePermanentlyReset = 4
+)
+
+// SetOnResponse sets the sink for replies to terminal queries (cursor
+// position, device attributes, mode reports). Replies are written back to
+// the application, normally through the PTY. fn is called from Parse, with
+// whatever lock guards the parser held, so it should queue the reply
+// rather than write it there.
+func (p *Parser) SetOnResponse(fn func([]byte)) {
+	p.onResponse = fn
+}
+
+// SetReplayMode suppresses query replies while replaying a log: the
+// application that asked is long gone and stale answers would be read as
+// keyboard input by whatever runs now. Turning it off shows anything held
+// by a synchronized update the log ended in.
+func (p *Parser) SetReplayMode(on bool) {
+	p.replaying = on
+	if !on {
+		p.EndSync()
+	}
+}
+
+// SetCellPixelSize sets the cell size reported to CSI 14/16 t queries.
+func (p *Parser) SetCellPixelSize(width, height int) {
+	p.cellPixelW = width
+	p.cellPixelH = height
+}
+
+// respond sends a reply to the application unless replaying.
+func (p *Parser) respond(format string, args ...any) {
+	if p.onResponse == nil || p.replaying {
+		return
+	}
+	p.onResponse([]byte(fmt.Sprintf(format, args...)))
+}
+
+// deviceStatusReport answers DSR (CSI n) and DECXCPR (CSI ? 6 n).
+func (p *Parser) deviceStatusReport(n int) {
+	switch n {
+	case 5: // Operating status: OK
+		p.respond("\x1b[0n")
+	case 6: // Cursor position report (1-based, relative to the origin in DECOM)
+		x, y := p.screen.OriginCursor()
+		if p.intermediate == "?" {
+			p.respond("\x1b[?%d;%dR", y+1, x+1)
+		} else {
+			p.respond("\x1b[%d;%dR", y+1, x+1)
+		}
+	}
+}
+
+// deviceAttributes answers DA1 (CSI c) and DA2 (CSI > c).
+func (p *Parser) deviceAttributes() {
+	if len(p.params) > 0 && p.params[0] != 0 {
+		return
+	}
+	switch p.intermediate {
+	case "":
+		p.respond(primaryDA)
+	case ">":
+		p.respond(secondaryDA)
+	}
+}
+
+// requestMode answers DECRQM (CSI ? Ps $ p and CSI Ps $ p) with DECRPM.
+func (p *Parser) requestMode() {
+	mode := 0
+	if len(p.params) > 0 {
+		mode = p.params[0]
+	}
+	switch p.intermediate {
+	case "?$":
+		p.respond("\x1b[?%d;%d$y", mode, p.decModeState(mode))
+	case "$":
+		p.respond("\x1b[%d;%d$y", mode, p.ansiModeState(mode))
+	}
+}
+
+// decModeState reports the state of a DEC private mode for DECRQM.
+func (p *Parser) decModeState(mode int) int {
+	flag := func(on bool) int {
+		if on {
+			return modeSet
+		}
+		return modeReset
+	}
+	switch mode {
+	case 1:
+		return flag(p.appCursorKeys)
+	case 6:
+		return flag(p.screen.originMode)
+	case 7:
+		return flag(p.screen.autoWrap)
+	case 9:
+		return flag(p.mouseMode == MouseX10)
+	case 25:
+		return flag(p.screen.cursor.Visible)
+	case 69:
+		return flag(p.screen.lrMargins)
+	case 1000:
+		return flag(p.mouseMode == MouseNormal)
+	case 1002:
+		return flag(p.mouseMode == MouseButtonEvent)
+	case 1003:
+		return flag(p.mouseMode == MouseAnyEvent)
+	case 1005:
+		return flag(p.mouseEncoding == MouseEncodingUTF8)
+	case 1006:
+		return flag(p.mouseEncoding == MouseEncodingSGR)
+	case 1015:
+		return flag(p.mouseEncoding == MouseEncodingURXVT)
+	case 47, 1047, 1049:
+		return flag(p.altScreen)
+	case 2004:
+		return flag(p.bracketedPaste)
+	case 2026:
+		return flag(p.sync.on)
+	}
+	return modeNotRecognized
+}
+
+// ansiModeState reports the state of an ANSI mode for DECRQM.
+func (p *Parser) ansiModeState(mode int) int {
+	switch mode {
+	case 4: // IRM
+		if p.screen.insertMode {
+			return modeSet
+		}
+		return modeReset
+	case 20: // LNM - LF never implies CR
+		return modePermanentlyReset
+	}
+	return modeNotRecognized
+}
+
+// windowReport answers the XTWINOPS size queries (CSI 14/16/18 t).
+func (p *Parser) windowReport(op int) {
+	cols, rows := p.screen.Size()
+	switch op {
+	case 14: // Text area size in pixels
+		p.respond("\x1b[4;%d;%dt", rows*p.cellPixelH, cols*p.cellPixelW)
+	case 16: // Cell size in pixels
+		p.respond("\x1b[6;%d;%dt", p.cellPixelH, p.cellPixelW)
+	case 18: // Text area size in characters
+		p.respond("\x1b[8;%d;%dt", rows, cols)
+	}
+}
+
+// terminalVersion answers XTVERSION (CSI > q) with a DCS string.
+func (p *Parser) terminalVersion() {
+	p.respond("\x1bP>|%s\x1b\\", xtVersion)
+}
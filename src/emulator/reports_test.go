@@ -0,0 +1,80 @@
+package emulator
+
+import "testing"
+
+func TestParserQueryReplies(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup string
+		query string
+		want  string
+	}{
+		{"DSR status", "", "\x1b[5n", "\x1b[0n"},
+		{"CPR", "\x1b[5;10H", "\x1b[6n", "\x1b[5;10R"},
+		{"DECXCPR", "\x1b[3;4H", "\x1b[?6n", "\x1b[?3;4R"},
+		{"DA1", "", "\x1b[c", primaryDA},
+		{"DA1 explicit zero", "", "\x1b[0c", primaryDA},
+		{"DA2", "", "\x1b[>c", secondaryDA},
+		{"XTVERSION", "", "\x1b[>q", "\x1bP>|prompt-grid\x1b\\"},
+		{"DECRQM set", "\x1b[?2004h", "\x1b[?2004$p", "\x1b[?2004;1$y"},
+		{"DECRQM reset", "", "\x1b[?1$p", "\x1b[?1;2$y"},
+		{"DECRQM cursor visible", "", "\x1b[?25$p", "\x1b[?25;1$y"},
+		{"DECRQM mouse", "\x1b[?1003h", "\x1b[?1003$p", "\x1b[?1003;1$y"},
+		{"DECRQM unknown", "", "\x1b[?12345$p", "\x1b[?12345;0$y"},
+		{"DECRQM ANSI", "", "\x1b[4$p", "\x1b[4;2$y"},
+		{"DECRQM insert mode", "\x1b[4h", "\x1b[4$p", "\x1b[4;1$y"},
+		{"DECRQM permanently reset", "", "\x1b[20$p", "\x1b[20;4$y"},
+		{"DECRQM autowrap", "\x1b[?7l", "\x1b[?7$p", "\x1b[?7;2$y"},
+		{"DECRQM origin mode", "\x1b[?6h", "\x1b[?6$p", "\x1b[?6;1$y"},
+		{"CPR in origin mode", "\x1b[5;20r\x1b[?6h\x1b[2;3H", "\x1b[6n", "\x1b[2;3R"},
+		{"window chars", "", "\x1b[18t", "\x1b[8;24;80t"},
+		{"window pixels", "", "\x1b[14t", "\x1b[4;480;800t"},
+		{"cell pixels", "", "\x1b[16t", "\x1b[6;20;10t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestParser()
+			p.SetCellPixelSize(10, 20)
+			var got []string
+			p.SetOnResponse(func(data []byte) {
+				got = append(got, string(data))
+			})
+			p.Parse([]byte(tt.setup))
+			got = nil
+			p.Parse([]byte(tt.query))
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("replies = %q, want [%q]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParserCPRPendingWrap(t *testing.T) {
+	p := NewParser(NewScreen(5, 3), NewScrollback())
+	var got string
+	p.SetOnResponse(func(data []byte) { got = string(data) })
+
+	// Filling the row leaves the cursor in the pending-wrap position
+	p.Parse([]byte("abcde\x1b[6n"))
+	if got != "\x1b[1;5R" {
+		t.Errorf("CPR = %q, want %q", got, "\x1b[1;5R")
+	}
+}
+
+func TestParserRepliesSilentInReplay(t *testing.T) {
+	p := newTestParser()
+	replies := 0
+	p.SetOnResponse(func([]byte) { replies++ })
+
+	p.SetReplayMode(true)
+	p.Parse([]byte("\x1b[6n\x1b[c\x1b[>q"))
+	p.SetReplayMode(false)
+	if replies != 0 {
+		t.Errorf("got %d replies during replay, want 0", replies)
+	}
+
+	p.Parse([]byte("\x1b[5n"))
+	if replies != 1 {
+		t.Errorf("got %d replies after replay, want 1", replies)
+	}
+}
@@ -1,5 +1,11 @@
 package emulator
 
+import "strings"
+
+// maxCombiningBytes caps the zero-width runes attached to one cell so a
+// stream of combining marks can't grow a cell without bound
+const maxCombiningBytes = 32
+
 // CursorStyle represents the cursor appearance
 type CursorStyle uint8
 
@@ -24,19 +30,39 @@ type Screen struct {
 	cursor     Cursor
 	scrollTop  int
 	scrollBot  int
-	dirty      []bool // Tracks which lines need repainting
-	attrs      Cell   // Current drawing attributes
+	dirty      []bool      // Tracks which lines need repainting
+	attrs      Cell        // Current drawing attributes
+	link       LinkID      // Current OSC 8 hyperlink, applied to written cells
+	links      *LinkTable  // Targets of the cells' links; the scrollback's once a parser has both
+	saved      savedCursor // DECSC state
+	images     *ImageStore // Inline images shown by image cells; nil without a parser
+
+	// Modes, margins and tab stops (see modes.go)
+	originMode  bool       // DECOM: addressing relative to the margins
+	autoWrap    bool       // DECAWM: writing past the right margin wraps
+	insertMode  bool       // IRM: writing shifts the rest of the line right
+	lrMargins   bool       // DECLRMM: DECSLRM left/right margins are enabled
+	marginLeft  int        // DECSLRM margins, inclusive
+	marginRight int        //
+	marginWrap  bool       // Cursor filled a right margin short of the edge: wrap before the next write
+	marginWrapY int        // Row the pending margin wrap applies to
+	tabStops    []bool     // Tab stop per column
+	charsets    [4]Charset // G0-G3 designations
+	gl          int        // Charset invoked into GL
 }
 
 // NewScreen creates a new screen buffer
 func NewScreen(cols, rows int) *Screen {
 	s := &Screen{
-		cols:      cols,
-		rows:      rows,
-		scrollTop: 0,
-		scrollBot: rows - 1,
-		dirty:     make([]bool, rows),
-		attrs:     DefaultCell(),
+		cols:        cols,
+		rows:        rows,
+		scrollTop:   0,
+		scrollBot:   rows - 1,
+		dirty:       make([]bool, rows),
+		attrs:       DefaultCell(),
+		links:       &LinkTable{},
+		autoWrap:    true,
+		marginRight: cols - 1,
 	}
 	s.cursor = Cursor{
 		X:       0,
@@ -52,6 +78,7 @@ func NewScreen(cols, rows int) *Screen {
 		}
 		s.dirty[i] = true
 	}
+	s.resetTabStops()
 	return s
 }
 
@@ -89,6 +116,16 @@ func (s *Screen) Cell(x, y int) Cell {
 	return s.cells[y][x]
 }
 
+// Line returns a copy of row y, or nil if out of range
+func (s *Screen) Line(y int) []Cell {
+	if y < 0 || y >= s.rows {
+		return nil
+	}
+	line := make([]Cell, s.cols)
+	copy(line, s.cells[y])
+	return line
+}
+
 // SetCell sets the cell at the given position
 func (s *Screen) SetCell(x, y int, cell Cell) {
 	if x < 0 || x >= s.cols || y < 0 || y >= s.rows {
@@ -98,28 +135,145 @@ func (s *Screen) SetCell(x, y int, cell Cell) {
 	s.dirty[y] = true
 }
 
-// Write writes a rune at the current cursor position with current attributes
-func (s *Screen) Write(r rune) {
-	if s.cursor.X >= s.cols {
-		s.cursor.X = 0
+// Write writes a rune at the current cursor position with current attributes.
+// Wide runes occupy two cells; zero-width runes attach to the previous glyph.
+// Returns the lines scrolled off the top if wrapping scrolled the region.
+func (s *Screen) Write(r rune) [][]Cell {
+	r = s.translate(r)
+	w := RuneWidth(r)
+	if s.attachToPrevious(r, w) {
+		return nil
+	}
+	if w == 0 {
+		// Nothing to combine with (e.g. start of line) - drop it
+		return nil
+	}
+	left, right := s.horizontalMargins()
+	if w == 2 && right-left < 1 {
+		w = 1
+	}
+	wrap := s.cursor.X > right || s.marginWrap && s.cursor.X == right && s.cursor.Y == s.marginWrapY
+	s.marginWrap = false
+	if !wrap && w == 2 && s.cursor.X == right {
+		// A wide glyph can't straddle the margin: blank the last column and wrap
+		s.splitWide(s.cursor.Y, s.cursor.X)
+		s.SetCell(s.cursor.X, s.cursor.Y, s.blankCell())
+		wrap = true
+	}
+	var scrolled [][]Cell
+	if wrap && !s.autoWrap {
+		// DECAWM off: overwrite at the margin
+		s.cursor.X = right - w + 1
+	} else if wrap {
+		// Soft wrap: flag the row so reflow and copy can rejoin it
+		if left == 0 && right == s.cols-1 {
+			s.cells[s.cursor.Y][right].Wrapped = true
+		}
+		s.cursor.X = left
 		s.cursor.Y++
 		if s.cursor.Y > s.scrollBot && s.cursor.Y-1 <= s.scrollBot {
 			// Wrapped past bottom of scroll region from inside → scroll
-			s.ScrollUp(1)
+			scrolled = s.ScrollUp(1)
 			s.cursor.Y = s.scrollBot
 		} else if s.cursor.Y >= s.rows {
 			s.cursor.Y = s.rows - 1
 		}
 	}
 
+	x, y := s.cursor.X, s.cursor.Y
+	if s.insertMode {
+		s.shiftRight(y, x, right, w)
+	}
+	s.splitWide(y, x)
+	s.splitWide(y, x+w)
+
 	cell := Cell{
-		Rune:  r,
-		FG:    s.attrs.FG,
-		BG:    s.attrs.BG,
-		Attrs: s.attrs.Attrs,
+		Rune:       r,
+		FG:         s.attrs.FG,
+		BG:         s.attrs.BG,
+		Attrs:      s.attrs.Attrs,
+		Decoration: s.attrs.Decoration,
+		Link:       s.link,
+	}
+	if x == 0 {
+		cell.Mark = s.cells[y][0].Mark
+	}
+	if w == 2 {
+		cell.Width = WidthWide
+		s.SetCell(x, y, cell)
+		cont := cell
+		cont.Rune = 0
+		cont.Width = WidthContinuation
+		cont.Mark = Mark{}
+		s.SetCell(x+1, y, cont)
+	} else {
+		s.SetCell(x, y, cell)
+	}
+	s.cursor.X += w
+	if s.cursor.X > right && right < s.cols-1 {
+		// Filled a right margin: the cursor waits on it until the next write
+		s.cursor.X = right
+		s.marginWrap = true
+		s.marginWrapY = y
+	}
+	return scrolled
+}
+
+// attachToPrevious appends r to the glyph left of the cursor when it extends
+// that glyph's grapheme cluster: combining marks, ZWJ sequences, skin tone
+// modifiers and the second half of a flag. Returns false if r starts a new cell.
+func (s *Screen) attachToPrevious(r rune, w int) bool {
+	x, y := s.cursor.X-1, s.cursor.Y
+	if x < 0 || x >= s.cols || y < 0 || y >= s.rows {
+		return false
+	}
+	if s.cells[y][x].IsContinuation() && x > 0 {
+		x--
 	}
-	s.SetCell(s.cursor.X, s.cursor.Y, cell)
-	s.cursor.X++
+	prev := &s.cells[y][x]
+	if prev.Rune == 0 {
+		return false
+	}
+
+	switch {
+	case w == 0:
+	case strings.HasSuffix(prev.Combining, string(zeroWidthJoiner)):
+	case isEmojiModifier(r) && prev.IsWide():
+	case isRegionalIndicator(r) && isRegionalIndicator(prev.Rune) && prev.Combining == "":
+	default:
+		return false
+	}
+
+	if len(prev.Combining)+len(string(r)) <= maxCombiningBytes {
+		prev.Combining += string(r)
+		s.dirty[y] = true
+	}
+	return true
+}
+
+// splitWide blanks both halves of a double-width glyph that straddles the
+// boundary between columns x-1 and x, so an edit starting or ending at x
+// never leaves half a glyph behind.
+func (s *Screen) splitWide(y, x int) {
+	if y < 0 || y >= s.rows || x <= 0 || x >= s.cols {
+		return
+	}
+	if !s.cells[y][x].IsContinuation() {
+		return
+	}
+	s.cells[y][x-1] = blankFrom(s.cells[y][x-1])
+	s.cells[y][x] = blankFrom(s.cells[y][x])
+	s.dirty[y] = true
+}
+
+// blankFrom returns a space keeping the colors, attributes and row marks of c
+func blankFrom(c Cell) Cell {
+	return Cell{Rune: ' ', FG: c.FG, BG: c.BG, Attrs: c.Attrs, Decoration: c.Decoration, Mark: c.Mark}
+}
+
+// blankCell returns a space in the current drawing attributes
+func (s *Screen) blankCell() Cell {
+	return Cell{Rune: ' ', FG: s.attrs.FG, BG: s.attrs.BG, Attrs: s.attrs.Attrs, Decoration: s.attrs.Decoration}
 }
 
 // SetAttrs sets the current drawing attributes
@@ -132,6 +286,16 @@ func (s *Screen) Attrs() Cell {
 	return s.attrs
 }
 
+// SetLink sets the hyperlink written cells point to (empty ends the link)
+func (s *Screen) SetLink(uri string) {
+	s.link = s.links.intern(uri)
+}
+
+// Link returns the hyperlink written cells currently point to
+func (s *Screen) Link() string {
+	return s.links.URI(s.link)
+}
+
 // ResetAttrs resets all drawing attributes to default
 func (s *Screen) ResetAttrs() {
 	s.attrs = DefaultCell()
@@ -152,11 +316,17 @@ func (s *Screen) ScrollRegion() (top, bottom int) {
 	return s.scrollTop, s.scrollBot
 }
 
-// ScrollUp scrolls the screen up by n lines within the scroll region
+// ScrollUp scrolls the screen up by n lines within the scroll region.
+// Returns the lines scrolled off, or nil if left/right margins limit the
+// scroll to part of each row.
 func (s *Screen) ScrollUp(n int) [][]Cell {
 	if n <= 0 {
 		return nil
 	}
+	if s.partialWidth() {
+		s.scrollColumns(n)
+		return nil
+	}
 
 	// Collect lines that will scroll off
 	scrolledOff := make([][]Cell, 0, n)
@@ -186,6 +356,10 @@ func (s *Screen) ScrollDown(n int) {
 	if n <= 0 {
 		return
 	}
+	if s.partialWidth() {
+		s.scrollColumns(-n)
+		return
+	}
 
 	for y := s.scrollBot; y >= s.scrollTop; y-- {
 		if y-n >= s.scrollTop {
@@ -199,6 +373,31 @@ func (s *Screen) ScrollDown(n int) {
 	}
 }
 
+// scrollColumns scrolls the rectangle inside the top/bottom and left/right
+// margins up by n rows (down if n < 0).
+func (s *Screen) scrollColumns(n int) {
+	left, right := s.marginLeft, s.marginRight
+	height := s.scrollBot - s.scrollTop + 1
+	for i := 0; i < height; i++ {
+		y := s.scrollTop + i
+		if n < 0 {
+			y = s.scrollBot - i
+		}
+		s.splitWide(y, left)
+		s.splitWide(y, right+1)
+		if src := y + n; src >= s.scrollTop && src <= s.scrollBot {
+			s.splitWide(src, left)
+			s.splitWide(src, right+1)
+			copy(s.cells[y][left:right+1], s.cells[src][left:right+1])
+		} else {
+			for x := left; x <= right; x++ {
+				s.cells[y][x] = DefaultCell()
+			}
+		}
+		s.dirty[y] = true
+	}
+}
+
 // Clear clears the entire screen
 func (s *Screen) Clear() {
 	for y := 0; y < s.rows; y++ {
@@ -211,19 +410,23 @@ func (s *Screen) Clear() {
 	s.cursor.Y = 0
 }
 
-// ClearLine clears the current line
+// ClearLine clears the current line. The row's marks are kept, since
+// shells clear the line as they redraw the prompt.
 func (s *Screen) ClearLine(mode int) {
 	y := s.cursor.Y
 	curX := s.cursor.X
 	if curX >= s.cols {
 		curX = s.cols - 1
 	}
+	mark := s.cells[y][0].Mark
 	switch mode {
 	case 0: // From cursor to end
+		s.splitWide(y, curX)
 		for x := curX; x < s.cols; x++ {
 			s.cells[y][x] = DefaultCell()
 		}
 	case 1: // From start to cursor
+		s.splitWide(y, curX+1)
 		for x := 0; x <= curX; x++ {
 			s.cells[y][x] = DefaultCell()
 		}
@@ -232,6 +435,7 @@ func (s *Screen) ClearLine(mode int) {
 			s.cells[y][x] = DefaultCell()
 		}
 	}
+	s.cells[y][0].Mark = mark
 	s.dirty[y] = true
 }
 
@@ -259,58 +463,118 @@ func (s *Screen) ClearScreen(mode int) {
 	}
 }
 
-// InsertChars inserts n blank characters at cursor
+// InsertChars inserts n blank characters at cursor, shifting the rest of
+// the line up to the right margin right
 func (s *Screen) InsertChars(n int) {
+	if s.cursor.X >= s.cols || s.outsideMargins() {
+		return
+	}
+	_, right := s.horizontalMargins()
+	s.shiftRight(s.cursor.Y, s.cursor.X, right, n)
+	s.marginWrap = false
+}
+
+// shiftRight moves the cells of row y from column x to right (inclusive)
+// n columns right, dropping those pushed past right and blanking the gap.
+func (s *Screen) shiftRight(y, x, right, n int) {
+	if n <= 0 || x > right {
+		return
+	}
+	wrapped := LineWrapped(s.cells[y])
+	mark := s.cells[y][0].Mark
+	s.splitWide(y, x)
+	s.splitWide(y, right+1)
+	for i := right; i >= x+n; i-- {
+		s.cells[y][i] = s.cells[y][i-n]
+	}
+	for i := x; i < x+n && i <= right; i++ {
+		s.cells[y][i] = DefaultCell()
+	}
+	// A wide glyph shifted into the last column lost its right half
+	if last := &s.cells[y][right]; last.IsWide() {
+		*last = blankFrom(*last)
+	}
+	if right == s.cols-1 {
+		s.cells[y][s.cols-1].Wrapped = wrapped
+	}
+	s.cells[y][0].Mark = mark
+	s.dirty[y] = true
+}
+
+// EraseChars blanks n cells starting at the cursor without moving it
+func (s *Screen) EraseChars(n int) {
 	y := s.cursor.Y
 	curX := s.cursor.X
-	if curX >= s.cols {
+	if curX >= s.cols || n <= 0 {
 		return
 	}
-	for x := s.cols - 1; x >= curX+n; x-- {
-		s.cells[y][x] = s.cells[y][x-n]
+	end := curX + n
+	if end > s.cols {
+		end = s.cols
 	}
-	for x := curX; x < curX+n && x < s.cols; x++ {
+	mark := s.cells[y][0].Mark
+	s.splitWide(y, curX)
+	s.splitWide(y, end)
+	for x := curX; x < end; x++ {
 		s.cells[y][x] = DefaultCell()
 	}
+	s.cells[y][0].Mark = mark
 	s.dirty[y] = true
 }
 
-// DeleteChars deletes n characters at cursor
+// DeleteChars deletes n characters at cursor, shifting the rest of the
+// line up to the right margin left
 func (s *Screen) DeleteChars(n int) {
 	y := s.cursor.Y
 	curX := s.cursor.X
-	if curX >= s.cols {
+	if curX >= s.cols || s.outsideMargins() {
 		return
 	}
-	for x := curX; x < s.cols-n; x++ {
+	_, right := s.horizontalMargins()
+	n = min(n, right-curX+1)
+	wrapped := LineWrapped(s.cells[y])
+	mark := s.cells[y][0].Mark
+	s.cells[y][s.cols-1].Wrapped = false
+	s.splitWide(y, curX)
+	s.splitWide(y, curX+n)
+	s.splitWide(y, right+1)
+	for x := curX; x <= right-n; x++ {
 		s.cells[y][x] = s.cells[y][x+n]
 	}
-	for x := s.cols - n; x < s.cols; x++ {
+	for x := right - n + 1; x <= right; x++ {
 		s.cells[y][x] = DefaultCell()
 	}
+	s.cells[y][s.cols-1].Wrapped = wrapped
+	s.cells[y][0].Mark = mark
+	s.marginWrap = false
 	s.dirty[y] = true
 }
 
-// InsertLines inserts n blank lines at cursor
+// InsertLines inserts n blank lines at cursor and moves it to the left
+// margin
 func (s *Screen) InsertLines(n int) {
-	if s.cursor.Y < s.scrollTop || s.cursor.Y > s.scrollBot {
+	if s.cursor.Y < s.scrollTop || s.cursor.Y > s.scrollBot || s.outsideMargins() {
 		return
 	}
 	savedTop := s.scrollTop
 	s.scrollTop = s.cursor.Y
 	s.ScrollDown(n)
 	s.scrollTop = savedTop
+	s.cursor.X, _ = s.horizontalMargins()
+	s.marginWrap = false
 }
 
-// DeleteLines deletes n lines at cursor
+// DeleteLines deletes n lines at cursor and moves it to the left margin
 func (s *Screen) DeleteLines(n int) {
-	if s.cursor.Y < s.scrollTop || s.cursor.Y > s.scrollBot {
+	if s.cursor.Y < s.scrollTop || s.cursor.Y > s.scrollBot || s.outsideMargins() {
 		return
 	}
 	savedTop := s.scrollTop
 	s.scrollTop = s.cursor.Y
 	s.ScrollUp(n)
 	s.scrollTop = savedTop
+	s.cursor.X, _ = s.horizontalMargins()
+	s.marginWrap = false
 }
 
 // IsDirty returns whether the given line needs repainting
@@ -356,6 +620,10 @@ func (s *Screen) Resize(cols, rows int) {
 				newCells[y][x] = DefaultCell()
 			}
 		}
+		// Narrowing can cut a wide glyph in half at the new margin
+		if cols > 0 && newCells[y][cols-1].IsWide() {
+			newCells[y][cols-1] = blankFrom(newCells[y][cols-1])
+		}
 		newDirty[y] = true
 	}
 
@@ -363,14 +631,59 @@ func (s *Screen) Resize(cols, rows int) {
 	s.dirty = newDirty
 	s.cols = cols
 	s.rows = rows
-	s.scrollTop = 0
-	s.scrollBot = rows - 1
+	s.fitModes()
 
 	// Clamp cursor
 	s.cursor.X = clamp(s.cursor.X, 0, cols-1)
 	s.cursor.Y = clamp(s.cursor.Y, 0, rows-1)
 }
 
+// Reflow resizes the screen, re-wrapping soft-wrapped lines to the new
+// width instead of cropping them. Rows pushed off the top to keep the
+// cursor on screen are returned for the scrollback.
+func (s *Screen) Reflow(cols, rows int) [][]Cell {
+	if cols == s.cols || cols < 1 || rows < 1 {
+		s.Resize(cols, rows)
+		return nil
+	}
+
+	oldX, oldY := s.cursor.X, s.cursor.Y
+	lines, curX, curY := reflow(s.cells, cols, s.cursor.X, s.cursor.Y)
+	if curY < 0 {
+		curX, curY = 0, len(lines)-1
+	}
+
+	// Blank rows below the cursor can go rather than push text off the top
+	for len(lines) > rows && len(lines)-1 > curY && trimmedLen(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	var scrolledOff [][]Cell
+	if excess := len(lines) - rows; excess > 0 {
+		top := min(excess, curY)
+		scrolledOff = lines[:top]
+		lines = lines[top : top+rows]
+		curY -= top
+	}
+	for len(lines) < rows {
+		lines = append(lines, blankRow(cols))
+	}
+
+	s.cells = lines
+	s.dirty = make([]bool, rows)
+	s.MarkAllDirty()
+	s.cols = cols
+	s.rows = rows
+	s.fitModes()
+	s.cursor.X = min(curX, cols)
+	s.cursor.Y = curY
+	// A cursor saved where it stands (e.g. by a full-screen app on the
+	// alternate screen) follows it; others are clamped when restored
+	if s.saved.cursor.X == oldX && s.saved.cursor.Y == oldY {
+		s.saved.cursor.X, s.saved.cursor.Y = s.cursor.X, s.cursor.Y
+	}
+	return scrolledOff
+}
+
 func clamp(v, min, max int) int {
 	if v < min {
 		return min
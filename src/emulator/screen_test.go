@@ -1,6 +1,9 @@
 package emulator
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestNewScreen(t *testing.T) {
 	s := NewScreen(80, 24)
@@ -295,3 +298,237 @@ func TestScreenCursorStyles(t *testing.T) {
 		t.Error("Cursor should be invisible")
 	}
 }
+
+func TestScreenWriteWide(t *testing.T) {
+	s := NewScreen(10, 2)
+
+	s.Write('a')
+	s.Write('日')
+	s.Write('b')
+
+	if c := s.Cell(1, 0); c.Rune != '日' || !c.IsWide() {
+		t.Errorf("Cell(1,0) = %q width %d, want wide '日'", c.Rune, c.Width)
+	}
+	if !s.Cell(2, 0).IsContinuation() {
+		t.Error("Cell(2,0) should be a continuation cell")
+	}
+	if s.Cell(3, 0).Rune != 'b' {
+		t.Errorf("Cell(3,0) = %q, want 'b'", s.Cell(3, 0).Rune)
+	}
+	if s.cursor.X != 4 {
+		t.Errorf("cursor.X = %d, want 4", s.cursor.X)
+	}
+}
+
+func TestScreenWriteWideAtMargin(t *testing.T) {
+	s := NewScreen(5, 2)
+
+	// Four narrow chars leave one column - too small for a wide glyph
+	for _, r := range "abcd日" {
+		s.Write(r)
+	}
+
+	if s.Cell(4, 0).Rune != ' ' {
+		t.Errorf("Cell(4,0) = %q, want blank padding", s.Cell(4, 0).Rune)
+	}
+	if c := s.Cell(0, 1); c.Rune != '日' || !c.IsWide() {
+		t.Errorf("Cell(0,1) = %q, want wide '日' after wrap", c.Rune)
+	}
+	if s.cursor.X != 2 || s.cursor.Y != 1 {
+		t.Errorf("cursor = (%d,%d), want (2,1)", s.cursor.X, s.cursor.Y)
+	}
+}
+
+func TestScreenCombiningMarks(t *testing.T) {
+	s := NewScreen(10, 1)
+
+	// e + COMBINING ACUTE ACCENT stays in one cell
+	s.Write('e')
+	s.Write('\u0301')
+	s.Write('x')
+
+	if got := s.Cell(0, 0).Text(); got != "e\u0301" {
+		t.Errorf("Cell(0,0).Text() = %q, want %q", got, "e\u0301")
+	}
+	if s.Cell(1, 0).Rune != 'x' {
+		t.Errorf("Cell(1,0) = %q, want 'x'", s.Cell(1, 0).Rune)
+	}
+
+	// A combining mark at column 0 has nothing to attach to
+	s.SetCursor(0, 0)
+	s.Write('\u0301')
+	if s.cursor.X != 0 {
+		t.Errorf("cursor.X = %d after orphan combining mark, want 0", s.cursor.X)
+	}
+}
+
+func TestScreenOverwriteWideHalf(t *testing.T) {
+	s := NewScreen(10, 1)
+	for _, r := range "日本" {
+		s.Write(r)
+	}
+
+	// Overwrite the right half of 日 - the left half must not survive alone
+	s.SetCursor(1, 0)
+	s.Write('x')
+
+	want := []rune{' ', 'x', '本'}
+	for i, r := range want {
+		if s.Cell(i, 0).Rune != r {
+			t.Errorf("Cell(%d,0) = %q, want %q", i, s.Cell(i, 0).Rune, r)
+		}
+		if i < 2 && s.Cell(i, 0).Width != WidthNormal {
+			t.Errorf("Cell(%d,0).Width = %d, want normal", i, s.Cell(i, 0).Width)
+		}
+	}
+}
+
+func TestScreenEditsNeverSplitWide(t *testing.T) {
+	fill := func() *Screen {
+		s := NewScreen(6, 1)
+		for _, r := range "a日本b" {
+			s.Write(r)
+		}
+		return s
+	}
+	noOrphans := func(t *testing.T, s *Screen) {
+		t.Helper()
+		cols, _ := s.Size()
+		for x := 0; x < cols; x++ {
+			c := s.Cell(x, 0)
+			if c.IsWide() && (x+1 >= cols || !s.Cell(x+1, 0).IsContinuation()) {
+				t.Errorf("wide cell at %d has no continuation", x)
+			}
+			if c.IsContinuation() && (x == 0 || !s.Cell(x-1, 0).IsWide()) {
+				t.Errorf("continuation at %d has no wide cell", x)
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		edit func(s *Screen)
+	}{
+		{"ClearLine from continuation", func(s *Screen) { s.SetCursor(2, 0); s.ClearLine(0) }},
+		{"ClearLine to wide", func(s *Screen) { s.SetCursor(3, 0); s.ClearLine(1) }},
+		{"EraseChars across pair", func(s *Screen) { s.SetCursor(2, 0); s.EraseChars(2) }},
+		{"InsertChars mid-glyph", func(s *Screen) { s.SetCursor(2, 0); s.InsertChars(1) }},
+		{"InsertChars pushes wide to margin", func(s *Screen) { s.SetCursor(0, 0); s.InsertChars(1) }},
+		{"DeleteChars mid-glyph", func(s *Screen) { s.SetCursor(2, 0); s.DeleteChars(2) }},
+		{"Resize cuts wide", func(s *Screen) { s.Resize(4, 1) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fill()
+			tt.edit(s)
+			noOrphans(t, s)
+		})
+	}
+}
+
+// rowText returns a screen row as a string with trailing spaces trimmed
+func rowText(s *Screen, y int) string {
+	var sb strings.Builder
+	cols, _ := s.Size()
+	for x := 0; x < cols; x++ {
+		sb.WriteString(s.Cell(x, y).Text())
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
+
+func TestScreenSoftWrapFlag(t *testing.T) {
+	s := NewScreen(5, 3)
+	for _, r := range "abcdefg" {
+		s.Write(r)
+	}
+	if !s.Cell(4, 0).Wrapped {
+		t.Error("row 0 should be flagged as soft-wrapped")
+	}
+	if s.Cell(4, 1).Wrapped {
+		t.Error("row 1 should not be flagged")
+	}
+
+	// Overwriting the last column clears the flag
+	s.SetCursor(4, 0)
+	s.Write('x')
+	if s.Cell(4, 0).Wrapped {
+		t.Error("rewriting the margin cell should clear the flag")
+	}
+
+	// Deleting characters keeps the flag at the margin
+	s = NewScreen(5, 2)
+	for _, r := range "abcdefg" {
+		s.Write(r)
+	}
+	s.SetCursor(1, 0)
+	s.DeleteChars(2)
+	if !s.Cell(4, 0).Wrapped || s.Cell(2, 0).Wrapped {
+		t.Error("DeleteChars should keep the wrap flag on the last column only")
+	}
+}
+
+func TestScreenReflow(t *testing.T) {
+	tests := []struct {
+		name       string
+		cols, rows int
+		input      string
+		newCols    int
+		want       []string
+		wantCursor [2]int
+		scrolled   int
+	}{
+		{
+			name: "narrow rewraps", cols: 10, rows: 4,
+			input: "0123456789ab\r\n$ ", newCols: 5,
+			want:       []string{"01234", "56789", "ab", "$"},
+			wantCursor: [2]int{2, 3},
+		},
+		{
+			name: "widen joins", cols: 5, rows: 4,
+			input: "0123456789ab\r\n$ ", newCols: 20,
+			want:       []string{"0123456789ab", "$", "", ""},
+			wantCursor: [2]int{2, 1},
+		},
+		{
+			name: "hard breaks stay", cols: 10, rows: 3,
+			input: "abc\r\ndef", newCols: 2,
+			want:       []string{"c", "de", "f"},
+			wantCursor: [2]int{1, 2},
+			scrolled:   1,
+		},
+		{
+			name: "overflow scrolls off the top", cols: 6, rows: 2,
+			input: "abcdef\r\nghijkl", newCols: 3,
+			want:       []string{"ghi", "jkl"},
+			wantCursor: [2]int{3, 1},
+			scrolled:   2,
+		},
+		{
+			name: "wide glyph moves to next row", cols: 6, rows: 2,
+			input: "abc日", newCols: 4,
+			want:       []string{"abc", "日"},
+			wantCursor: [2]int{2, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScreen(tt.cols, tt.rows)
+			p := NewParser(s, NewScrollback())
+			p.Parse([]byte(tt.input))
+
+			scrolled := s.Reflow(tt.newCols, tt.rows)
+			if len(scrolled) != tt.scrolled {
+				t.Errorf("scrolled %d rows, want %d", len(scrolled), tt.scrolled)
+			}
+			for y := 0; y < tt.rows && y < len(tt.want); y++ {
+				if got := rowText(s, y); got != tt.want[y] {
+					t.Errorf("row %d = %q, want %q", y, got, tt.want[y])
+				}
+			}
+			cur := s.Cursor()
+			if cur.X != tt.wantCursor[0] || cur.Y != tt.wantCursor[1] {
+				t.Errorf("cursor = (%d,%d), want (%d,%d)", cur.X, cur.Y, tt.wantCursor[0], tt.wantCursor[1])
+			}
+		})
+	}
+}
@@ -1,150 +1,186 @@
 package emulator
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
-	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 	"unsafe"
 )
 
 const (
 	ringSize     = 100             // Lines kept in memory ring buffer
-	diskMaxBytes = 5 * 1024 * 1024 // 5MB max disk file size
-	diskTrimTo   = 2 * 1024 * 1024 // Trim to ~2MB when over limit
+	diskMaxBytes = 5 * 1024 * 1024 // Live segment size that triggers an archive roll-over
+	diskTrimTo   = 2 * 1024 * 1024 // Live segment size kept after a roll-over
+	ageCheckGap  = time.Minute     // Least time between checks of the retention age limit
 	cacheWindow  = 1_000           // Lines loaded from disk per cache fill
 )
 
-// ScrollbackPath returns the JSONL scrollback file path for a session name.
+// scrollbackExt is the file extension of persisted scrollback files
+const scrollbackExt = ".scrollback"
+
+// SessionsDir returns the directory holding per-session scrollback files.
 // Uses the same directory as ptylog.
-func ScrollbackPath(name string) string {
+func SessionsDir() string {
 	home, _ := os.UserHomeDir()
-	dir := filepath.Join(home, ".config", "prompt-grid", "sessions")
+	return filepath.Join(home, ".config", "prompt-grid", "sessions")
+}
+
+// ScrollbackPath returns the scrollback segment path for a session name.
+func ScrollbackPath(name string) string {
+	dir := SessionsDir()
 	os.MkdirAll(dir, 0755)
 	r := strings.NewReplacer("/", "_", "\\", "_", "\x00", "_")
-	return filepath.Join(dir, r.Replace(name)+".scrollback")
+	return filepath.Join(dir, r.Replace(name)+scrollbackExt)
 }
 
-// DeleteScrollback removes the scrollback file for a session.
+// DeleteScrollback removes the scrollback file, index, archives, images
+// and snapshot for a session.
 func DeleteScrollback(name string) {
-	os.Remove(ScrollbackPath(name))
+	path := ScrollbackPath(name)
+	os.Remove(path)
+	os.Remove(indexPath(path))
+	os.RemoveAll(archiveDir(path))
+	os.RemoveAll(imagesDir(path))
+	os.Remove(snapshotPath(path))
 }
 
-// RenameScrollback renames a session's scrollback file.
+// RenameScrollback renames a session's scrollback file, index, archives,
+// images and snapshot. Use Scrollback.Rename for a scrollback that is open.
 func RenameScrollback(oldName, newName string) {
-	os.Rename(ScrollbackPath(oldName), ScrollbackPath(newName))
+	renameFiles(ScrollbackPath(oldName), ScrollbackPath(newName))
+}
+
+// renameFiles moves a scrollback file and everything stored beside it
+func renameFiles(oldPath, newPath string) error {
+	err := os.Rename(oldPath, newPath)
+	os.Rename(indexPath(oldPath), indexPath(newPath))
+	os.Rename(archiveDir(oldPath), archiveDir(newPath))
+	os.Rename(imagesDir(oldPath), imagesDir(newPath))
+	os.Rename(snapshotPath(oldPath), snapshotPath(newPath))
+	return err
 }
 
 // Scrollback manages terminal scrollback history with disk persistence.
 // Only the most recent ringSize lines are kept in memory; older lines live
-// in a JSONL file and are loaded on demand when the user scrolls back.
+// in an indexed binary segment file (see segment.go) and are loaded on
+// demand when the user scrolls back. When the segment grows past
+// diskMaxBytes its oldest lines roll into compressed archives (see
+// archive.go), which stay readable until the retention policy drops them.
+// Every line pushed is stamped with the time it arrived.
 type Scrollback struct {
 	mu sync.Mutex
 
-	// Ring buffer — holds last ringSize lines in memory
-	ring     [][]Cell
-	ringHead int // Index of the oldest line in the ring
-	ringFill int // Number of valid entries (0..ringSize)
-	total    int // Total accessible lines (= disk file line count)
+	// Ring buffer — holds last ringSize lines in memory (more after a
+	// reflow to a narrower width, so no reflowed row is lost)
+	ring      [][]Cell
+	ringTimes []int64 // Arrival time of each ring line, Unix milliseconds (0 = unknown)
+	ringHead  int     // Index of the oldest line in the ring
+	ringFill  int     // Number of valid entries (0..len(ring))
+	total     int     // Total accessible lines (= archived + live segment lines)
 
 	// Disk storage
-	path   string
-	file   *os.File
-	wbuf   *bufio.Writer
-	fbytes int64 // Approximate bytes written (for trim check)
+	path      string
+	seg       *segment
+	archives  []archiveFile // Sealed archives, oldest first
+	archived  int           // Lines held in archives
+	archBytes int64         // Bytes held in archives
+	arch      *archive      // Most recently read archive, decompressed
+	retention Retention
+	ageCheck  time.Time // When the retention age limit was last checked
+	dropped   int       // Lines dropped from the front so far, by retention or Clear
 
 	// replay=true suppresses disk writes during ptylog replay
 	replay bool
 
-	// frozen=true suppresses trimming while user is in scroll mode
+	// frozen=true suppresses retention while user is in scroll mode
 	frozen bool
 
 	// View cache — window of disk lines loaded on demand
 	cache      [][]Cell
+	cacheTimes []int64
 	cacheStart int // Absolute line index of cache[0]
+
+	images *ImageStore // Inline images shown by image cells, saved beside the file
+	links  *LinkTable  // Hyperlink targets of the lines' cells and the screens'
+
+	now func() time.Time // Clock stamping pushed lines; time.Now unless a test sets it
 }
 
 // NewScrollback creates an in-memory-only scrollback (no disk backing).
 // Used in tests and when no path is available.
 func NewScrollback() *Scrollback {
 	return &Scrollback{
-		ring: make([][]Cell, ringSize),
+		ring:      make([][]Cell, ringSize),
+		ringTimes: make([]int64, ringSize),
+		images:    NewImageStore(""),
+		links:     &LinkTable{},
+		now:       time.Now,
 	}
 }
 
 // NewScrollbackWithPath creates a disk-backed scrollback.
-// Existing lines from a previous run are loaded from path into the ring.
+// Existing lines from a previous run are loaded from path into the ring;
+// a JSONL file written by an older build is converted on the way.
 func NewScrollbackWithPath(path string) (*Scrollback, error) {
 	// Ensure directory exists
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return nil, err
 	}
 
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	links := &LinkTable{}
+	seg, err := openSegment(path, links)
 	if err != nil {
 		return nil, err
 	}
 
 	sb := &Scrollback{
-		ring: make([][]Cell, ringSize),
-		path: path,
-		file: f,
-	}
-
-	// Count total disk lines and load last ringSize into ring
-	sb.total = sb.loadFromFile(f)
-
-	// Seek to end for appending
-	size, err := f.Seek(0, io.SeekEnd)
-	if err != nil {
-		f.Close()
-		return nil, err
-	}
-	sb.fbytes = size
-	sb.wbuf = bufio.NewWriterSize(f, 64*1024)
-
+		ring:   make([][]Cell, ringSize),
+		path:   path,
+		seg:    seg,
+		images: NewImageStore(imagesDir(path)),
+		links:  links,
+		now:    time.Now,
+	}
+	sb.listArchivesLocked()
+	sb.loadRingLocked()
 	return sb, nil
 }
 
-// loadFromFile reads all lines from f, sets ring to last ringSize lines, returns total count.
-// f must be positioned at start. Caller is responsible for seeking to end afterward.
-func (s *Scrollback) loadFromFile(f *os.File) int {
-	scanner := bufio.NewScanner(f)
-	scanner.Buffer(make([]byte, 2*1024*1024), 2*1024*1024)
-
-	// Collect all raw lines (just bytes, not decoded yet)
-	var rawLines [][]byte
-	for scanner.Scan() {
-		b := make([]byte, len(scanner.Bytes()))
-		copy(b, scanner.Bytes())
-		rawLines = append(rawLines, b)
+// listArchivesLocked reads the archives on disk and totals their lines
+// and bytes. Caller must hold s.mu (or own s exclusively).
+func (s *Scrollback) listArchivesLocked() {
+	s.archives = listArchives(archiveDir(s.path))
+	s.archived, s.archBytes, s.arch = 0, 0, nil
+	for _, a := range s.archives {
+		s.archived += a.lines
+		s.archBytes += a.size
 	}
+}
 
-	total := len(rawLines)
-
-	// Load last ringSize into ring
-	start := total - ringSize
-	if start < 0 {
-		start = 0
-	}
-	tail := rawLines[start:]
-	for i, raw := range tail {
-		s.ring[i] = decodeLine(raw)
-	}
+// loadRingLocked fills the ring with the last ringSize lines of the segment.
+// Caller must hold s.mu (or own s exclusively).
+func (s *Scrollback) loadRingLocked() {
+	s.total = s.archived + s.seg.lines
+	tail, times := s.seg.readLines(s.seg.lines-ringSize, s.seg.lines)
+	s.ring = make([][]Cell, ringSize)
+	s.ringTimes = make([]int64, ringSize)
+	copy(s.ring, tail)
+	copy(s.ringTimes, times)
 	s.ringFill = len(tail)
 	s.ringHead = 0
-
-	return total
+	s.cache = nil
 }
 
 // SetReplayMode enables or disables replay mode.
 // In replay mode, Push() does NOT modify the ring or write to disk.
 // The ring keeps what was loaded from disk at startup.
-// Call SetReplayMode(true) before ptylog.ReplayLog, false afterward.
+// Set it around a ptylog.ReplayLog into this scrollback's parser; the app
+// doesn't replay logs today, restoring sessions from snapshots instead.
 func (s *Scrollback) SetReplayMode(on bool) {
 	s.mu.Lock()
 	s.replay = on
@@ -152,15 +188,29 @@ func (s *Scrollback) SetReplayMode(on bool) {
 }
 
 // SetFrozen enables or disables frozen mode.
-// When frozen, disk trimming is suppressed so old lines aren't deleted
-// while the user is viewing scrollback history.
+// When frozen, the retention policy is suspended so old lines aren't
+// deleted while the user is viewing scrollback history; it catches up on
+// the next Push after thawing.
 func (s *Scrollback) SetFrozen(on bool) {
 	s.mu.Lock()
 	s.frozen = on
 	s.mu.Unlock()
 }
 
-// Push adds lines to the scrollback.
+// SetRetention sets how much history a disk-backed scrollback keeps and
+// applies it right away.
+func (s *Scrollback) SetRetention(r Retention) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = r
+	if s.seg != nil {
+		s.applyRetentionLocked()
+	}
+}
+
+// Push adds lines to the scrollback, stamped with the current time. A
+// stamp is never older than the line before it, so times only grow even if
+// the clock steps back.
 func (s *Scrollback) Push(lines ...[]Cell) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -170,34 +220,102 @@ func (s *Scrollback) Push(lines ...[]Cell) {
 		return
 	}
 
+	at := s.now().UnixMilli()
+	if s.ringFill > 0 {
+		if last := s.ringTimes[(s.ringHead+s.ringFill-1)%len(s.ring)]; last > at {
+			at = last
+		}
+	}
 	for _, line := range lines {
 		lineCopy := make([]Cell, len(line))
 		copy(lineCopy, line)
 
 		// Write to disk if backed
-		if s.wbuf != nil {
-			s.writeLineLocked(lineCopy)
+		if s.seg != nil {
+			s.seg.append(lineCopy, at)
 		}
 
 		// Update ring buffer
-		if s.ringFill < ringSize {
-			idx := (s.ringHead + s.ringFill) % ringSize
-			s.ring[idx] = lineCopy
+		var idx int
+		if s.ringFill < len(s.ring) {
+			idx = (s.ringHead + s.ringFill) % len(s.ring)
 			s.ringFill++
 		} else {
 			// Ring full: overwrite oldest
-			s.ring[s.ringHead] = lineCopy
-			s.ringHead = (s.ringHead + 1) % ringSize
+			idx = s.ringHead
+			s.ringHead = (s.ringHead + 1) % len(s.ring)
 		}
+		s.ring[idx] = lineCopy
+		s.ringTimes[idx] = at
 		s.total++
 	}
 
-	if s.wbuf != nil {
-		_ = s.wbuf.Flush()
+	if s.seg != nil {
+		_ = s.seg.flush()
 		s.checkTrimLocked()
 	}
 }
 
+// Reflow re-wraps the in-memory lines to cols, joining soft-wrapped rows
+// first. The ring's lines are rewritten at the tail of the disk file so
+// line indices stay in step; older lines keep the width they were written at.
+// Each new row keeps the arrival time of the line it came from.
+func (s *Scrollback) Reflow(cols int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ringFill == 0 || cols < 1 {
+		return
+	}
+	rows := make([][]Cell, s.ringFill)
+	rowTimes := make([]int64, s.ringFill)
+	for i := range rows {
+		rows[i] = s.ring[(s.ringHead+i)%len(s.ring)]
+		rowTimes[i] = s.ringTimes[(s.ringHead+i)%len(s.ring)]
+	}
+	lines, _, _ := reflow(rows, cols, 0, -1)
+	times := reflowTimes(rows, rowTimes, lines)
+
+	// The ring may grow past ringSize when narrowing; keep every row
+	n := max(ringSize, len(lines))
+	ringStart := s.total - s.ringFill
+	s.total = ringStart + len(lines)
+	s.ring = make([][]Cell, n)
+	s.ringTimes = make([]int64, n)
+	copy(s.ring, lines)
+	copy(s.ringTimes, times)
+	s.ringHead = 0
+	s.ringFill = len(lines)
+
+	if s.seg != nil {
+		_ = s.seg.truncate(ringStart - s.archived)
+		for i, line := range lines {
+			s.seg.append(line, times[i])
+		}
+		_ = s.seg.flush()
+	}
+}
+
+// reflowTimes returns the arrival time of each row reflow made from rows:
+// that of the first row of the logical line it came from. Both sides hold
+// the same logical lines in order, each ending at a row that isn't wrapped.
+func reflowTimes(rows [][]Cell, times []int64, out [][]Cell) []int64 {
+	res := make([]int64, len(out))
+	i := 0 // First row of the current logical line in rows
+	for j, row := range out {
+		if i < len(times) {
+			res[j] = times[i]
+		}
+		if !LineWrapped(row) {
+			for i < len(rows) && LineWrapped(rows[i]) {
+				i++
+			}
+			i++
+		}
+	}
+	return res
+}
+
 // Count returns the total number of accessible scrollback lines.
 func (s *Scrollback) Count() int {
 	s.mu.Lock()
@@ -210,26 +328,57 @@ func (s *Scrollback) Count() int {
 func (s *Scrollback) Line(i int) []Cell {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	line, _ := s.lineLocked(i)
+	return line
+}
+
+// Time returns when the line at absolute index i arrived, or the zero time
+// if it's out of range or was written before arrival times were kept.
+func (s *Scrollback) Time(i int) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, at := s.lineLocked(i)
+	if at == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(at)
+}
+
+// LineAt returns the index of the first line that arrived at or after t,
+// or Count() if none did. Arrival times only grow, so this is a binary
+// search; lines without a time count as older than any t.
+func (s *Scrollback) LineAt(t time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := t.UnixMilli()
+	return sort.Search(s.total, func(i int) bool {
+		_, at := s.lineLocked(i)
+		return at >= ms
+	})
+}
 
+// lineLocked returns line i and its arrival time in Unix milliseconds,
+// filling the cache from disk on a miss. Caller must hold s.mu.
+func (s *Scrollback) lineLocked(i int) ([]Cell, int64) {
 	if i < 0 || i >= s.total {
-		return nil
+		return nil, 0
 	}
 
 	// Check ring first (most recent lines)
 	ringStart := s.total - s.ringFill
 	if i >= ringStart {
-		offset := i - ringStart
-		return s.ring[(s.ringHead+offset)%ringSize]
+		k := (s.ringHead + i - ringStart) % len(s.ring)
+		return s.ring[k], s.ringTimes[k]
 	}
 
 	// No disk — old lines are gone
-	if s.path == "" {
-		return nil
+	if s.seg == nil {
+		return nil, 0
 	}
 
 	// Check cache
 	if len(s.cache) > 0 && i >= s.cacheStart && i < s.cacheStart+len(s.cache) {
-		return s.cache[i-s.cacheStart]
+		return s.cache[i-s.cacheStart], s.cacheTimes[i-s.cacheStart]
 	}
 
 	// Load window from disk
@@ -242,13 +391,13 @@ func (s *Scrollback) Line(i int) []Cell {
 		end = ringStart
 	}
 
-	s.cache = s.loadDiskRange(start, end)
+	s.cache, s.cacheTimes = s.readLinesLocked(start, end)
 	s.cacheStart = start
 
 	if i >= s.cacheStart && i < s.cacheStart+len(s.cache) {
-		return s.cache[i-s.cacheStart]
+		return s.cache[i-s.cacheStart], s.cacheTimes[i-s.cacheStart]
 	}
-	return nil
+	return nil, 0
 }
 
 // Lines returns a slice of lines in [start, end).
@@ -270,39 +419,145 @@ func (s *Scrollback) Lines(start, end int) [][]Cell {
 	return result
 }
 
-// Clear removes all lines from memory and truncates the disk file.
+// ScanLines calls fn for each line in [0, end), oldest first, until fn
+// returns false. Disk lines are decoded one at a time from separate file
+// handles, so the whole segment is never held in memory and Push isn't
+// blocked during the scan. The handles are opened before the lock is
+// released, so the scan sees the lines as they were when it began even if
+// a roll-over or the retention policy rewrites the files meanwhile. fn gets
+// the indices of that moment; shift is how many lines have since been
+// dropped from the front, to subtract from them for current indices.
+func (s *Scrollback) ScanLines(end int, fn func(i int, line []Cell) bool) (shift int) {
+	s.mu.Lock()
+	if end > s.total {
+		end = s.total
+	}
+	ringStart := s.total - s.ringFill
+	var ring [][]Cell
+	for i := max(ringStart, 0); i < end; i++ {
+		ring = append(ring, s.ring[(s.ringHead+i-ringStart)%len(s.ring)])
+	}
+	var files []historyFile
+	if s.seg != nil && ringStart > 0 {
+		_ = s.seg.flush()
+		for _, a := range s.archives {
+			f, _ := os.Open(a.path)
+			files = append(files, historyFile{f: f, lines: a.lines, gz: true})
+		}
+		f, _ := os.Open(s.path)
+		files = append(files, historyFile{f: f, lines: max(ringStart-s.archived, 0)})
+	}
+	dropped := s.dropped
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		shift = s.dropped - dropped
+		s.mu.Unlock()
+	}()
+	stopped := false
+	scanHistoryFiles(files, s.links, func(i int, line []Cell) bool {
+		if i >= ringStart || i >= end {
+			return false
+		}
+		stopped = !fn(i, line)
+		return !stopped
+	})
+	if stopped {
+		return
+	}
+	for i, line := range ring {
+		if !fn(ringStart+i, line) {
+			return
+		}
+	}
+	return
+}
+
+// Flush writes buffered lines to the disk file, so readers of the file
+// (such as a cross-session search) see everything pushed so far.
+func (s *Scrollback) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seg != nil {
+		_ = s.seg.flush()
+	}
+}
+
+// Images returns the store holding the inline images shown in the
+// scrollback and on screen. Clear leaves it alone, since images may still
+// be on screen; the store's own caps bound it.
+func (s *Scrollback) Images() *ImageStore {
+	return s.images
+}
+
+// Links returns the table the link IDs of the scrollback's and screens'
+// cells resolve in. Like the image store it lasts as long as the
+// scrollback; it holds each distinct target once, up to maxLinks.
+func (s *Scrollback) Links() *LinkTable {
+	return s.links
+}
+
+// Clear removes all lines from memory, truncates the disk file and deletes
+// the archives.
 func (s *Scrollback) Clear() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.ring = make([][]Cell, ringSize)
+	s.ringTimes = make([]int64, ringSize)
 	s.ringHead = 0
 	s.ringFill = 0
+	s.dropped += s.total
 	s.total = 0
 	s.cache = nil
 	s.cacheStart = 0
 
-	if s.file != nil {
-		_ = s.wbuf.Flush()
-		_ = s.file.Truncate(0)
-		_, _ = s.file.Seek(0, io.SeekEnd)
-		s.wbuf.Reset(s.file)
-		s.fbytes = 0
+	if s.seg != nil {
+		_ = s.seg.truncate(0)
+		os.RemoveAll(archiveDir(s.path))
+		s.archives, s.archived, s.archBytes, s.arch = nil, 0, 0, nil
 	}
 }
 
-// Close flushes and closes the disk file.
-func (s *Scrollback) Close() {
+// Rename moves the scrollback's file, index, archives, images and snapshot to
+// path and carries on writing there; lines and their times are kept. A
+// scrollback without a file is left alone.
+func (s *Scrollback) Rename(path string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.wbuf != nil {
-		_ = s.wbuf.Flush()
+	if s.seg == nil || path == s.path {
+		return nil
 	}
-	if s.file != nil {
-		_ = s.file.Close()
-		s.file = nil
-		s.wbuf = nil
+	s.seg.close()
+	s.seg = nil
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	renameErr := renameFiles(s.path, path)
+	if renameErr != nil {
+		path = s.path // Keep writing where the lines are
+	}
+	seg, err := openSegment(path, s.links)
+	if err != nil {
+		return err
+	}
+	s.path, s.seg = path, seg
+	s.listArchivesLocked()
+	s.images.setDir(imagesDir(path))
+	return renameErr
+}
+
+// Close flushes and closes the disk file, and waits for images to be saved.
+func (s *Scrollback) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.images.flush()
+	if s.seg != nil {
+		s.seg.close()
+		s.seg = nil
 	}
 }
 
@@ -314,48 +569,84 @@ func (s *Scrollback) MemoryBytes() int {
 	cellSize := int(unsafe.Sizeof(Cell{}))
 	total := 0
 	for i := 0; i < s.ringFill; i++ {
-		line := s.ring[(s.ringHead+i)%ringSize]
+		line := s.ring[(s.ringHead+i)%len(s.ring)]
 		total += len(line) * cellSize
 	}
 	return total
 }
 
-// writeLineLocked encodes one line and appends to the buffered writer.
-// Caller must hold s.mu.
-func (s *Scrollback) writeLineLocked(line []Cell) {
-	// Find last non-empty cell (trim trailing blanks to save space)
-	last := -1
-	for i := len(line) - 1; i >= 0; i-- {
-		c := line[i]
-		if c.Rune != 0 && c.Rune != ' ' {
-			last = i
-			break
-		}
-		if c.FG.Type != ColorDefault || c.BG.Type != ColorDefault || c.Attrs != 0 {
-			last = i
-			break
-		}
-	}
+// The JSONL line encoding of older builds follows. Their files are still
+// read, to convert them to segments and to search them before that.
 
-	// Encode as JSON array of [rune, fgPacked, bgPacked, attrs]
-	encoded := make([][4]int64, last+1)
-	for i := 0; i <= last; i++ {
-		c := line[i]
-		encoded[i] = [4]int64{
-			int64(c.Rune),
-			packColor(c.FG),
-			packColor(c.BG),
-			int64(c.Attrs),
-		}
-	}
+// encodedWrapped marks a soft-wrapped row's last cell in the attrs slot,
+// above the bits used by AttrFlags.
+const encodedWrapped = 1 << 8
 
-	data, err := json.Marshal(encoded)
-	if err != nil {
-		return
+// encodedLinkShift places a cell's 1-based index into its line's link
+// table in the attrs slot, above encodedWrapped. Zero means no link.
+const encodedLinkShift = 16
+
+// encodedMarkShift places a row's shell integration mark flags in its
+// first cell's attrs slot, above the link index; the exit code sits
+// encodedExitShift bits up.
+const (
+	encodedMarkShift = 40
+	encodedExitShift = 48
+)
+
+// Line encoding versions. The base format has no version field; in
+// lineFormatDecorated each tuple carries the cell's Decoration after attrs:
+// [rune, fg, bg, attrs, decoration, width, combining runes...]. Lines were
+// written in the oldest format that held them.
+const (
+	lineFormatBase      = 1
+	lineFormatDecorated = 2
+)
+
+// diskLine is the encoding of a line with hyperlinks or decorations: the
+// format version, the cell tuples and the link URIs they index. Other
+// lines are a bare tuple array.
+type diskLine struct {
+	Version int       `json:"v,omitempty"`
+	Cells   [][]int64 `json:"cells"`
+	Links   []string  `json:"links,omitempty"`
+}
+
+// decodeCell unpacks a cell's JSON integer tuple, resolving link indexes
+// against links and interning the target in table. Short tuples yield a
+// blank cell.
+func decodeCell(e []int64, links []string, version int, table *LinkTable) Cell {
+	if len(e) < 4 {
+		return DefaultCell()
+	}
+	var deco Decoration
+	if version >= lineFormatDecorated && len(e) > 4 {
+		deco = Decoration(e[4])
+		e = append(e[:4:4], e[5:]...)
+	}
+	c := Cell{
+		Rune:       rune(e[0]),
+		FG:         unpackColor(e[1]),
+		BG:         unpackColor(e[2]),
+		Attrs:      AttrFlags(e[3]),
+		Wrapped:    e[3]&encodedWrapped != 0,
+		Decoration: deco,
+	}
+	c.Mark = Mark{Flags: MarkFlags(e[3] >> encodedMarkShift), ExitCode: uint8(e[3] >> encodedExitShift)}
+	if link := int(e[3]>>encodedLinkShift) & 0xFFFFFF; link > 0 && link <= len(links) {
+		c.Link = table.intern(links[link-1])
+	}
+	if len(e) > 4 {
+		c.Width = CellWidth(e[4])
+	}
+	if len(e) > 5 {
+		var sb strings.Builder
+		for _, r := range e[5:] {
+			sb.WriteRune(rune(r))
+		}
+		c.Combining = sb.String()
 	}
-	n, _ := s.wbuf.Write(data)
-	s.wbuf.WriteByte('\n')
-	s.fbytes += int64(n) + 1
+	return c
 }
 
 // packColor encodes a Color into a single int64.
@@ -382,127 +673,54 @@ func unpackColor(v int64) Color {
 	}
 }
 
-// decodeLine parses a JSON-encoded line back into a []Cell.
-func decodeLine(data []byte) []Cell {
+// decodeLine parses a JSONL-encoded line back into a []Cell, interning
+// its links in table.
+func decodeLine(data []byte, table *LinkTable) []Cell {
 	if len(data) == 0 || bytes.Equal(data, []byte("[]")) {
 		return nil
 	}
-	var encoded [][4]int64
-	if err := json.Unmarshal(data, &encoded); err != nil {
-		return nil
-	}
-	line := make([]Cell, len(encoded))
-	for i, e := range encoded {
-		line[i] = Cell{
-			Rune:  rune(e[0]),
-			FG:    unpackColor(e[1]),
-			BG:    unpackColor(e[2]),
-			Attrs: AttrFlags(e[3]),
+	var dl diskLine
+	if data[0] == '{' {
+		if err := json.Unmarshal(data, &dl); err != nil {
+			return nil
 		}
-	}
-	return line
-}
-
-// loadDiskRange reads lines [start, end) from the disk file.
-// Opens a separate read-only FD to avoid position conflict with the writer.
-// Caller must hold s.mu.
-func (s *Scrollback) loadDiskRange(start, end int) [][]Cell {
-	if s.path == "" || start >= end {
-		return nil
-	}
-
-	// Flush any buffered writes first
-	if s.wbuf != nil {
-		_ = s.wbuf.Flush()
-	}
-
-	// Open a separate read FD
-	rf, err := os.Open(s.path)
-	if err != nil {
+	} else if err := json.Unmarshal(data, &dl.Cells); err != nil {
 		return nil
 	}
-	defer rf.Close()
-
-	scanner := bufio.NewScanner(rf)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
-
-	var result [][]Cell
-	lineNum := 0
-	for scanner.Scan() {
-		if lineNum >= end {
-			break
-		}
-		if lineNum >= start {
-			result = append(result, decodeLine(scanner.Bytes()))
-		}
-		lineNum++
+	line := make([]Cell, len(dl.Cells))
+	for i, e := range dl.Cells {
+		line[i] = decodeCell(e, dl.Links, dl.Version, table)
 	}
-	return result
+	return line
 }
 
-// checkTrimLocked trims the disk file if it exceeds diskMaxBytes.
-// Skipped when frozen (user is in scroll mode) to avoid deleting lines they're viewing.
-// Caller must hold s.mu.
+// checkTrimLocked rolls the oldest lines of the live segment into an
+// archive once it exceeds diskMaxBytes, then applies the retention policy
+// if a limit has been crossed. Caller must hold s.mu.
 func (s *Scrollback) checkTrimLocked() {
-	if s.frozen || s.fbytes < diskMaxBytes {
-		return
-	}
-	s.trimDiskLocked()
-}
-
-// trimDiskLocked reads the file, drops lines from the front to reach diskTrimTo bytes,
-// rewrites the file, and updates total/bytes.
-func (s *Scrollback) trimDiskLocked() {
-	if s.file == nil {
-		return
-	}
-
-	// Flush first
-	_ = s.wbuf.Flush()
-
-	// Read entire file
-	data, err := os.ReadFile(s.path)
-	if err != nil {
-		return
+	if s.seg.size >= diskMaxBytes {
+		s.archiveLocked()
 	}
-
-	if int64(len(data)) <= diskTrimTo {
-		s.fbytes = int64(len(data))
-		return
-	}
-
-	// Find cut point: discard first (len-diskTrimTo) bytes, then align to next newline
-	cutPoint := int64(len(data)) - diskTrimTo
-	for cutPoint < int64(len(data)) {
-		if data[cutPoint] == '\n' {
-			cutPoint++
-			break
-		}
-		cutPoint++
+	if s.retentionDueLocked() {
+		s.applyRetentionLocked()
 	}
+}
 
-	kept := data[cutPoint:]
-
-	// Rewrite file
-	_ = s.file.Truncate(0)
-	_, _ = s.file.Seek(0, io.SeekStart)
-	_, _ = s.file.Write(kept)
-	_ = s.file.Sync()
-	_, _ = s.file.Seek(0, io.SeekEnd)
-	s.wbuf.Reset(s.file)
-	s.fbytes = int64(len(kept))
-
-	// Recount lines (total changed)
-	s.total = bytes.Count(kept, []byte{'\n'})
-
-	// Invalidate cache
-	s.cache = nil
-
-	// Reload ring from new file tail
-	rf, err := os.Open(s.path)
-	if err != nil {
-		return
-	}
-	defer rf.Close()
-	s.total = s.loadFromFile(rf)
+// readLinesLocked returns lines [start, end), which must be below the ring,
+// and their arrival times, reading archives and the live segment as
+// needed. Caller must hold s.mu.
+func (s *Scrollback) readLinesLocked(start, end int) ([][]Cell, []int64) {
+	lines := make([][]Cell, 0, end-start)
+	times := make([]int64, 0, end-start)
+	for i := start; i < min(end, s.archived); i++ {
+		line, at := s.archiveLineLocked(i)
+		lines = append(lines, line)
+		times = append(times, at)
+	}
+	if end > s.archived {
+		live, liveTimes := s.seg.readLines(max(start, s.archived)-s.archived, end-s.archived)
+		lines = append(lines, live...)
+		times = append(times, liveTimes...)
+	}
+	return lines, times
 }
@@ -1,9 +1,12 @@
 package emulator
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewScrollback(t *testing.T) {
@@ -304,7 +307,7 @@ func TestScrollbackReplayMode(t *testing.T) {
 	}
 }
 
-// TestScrollbackDiskLineColors verifies that colors survive the JSONL round-trip.
+// TestScrollbackDiskLineColors verifies that colors survive the disk round-trip.
 func TestScrollbackDiskLineColors(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "test.scrollback")
@@ -381,3 +384,302 @@ func TestScrollbackClearWithDisk(t *testing.T) {
 		t.Errorf("file size after Clear = %d, want 0", info.Size())
 	}
 }
+
+// TestScrollbackDiskWideCells verifies wide and combined cells survive the
+// disk round-trip, and that lines written in the older four-element
+// format still decode.
+func TestScrollbackDiskWideCells(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.scrollback")
+
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+
+	s := NewScreen(6, 1)
+	for _, r := range "e\u0301日" {
+		s.Write(r)
+	}
+	sb.Push(s.ScrollUp(1)[0])
+	for i := 1; i < 150; i++ {
+		sb.Push([]Cell{{Rune: 'A'}})
+	}
+
+	got := sb.Line(0)
+	if len(got) < 3 {
+		t.Fatalf("Line(0) has %d cells, want at least 3", len(got))
+	}
+	if got[0].Text() != "e\u0301" {
+		t.Errorf("Cell 0 = %q, want %q", got[0].Text(), "e\u0301")
+	}
+	if got[1].Rune != '日' || !got[1].IsWide() {
+		t.Errorf("Cell 1 = %q width %d, want wide '日'", got[1].Rune, got[1].Width)
+	}
+	if !got[2].IsContinuation() {
+		t.Error("Cell 2 should be a continuation (trailing trim must keep it)")
+	}
+	sb.Close()
+
+	legacy := decodeLine([]byte(`[[72,0,0,1],[105,0,0,0]]`), nil)
+	if len(legacy) != 2 || legacy[0].Rune != 'H' || legacy[0].Attrs != AttrBold || legacy[1].Width != WidthNormal {
+		t.Errorf("legacy decode = %+v", legacy)
+	}
+}
+
+// TestScrollbackDiskLinks verifies hyperlinks survive the disk round-trip.
+func TestScrollbackDiskLinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.scrollback")
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	a, b := sb.Links().intern("https://a.example"), sb.Links().intern("file:///tmp/b.go")
+	sb.Push([]Cell{{Rune: 'x', Link: a}, {Rune: 'y', Link: a}, {Rune: ' '}, {Rune: 'z', Link: b}})
+	sb.Push([]Cell{{Rune: 'p'}})
+	sb.Close()
+
+	sb, err = NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer sb.Close()
+	// The reopened scrollback has a table of its own
+	got := sb.Line(0)
+	uri := func(c Cell) string { return sb.Links().URI(c.Link) }
+	if len(got) != 4 || uri(got[0]) != "https://a.example" || got[1].Link != got[0].Link || got[2].Link != 0 || uri(got[3]) != "file:///tmp/b.go" {
+		t.Errorf("Line(0) = %+v", got)
+	}
+	if p := sb.Line(1); len(p) != 1 || p[0].Rune != 'p' || p[0].Link != 0 {
+		t.Errorf("Line(1) = %+v", p)
+	}
+}
+
+// TestScrollbackDiskDecoration verifies underline styles and colors and
+// overline survive the disk round-trip.
+func TestScrollbackDiskDecoration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.scrollback")
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	curly := Decoration(0).WithUnderlineStyle(UnderlineCurly).WithUnderlineColor(RGBColor(255, 0, 0))
+	over := Decoration(0).WithOverline(true)
+	sb.Push([]Cell{
+		{Rune: 'a', Attrs: AttrUnderline, Decoration: curly, Link: sb.Links().intern("https://a.example")},
+		{Rune: '日', Width: WidthWide, Decoration: over},
+		{Width: WidthContinuation, Decoration: over},
+		{Rune: ' ', Attrs: AttrUnderline, Decoration: curly},
+	})
+	sb.Push([]Cell{{Rune: 'p', Attrs: AttrUnderline}})
+	sb.Close()
+
+	sb, err = NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer sb.Close()
+	got := sb.Line(0)
+	if len(got) != 4 {
+		t.Fatalf("Line(0) = %+v", got)
+	}
+	if got[0].Decoration != curly || got[0].Attrs != AttrUnderline || sb.Links().URI(got[0].Link) != "https://a.example" {
+		t.Errorf("cell 0 = %+v", got[0])
+	}
+	if got[1].Decoration != over || !got[1].IsWide() || !got[2].IsContinuation() {
+		t.Errorf("cells 1-2 = %+v", got[1:3])
+	}
+	if got[3].Decoration != curly {
+		t.Errorf("trailing underlined blank = %+v", got[3])
+	}
+	if p := sb.Line(1); len(p) != 1 || p[0].Decoration != 0 || p[0].Attrs != AttrUnderline {
+		t.Errorf("Line(1) = %+v", p)
+	}
+}
+
+// TestScrollbackReflow verifies the ring is re-wrapped, the wrap flag
+// survives the disk round-trip, and the rewritten file tail matches the ring.
+func TestScrollbackReflow(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.scrollback")
+
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	sb.Push([]Cell{{Rune: 'o'}, {Rune: 'l'}, {Rune: 'd'}})
+
+	// "abcdefgh" soft-wrapped at 4 columns, then a short line
+	s := NewScreen(4, 3)
+	p := NewParser(s, NewScrollback())
+	p.Parse([]byte("abcdefgh\r\nxy"))
+	sb.Push(s.ScrollUp(3)...)
+	if n := sb.Count(); n != 4 {
+		t.Fatalf("Count() = %d, want 4", n)
+	}
+	if !LineWrapped(sb.Line(1)) || LineWrapped(sb.Line(2)) {
+		t.Fatal("only the first row of the wrapped line should be flagged")
+	}
+
+	sb.Reflow(8)
+	want := []string{"old", "abcdefgh", "xy"}
+	check := func(sb *Scrollback) {
+		t.Helper()
+		if n := sb.Count(); n != len(want) {
+			t.Fatalf("Count() = %d, want %d", n, len(want))
+		}
+		for i, w := range want {
+			var got strings.Builder
+			for _, c := range sb.Line(i) {
+				got.WriteString(c.Text())
+			}
+			if strings.TrimRight(got.String(), " ") != w {
+				t.Errorf("Line(%d) = %q, want %q", i, got.String(), w)
+			}
+		}
+	}
+	check(sb)
+	sb.Close()
+
+	reopened, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	check(reopened)
+
+	// Narrowing again flags the split rows
+	reopened.Reflow(3)
+	want = []string{"old", "abc", "def", "gh", "xy"}
+	check(reopened)
+	if !LineWrapped(reopened.Line(1)) || !LineWrapped(reopened.Line(2)) || LineWrapped(reopened.Line(3)) {
+		t.Error("rows of the re-split line should be flagged except the last")
+	}
+}
+
+// stepClock returns a clock for Scrollback.now that starts at start and
+// moves a minute on each call
+func stepClock(start time.Time) func() time.Time {
+	next := start
+	return func() time.Time {
+		t := next
+		next = next.Add(time.Minute)
+		return t
+	}
+}
+
+func TestScrollbackTimes(t *testing.T) {
+	start := time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a"+scrollbackExt)
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	sb.now = stepClock(start)
+	for i := 0; i < 300; i++ {
+		sb.Push(textLine(fmt.Sprintf("line %d", i)))
+	}
+
+	check := func(sb *Scrollback) {
+		t.Helper()
+		// Lines on disk and in the ring alike
+		for _, i := range []int{0, 150, 299} {
+			if got, want := sb.Time(i), start.Add(time.Duration(i)*time.Minute); !got.Equal(want) {
+				t.Errorf("Time(%d) = %v, want %v", i, got, want)
+			}
+		}
+		if !sb.Time(sb.Count()).IsZero() || !sb.Time(-1).IsZero() {
+			t.Error("Time out of range should be zero")
+		}
+		tests := []struct {
+			t    time.Time
+			want int
+		}{
+			{start.Add(-time.Hour), 0},
+			{start, 0},
+			{start.Add(90*time.Minute + time.Second), 91},
+			{start.Add(299 * time.Minute), 299},
+			{start.Add(time.Hour * 24), sb.Count()},
+		}
+		for _, tt := range tests {
+			if got := sb.LineAt(tt.t); got != tt.want {
+				t.Errorf("LineAt(%v) = %d, want %d", tt.t, got, tt.want)
+			}
+		}
+	}
+	check(sb)
+
+	// Renaming an open scrollback keeps its times and keeps writing
+	renamed := filepath.Join(dir, "b"+scrollbackExt)
+	if err := sb.Rename(renamed); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("old file still there: %v", err)
+	}
+	check(sb)
+	sb.Push(textLine("after rename"))
+	sb.Close()
+
+	// And a restart
+	reopened, err := NewScrollbackWithPath(renamed)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.Count() != 301 || rowString(reopened.Line(300)) != "after rename" {
+		t.Fatalf("reopened Count = %d, last line %q", reopened.Count(), rowString(reopened.Line(300)))
+	}
+	check(reopened)
+}
+
+func TestScrollbackTimesOnlyGrow(t *testing.T) {
+	start := time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)
+	sb := NewScrollback()
+	clock := []time.Time{start, start.Add(-time.Hour), start.Add(time.Minute)}
+	sb.now = func() time.Time {
+		t := clock[0]
+		clock = clock[1:]
+		return t
+	}
+	for i := 0; i < 3; i++ {
+		sb.Push(textLine(fmt.Sprintf("line %d", i)))
+	}
+
+	// The clock stepped back an hour for line 1
+	want := []time.Time{start, start, start.Add(time.Minute)}
+	for i, w := range want {
+		if got := sb.Time(i); !got.Equal(w) {
+			t.Errorf("Time(%d) = %v, want %v", i, got, w)
+		}
+	}
+	if got := sb.LineAt(start.Add(time.Second)); got != 2 {
+		t.Errorf("LineAt = %d, want 2", got)
+	}
+}
+
+func TestScrollbackReflowKeepsTimes(t *testing.T) {
+	start := time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)
+	sb := NewScrollback()
+	sb.now = stepClock(start)
+
+	// "abcdefgh" soft-wrapped at 4 columns arrives a row at a time
+	s := NewScreen(4, 3)
+	p := NewParser(s, NewScrollback())
+	p.Parse([]byte("abcdefgh\r\nxy"))
+	for _, row := range s.ScrollUp(3) {
+		sb.Push(row)
+	}
+
+	sb.Reflow(3) // "abc" "def" "gh" "xy"
+	want := []time.Time{start, start, start, start.Add(2 * time.Minute)}
+	if sb.Count() != len(want) {
+		t.Fatalf("Count = %d, want %d", sb.Count(), len(want))
+	}
+	for i, w := range want {
+		if got := sb.Time(i); !got.Equal(w) {
+			t.Errorf("Time(%d) = %v, want %v", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package emulator
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxSearchMatches caps the matches kept by Search so a query like "e"
+// over a full scrollback can't build an unbounded list. The newest
+// matches are kept.
+const maxSearchMatches = 10_000
+
+// Match is a search hit covering cells [Start, End) of an absolute line.
+// Lines 0..Count()-1 are scrollback (oldest first) and the screen rows
+// follow, so a line keeps its index as it scrolls off the screen.
+type Match struct {
+	Line       int
+	Start, End int
+}
+
+// Searcher finds plain-text or regex matches in terminal lines.
+// Queries are case-insensitive unless they contain an upper-case letter.
+type Searcher struct {
+	re *regexp.Regexp
+}
+
+// NewSearcher compiles a query. Plain queries match literally.
+func NewSearcher(query string, isRegex bool) (*Searcher, error) {
+	pattern := query
+	if !isRegex {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if !strings.ContainsFunc(query, unicode.IsUpper) {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Searcher{re: re}, nil
+}
+
+// FindInLine returns the matches in one row of cells, tagged with line.
+func (s *Searcher) FindInLine(line int, cells []Cell) []Match {
+	text, cols := lineText(cells)
+	var matches []Match
+	for _, loc := range s.re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue // Empty matches (e.g. "a*") highlight nothing
+		}
+		matches = append(matches, Match{Line: line, Start: cols[loc[0]], End: cols[loc[1]]})
+	}
+	return matches
+}
+
+// lineText returns a row's text and a map from each byte offset in it
+// (plus the end) to the column of the cell it came from.
+func lineText(cells []Cell) (string, []int) {
+	var sb strings.Builder
+	cols := make([]int, 0, len(cells)+1)
+	for x, c := range cells {
+		t := c.Text()
+		sb.WriteString(t)
+		for range len(t) {
+			cols = append(cols, x)
+		}
+	}
+	return sb.String(), append(cols, len(cells))
+}
+
+// Search scans scrollback lines [0, count) and then the screen rows
+// (lines count, count+1, ...), returning matches oldest first. Disk-backed
+// scrollback is streamed a line at a time; lines the retention policy drops
+// meanwhile are left out and the other matches' lines moved to suit.
+func (s *Searcher) Search(sb *Scrollback, count int, screen [][]Cell) []Match {
+	var matches []Match
+	add := func(m []Match) {
+		matches = append(matches, m...)
+		if len(matches) > 2*maxSearchMatches {
+			matches = append(matches[:0], matches[len(matches)-maxSearchMatches:]...)
+		}
+	}
+	shift := sb.ScanLines(count, func(i int, line []Cell) bool {
+		add(s.FindInLine(i, line))
+		return true
+	})
+	for y, row := range screen {
+		add(s.FindInLine(count+y, row))
+	}
+	if shift > 0 {
+		kept := matches[:0]
+		for _, m := range matches {
+			if m.Line -= shift; m.Line >= 0 {
+				kept = append(kept, m)
+			}
+		}
+		matches = kept
+	}
+	if len(matches) > maxSearchMatches {
+		matches = matches[len(matches)-maxSearchMatches:]
+	}
+	return matches
+}
@@ -0,0 +1,108 @@
+package emulator
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestSearcherFindInLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		regex   bool
+		text    string
+		want    []Match
+		wantErr bool
+	}{
+		{"plain", "foo", false, "a foo b foo", []Match{{0, 2, 5}, {0, 8, 11}}, false},
+		{"smart case lower", "error", false, "ERROR Error", []Match{{0, 0, 5}, {0, 6, 11}}, false},
+		{"smart case upper", "Error", false, "ERROR Error", []Match{{0, 6, 11}}, false},
+		{"plain is literal", "a.c", false, "abc a.c", []Match{{0, 4, 7}}, false},
+		{"regex", `\d+`, true, "id 42 of 7", []Match{{0, 3, 5}, {0, 9, 10}}, false},
+		{"empty matches skipped", "x*", true, "abc", nil, false},
+		{"wide cells", "本", false, "日本語", []Match{{0, 2, 4}}, false},
+		{"bad regex", "(", true, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSearcher(tt.query, tt.regex)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NewSearcher: expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewSearcher: %v", err)
+			}
+			screen := NewScreen(20, 1)
+			for _, r := range tt.text {
+				screen.Write(r)
+			}
+			got := s.FindInLine(0, screen.Line(0))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("FindInLine = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSearcherSearch verifies the scan covers disk-only lines, the ring and
+// the screen, with absolute line numbers in order.
+func TestSearcherSearch(t *testing.T) {
+	dir := t.TempDir()
+	sb, err := NewScrollbackWithPath(filepath.Join(dir, "test.scrollback"))
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	defer sb.Close()
+
+	p := NewParser(NewScreen(20, 5), sb)
+	for i := 0; i < 300; i++ {
+		p.Parse([]byte(fmt.Sprintf("line %d\r\n", i)))
+	}
+	p.Parse([]byte("line 7 again"))
+
+	s, err := NewSearcher("line 7", false)
+	if err != nil {
+		t.Fatalf("NewSearcher: %v", err)
+	}
+	count := sb.Count()
+	var screen [][]Cell
+	for y := 0; y < 5; y++ {
+		screen = append(screen, p.Screen().Line(y))
+	}
+	got := s.Search(sb, count, screen)
+
+	// "line 7", "line 70".."line 79" on disk, "line 7 again" on screen
+	if len(got) != 12 {
+		t.Fatalf("got %d matches, want 12: %v", len(got), got)
+	}
+	if got[0].Line != 7 {
+		t.Errorf("first match on line %d, want 7", got[0].Line)
+	}
+	if last := got[len(got)-1]; last.Line != count+4 {
+		t.Errorf("last match on line %d, want %d (screen row 4)", last.Line, count+4)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Line <= got[i-1].Line {
+			t.Errorf("matches out of order at %d: %v", i, got)
+		}
+	}
+}
+
+func TestScrollbackScanLinesStopsEarly(t *testing.T) {
+	sb := NewScrollback()
+	for i := 0; i < 10; i++ {
+		sb.Push([]Cell{{Rune: rune('0' + i)}})
+	}
+	var seen []int
+	sb.ScanLines(sb.Count(), func(i int, line []Cell) bool {
+		seen = append(seen, i)
+		return i < 3
+	})
+	if fmt.Sprint(seen) != "[0 1 2 3]" {
+		t.Errorf("scanned %v, want [0 1 2 3]", seen)
+	}
+}
@@ -0,0 +1,757 @@
+package emulator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Scrollback segment format. A segment file starts with an 8 byte header,
+// segmentMagic and the file version, then holds one record per line: a
+// uvarint payload length and the payload, whose first byte is the line's
+// format version. An empty file is an empty segment; the header is written
+// with the first line.
+//
+// A version 1 payload is the line's link table followed by runs of cells
+// sharing a style, so attributes cost a few bytes per run rather than per
+// cell. A version 2 payload is the same preceded by the time the line
+// arrived, as uvarint Unix milliseconds:
+//
+//	links: uvarint count, then each URI as a uvarint length and its bytes
+//	run:   uvarint cell count, style, then each cell
+//	style: a mask byte, then the fields it flags as uvarints: FG and BG
+//	       (packColor), attrs, decoration, 1-based link index, and the
+//	       mark's flags and exit code
+//	cell:  uvarint rune<<2 | width, where width 3 is followed by the real
+//	       width and the combining runes (uvarint count, then each rune)
+//
+// The sidecar index (<name>.index) holds every line's record offset as a
+// little-endian uint64, so line N is two reads away. The index is derived
+// data: when it doesn't match the segment it is rebuilt by a scan.
+const (
+	segmentMagic   = "PGSB"
+	segmentVersion = 1
+	segmentHeader  = 8
+	indexEntrySize = 8
+
+	// maxRecordSize bounds a record read from disk, guarding against a
+	// corrupt length
+	maxRecordSize = 16 << 20
+)
+
+// Line format versions within a segment
+const (
+	lineFormatRuns  = 1
+	lineFormatTimed = 2 // lineFormatRuns with an arrival time
+)
+
+// Style fields present in a run, flagged in its mask byte
+const (
+	styleFG = 1 << iota
+	styleBG
+	styleAttrs
+	styleDecoration
+	styleLink
+	styleMark
+	styleWrapped
+)
+
+// cellExtended in a cell's width bits means the width and combining runes follow
+const cellExtended = 3
+
+// errBadRecord reports a record that can't be decoded
+var errBadRecord = errors.New("scrollback: bad line record")
+
+// indexPath returns the path of a segment's line offset index
+func indexPath(path string) string {
+	return strings.TrimSuffix(path, scrollbackExt) + ".index"
+}
+
+// diskLen returns the length of line without its trailing blank cells,
+// which aren't written to disk.
+func diskLen(line []Cell) int {
+	for i := len(line) - 1; i >= 0; i-- {
+		c := line[i]
+		if c.Rune != 0 && c.Rune != ' ' || c.Width != WidthNormal || c.Wrapped {
+			return i + 1
+		}
+		if c.FG.Type != ColorDefault || c.BG.Type != ColorDefault || c.Attrs != 0 || c.Decoration != 0 || c.Link != 0 || c.Mark != (Mark{}) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// sameStyle reports whether two cells can share a run
+func sameStyle(a, b Cell) bool {
+	return a.FG == b.FG && a.BG == b.BG && a.Attrs == b.Attrs && a.Decoration == b.Decoration &&
+		a.Link == b.Link && a.Mark == b.Mark && a.Wrapped == b.Wrapped
+}
+
+// appendRecord appends line's record, length prefix included, to b. at is
+// when the line arrived in Unix milliseconds, 0 if unknown. The cells'
+// link IDs resolve in table.
+func appendRecord(b []byte, line []Cell, at int64, table *LinkTable) []byte {
+	line = line[:diskLen(line)]
+	var links []string
+	var linkIndex map[LinkID]int
+	for _, c := range line {
+		if c.Link != 0 && linkIndex[c.Link] == 0 {
+			if linkIndex == nil {
+				linkIndex = map[LinkID]int{}
+			}
+			links = append(links, table.URI(c.Link))
+			linkIndex[c.Link] = len(links)
+		}
+	}
+
+	p := []byte{lineFormatRuns}
+	if at > 0 {
+		p = binary.AppendUvarint([]byte{lineFormatTimed}, uint64(at))
+	}
+	p = binary.AppendUvarint(p, uint64(len(links)))
+	for _, uri := range links {
+		p = binary.AppendUvarint(p, uint64(len(uri)))
+		p = append(p, uri...)
+	}
+	for i := 0; i < len(line); {
+		j := i + 1
+		for j < len(line) && sameStyle(line[i], line[j]) {
+			j++
+		}
+		p = binary.AppendUvarint(p, uint64(j-i))
+		p = appendStyle(p, line[i], linkIndex[line[i].Link])
+		for _, c := range line[i:j] {
+			p = appendCell(p, c)
+		}
+		i = j
+	}
+
+	b = binary.AppendUvarint(b, uint64(len(p)))
+	return append(b, p...)
+}
+
+// appendStyle appends the style of a run starting with c
+func appendStyle(b []byte, c Cell, link int) []byte {
+	fg, bg := packColor(c.FG), packColor(c.BG)
+	var mask byte
+	if fg != 0 {
+		mask |= styleFG
+	}
+	if bg != 0 {
+		mask |= styleBG
+	}
+	if c.Attrs != 0 {
+		mask |= styleAttrs
+	}
+	if c.Decoration != 0 {
+		mask |= styleDecoration
+	}
+	if link != 0 {
+		mask |= styleLink
+	}
+	if c.Mark != (Mark{}) {
+		mask |= styleMark
+	}
+	if c.Wrapped {
+		mask |= styleWrapped
+	}
+	b = append(b, mask)
+	if mask&styleFG != 0 {
+		b = binary.AppendUvarint(b, uint64(fg))
+	}
+	if mask&styleBG != 0 {
+		b = binary.AppendUvarint(b, uint64(bg))
+	}
+	if mask&styleAttrs != 0 {
+		b = binary.AppendUvarint(b, uint64(c.Attrs))
+	}
+	if mask&styleDecoration != 0 {
+		b = binary.AppendUvarint(b, uint64(c.Decoration))
+	}
+	if mask&styleLink != 0 {
+		b = binary.AppendUvarint(b, uint64(link))
+	}
+	if mask&styleMark != 0 {
+		b = append(b, byte(c.Mark.Flags), c.Mark.ExitCode)
+	}
+	return b
+}
+
+// appendCell appends a cell's rune, width and combining runes
+func appendCell(b []byte, c Cell) []byte {
+	if c.Combining == "" && c.Width < cellExtended {
+		return binary.AppendUvarint(b, uint64(uint32(c.Rune))<<2|uint64(c.Width))
+	}
+	b = binary.AppendUvarint(b, uint64(uint32(c.Rune))<<2|cellExtended)
+	b = binary.AppendUvarint(b, uint64(c.Width))
+	b = binary.AppendUvarint(b, uint64(utf8.RuneCountInString(c.Combining)))
+	for _, r := range c.Combining {
+		b = binary.AppendUvarint(b, uint64(uint32(r)))
+	}
+	return b
+}
+
+// recordReader decodes the fields of a record payload, remembering the
+// first error so callers check once at the end.
+type recordReader struct {
+	b   []byte
+	err error
+}
+
+func (r *recordReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *recordReader) byte() byte {
+	if len(r.b) == 0 {
+		r.fail()
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+func (r *recordReader) bytes(n uint64) []byte {
+	if n > uint64(len(r.b)) {
+		r.fail()
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *recordReader) fail() {
+	r.err = errBadRecord
+	r.b = nil
+}
+
+// decodeRecord parses a record payload back into a line and its arrival
+// time in Unix milliseconds (0 if not recorded), interning its links in
+// table. Lines in a newer format than this build knows decode as blank.
+func decodeRecord(p []byte, table *LinkTable) ([]Cell, int64, error) {
+	r := recordReader{b: p}
+	var at int64
+	switch r.byte() {
+	case lineFormatRuns:
+	case lineFormatTimed:
+		at = int64(r.uvarint())
+	default:
+		return nil, 0, r.err
+	}
+	n := r.uvarint()
+	if n > uint64(len(r.b)) { // Every link takes at least a byte
+		return nil, 0, errBadRecord
+	}
+	links := make([]LinkID, n)
+	for i := range links {
+		links[i] = table.intern(string(r.bytes(r.uvarint())))
+	}
+
+	var line []Cell
+	for len(r.b) > 0 && r.err == nil {
+		n := r.uvarint()
+		if n > uint64(len(r.b)) { // Every cell takes at least a byte
+			return nil, 0, errBadRecord
+		}
+		style := decodeStyle(&r, links)
+		for ; n > 0 && r.err == nil; n-- {
+			c := style
+			v := r.uvarint()
+			c.Rune, c.Width = rune(v>>2), CellWidth(v&3)
+			if c.Width == cellExtended {
+				c.Width = CellWidth(r.uvarint())
+				var sb strings.Builder
+				for k := r.uvarint(); k > 0 && r.err == nil; k-- {
+					sb.WriteRune(rune(r.uvarint()))
+				}
+				c.Combining = sb.String()
+			}
+			line = append(line, c)
+		}
+	}
+	return line, at, r.err
+}
+
+// decodeStyle reads a run's style into a template cell
+func decodeStyle(r *recordReader, links []LinkID) Cell {
+	var c Cell
+	mask := r.byte()
+	if mask&styleFG != 0 {
+		c.FG = unpackColor(int64(r.uvarint()))
+	}
+	if mask&styleBG != 0 {
+		c.BG = unpackColor(int64(r.uvarint()))
+	}
+	if mask&styleAttrs != 0 {
+		c.Attrs = AttrFlags(r.uvarint())
+	}
+	if mask&styleDecoration != 0 {
+		c.Decoration = Decoration(r.uvarint())
+	}
+	if mask&styleLink != 0 {
+		if link := r.uvarint(); link > 0 && link <= uint64(len(links)) {
+			c.Link = links[link-1]
+		}
+	}
+	if mask&styleMark != 0 {
+		c.Mark = Mark{Flags: MarkFlags(r.byte()), ExitCode: r.byte()}
+	}
+	c.Wrapped = mask&styleWrapped != 0
+	return c
+}
+
+// readRecord reads one length-prefixed record payload from r. A record cut
+// short by a crash reads as io.ErrUnexpectedEOF.
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxRecordSize {
+		return nil, errBadRecord
+	}
+	p := make([]byte, n)
+	if _, err := io.ReadFull(r, p); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return p, nil
+}
+
+// segmentHeaderBytes returns the header starting a segment file
+func segmentHeaderBytes() []byte {
+	h := make([]byte, segmentHeader)
+	copy(h, segmentMagic)
+	h[len(segmentMagic)] = segmentVersion
+	return h
+}
+
+// checkHeader reports whether h starts a segment this build can read
+func checkHeader(h []byte) bool {
+	return len(h) >= segmentHeader && string(h[:len(segmentMagic)]) == segmentMagic && h[len(segmentMagic)] <= segmentVersion
+}
+
+// scanScrollback streams the lines of a scrollback file, calling fn for
+// each until it returns false, with their links interned in table. Files
+// still in the JSONL encoding of older builds are read too, and a torn
+// last record ends the scan quietly.
+func scanScrollback(r io.Reader, table *LinkTable, fn func(i int, line []Cell) bool) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, err := br.Peek(1)
+	if err != nil {
+		return nil // Empty
+	}
+	if head[0] != segmentMagic[0] {
+		scanner := bufio.NewScanner(br)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		for i := 0; scanner.Scan(); i++ {
+			if !fn(i, decodeLine(scanner.Bytes(), table)) {
+				return nil
+			}
+		}
+		return scanner.Err()
+	}
+
+	if head, err = br.Peek(segmentHeader); err != nil || !checkHeader(head) {
+		return errBadRecord
+	}
+	br.Discard(segmentHeader)
+	for i := 0; ; i++ {
+		p, err := readRecord(br)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _, _ := decodeRecord(p, table)
+		if !fn(i, line) {
+			return nil
+		}
+	}
+}
+
+// segment is a scrollback file in the binary line format together with
+// its index of line offsets. Writes are buffered; reads flush first.
+type segment struct {
+	path          string
+	data, index   *os.File
+	wdata, windex *bufio.Writer
+	size          int64 // Bytes in the data file, buffered writes included
+	lines         int
+	rec           []byte     // Scratch for encoding a record
+	links         *LinkTable // Resolves the link IDs of lines written and read
+}
+
+// openSegment opens or creates the segment at path, whose lines' link
+// IDs resolve in links. A JSONL scrollback file from an older build is
+// converted first, and an index that doesn't match the data is rebuilt,
+// dropping a torn last record.
+func openSegment(path string, links *LinkTable) (*segment, error) {
+	if err := migrateJSONL(path); err != nil {
+		return nil, err
+	}
+	data, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.OpenFile(indexPath(path), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	g := &segment{path: path, data: data, index: index, links: links}
+	if err := g.load(); err != nil {
+		g.close()
+		return nil, err
+	}
+	g.wdata = bufio.NewWriterSize(data, 64*1024)
+	g.windex = bufio.NewWriterSize(index, 4*1024)
+	return g, nil
+}
+
+// load sizes the segment, checking the index against the data file, and
+// positions both files for appending.
+func (g *segment) load() error {
+	info, err := g.data.Stat()
+	if err != nil {
+		return err
+	}
+	g.size = info.Size()
+	if g.size > 0 {
+		h := make([]byte, segmentHeader)
+		if _, err := g.data.ReadAt(h, 0); err != nil || !checkHeader(h) {
+			return errBadRecord
+		}
+	}
+	if !g.indexValid() {
+		if err := g.rebuildIndex(); err != nil {
+			return err
+		}
+	}
+	if _, err := g.data.Seek(g.size, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = g.index.Seek(int64(g.lines)*indexEntrySize, io.SeekStart)
+	return err
+}
+
+// indexValid reports whether the index covers exactly the records in the
+// data file, setting the line count if so.
+func (g *segment) indexValid() bool {
+	info, err := g.index.Stat()
+	if err != nil || info.Size()%indexEntrySize != 0 {
+		return false
+	}
+	n := int(info.Size() / indexEntrySize)
+	if n == 0 {
+		g.lines = 0
+		return g.size <= segmentHeader
+	}
+	var e [indexEntrySize]byte
+	if _, err := g.index.ReadAt(e[:], int64(n-1)*indexEntrySize); err != nil {
+		return false
+	}
+	last := int64(binary.LittleEndian.Uint64(e[:]))
+	end, err := g.recordEnd(last)
+	if err != nil || end != g.size {
+		return false
+	}
+	g.lines = n
+	return true
+}
+
+// recordEnd returns the offset just past the record at off
+func (g *segment) recordEnd(off int64) (int64, error) {
+	var b [binary.MaxVarintLen64]byte
+	n, err := g.data.ReadAt(b[:], off)
+	if n == 0 {
+		return 0, err
+	}
+	length, k := binary.Uvarint(b[:n])
+	if k <= 0 {
+		return 0, errBadRecord
+	}
+	return off + int64(k) + int64(length), nil
+}
+
+// rebuildIndex rewrites the index from a scan of the data file, cutting
+// the data back to its last whole record.
+func (g *segment) rebuildIndex() error {
+	if err := g.index.Truncate(0); err != nil {
+		return err
+	}
+	g.lines = 0
+	if g.size <= segmentHeader {
+		g.size = 0
+		return g.data.Truncate(0)
+	}
+	br := bufio.NewReaderSize(io.NewSectionReader(g.data, segmentHeader, g.size-segmentHeader), 64*1024)
+	w := bufio.NewWriter(io.NewOffsetWriter(g.index, 0))
+	off := int64(segmentHeader)
+	for {
+		p, err := readRecord(br)
+		if err != nil {
+			break
+		}
+		w.Write(binary.LittleEndian.AppendUint64(nil, uint64(off)))
+		off += int64(uvarintLen(uint64(len(p))) + len(p))
+		g.lines++
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	g.size = off
+	return g.data.Truncate(off)
+}
+
+// uvarintLen returns the encoded size of v
+func uvarintLen(v uint64) int {
+	var b [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(b[:], v)
+}
+
+// append writes a line that arrived at Unix milliseconds at to the end of
+// the segment, returning the record's size
+func (g *segment) append(line []Cell, at int64) int64 {
+	if g.size == 0 {
+		n, _ := g.wdata.Write(segmentHeaderBytes())
+		g.size += int64(n)
+	}
+	g.rec = appendRecord(g.rec[:0], line, at, g.links)
+	var e [indexEntrySize]byte
+	binary.LittleEndian.PutUint64(e[:], uint64(g.size))
+	g.windex.Write(e[:])
+	n, _ := g.wdata.Write(g.rec)
+	g.size += int64(n)
+	g.lines++
+	return int64(n)
+}
+
+// flush writes buffered lines to the files, data before index so a crash
+// between the two leaves an index to rebuild rather than one pointing
+// past the data.
+func (g *segment) flush() error {
+	if err := g.wdata.Flush(); err != nil {
+		return err
+	}
+	return g.windex.Flush()
+}
+
+// offsets returns the record offsets of lines [start, end] (note: closed),
+// with the end of the data standing in for line g.lines. Buffered writes
+// must have been flushed.
+func (g *segment) offsets(start, end int) ([]int64, error) {
+	n := min(end+1, g.lines) - start
+	raw := make([]byte, n*indexEntrySize)
+	if _, err := g.index.ReadAt(raw, int64(start)*indexEntrySize); err != nil {
+		return nil, err
+	}
+	offs := make([]int64, 0, end-start+1)
+	for i := 0; i < n; i++ {
+		offs = append(offs, int64(binary.LittleEndian.Uint64(raw[i*indexEntrySize:])))
+	}
+	if end == g.lines {
+		offs = append(offs, g.size)
+	}
+	return offs, nil
+}
+
+// lineAt returns the first line whose record starts at or after off, or
+// g.lines if none does. The index offsets are sorted, so this is a binary
+// search. Buffered writes must have been flushed.
+func (g *segment) lineAt(off int64) int {
+	return sort.Search(g.lines, func(i int) bool {
+		offs, err := g.offsets(i, i)
+		return err != nil || offs[0] >= off
+	})
+}
+
+// readLines returns lines [start, end) and their arrival times with one
+// read of the index and one of the data.
+func (g *segment) readLines(start, end int) ([][]Cell, []int64) {
+	start, end = max(start, 0), min(end, g.lines)
+	if start >= end || g.flush() != nil {
+		return nil, nil
+	}
+	offs, err := g.offsets(start, end)
+	if err != nil {
+		return nil, nil
+	}
+	data := make([]byte, offs[len(offs)-1]-offs[0])
+	if _, err := g.data.ReadAt(data, offs[0]); err != nil {
+		return nil, nil
+	}
+	lines := make([][]Cell, end-start)
+	times := make([]int64, end-start)
+	for i := range lines {
+		rec := data[offs[i]-offs[0] : offs[i+1]-offs[0]]
+		length, k := binary.Uvarint(rec)
+		if k <= 0 || uint64(len(rec)-k) < length {
+			continue
+		}
+		lines[i], times[i], _ = decodeRecord(rec[k:k+int(length)], g.links)
+	}
+	return lines, times
+}
+
+// truncate keeps the first n lines, dropping the rest.
+func (g *segment) truncate(n int) error {
+	if n >= g.lines {
+		return nil
+	}
+	if err := g.flush(); err != nil {
+		return err
+	}
+	size := int64(0)
+	if n > 0 {
+		offs, err := g.offsets(n, n)
+		if err != nil {
+			return err
+		}
+		size = offs[0]
+	}
+	if err := g.data.Truncate(size); err != nil {
+		return err
+	}
+	if err := g.index.Truncate(int64(n) * indexEntrySize); err != nil {
+		return err
+	}
+	g.size, g.lines = size, n
+	g.data.Seek(size, io.SeekStart)
+	g.index.Seek(int64(n)*indexEntrySize, io.SeekStart)
+	g.wdata.Reset(g.data)
+	g.windex.Reset(g.index)
+	return nil
+}
+
+// dropFront removes the first n lines, copying the rest into new files
+// that replace the old ones.
+func (g *segment) dropFront(n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if n >= g.lines {
+		return g.truncate(0)
+	}
+	if err := g.flush(); err != nil {
+		return err
+	}
+	offs, err := g.offsets(n, n)
+	if err != nil {
+		return err
+	}
+	shift := offs[0] - segmentHeader
+
+	tmp := g.path + ".tmp"
+	data, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(data, 64*1024)
+	w.Write(segmentHeaderBytes())
+	io.Copy(w, io.NewSectionReader(g.data, offs[0], g.size-offs[0]))
+	err = w.Flush()
+	if cerr := data.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	raw := make([]byte, (g.lines-n)*indexEntrySize)
+	if _, err := g.index.ReadAt(raw, int64(n)*indexEntrySize); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	for i := 0; i < len(raw); i += indexEntrySize {
+		off := binary.LittleEndian.Uint64(raw[i:])
+		binary.LittleEndian.PutUint64(raw[i:], off-uint64(shift))
+	}
+	if err := os.WriteFile(indexPath(tmp), raw, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	g.close()
+	err = os.Rename(indexPath(tmp), indexPath(g.path))
+	if err == nil {
+		err = os.Rename(tmp, g.path)
+	}
+	// Reopen whichever files are in place; a stale index is rebuilt
+	reopened, oerr := openSegment(g.path, g.links)
+	if oerr != nil {
+		return oerr
+	}
+	*g = *reopened
+	return err
+}
+
+// close flushes and closes the files
+func (g *segment) close() {
+	if g.wdata != nil {
+		g.flush()
+	}
+	g.data.Close()
+	g.index.Close()
+}
+
+// migrateJSONL converts a scrollback file in the JSONL encoding of older
+// builds to a segment in place. Other files are left alone.
+func migrateJSONL(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, 1)
+	if n, _ := f.Read(head); n == 0 || head[0] == segmentMagic[0] {
+		return nil
+	}
+	f.Seek(0, io.SeekStart)
+
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	os.Remove(indexPath(tmp))
+	// The lines only pass through, so their links need a table for the
+	// length of the conversion
+	links := &LinkTable{}
+	g, err := openSegment(tmp, links)
+	if err != nil {
+		return err
+	}
+	err = scanScrollback(f, links, func(_ int, line []Cell) bool {
+		g.append(line, 0) // JSONL lines carry no time
+		return true
+	})
+	if ferr := g.flush(); err == nil {
+		err = ferr
+	}
+	g.close()
+	if err != nil {
+		os.Remove(tmp)
+		os.Remove(indexPath(tmp))
+		return err
+	}
+	if err := os.Rename(indexPath(tmp), indexPath(path)); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,448 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testLinks is the link table of the lines tests build by hand
+var testLinks = &LinkTable{}
+
+// sameLine reports whether two lines match, comparing link targets
+// rather than IDs from two different tables
+func sameLine(a []Cell, aLinks *LinkTable, b []Cell, bLinks *LinkTable) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if aLinks.URI(a[i].Link) != bLinks.URI(b[i].Link) {
+			return false
+		}
+		x, y := a[i], b[i]
+		x.Link, y.Link = 0, 0
+		if !reflect.DeepEqual(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeJSONLine encodes a line the way builds before segments wrote
+// their JSONL scrollback files.
+func encodeJSONLine(line []Cell) []byte {
+	line = line[:diskLen(line)]
+	version := lineFormatBase
+	for _, c := range line {
+		if c.Decoration != 0 {
+			version = lineFormatDecorated
+			break
+		}
+	}
+	encoded := make([][]int64, len(line))
+	var links []string
+	linkIndex := map[string]int{}
+	for i, c := range line {
+		link := 0
+		if uri := testLinks.URI(c.Link); uri != "" {
+			if link = linkIndex[uri]; link == 0 {
+				links = append(links, uri)
+				link = len(links)
+				linkIndex[uri] = link
+			}
+		}
+		attrs := int64(c.Attrs) | int64(link)<<encodedLinkShift |
+			int64(c.Mark.Flags)<<encodedMarkShift | int64(c.Mark.ExitCode)<<encodedExitShift
+		if c.Wrapped {
+			attrs |= encodedWrapped
+		}
+		e := []int64{int64(c.Rune), packColor(c.FG), packColor(c.BG), attrs}
+		if version >= lineFormatDecorated {
+			e = append(e, int64(c.Decoration))
+		}
+		if c.Width != WidthNormal || c.Combining != "" {
+			e = append(e, int64(c.Width))
+			for _, r := range c.Combining {
+				e = append(e, int64(r))
+			}
+		}
+		encoded[i] = e
+	}
+	var data []byte
+	if links != nil || version != lineFormatBase {
+		data, _ = json.Marshal(diskLine{Version: version, Cells: encoded, Links: links})
+	} else {
+		data, _ = json.Marshal(encoded)
+	}
+	return data
+}
+
+// textLine returns plain cells for s
+func textLine(s string) []Cell {
+	var line []Cell
+	for _, r := range s {
+		line = append(line, Cell{Rune: r})
+	}
+	return line
+}
+
+// sampleLines returns n lines resembling shell output: plain text, a
+// colored prompt, ls-style colored columns and the odd hyperlink.
+func sampleLines(n int) [][]Cell {
+	green, blue := IndexedColor(2), RGBColor(80, 120, 255)
+	lines := make([][]Cell, n)
+	for i := range lines {
+		var line []Cell
+		add := func(s string, fg Color, attrs AttrFlags, link string) {
+			for _, r := range s {
+				line = append(line, Cell{Rune: r, FG: fg, Attrs: attrs, Link: testLinks.intern(link)})
+			}
+		}
+		switch i % 4 {
+		case 0:
+			add(fmt.Sprintf("ok  \tprompt-grid/src/pkg%d\t0.%03ds", i, i%1000), Color{}, 0, "")
+		case 1:
+			add("user@host", green, AttrBold, "")
+			add(":", Color{}, 0, "")
+			add("~/src/prompt-grid", blue, AttrBold, "")
+			add(fmt.Sprintf("$ go test ./... -run Test%d", i), Color{}, 0, "")
+		case 2:
+			for k := 0; k < 6; k++ {
+				add(fmt.Sprintf("file%02d.go  ", k), blue, 0, "")
+				add(fmt.Sprintf("dir%02d  ", k), green, AttrBold, "")
+			}
+		case 3:
+			add("see ", Color{}, 0, "")
+			add(fmt.Sprintf("https://example.com/build/%d", i), Color{}, AttrUnderline, fmt.Sprintf("https://example.com/build/%d", i))
+		}
+		for len(line) < 80 {
+			line = append(line, Cell{Rune: ' '})
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	curly := Decoration(0).WithUnderlineStyle(UnderlineCurly).WithUnderlineColor(RGBColor(255, 0, 0))
+	tests := []struct {
+		name string
+		line []Cell
+	}{
+		{"empty", nil},
+		{"blank", []Cell{{Rune: ' '}, {Rune: ' '}}},
+		{"text", textLine("hello, world")},
+		{"styles", []Cell{
+			{Rune: 'a', FG: IndexedColor(1), Attrs: AttrBold},
+			{Rune: 'b', FG: IndexedColor(1), Attrs: AttrBold},
+			{Rune: 'c', BG: RGBColor(1, 2, 3), Attrs: AttrUnderline, Decoration: curly},
+			{Rune: ' ', BG: RGBColor(1, 2, 3)},
+		}},
+		{"wide and combining", []Cell{
+			{Rune: 'e', Combining: "́"},
+			{Rune: '日', Width: WidthWide},
+			{Width: WidthContinuation},
+			{Rune: '👍', Width: WidthWide, Combining: "\U0001F3FD"},
+			{Width: WidthContinuation},
+		}},
+		{"links", []Cell{
+			{Rune: 'x', Link: testLinks.intern("https://a.example")},
+			{Rune: 'y', Link: testLinks.intern("https://b.example")},
+			{Rune: 'z', Link: testLinks.intern("https://a.example")},
+		}},
+		{"mark and wrap", []Cell{
+			{Rune: '$', Mark: Mark{Flags: MarkPrompt | MarkEnd, ExitCode: 2}},
+			{Rune: 'l'},
+			{Rune: 's', Wrapped: true},
+		}},
+		{"image", []Cell{ImageCell(0xABCDEF, 3, 12), ImageCell(0xABCDEF, 4, 12)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without and with an arrival time
+			for _, at := range []int64{0, 1_760_000_000_123} {
+				rec := appendRecord(nil, tt.line, at, testLinks)
+				p, err := readRecord(bufio.NewReader(bytes.NewReader(rec)))
+				if err != nil {
+					t.Fatalf("readRecord: %v", err)
+				}
+				got, gotAt, err := decodeRecord(p, testLinks)
+				if err != nil {
+					t.Fatalf("decodeRecord: %v", err)
+				}
+				want := tt.line[:diskLen(tt.line)]
+				if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+					t.Errorf("decoded %+v\nwant %+v", got, want)
+				}
+				if gotAt != at {
+					t.Errorf("time = %d, want %d", gotAt, at)
+				}
+			}
+		})
+	}
+
+	// Corrupt payloads fail rather than panic
+	for _, p := range [][]byte{{1, 200}, {1, 0, 255, 255, 255, 255, 1}, {1, 0, 2, 0}} {
+		if _, _, err := decodeRecord(p, testLinks); err == nil {
+			t.Errorf("decodeRecord(%v) succeeded", p)
+		}
+	}
+	// Lines in a newer format read as blank
+	if line, _, err := decodeRecord([]byte{9, 1, 2, 3}, testLinks); line != nil || err != nil {
+		t.Errorf("newer format = %v, %v", line, err)
+	}
+}
+
+func TestScrollbackMigratesJSONL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "old"+scrollbackExt)
+	lines := sampleLines(300)
+	lines[5] = []Cell{{Rune: '日', Width: WidthWide, Decoration: Decoration(0).WithOverline(true)}, {Width: WidthContinuation}}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(encodeJSONLine(line))
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("not json\n")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sessions not yet reopened are still searchable
+	s, _ := NewSearcher("build/299", false)
+	if hits, _ := s.SearchSessions(dir, 0); len(hits) != 1 || hits[0].Line != 299 {
+		t.Errorf("search of the JSONL file = %+v", hits)
+	}
+
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	if n := sb.Count(); n != len(lines)+1 {
+		t.Fatalf("Count = %d, want %d", n, len(lines)+1)
+	}
+	for _, i := range []int{0, 3, 5, 150, 299} {
+		want := lines[i][:diskLen(lines[i])]
+		if got := sb.Line(i); !sameLine(got, sb.Links(), want, testLinks) {
+			t.Errorf("Line(%d) = %+v\nwant %+v", i, got, want)
+		}
+	}
+	if got := sb.Line(300); len(got) != 0 {
+		t.Errorf("undecodable line = %+v, want blank", got)
+	}
+	sb.Push(textLine("after"))
+	sb.Close()
+
+	data, _ := os.ReadFile(path)
+	if !checkHeader(data) {
+		t.Errorf("file header = %q, want a segment", data[:8])
+	}
+	if _, err := os.Stat(indexPath(path)); err != nil {
+		t.Errorf("index: %v", err)
+	}
+	sb, err = NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer sb.Close()
+	if got := rowString(sb.Line(301)); got != "after" {
+		t.Errorf("Line(301) after reopen = %q", got)
+	}
+}
+
+// rowString returns the text of a line without trailing blanks
+func rowString(line []Cell) string {
+	var sb strings.Builder
+	for _, c := range line {
+		sb.WriteString(c.Text())
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
+
+func TestSegmentIndexRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s"+scrollbackExt)
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	for i := 0; i < 250; i++ {
+		sb.Push(textLine(fmt.Sprintf("line %d", i)))
+	}
+	sb.Close()
+
+	reopen := func() *Scrollback {
+		t.Helper()
+		sb, err := NewScrollbackWithPath(path)
+		if err != nil {
+			t.Fatalf("reopen: %v", err)
+		}
+		return sb
+	}
+	check := func(sb *Scrollback, n int) {
+		t.Helper()
+		if got := sb.Count(); got != n {
+			t.Fatalf("Count = %d, want %d", got, n)
+		}
+		for _, i := range []int{0, 7, n - 1} {
+			if got, want := rowString(sb.Line(i)), fmt.Sprintf("line %d", i); got != want {
+				t.Errorf("Line(%d) = %q, want %q", i, got, want)
+			}
+		}
+	}
+
+	// A missing index is rebuilt
+	os.Remove(indexPath(path))
+	sb = reopen()
+	check(sb, 250)
+	sb.Close()
+
+	// So is one that lost its last entries
+	os.Truncate(indexPath(path), 100*indexEntrySize)
+	sb = reopen()
+	check(sb, 250)
+	sb.Close()
+
+	// A record torn by a crash is dropped, and appending carries on after it
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.Write([]byte{40, 1, 0, 3})
+	f.Close()
+	sb = reopen()
+	check(sb, 250)
+	sb.Push(textLine("line 250"))
+	sb.Close()
+	sb = reopen()
+	defer sb.Close()
+	check(sb, 251)
+}
+
+func TestSegmentDropFront(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s"+scrollbackExt)
+	g, err := openSegment(path, testLinks)
+	if err != nil {
+		t.Fatalf("openSegment: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		g.append(textLine(fmt.Sprintf("line %d", i)), 0)
+	}
+	if err := g.dropFront(20); err != nil {
+		t.Fatalf("dropFront: %v", err)
+	}
+	g.append(textLine("line 50"), 0)
+	if g.lines != 31 {
+		t.Fatalf("lines = %d, want 31", g.lines)
+	}
+	got, _ := g.readLines(0, g.lines)
+	for i, line := range got {
+		if want := fmt.Sprintf("line %d", i+20); rowString(line) != want {
+			t.Errorf("line %d = %q, want %q", i, rowString(line), want)
+		}
+	}
+	g.close()
+
+	// The rewritten index matches the data
+	g, err = openSegment(path, testLinks)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer g.close()
+	if !g.indexValid() || g.lines != 31 {
+		t.Errorf("reopened index valid=%v lines=%d", g.indexValid(), g.lines)
+	}
+}
+
+// writeJSONL writes lines as a JSONL scrollback file, returning its size
+func writeJSONL(path string, lines [][]Cell) int64 {
+	f, _ := os.Create(path)
+	w := bufio.NewWriterSize(f, 64*1024)
+	for _, line := range lines {
+		w.Write(encodeJSONLine(line))
+		w.WriteByte('\n')
+	}
+	w.Flush()
+	info, _ := f.Stat()
+	f.Close()
+	return info.Size()
+}
+
+// BenchmarkScrollbackWrite compares encoding and writing lines in the
+// JSONL format against segments, reporting the disk bytes per line.
+func BenchmarkScrollbackWrite(b *testing.B) {
+	lines := sampleLines(1000)
+	b.Run("jsonl", func(b *testing.B) {
+		f, _ := os.Create(filepath.Join(b.TempDir(), "s"+scrollbackExt))
+		defer f.Close()
+		w := bufio.NewWriterSize(f, 64*1024)
+		var n int64
+		for i := 0; i < b.N; i++ {
+			k, _ := w.Write(encodeJSONLine(lines[i%len(lines)]))
+			w.WriteByte('\n')
+			n += int64(k) + 1
+		}
+		w.Flush()
+		b.ReportMetric(float64(n)/float64(b.N), "disk-B/line")
+	})
+	b.Run("segment", func(b *testing.B) {
+		g, err := openSegment(filepath.Join(b.TempDir(), "s"+scrollbackExt), testLinks)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer g.close()
+		var n int64
+		for i := 0; i < b.N; i++ {
+			n += g.append(lines[i%len(lines)], 0) + indexEntrySize
+		}
+		g.flush()
+		b.ReportMetric(float64(n)/float64(b.N), "disk-B/line")
+	})
+}
+
+// BenchmarkScrollbackRandomAccess compares reading one random line: the
+// JSONL format has to be scanned from the start, a segment seeks through
+// its index.
+func BenchmarkScrollbackRandomAccess(b *testing.B) {
+	const total = 20_000
+	lines := sampleLines(total)
+	b.Run("jsonl", func(b *testing.B) {
+		path := filepath.Join(b.TempDir(), "s"+scrollbackExt)
+		writeJSONL(path, lines)
+		rng := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			want := rng.Intn(total)
+			f, _ := os.Open(path)
+			scanner := bufio.NewScanner(f)
+			scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+			for n := 0; scanner.Scan(); n++ {
+				if n == want {
+					decodeLine(scanner.Bytes(), testLinks)
+					break
+				}
+			}
+			f.Close()
+		}
+	})
+	b.Run("segment", func(b *testing.B) {
+		g, err := openSegment(filepath.Join(b.TempDir(), "s"+scrollbackExt), testLinks)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer g.close()
+		for _, line := range lines {
+			g.append(line, 0)
+		}
+		g.flush()
+		rng := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			n := rng.Intn(total)
+			g.readLines(n, n+1)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package emulator
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snippetWidth is the maximum number of cells shown around a match
+const snippetWidth = 80
+
+// SessionHit is one line of a persisted scrollback that matched a
+// cross-session search.
+type SessionHit struct {
+	Session    string    // Session name, taken from the file name
+	Line       int       // Absolute scrollback line index
+	Start, End int       // First match on the line, in cells
+	Snippet    string    // Line text around the match
+	ModTime    time.Time // Last write to the session's scrollback
+}
+
+// SearchSessions searches every *.scrollback file in dir, attached or
+// not, and returns up to limit hits ranked best first: the most recently
+// written sessions come first and, within a session, the newest lines.
+// A file that can't be read is skipped.
+func (s *Searcher) SearchSessions(dir string, limit int) ([]SessionHit, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+scrollbackExt))
+	if err != nil {
+		return nil, err
+	}
+	var hits []SessionHit
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		session := strings.TrimSuffix(filepath.Base(path), scrollbackExt)
+		hits = append(hits, s.searchFile(path, session, info.ModTime(), limit)...)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if !a.ModTime.Equal(b.ModTime) {
+			return a.ModTime.After(b.ModTime)
+		}
+		if a.Session != b.Session {
+			return a.Session < b.Session
+		}
+		return a.Line > b.Line
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// searchFile streams one scrollback file and its archives, keeping the
+// newest limit hits
+func (s *Searcher) searchFile(path, session string, mod time.Time, limit int) []SessionHit {
+	var hits []SessionHit
+	scanHistory(path, nil, func(i int, line []Cell) bool {
+		m := s.FindInLine(i, line)
+		if len(m) == 0 {
+			return true
+		}
+		hits = append(hits, SessionHit{
+			Session: session,
+			Line:    i,
+			Start:   m[0].Start,
+			End:     m[0].End,
+			Snippet: Snippet(line, m[0].Start, m[0].End),
+			ModTime: mod,
+		})
+		if limit > 0 && len(hits) > 2*limit {
+			hits = append(hits[:0], hits[len(hits)-limit:]...)
+		}
+		return true
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[len(hits)-limit:]
+	}
+	return hits
+}
+
+// Snippet returns the text of line around cells [start, end), at most
+// snippetWidth cells wide, with "…" marking trimmed ends.
+func Snippet(line []Cell, start, end int) string {
+	n := trimmedLen(line)
+	from, to := 0, n
+	if n > snippetWidth {
+		// Centre the match, keeping the window inside the line
+		from = max(0, min((start+end-snippetWidth)/2, n-snippetWidth))
+		to = from + snippetWidth
+	}
+	var sb strings.Builder
+	for _, c := range line[from:to] {
+		sb.WriteString(c.Text())
+	}
+	text := strings.TrimSpace(sb.String())
+	if from > 0 {
+		text = "…" + text
+	}
+	if to < n {
+		text += "…"
+	}
+	return text
+}
@@ -0,0 +1,94 @@
+package emulator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSession persists lines as a session's scrollback file in dir and
+// stamps it with mod.
+func writeSession(t *testing.T, dir, name string, mod time.Time, lines ...string) {
+	t.Helper()
+	path := filepath.Join(dir, name+scrollbackExt)
+	sb, err := NewScrollbackWithPath(path)
+	if err != nil {
+		t.Fatalf("NewScrollbackWithPath: %v", err)
+	}
+	for _, text := range lines {
+		var line []Cell
+		for _, r := range text {
+			line = append(line, Cell{Rune: r})
+		}
+		sb.Push(line)
+	}
+	sb.Close()
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+}
+
+func TestSearchSessions(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeSession(t, dir, "old", now.Add(-48*time.Hour), "panic: old", "ok")
+	writeSession(t, dir, "new", now, "panic: first", "fine", "panic: second")
+	writeSession(t, dir, "quiet", now.Add(-time.Hour), "nothing here")
+	os.WriteFile(filepath.Join(dir, "new.ptylog"), []byte("panic: not scrollback"), 0644)
+
+	s, err := NewSearcher("panic", false)
+	if err != nil {
+		t.Fatalf("NewSearcher: %v", err)
+	}
+	hits, err := s.SearchSessions(dir, 0)
+	if err != nil {
+		t.Fatalf("SearchSessions: %v", err)
+	}
+
+	// Most recently written session first, newest line first within it
+	var got []string
+	for _, h := range hits {
+		got = append(got, fmt.Sprintf("%s:%d:%s", h.Session, h.Line, h.Snippet))
+	}
+	want := []string{"new:2:panic: second", "new:0:panic: first", "old:0:panic: old"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("hits = %v, want %v", got, want)
+	}
+	if hits[0].Start != 0 || hits[0].End != 5 {
+		t.Errorf("first hit covers [%d, %d), want [0, 5)", hits[0].Start, hits[0].End)
+	}
+
+	limited, _ := s.SearchSessions(dir, 2)
+	if len(limited) != 2 || limited[1].Session != "new" {
+		t.Errorf("limit 2 returned %v", limited)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("a", 100) + "NEEDLE" + strings.Repeat("b", 100)
+	tests := []struct {
+		name       string
+		text       string
+		start, end int
+		want       string
+	}{
+		{"short line", "  hello world   ", 2, 7, "hello world"},
+		{"middle of long line", long, 100, 106, "…" + strings.Repeat("a", 37) + "NEEDLE" + strings.Repeat("b", 37) + "…"},
+		{"start of long line", long, 0, 1, strings.Repeat("a", 80) + "…"},
+		{"end of long line", long, 205, 206, "…" + strings.Repeat("b", 80)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var line []Cell
+			for _, r := range tt.text {
+				line = append(line, Cell{Rune: r})
+			}
+			if got := Snippet(line, tt.start, tt.end); got != tt.want {
+				t.Errorf("Snippet = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
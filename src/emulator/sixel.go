@@ -0,0 +1,230 @@
+package emulator
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// maxSixelColors is the number of Sixel color registers
+const maxSixelColors = 256
+
+// sixelPalette is the VT340's default color registers, in percent
+var sixelPalette = [16][3]int{
+	{0, 0, 0}, {20, 20, 80}, {80, 13, 13}, {20, 80, 20},
+	{80, 20, 80}, {20, 80, 80}, {80, 80, 20}, {53, 53, 53},
+	{26, 26, 26}, {33, 33, 60}, {60, 26, 26}, {33, 60, 33},
+	{60, 33, 60}, {33, 60, 60}, {60, 60, 33}, {80, 80, 80},
+}
+
+// sixel shows the Sixel image in the DCS just collected. The cursor ends
+// on the line below the image, in the column it started in.
+func (p *Parser) sixel() {
+	pixels, err := decodeSixel(p.params, p.strData)
+	if err != nil {
+		return
+	}
+	img := p.addImage(pixels, imageLayout{})
+	if img == nil {
+		return
+	}
+	x0 := p.placeImage(img)
+	p.lineFeed()
+	p.screen.cursor.X = x0
+}
+
+// sixelDecoder paints Sixel data onto an image that grows as needed
+type sixelDecoder struct {
+	img     *image.NRGBA
+	palette [maxSixelColors]color.NRGBA
+	color   color.NRGBA
+	x, y    int // Position of the current sixel: column and top row of the band
+	width   int // Columns painted or declared
+	height  int // Rows painted or declared
+}
+
+// decodeSixel decodes the data of a Sixel DCS with the given parameters.
+// P2 = 1 leaves unpainted pixels transparent; otherwise they take color
+// register 0. Pixels beyond the image caps are dropped.
+func decodeSixel(params []int, data []byte) (*image.NRGBA, error) {
+	d := &sixelDecoder{img: image.NewNRGBA(image.Rect(0, 0, 0, 0))}
+	for i, c := range sixelPalette {
+		d.palette[i] = sixelRGB(c[0], c[1], c[2])
+	}
+	d.color = d.palette[0]
+	background := d.palette[0]
+
+	repeat := 1
+	for i := 0; i < len(data); {
+		b := data[i]
+		i++
+		switch {
+		case b >= '?' && b <= '~': // Sixel: six vertical pixels
+			d.paint(b-'?', repeat)
+			repeat = 1
+		case b == '!': // Repeat introducer: !count sixel
+			var n []int
+			n, i = sixelParams(data, i)
+			repeat = max(1, n[0])
+		case b == '#': // Color: #Pc selects, #Pc;Pu;Px;Py;Pz defines and selects
+			var n []int
+			n, i = sixelParams(data, i)
+			d.setColor(n)
+		case b == '$': // Carriage return
+			d.x = 0
+		case b == '-': // Next line
+			d.x = 0
+			d.y += 6
+		case b == '"': // Raster attributes: "Pan;Pad;Ph;Pv
+			var n []int
+			n, i = sixelParams(data, i)
+			if len(n) >= 4 && d.width == 0 && d.height == 0 {
+				d.width = min(n[2], maxImageSide)
+				d.height = min(n[3], maxImageSide)
+				d.grow(d.width, d.height)
+			}
+		}
+	}
+
+	if d.width == 0 || d.height == 0 {
+		return nil, fmt.Errorf("empty sixel image")
+	}
+	img := d.img.SubImage(image.Rect(0, 0, min(d.width, d.img.Rect.Dx()), min(d.height, d.img.Rect.Dy()))).(*image.NRGBA)
+	if len(params) < 2 || params[1] != 1 {
+		for i := 3; i < len(img.Pix); i += 4 {
+			if img.Pix[i] == 0 {
+				copy(img.Pix[i-3:i+1], []uint8{background.R, background.G, background.B, background.A})
+			}
+		}
+	}
+	return img, nil
+}
+
+// paint draws a sixel repeat times at the current position and advances
+func (d *sixelDecoder) paint(bits byte, repeat int) {
+	if bits != 0 && d.grow(d.x+repeat, d.y+6) {
+		c := d.color
+		for bit := 0; bit < 6; bit++ {
+			if bits&(1<<bit) == 0 {
+				continue
+			}
+			y := d.y + bit
+			if y >= d.img.Rect.Dy() {
+				break
+			}
+			end := min(d.x+repeat, d.img.Rect.Dx())
+			for x := d.x; x < end; x++ {
+				i := d.img.PixOffset(x, y)
+				d.img.Pix[i], d.img.Pix[i+1], d.img.Pix[i+2], d.img.Pix[i+3] = c.R, c.G, c.B, 255
+			}
+			d.height = max(d.height, y+1)
+		}
+	}
+	d.x += repeat
+	d.width = max(d.width, min(d.x, d.img.Rect.Dx()))
+}
+
+// grow enlarges the image to at least w x h pixels, within the caps.
+// Reports whether any of the area fits.
+func (d *sixelDecoder) grow(w, h int) bool {
+	w, h = min(w, maxImageSide), min(h, maxImageSide)
+	cur := d.img.Rect.Size()
+	if w <= cur.X && h <= cur.Y {
+		return true
+	}
+	nw, nh := max(w, cur.X), max(h, cur.Y)
+	// Grow by doubling so long images don't copy on every band
+	if dw, dh := min(max(nw, cur.X*2), maxImageSide), min(max(nh, cur.Y*2), maxImageSide); dw*dh <= maxImagePixels {
+		nw, nh = dw, dh
+	}
+	if nw*nh > maxImagePixels {
+		return d.x < cur.X && d.y < cur.Y
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, nw, nh))
+	for y := 0; y < cur.Y; y++ {
+		copy(img.Pix[img.PixOffset(0, y):], d.img.Pix[d.img.PixOffset(0, y):d.img.PixOffset(cur.X, y)])
+	}
+	d.img = img
+	return true
+}
+
+// setColor handles #Pc (select) and #Pc;Pu;Px;Py;Pz (define and select).
+// Pu 1 is HLS with hue, lightness and saturation; Pu 2 is RGB in percent.
+func (d *sixelDecoder) setColor(n []int) {
+	pc := n[0]
+	if pc < 0 || pc >= maxSixelColors {
+		return
+	}
+	if len(n) >= 5 {
+		switch n[1] {
+		case 1:
+			d.palette[pc] = sixelHLS(n[2], n[3], n[4])
+		case 2:
+			d.palette[pc] = sixelRGB(n[2], n[3], n[4])
+		}
+	}
+	d.color = d.palette[pc]
+}
+
+// sixelParams reads the ';'-separated decimal numbers starting at data[i].
+// Always returns at least one number.
+func sixelParams(data []byte, i int) ([]int, int) {
+	n := []int{0}
+	for ; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b >= '0' && b <= '9':
+			if v := n[len(n)-1]; v < maxImageSide*10 {
+				n[len(n)-1] = v*10 + int(b-'0')
+			}
+		case b == ';':
+			n = append(n, 0)
+		default:
+			return n, i
+		}
+	}
+	return n, i
+}
+
+// sixelRGB converts a color given as percentages
+func sixelRGB(r, g, b int) color.NRGBA {
+	pct := func(v int) uint8 { return uint8(clamp(v, 0, 100) * 255 / 100) }
+	return color.NRGBA{pct(r), pct(g), pct(b), 255}
+}
+
+// sixelHLS converts a DEC HLS color, whose hue 0 is blue rather than red
+func sixelHLS(h, l, s int) color.NRGBA {
+	hue := float64((h+240)%360) / 360
+	lf, sf := float64(clamp(l, 0, 100))/100, float64(clamp(s, 0, 100))/100
+	if sf == 0 {
+		return sixelRGB(l, l, l)
+	}
+	var q float64
+	if lf < 0.5 {
+		q = lf * (1 + sf)
+	} else {
+		q = lf + sf - lf*sf
+	}
+	p := 2*lf - q
+	channel := func(t float64) uint8 {
+		switch {
+		case t < 0:
+			t++
+		case t > 1:
+			t--
+		}
+		var v float64
+		switch {
+		case t < 1.0/6:
+			v = p + (q-p)*6*t
+		case t < 1.0/2:
+			v = q
+		case t < 2.0/3:
+			v = p + (q-p)*(2.0/3-t)*6
+		default:
+			v = p
+		}
+		return uint8(v*255 + 0.5)
+	}
+	return color.NRGBA{channel(hue + 1.0/3), channel(hue), channel(hue - 1.0/3), 255}
+}
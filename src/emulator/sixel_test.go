@@ -0,0 +1,127 @@
+package emulator
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestDecodeSixel(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+	black := color.NRGBA{0, 0, 0, 255}
+
+	tests := []struct {
+		name   string
+		params []int
+		data   string
+		w, h   int
+		pixels map[[2]int]color.NRGBA
+	}{
+		{
+			name: "RGB colors and repeat",
+			data: "#1;2;100;0;0#1!3~$#2;2;0;0;100??~",
+			w:    3, h: 6,
+			pixels: map[[2]int]color.NRGBA{{0, 0}: red, {1, 5}: red, {2, 0}: blue, {2, 5}: blue},
+		},
+		{
+			name: "next band",
+			data: "#1;2;100;0;0@-@",
+			w:    1, h: 7,
+			pixels: map[[2]int]color.NRGBA{{0, 0}: red, {0, 1}: black, {0, 6}: red},
+		},
+		{
+			name:   "transparent background",
+			params: []int{0, 1},
+			data:   "#1;2;100;0;0A",
+			w:      1, h: 2,
+			pixels: map[[2]int]color.NRGBA{{0, 0}: {}, {0, 1}: red},
+		},
+		{
+			name: "raster size",
+			data: "\"1;1;4;12#1;2;100;0;0@",
+			w:    4, h: 12,
+			pixels: map[[2]int]color.NRGBA{{0, 0}: red, {3, 11}: black},
+		},
+		{
+			name: "HLS hue 120 is red",
+			data: "#1;1;120;50;100@",
+			w:    1, h: 1,
+			pixels: map[[2]int]color.NRGBA{{0, 0}: red},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := decodeSixel(tt.params, []byte(tt.data))
+			if err != nil {
+				t.Fatalf("decodeSixel: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+				t.Fatalf("size = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.w, tt.h)
+			}
+			for pt, want := range tt.pixels {
+				if got := img.NRGBAAt(pt[0], pt[1]); got != want {
+					t.Errorf("pixel %v = %v, want %v", pt, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeSixelCaps(t *testing.T) {
+	if _, err := decodeSixel(nil, []byte("#1$-")); err == nil {
+		t.Error("empty image decoded without error")
+	}
+	img, err := decodeSixel(nil, []byte("!99999~"))
+	if err != nil {
+		t.Fatalf("decodeSixel: %v", err)
+	}
+	if w := img.Bounds().Dx(); w != maxImageSide {
+		t.Errorf("width = %d, want clipped to %d", w, maxImageSide)
+	}
+}
+
+func TestParserSixel(t *testing.T) {
+	p := NewParser(NewScreen(10, 4), NewScrollback())
+	p.SetCellPixelSize(2, 4)
+
+	// A 3x8 image covers 2x2 cells of 2x4 pixels
+	p.Parse([]byte("ab\x1bPq#1;2;100;0;0!3~-!3A\x1b\\c"))
+
+	s := p.Screen()
+	for _, tt := range []struct{ x, y, col, row int }{{2, 0, 0, 0}, {3, 0, 1, 0}, {2, 1, 0, 1}, {3, 1, 1, 1}} {
+		id, col, row, ok := s.Cell(tt.x, tt.y).ImageTile()
+		if !ok || col != tt.col || row != tt.row {
+			t.Errorf("cell (%d,%d) = tile %d (%d,%d) %v, want (%d,%d)", tt.x, tt.y, id, col, row, ok, tt.col, tt.row)
+		}
+	}
+	if s.Cell(4, 0).Rune != ' ' {
+		t.Errorf("cell right of the image = %q", s.Cell(4, 0).Rune)
+	}
+
+	// The cursor goes below the image, back in its first column
+	if got := rowText(s, 2); got != "  c" {
+		t.Errorf("row 2 = %q, want %q", got, "  c")
+	}
+
+	id, _, _, _ := s.Cell(2, 0).ImageTile()
+	img := s.Image(id)
+	if img == nil || img.Cols != 2 || img.Rows != 2 || img.Pixels.Bounds().Dx() != 4 || img.Pixels.Bounds().Dy() != 8 {
+		t.Fatalf("image = %+v", img)
+	}
+	if got := img.Pixels.NRGBAAt(2, 7); got != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("pixel (2,7) = %v", got)
+	}
+	if got := img.Pixels.NRGBAAt(3, 0); got.A != 0 {
+		t.Errorf("padding pixel = %v, want transparent", got)
+	}
+}
+
+func TestParserDCSNotSixel(t *testing.T) {
+	p := NewParser(NewScreen(10, 2), NewScrollback())
+
+	// DECRQSS and aborted strings are consumed without printing
+	p.Parse([]byte("a\x1bP$qm\x1b\\b\x1bPqxyz\x18c"))
+	if got := rowText(p.Screen(), 0); got != "abc" {
+		t.Errorf("row = %q, want %q", got, "abc")
+	}
+}
@@ -0,0 +1,329 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+)
+
+// A snapshot is the terminal state the byte stream leaves behind and the
+// scrollback doesn't keep: both screens, the cursor and its style, drawing
+// attributes, margins, modes, charsets, tab stops and the title. It is
+// saved to <name>.snapshot beside the scrollback so a session comes back
+// after a daemon restart looking as it was left.
+//
+// Snapshots are JSON. Fields may be added without a version bump, since
+// older builds ignore fields they don't know; snapshotVersion changes only
+// when an existing field changes meaning. A build refuses snapshots newer
+// than it knows, and the session starts on an empty screen instead.
+// Screen rows are stored as scrollback line records (see segment.go).
+
+const (
+	snapshotVersion = 1
+	snapshotExt     = ".snapshot"
+
+	// maxSnapshotCells bounds the screen a snapshot may restore, guarding
+	// against a corrupt size
+	maxSnapshotCells = 1 << 22
+)
+
+var (
+	errSnapshotVersion = errors.New("snapshot: newer version than this build reads")
+	errBadSnapshot     = errors.New("snapshot: bad screen")
+)
+
+type parserSnapshot struct {
+	Version        int                 `json:"version"`
+	Main           screenSnapshot      `json:"main"`
+	Alt            *screenSnapshot     `json:"alt,omitempty"`
+	AltScreen      bool                `json:"alt_screen,omitempty"`
+	Title          string              `json:"title,omitempty"`
+	TitleStack     []string            `json:"title_stack,omitempty"`
+	MouseMode      MouseMode           `json:"mouse_mode,omitempty"`
+	MouseEncoding  MouseEncoding       `json:"mouse_encoding,omitempty"`
+	BracketedPaste bool                `json:"bracketed_paste,omitempty"`
+	AppCursorKeys  bool                `json:"app_cursor_keys,omitempty"`
+	AppKeypad      bool                `json:"app_keypad,omitempty"`
+	Keyboard       [2]keyboardSnapshot `json:"keyboard"`
+	ModifyKeys     int                 `json:"modify_other_keys,omitempty"`
+	LastRune       rune                `json:"last_rune,omitempty"`
+	CWDHost        string              `json:"cwd_host,omitempty"`
+	CWDPath        string              `json:"cwd_path,omitempty"`
+}
+
+type keyboardSnapshot struct {
+	Flags int   `json:"flags,omitempty"`
+	Stack []int `json:"stack,omitempty"`
+}
+
+type screenSnapshot struct {
+	Cols        int                  `json:"cols"`
+	Rows        int                  `json:"rows"`
+	Cells       [][]byte             `json:"cells"` // One line record per row
+	Cursor      Cursor               `json:"cursor"`
+	ScrollTop   int                  `json:"scroll_top"`
+	ScrollBot   int                  `json:"scroll_bot"`
+	Attrs       Cell                 `json:"attrs"`
+	Link        string               `json:"link,omitempty"`
+	PendingMark *Mark                `json:"pending_mark,omitempty"` // Only read: older builds held marks for the next row
+	Saved       *savedCursorSnapshot `json:"saved,omitempty"`
+	OriginMode  bool                 `json:"origin_mode,omitempty"`
+	AutoWrap    bool                 `json:"auto_wrap"`
+	InsertMode  bool                 `json:"insert_mode,omitempty"`
+	LRMargins   bool                 `json:"lr_margins,omitempty"`
+	MarginLeft  int                  `json:"margin_left"`
+	MarginRight int                  `json:"margin_right"`
+	MarginWrap  bool                 `json:"margin_wrap,omitempty"`
+	MarginWrapY int                  `json:"margin_wrap_y,omitempty"`
+	TabStops    []int                `json:"tab_stops"`
+	Charsets    [4]Charset           `json:"charsets"`
+	GL          int                  `json:"gl,omitempty"`
+}
+
+type savedCursorSnapshot struct {
+	Cursor     Cursor     `json:"cursor"`
+	Attrs      Cell       `json:"attrs"`
+	OriginMode bool       `json:"origin_mode,omitempty"`
+	Charsets   [4]Charset `json:"charsets"`
+	GL         int        `json:"gl,omitempty"`
+}
+
+// SnapshotPath returns the snapshot path for a session name
+func SnapshotPath(name string) string {
+	return snapshotPath(ScrollbackPath(name))
+}
+
+// snapshotPath returns the path of the snapshot beside a scrollback file
+func snapshotPath(path string) string {
+	return strings.TrimSuffix(path, scrollbackExt) + snapshotExt
+}
+
+// WriteSnapshot saves a snapshot under a temporary name and renames it
+// into place, so a crash mid-write leaves the previous snapshot.
+func WriteSnapshot(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Snapshot encodes the parser's terminal state for Restore. Sequences
+// still being parsed, held synchronized updates and kitty images not yet
+// placed aren't included.
+func (p *Parser) Snapshot() ([]byte, error) {
+	snap := parserSnapshot{
+		Version:        snapshotVersion,
+		Main:           p.main.snapshot(),
+		AltScreen:      p.altScreen,
+		Title:          p.title,
+		TitleStack:     p.titleStack,
+		MouseMode:      p.mouseMode,
+		MouseEncoding:  p.mouseEncoding,
+		BracketedPaste: p.bracketedPaste,
+		AppCursorKeys:  p.appCursorKeys,
+		AppKeypad:      p.appKeypad,
+		ModifyKeys:     p.modifyOtherKeys,
+		LastRune:       p.lastRune,
+		CWDHost:        p.cwdHost,
+		CWDPath:        p.cwdPath,
+	}
+	if p.alt != nil {
+		alt := p.alt.snapshot()
+		snap.Alt = &alt
+	}
+	for i, k := range p.keyboard {
+		snap.Keyboard[i] = keyboardSnapshot{Flags: k.flags, Stack: k.stack}
+	}
+	return json.Marshal(snap)
+}
+
+// Restore replaces the parser's terminal state with a snapshot from
+// Snapshot, resizing the screens to the size they were saved at. The
+// parser is left unchanged if the snapshot can't be read. Callbacks
+// aren't called for the restored title or working directory.
+func (p *Parser) Restore(data []byte) error {
+	var snap parserSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if snap.Version < 1 || snap.Version > snapshotVersion {
+		return errSnapshotVersion
+	}
+	main, err := snap.Main.restore(p.main.links)
+	if err != nil {
+		return err
+	}
+	var alt *Screen
+	if snap.Alt != nil {
+		if alt, err = snap.Alt.restore(p.main.links); err != nil {
+			return err
+		}
+		if alt.cols != main.cols || alt.rows != main.rows {
+			alt.Resize(main.cols, main.rows)
+		}
+	}
+	if snap.AltScreen && alt == nil {
+		return errBadSnapshot
+	}
+
+	// The main screen is restored in place: callers hold on to it
+	images := p.main.images
+	*p.main = *main
+	p.main.images = images
+	p.alt = alt
+	if alt != nil {
+		alt.images = images
+	}
+	p.screen = p.main
+	p.altScreen = snap.AltScreen
+	if p.altScreen {
+		p.screen = p.alt
+	}
+
+	p.title = snap.Title
+	p.titleStack = snap.TitleStack
+	if len(p.titleStack) > maxTitleStack {
+		p.titleStack = p.titleStack[len(p.titleStack)-maxTitleStack:]
+	}
+	p.mouseMode = snap.MouseMode
+	p.mouseEncoding = snap.MouseEncoding
+	p.bracketedPaste = snap.BracketedPaste
+	p.appCursorKeys = snap.AppCursorKeys
+	p.appKeypad = snap.AppKeypad
+	p.modifyOtherKeys = min(max(snap.ModifyKeys, 0), maxModifyOtherKeys)
+	for i, k := range snap.Keyboard {
+		p.keyboard[i] = keyboardFlags{flags: k.Flags, stack: k.Stack}
+	}
+	// The shell may have moved on while detached; its next mark says where
+	p.shellState = ShellUnknown
+	p.lastRune = snap.LastRune
+	p.cwdHost, p.cwdPath = snap.CWDHost, snap.CWDPath
+	p.state = StateGround
+	return nil
+}
+
+// RetireScreen is for a restored session whose program is gone and is
+// being started afresh: the main screen's rows, down to the last one with
+// text, are pushed into the scrollback and the terminal is reset as RIS
+// does, so the new program gets default modes and the old screen stays
+// right above its output.
+func (p *Parser) RetireScreen() {
+	last := -1
+	for y, row := range p.main.cells {
+		if trimmedLen(row) > 0 {
+			last = y
+		}
+	}
+	for y := 0; y <= last; y++ {
+		p.scrollback.Push(p.main.Line(y))
+	}
+	p.reset()
+}
+
+// snapshot captures the screen's state
+func (s *Screen) snapshot() screenSnapshot {
+	snap := screenSnapshot{
+		Cols:        s.cols,
+		Rows:        s.rows,
+		Cells:       make([][]byte, s.rows),
+		Cursor:      s.cursor,
+		ScrollTop:   s.scrollTop,
+		ScrollBot:   s.scrollBot,
+		Attrs:       s.attrs,
+		Link:        s.links.URI(s.link),
+		OriginMode:  s.originMode,
+		AutoWrap:    s.autoWrap,
+		InsertMode:  s.insertMode,
+		LRMargins:   s.lrMargins,
+		MarginLeft:  s.marginLeft,
+		MarginRight: s.marginRight,
+		MarginWrap:  s.marginWrap,
+		MarginWrapY: s.marginWrapY,
+		TabStops:    []int{},
+		Charsets:    s.charsets,
+		GL:          s.gl,
+	}
+	for y, row := range s.cells {
+		// The record without its length prefix
+		rec := appendRecord(nil, row, 0, s.links)
+		_, k := binary.Uvarint(rec)
+		snap.Cells[y] = rec[k:]
+	}
+	for x, stop := range s.tabStops {
+		if stop {
+			snap.TabStops = append(snap.TabStops, x)
+		}
+	}
+	if s.saved.ok {
+		snap.Saved = &savedCursorSnapshot{
+			Cursor:     s.saved.cursor,
+			Attrs:      s.saved.attrs,
+			OriginMode: s.saved.originMode,
+			Charsets:   s.saved.charsets,
+			GL:         s.saved.gl,
+		}
+	}
+	return snap
+}
+
+// restore builds a screen from a snapshot, clamping what is out of range,
+// with its links interned in the session's table
+func (snap *screenSnapshot) restore(links *LinkTable) (*Screen, error) {
+	cols, rows := snap.Cols, snap.Rows
+	if cols < 1 || rows < 1 || cols*rows > maxSnapshotCells || len(snap.Cells) != rows {
+		return nil, errBadSnapshot
+	}
+	s := NewScreen(cols, rows)
+	s.links = links
+	for y, rec := range snap.Cells {
+		line, _, err := decodeRecord(rec, links)
+		if err != nil {
+			return nil, err
+		}
+		copy(s.cells[y], line[:min(len(line), cols)])
+	}
+
+	s.cursor = snap.Cursor
+	s.cursor.X = clamp(s.cursor.X, 0, cols)
+	s.cursor.Y = clamp(s.cursor.Y, 0, rows-1)
+	if snap.ScrollTop >= 0 && snap.ScrollTop < snap.ScrollBot && snap.ScrollBot < rows {
+		s.scrollTop, s.scrollBot = snap.ScrollTop, snap.ScrollBot
+	}
+	s.attrs = snap.Attrs
+	s.link = links.intern(snap.Link)
+	s.originMode = snap.OriginMode
+	s.autoWrap = snap.AutoWrap
+	s.insertMode = snap.InsertMode
+	s.lrMargins = snap.LRMargins
+	if snap.MarginLeft >= 0 && snap.MarginLeft < snap.MarginRight && snap.MarginRight < cols {
+		s.marginLeft, s.marginRight = snap.MarginLeft, snap.MarginRight
+	}
+	s.marginWrap = snap.MarginWrap
+	s.marginWrapY = clamp(snap.MarginWrapY, 0, rows-1)
+	s.tabStops = make([]bool, cols)
+	for _, x := range snap.TabStops {
+		if x >= 0 && x < cols {
+			s.tabStops[x] = true
+		}
+	}
+	s.charsets = snap.Charsets
+	s.gl = clamp(snap.GL, 0, len(s.charsets)-1)
+	if saved := snap.Saved; saved != nil {
+		s.saved = savedCursor{
+			cursor:     saved.Cursor,
+			attrs:      saved.Attrs,
+			originMode: saved.OriginMode,
+			charsets:   saved.Charsets,
+			gl:         clamp(saved.GL, 0, len(s.charsets)-1),
+			ok:         true,
+		}
+	}
+	if snap.PendingMark != nil {
+		s.AddMark(*snap.PendingMark)
+	}
+	return s, nil
+}
@@ -0,0 +1,187 @@
+package emulator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sameTerminal reports how two parsers' visible state differs, or ""
+func sameTerminal(a, b *Parser) string {
+	ac, ar := a.Screen().Size()
+	bc, br := b.Screen().Size()
+	if ac != bc || ar != br {
+		return "size differs"
+	}
+	for y := 0; y < ar; y++ {
+		if !reflect.DeepEqual(a.Screen().Line(y), b.Screen().Line(y)) {
+			return "row " + rowText(a.Screen(), y) + " != " + rowText(b.Screen(), y)
+		}
+	}
+	if a.Screen().Cursor() != b.Screen().Cursor() {
+		return "cursor differs"
+	}
+	if a.Screen().Attrs() != b.Screen().Attrs() {
+		return "attrs differ"
+	}
+	if a.AltScreen() != b.AltScreen() || a.Title() != b.Title() || a.MouseMode() != b.MouseMode() ||
+		a.MouseEncoding() != b.MouseEncoding() || a.BracketedPaste() != b.BracketedPaste() ||
+		a.AppCursorKeys() != b.AppCursorKeys() || a.AppKeypad() != b.AppKeypad() ||
+		a.keyboardState().flags != b.keyboardState().flags {
+		return "modes differ"
+	}
+	return ""
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup string // Output before the snapshot
+		after string // Output fed to both terminals afterwards
+	}{
+		{
+			name: "text and attributes",
+			setup: "\x1b]0;build\x07\x1b[1;38;2;10;20;30;48;5;17mbold\x1b[0m plain " +
+				"\x1b]8;;https://example.com\x07link\x1b]8;;\x07 世界\r\nnext\x1b[4:3;58;5;9m",
+			after: "curly\r\nmore",
+		},
+		{
+			name:  "cursor and modes",
+			setup: "\x1b[5 q\x1b[?25l\x1b[?1002h\x1b[?1006h\x1b[?2004h\x1b[?1h\x1b=\x1b[>5u\x1b[4h\x1b[?7l",
+			after: "abc\x1b[Hxy" + strings.Repeat("z", 30),
+		},
+		{
+			name:  "scroll region and saved cursor",
+			setup: "one\r\ntwo\r\nthree\x1b[2;4r\x1b[3;2H\x1b[31m\x1b7\x1b[m\x1b[H",
+			after: "\x1b8red\x1b[4H\n\n\nscrolled",
+		},
+		{
+			name:  "charsets and tab stops",
+			setup: "\x1b[3g\x1bH\x1b[5G\x1bH\x1b)0\x0e",
+			after: "\tqqq\x0f\tqqq",
+		},
+		{
+			name:  "margins",
+			setup: "\x1b[?69h\x1b[3;8s\x1b[1;3H",
+			after: "abcdefghijk",
+		},
+		{
+			name:  "alternate screen",
+			setup: "shell$ vim\x1b[?1049h\x1b[2;3Hediting\x1b[?1000h",
+			after: "more\x1b[?1049l after",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := NewParser(NewScreen(20, 6), NewScrollback())
+			orig.Parse([]byte(tt.setup))
+			data, err := orig.Snapshot()
+			if err != nil {
+				t.Fatalf("Snapshot: %v", err)
+			}
+
+			// Restored into a terminal of another size, which takes the
+			// snapshot's
+			restored := NewParser(NewScreen(80, 24), NewScrollback())
+			screen := restored.main
+			if err := restored.Restore(data); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			if restored.main != screen {
+				t.Error("Restore replaced the main screen rather than restoring it in place")
+			}
+			if diff := sameTerminal(orig, restored); diff != "" {
+				t.Fatalf("after Restore: %s", diff)
+			}
+
+			// The restored terminal carries on as the original would
+			orig.Parse([]byte(tt.after))
+			restored.Parse([]byte(tt.after))
+			if diff := sameTerminal(orig, restored); diff != "" {
+				t.Errorf("after more output: %s", diff)
+			}
+		})
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s"+scrollbackExt)
+	p := newTestParser()
+	p.Parse([]byte("\x1b]2;title\x07hello"))
+	data, _ := p.Snapshot()
+	if err := WriteSnapshot(snapshotPath(path), data); err != nil {
+		t.Fatalf("WriteSnapshot: %v", err)
+	}
+
+	renamed := filepath.Join(filepath.Dir(path), "t"+scrollbackExt)
+	renameFiles(path, renamed)
+	got, err := os.ReadFile(snapshotPath(renamed))
+	if err != nil {
+		t.Fatalf("snapshot not renamed with the scrollback: %v", err)
+	}
+	q := newTestParser()
+	if err := q.Restore(got); err != nil || q.Title() != "title" || rowText(q.Screen(), 0) != "hello" {
+		t.Errorf("Restore = %v, title %q, row %q", err, q.Title(), rowText(q.Screen(), 0))
+	}
+}
+
+func TestSnapshotVersion(t *testing.T) {
+	p := newTestParser()
+	p.Parse([]byte("kept"))
+
+	tests := []struct {
+		name string
+		data string
+		ok   bool
+	}{
+		{"newer version", `{"version":2,"main":{"cols":1,"rows":1,"cells":["AQA="]}}`, false},
+		{"no version", `{"main":{"cols":1,"rows":1,"cells":["AQA="]}}`, false},
+		{"unknown fields", `{"version":1,"future":true,"main":{"cols":1,"rows":1,"cells":["AQA="],"more":[1]}}`, true},
+		{"bad size", `{"version":1,"main":{"cols":0,"rows":1,"cells":["AQA="]}}`, false},
+		{"missing rows", `{"version":1,"main":{"cols":4,"rows":2,"cells":["AQA="]}}`, false},
+		{"alternate screen missing", `{"version":1,"alt_screen":true,"main":{"cols":1,"rows":1,"cells":["AQA="]}}`, false},
+		{"not json", `PGSB`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestParser()
+			q.Parse([]byte("kept"))
+			err := q.Restore([]byte(tt.data))
+			if (err == nil) != tt.ok {
+				t.Fatalf("Restore error = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok && rowText(q.Screen(), 0) != "kept" {
+				t.Errorf("failed Restore changed the screen to %q", rowText(q.Screen(), 0))
+			}
+		})
+	}
+}
+
+func TestRetireScreen(t *testing.T) {
+	p := NewParser(NewScreen(20, 6), NewScrollback())
+	p.Parse([]byte("old\r\nlast screen\r\n\x1b[?1002h\x1b[?2004h\x1b[>1u\x1b[2;4r"))
+	data, _ := p.Snapshot()
+
+	q := NewParser(NewScreen(20, 6), NewScrollback())
+	if err := q.Restore(data); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	q.RetireScreen()
+
+	sb := q.Scrollback()
+	if sb.Count() != 2 || rowString(sb.Line(0)) != "old" || rowString(sb.Line(1)) != "last screen" {
+		t.Fatalf("scrollback has %d lines: %q", sb.Count(), rowString(sb.Line(0)))
+	}
+	if rowText(q.Screen(), 0) != "" || q.Screen().Cursor().Y != 0 {
+		t.Errorf("screen not cleared: %q, cursor row %d", rowText(q.Screen(), 0), q.Screen().Cursor().Y)
+	}
+	if q.MouseMode() != MouseNone || q.BracketedPaste() || q.keyboardState().flags != 0 {
+		t.Error("modes of the old program were kept")
+	}
+	if top, bot := q.Screen().ScrollRegion(); top != 0 || bot != 5 {
+		t.Errorf("scroll region = %d-%d, want reset", top, bot)
+	}
+}
@@ -0,0 +1,182 @@
+package emulator
+
+import (
+	"bytes"
+	"time"
+)
+
+const (
+	// syncTimeout is how long a synchronized update may hold output back
+	// before it is shown anyway, in case the program died mid-frame
+	syncTimeout = 150 * time.Millisecond
+
+	// maxSyncBytes caps the output one synchronized update may hold
+	maxSyncBytes = 8 << 20
+)
+
+// syncState is a synchronized update in progress (DECSET 2026). Output
+// after its start is held unparsed until its end, so the screen only ever
+// shows whole frames.
+type syncState struct {
+	on      bool
+	started time.Time
+	held    []byte   // Output received since the update began
+	scanned int      // Bytes of held already searched for the update's end
+	scan    syncScan // Where the search is in an escape sequence
+}
+
+// syncScan follows the escape sequences in held output, just closely
+// enough to find the one ending the update: a DECRST resetting 2026 among
+// any other modes, tmux's DCS form (ESC P = 2 s ST), or RIS.
+type syncScan struct {
+	state uint8
+	seq   []byte // The CSI parameters or DCS body so far
+}
+
+const (
+	scanGround = iota
+	scanEscape
+	scanCSI
+	scanDCS
+	scanDCSEscape
+)
+
+// maxScanSeq caps the sequence kept by syncScan; longer ones can't end
+// an update
+const maxScanSeq = 64
+
+// end reports whether b ends the synchronized update
+func (s *syncScan) end(b byte) bool {
+	switch s.state {
+	case scanEscape:
+		s.state = scanGround
+		switch b {
+		case '[':
+			s.state, s.seq = scanCSI, s.seq[:0]
+		case 'P':
+			s.state, s.seq = scanDCS, s.seq[:0]
+		case 'c': // RIS
+			return true
+		case 0x1b:
+			s.state = scanEscape
+		}
+	case scanCSI:
+		switch {
+		case b == 0x1b:
+			s.state = scanEscape
+		case b == 0x18 || b == 0x1a: // CAN, SUB
+			s.state = scanGround
+		case b >= 0x40 && b <= 0x7e:
+			s.state = scanGround
+			return b == 'l' && resetsSync(s.seq)
+		case len(s.seq) < maxScanSeq:
+			s.seq = append(s.seq, b)
+		}
+	case scanDCS:
+		if b == 0x1b {
+			s.state = scanDCSEscape
+		} else if len(s.seq) < maxScanSeq {
+			s.seq = append(s.seq, b)
+		}
+	case scanDCSEscape:
+		if b == '\\' {
+			s.state = scanGround
+			return string(s.seq) == "=2s"
+		}
+		// ESC ends the string and starts a sequence of its own
+		s.state = scanEscape
+		return s.end(b)
+	default:
+		if b == 0x1b {
+			s.state = scanEscape
+		}
+	}
+	return false
+}
+
+// resetsSync reports whether DECRST parameters include 2026
+func resetsSync(params []byte) bool {
+	rest, ok := bytes.CutPrefix(params, []byte("?"))
+	if !ok {
+		return false
+	}
+	for len(rest) > 0 {
+		var mode []byte
+		mode, rest, _ = bytes.Cut(rest, []byte(";"))
+		if string(mode) == "2026" {
+			return true
+		}
+	}
+	return false
+}
+
+// beginSync starts a synchronized update
+func (p *Parser) beginSync() {
+	if !p.sync.on {
+		p.sync = syncState{on: true, started: time.Now(), held: p.sync.held[:0], scan: syncScan{seq: p.sync.scan.seq[:0]}}
+	}
+}
+
+// holdSync holds data back for the synchronized update in progress. When
+// it contains the update's end, the held frame is parsed, which ends the
+// update as the terminal would, and the data after the end is returned for
+// parsing as usual.
+func (p *Parser) holdSync(data []byte) []byte {
+	s := &p.sync
+	s.held = append(s.held, data...)
+	end := -1
+	for i := s.scanned; i < len(s.held); i++ {
+		if s.scan.end(s.held[i]) {
+			end = i + 1
+			break
+		}
+	}
+	if end < 0 {
+		s.scanned = len(s.held)
+		if len(s.held) > maxSyncBytes {
+			p.EndSync()
+		}
+		return nil
+	}
+
+	frame := s.held[:end]
+	rest := append([]byte(nil), s.held[end:]...)
+	for _, b := range frame {
+		p.parseByte(b)
+	}
+	p.sync = syncState{held: frame[:0]}
+	return rest
+}
+
+// SyncPending reports whether a synchronized update is holding output
+// back, and when it times out.
+func (p *Parser) SyncPending() (deadline time.Time, ok bool) {
+	if !p.sync.on {
+		return time.Time{}, false
+	}
+	return p.sync.started.Add(syncTimeout), true
+}
+
+// ExpireSync ends a synchronized update that has held output back past
+// its timeout, showing what it held. Reports whether one is still pending
+// and when it times out.
+func (p *Parser) ExpireSync(now time.Time) (deadline time.Time, ok bool) {
+	if deadline, ok := p.SyncPending(); ok && !now.Before(deadline) {
+		p.EndSync()
+	}
+	return p.SyncPending()
+}
+
+// EndSync ends any synchronized update, parsing the output it held back.
+func (p *Parser) EndSync() {
+	if !p.sync.on {
+		return
+	}
+	held := p.sync.held
+	p.sync = syncState{}
+	for _, b := range held {
+		p.parseByte(b)
+	}
+	// Held output may itself begin an update; it has been shown already
+	p.sync = syncState{held: held[:0]}
+}
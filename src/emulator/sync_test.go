@@ -0,0 +1,112 @@
+package emulator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSynchronizedUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []string // Row 0 after each chunk
+	}{
+		{"held until end", []string{"a\x1b[?2026hb", "c", "\x1b[?2026ld"}, []string{"a", "a", "abcd"}},
+		{"end split across reads", []string{"\x1b[?2026hx\x1b[?20", "26l"}, []string{"", "x"}},
+		{"tmux DCS form", []string{"\x1bP=1s\x1b\\x", "y\x1bP=2s\x1b\\z"}, []string{"", "xyz"}},
+		{"two frames in one read", []string{"\x1b[?2026ha\x1b[?2026l\x1b[?2026hb"}, []string{"a"}},
+		{"end without begin", []string{"a\x1b[?2026lb"}, []string{"ab"}},
+		{"ended with other modes", []string{"\x1b[?2026hx", "\x1b[?25;2026ly"}, []string{"", "xy"}},
+		{"other modes only", []string{"\x1b[?2026hx\x1b[?25;1026l", "y"}, []string{"", ""}},
+		{"ended by RIS", []string{"\x1b[?2026hx", "\x1bcy"}, []string{"", "y"}},
+		{"tmux DCS split", []string{"\x1bP=1s\x1b\\x\x1bP=2", "s\x1b\\"}, []string{"", "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(NewScreen(10, 2), NewScrollback())
+			for i, chunk := range tt.chunks {
+				p.Parse([]byte(chunk))
+				if got := rowText(p.Screen(), 0); got != tt.want[i] {
+					t.Errorf("after chunk %d: row = %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestSynchronizedUpdateReset verifies RIS outside a held frame clears
+// the update, so nothing stays held behind it.
+func TestSynchronizedUpdateReset(t *testing.T) {
+	p := NewParser(NewScreen(10, 2), NewScrollback())
+	p.Parse([]byte("\x1b[?2026hx"))
+	p.RetireScreen()
+	if _, ok := p.SyncPending(); ok {
+		t.Error("update still pending after a reset")
+	}
+	p.Parse([]byte("y"))
+	if got := rowText(p.Screen(), 0); got != "y" {
+		t.Errorf("row = %q, want %q", got, "y")
+	}
+}
+
+func TestSynchronizedUpdateTimeout(t *testing.T) {
+	p := NewParser(NewScreen(10, 2), NewScrollback())
+	p.Parse([]byte("\x1b[?2026hheld"))
+
+	deadline, ok := p.SyncPending()
+	if !ok {
+		t.Fatal("no update pending")
+	}
+	if _, ok := p.ExpireSync(deadline.Add(-time.Millisecond)); !ok || rowText(p.Screen(), 0) != "" {
+		t.Error("update ended before its timeout")
+	}
+	if _, ok := p.ExpireSync(deadline); ok {
+		t.Error("update still pending after its timeout")
+	}
+	if got := rowText(p.Screen(), 0); got != "held" {
+		t.Errorf("row = %q, want %q", got, "held")
+	}
+
+	// Output after the timeout is shown as it arrives
+	p.Parse([]byte("!"))
+	if got := rowText(p.Screen(), 0); got != "held!" {
+		t.Errorf("row = %q, want %q", got, "held!")
+	}
+}
+
+func TestSynchronizedUpdateLimits(t *testing.T) {
+	// A program that never ends its update can't hold unbounded output
+	p := NewParser(NewScreen(10, 2), NewScrollback())
+	p.Parse([]byte("\x1b[?2026h"))
+	p.Parse([]byte(strings.Repeat("x", maxSyncBytes+1)))
+	if _, ok := p.SyncPending(); ok {
+		t.Error("update still pending past maxSyncBytes")
+	}
+
+	// A replayed log ending mid-update is shown
+	p = NewParser(NewScreen(10, 2), NewScrollback())
+	p.SetReplayMode(true)
+	p.Parse([]byte("\x1b[?2026hlog"))
+	p.SetReplayMode(false)
+	if got := rowText(p.Screen(), 0); got != "log" {
+		t.Errorf("row after replay = %q, want %q", got, "log")
+	}
+}
+
+func TestSynchronizedUpdateDECRQM(t *testing.T) {
+	p := NewParser(NewScreen(10, 2), NewScrollback())
+	var got []string
+	p.SetOnResponse(func(data []byte) { got = append(got, string(data)) })
+
+	p.Parse([]byte("\x1b[?2026$p"))
+	p.Parse([]byte("\x1b[?2026h\x1b[?2026$p"))
+	if len(got) != 1 {
+		t.Fatalf("replies = %q, want the query in the update held back", got)
+	}
+	p.Parse([]byte("\x1b[?2026l"))
+	want := []string{"\x1b[?2026;2$y", "\x1b[?2026;1$y"}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("replies = %q, want %q", got, want)
+	}
+}
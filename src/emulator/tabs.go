@@ -0,0 +1,66 @@
+package emulator
+
+// tabWidth is the spacing of the default tab stops
+const tabWidth = 8
+
+// resetTabStops sets a tab stop every tabWidth columns
+func (s *Screen) resetTabStops() {
+	s.tabStops = make([]bool, s.cols)
+	for x := tabWidth; x < s.cols; x += tabWidth {
+		s.tabStops[x] = true
+	}
+}
+
+// resizeTabStops keeps the tab stops within cols and extends the default
+// ones into any new columns.
+func (s *Screen) resizeTabStops(cols int) {
+	stops := make([]bool, cols)
+	copy(stops, s.tabStops)
+	for x := len(s.tabStops); x < cols; x++ {
+		stops[x] = x > 0 && x%tabWidth == 0
+	}
+	s.tabStops = stops
+}
+
+// SetTabStop sets a tab stop at the cursor column (HTS)
+func (s *Screen) SetTabStop() {
+	if x := s.cursor.X; x < s.cols {
+		s.tabStops[x] = true
+	}
+}
+
+// ClearTabStop clears the tab stop at the cursor column, or every tab stop
+// if all is set (TBC 0 and 3).
+func (s *Screen) ClearTabStop(all bool) {
+	if all {
+		s.tabStops = make([]bool, s.cols)
+	} else if x := s.cursor.X; x < s.cols {
+		s.tabStops[x] = false
+	}
+}
+
+// Tab moves the cursor forward n tab stops (HT, CHT), stopping at the
+// right margin.
+func (s *Screen) Tab(n int) {
+	_, right := s.horizontalMargins()
+	x := min(s.cursor.X, s.cols-1)
+	for ; n > 0 && x < right; n-- {
+		for x++; x < right && !s.tabStops[x]; x++ {
+		}
+	}
+	s.cursor.X = x
+	s.marginWrap = false
+}
+
+// BackTab moves the cursor back n tab stops (CBT), stopping at the left
+// margin.
+func (s *Screen) BackTab(n int) {
+	left, _ := s.horizontalMargins()
+	x := min(s.cursor.X, s.cols-1)
+	for ; n > 0 && x > left; n-- {
+		for x--; x > left && !s.tabStops[x]; x-- {
+		}
+	}
+	s.cursor.X = x
+	s.marginWrap = false
+}
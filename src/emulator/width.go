@@ -0,0 +1,61 @@
+package emulator
+
+import (
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// zeroWidthJoiner glues emoji into a single grapheme (e.g. family sequences)
+const zeroWidthJoiner = '\u200D'
+
+// RuneWidth returns the number of terminal columns a rune occupies:
+// 0 for combining marks and other zero-width runes, 2 for East Asian
+// wide/fullwidth characters and emoji, 1 otherwise.
+func RuneWidth(r rune) int {
+	switch {
+	case r == 0:
+		return 0
+	case r < 0x300:
+		// Fast path for ASCII and Latin-1
+		return 1
+	case isZeroWidth(r):
+		return 0
+	case isRegionalIndicator(r):
+		// Flags are two regional indicators drawn as one wide glyph
+		return 2
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// isZeroWidth reports whether r attaches to the preceding cell rather
+// than occupying a column of its own.
+func isZeroWidth(r rune) bool {
+	switch {
+	case r == zeroWidthJoiner, r == '\u200B', r == '\u200C', r == '\u2060':
+		return true
+	case r >= '\uFE00' && r <= '\uFE0F': // Variation selectors
+		return true
+	case r >= 0xE0100 && r <= 0xE01EF: // Variation selectors supplement
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // Emoji tag sequences
+		return true
+	case r >= 0x1160 && r <= 0x11FF: // Hangul medial vowels / final consonants
+		return true
+	}
+	return unicode.In(r, unicode.Mn, unicode.Me)
+}
+
+// isEmojiModifier reports whether r is a Fitzpatrick skin tone modifier.
+func isEmojiModifier(r rune) bool {
+	return r >= 0x1F3FB && r <= 0x1F3FF
+}
+
+// isRegionalIndicator reports whether r is one half of a flag pair.
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
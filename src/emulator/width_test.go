@@ -0,0 +1,28 @@
+package emulator
+
+import "testing"
+
+func TestRuneWidth(t *testing.T) {
+	tests := []struct {
+		r    rune
+		want int
+	}{
+		{'A', 1},
+		{'é', 1},
+		{'│', 1},
+		{'日', 2},
+		{'한', 2},
+		{'Ａ', 2}, // Fullwidth Latin
+		{'😀', 2},
+		{'🇯', 2},
+		{'\u0301', 0}, // Combining acute
+		{'\u200D', 0}, // ZWJ
+		{'\uFE0F', 0}, // VS16
+		{0, 0},
+	}
+	for _, tt := range tests {
+		if got := RuneWidth(tt.r); got != tt.want {
+			t.Errorf("RuneWidth(%q) = %d, want %d", tt.r, got, tt.want)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gioui.org/app"
@@ -15,6 +16,7 @@ import (
 
 	"prompt-grid/src/config"
 	"prompt-grid/src/emulator"
+	"prompt-grid/src/ipc"
 	"prompt-grid/src/pty"
 	"prompt-grid/src/ptylog"
 	"prompt-grid/src/render"
@@ -25,6 +27,13 @@ import (
 // ErrSessionNotFound is returned when a session is not found
 var ErrSessionNotFound = errors.New("session not found")
 
+// ErrReservedName is returned for a session name that is a CLI command
+// (see ipc.ReservedNames)
+var ErrReservedName = errors.New("session name is reserved for a prompt-grid command")
+
+// frameInterval is the shortest time between redraws requested by PTY output
+const frameInterval = 8 * time.Millisecond
+
 // DiscordStatus provides Discord connection status
 type DiscordStatus interface {
 	IsConnected() bool
@@ -47,11 +56,16 @@ type App struct {
 	discordBot      DiscordStatus
 	config          *config.Config
 	configPath      string
-	startupComplete bool // Set after discoverSessions(); session exits after this always clean up
+	clipboard       Clipboard // Target of programs' OSC 52 requests
+	startupComplete bool      // Set after discoverSessions(); session exits after this always clean up
 
 	observersMu sync.RWMutex
 	observers   []SessionLifecycleObserver
 
+	// Config save put off by saveConfigSoon
+	saveMu    sync.Mutex
+	saveTimer *time.Timer
+
 	// Trace support
 	traceMu      sync.RWMutex
 	tracer       *trace.Tracer
@@ -77,13 +91,28 @@ type SessionState struct {
 	ptyLog       *ptylog.Writer      // PTY output logger for persistence
 	promptStatus PromptStatusValue   // Current prompt detection status (atomic)
 
+	// snapMu is held while the snapshot file is written, renamed or
+	// removed, so a save never races a rename or close
+	snapMu     sync.Mutex
+	snapshot   []byte // Terminal state last saved to the snapshot file, protected by snapMu
+	snapClosed bool   // The session is gone: its snapshot must not be written again, protected by snapMu
+
+	// Prompt transitions, so a script can wait for the prompt that answers
+	// what it sent rather than the one it was sent at
+	promptSeq   atomic.Uint64 // Prompts the detector has seen the session arrive at
+	promptLine  int64         // 1 + absolute cursor line of the last prompt seen, 0 while busy; prompt detector only
+	awaitSeq    atomic.Uint64 // promptSeq the input last sent over IPC waits for
+	promptDirty atomic.Bool   // Output parsed since the prompt detector last looked
+
 	// screenMu protects parser/screen/scrollback/scrollOffset from concurrent
 	// access between the PTY data callback (writes) and the Gio render thread (reads).
 	screenMu sync.RWMutex
 
 	// Scrollback viewing state
-	scrollOffset int  // Lines scrolled up from bottom (0 = viewing live terminal)
-	scrollMode   bool // True when user is viewing history (frozen view)
+	scrollOffset int                        // Lines scrolled up from bottom (0 = viewing live terminal)
+	scrollMode   bool                       // True when user is viewing history (frozen view)
+	search       *searchState               // Find-bar results (nil when not searching), protected by screenMu
+	clipboardAsk *emulator.ClipboardRequest // OSC 52 request awaiting the user's answer, protected by screenMu
 
 	// Activity tracking
 	lastActivity     time.Time // Last time user interacted with session (typing/Discord, for collapse mode)
@@ -94,6 +123,14 @@ type SessionState struct {
 	pendingData        []byte
 	pendingLastRecv    time.Time // Timestamp of most recent PTY data arrival
 	pendingLastInvalid time.Time // Last time invalidateSession was called (rate limit)
+	pendingFlush       bool      // Invalidate scheduled for data arriving within the rate limit
+	syncDeadline       time.Time // When the synchronized update holding output back times out (zero if none)
+
+	// Replies to terminal queries, queued while parsing and written to the
+	// PTY by writeReplies once screenMu is released
+	replyMu  sync.Mutex
+	replies  []byte
+	replying bool // A writeReplies call is writing; it takes what is queued meanwhile
 
 	// Selection state
 	selStart     SelectionPoint
@@ -146,20 +183,27 @@ func (s *SessionState) Colors() render.SessionColor {
 // Called before rendering (Gio frame) and before screen reads (prompt detector,
 // Discord streamer). This is the double-buffer mechanism: PTY data is buffered
 // in OnData and parsed here, so intermediate states (screen cleared mid-redraw)
-// are never visible.
+// are never visible. Programs that mark their frames with synchronized updates
+// (DECSET 2026) are shown a whole frame at a time even across batches: the
+// parser holds a frame back until it ends or times out.
 func (s *SessionState) drainPendingData() {
 	s.pendingMu.Lock()
 	data := s.pendingData
 	s.pendingData = nil
+	syncDeadline := s.syncDeadline
 	s.pendingMu.Unlock()
 
-	if len(data) == 0 {
+	if len(data) == 0 && (syncDeadline.IsZero() || time.Now().Before(syncDeadline)) {
 		return
 	}
 
 	s.screenMu.Lock()
 	oldCount := s.scrollback.Count()
 	s.parser.Parse(data)
+	if len(data) > 0 {
+		s.promptDirty.Store(true)
+	}
+	syncDeadline, _ = s.parser.ExpireSync(time.Now())
 	if s.scrollMode {
 		newCount := s.scrollback.Count()
 		if delta := newCount - oldCount; delta > 0 {
@@ -168,6 +212,47 @@ func (s *SessionState) drainPendingData() {
 	}
 	s.ResetScrollOffset()
 	s.screenMu.Unlock()
+	s.writeReplies()
+
+	s.pendingMu.Lock()
+	s.syncDeadline = syncDeadline
+	s.pendingMu.Unlock()
+}
+
+// queueReply queues a reply to a terminal query. The parser calls it with
+// screenMu held, where a PTY write that blocks would stall rendering.
+func (s *SessionState) queueReply(data []byte) {
+	s.replyMu.Lock()
+	s.replies = append(s.replies, data...)
+	s.replyMu.Unlock()
+}
+
+// writeReplies writes the queued replies to the PTY, in order. If another
+// call is already writing, it picks up what was queued and this returns.
+func (s *SessionState) writeReplies() {
+	s.replyMu.Lock()
+	if s.replying {
+		s.replyMu.Unlock()
+		return
+	}
+	s.replying = true
+	for len(s.replies) > 0 {
+		data := s.replies
+		s.replies = nil
+		s.replyMu.Unlock()
+		s.pty.Write(data)
+		s.replyMu.Lock()
+	}
+	s.replying = false
+	s.replyMu.Unlock()
+}
+
+// SyncDeadline returns when the synchronized update holding output back
+// times out, or zero if there is none.
+func (s *SessionState) SyncDeadline() time.Time {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	return s.syncDeadline
 }
 
 // traceEvent logs a trace event if this session is being traced.
@@ -244,6 +329,50 @@ func (s *SessionState) InScrollMode() bool {
 	return s.scrollMode
 }
 
+// MouseReporting returns true when the application has enabled mouse
+// tracking and the live view is showing, so pointer events belong to the PTY.
+func (s *SessionState) MouseReporting() bool {
+	s.screenMu.RLock()
+	defer s.screenMu.RUnlock()
+	return s.parser != nil && s.parser.MouseMode() != emulator.MouseNone && s.scrollOffset == 0
+}
+
+// SendMouse encodes a pointer event for the application's mouse mode and
+// encoding and writes it to the PTY. Returns false if nothing was sent.
+func (s *SessionState) SendMouse(ev emulator.MouseEvent) bool {
+	s.screenMu.RLock()
+	var data []byte
+	if s.parser != nil {
+		data = emulator.EncodeMouse(s.parser.MouseMode(), s.parser.MouseEncoding(), ev)
+	}
+	s.screenMu.RUnlock()
+
+	if len(data) == 0 {
+		return false
+	}
+	s.pty.Write(data)
+	return true
+}
+
+// BracketedPaste returns true when the application has enabled bracketed paste mode.
+func (s *SessionState) BracketedPaste() bool {
+	s.screenMu.RLock()
+	defer s.screenMu.RUnlock()
+	return s.parser != nil && s.parser.BracketedPaste()
+}
+
+// Paste writes clipboard data to the PTY, bracketed if the application asked
+// for it so multi-line text arrives as one paste rather than line-by-line input.
+func (s *SessionState) Paste(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	s.traceEvent(trace.Event{Type: "paste", Text: string(data)})
+	_, err := s.pty.Write(emulator.EncodePaste(data, s.BracketedPaste()))
+	s.TouchActivity()
+	return err
+}
+
 // LockScreen takes a read lock on the screen/scrollback state.
 // Use when reading screen content from a non-Gio goroutine (e.g., Discord streamer).
 func (s *SessionState) LockScreen() {
@@ -340,7 +469,7 @@ func (s *SessionState) GetSelectedText() string {
 	}
 
 	cols, _ := s.Screen().Size()
-	var result []rune
+	var result strings.Builder
 
 	for y := startY; y <= endY; y++ {
 		lineStart := 0
@@ -354,21 +483,17 @@ func (s *SessionState) GetSelectedText() string {
 		}
 
 		for x := lineStart; x <= lineEnd; x++ {
-			cell := s.Screen().Cell(x, y)
-			if cell.Rune == 0 {
-				result = append(result, ' ')
-			} else {
-				result = append(result, cell.Rune)
-			}
+			result.WriteString(s.Screen().Cell(x, y).Text())
 		}
 
-		// Add newline between lines (but not at the end)
-		if y < endY {
-			result = append(result, '\n')
+		// Add newline between lines (but not at the end, and not where
+		// the text soft-wrapped onto the next row)
+		if y < endY && !s.Screen().Cell(cols-1, y).Wrapped {
+			result.WriteByte('\n')
 		}
 	}
 
-	return string(result)
+	return result.String()
 }
 
 // NewApp creates a new application
@@ -379,6 +504,7 @@ func NewApp(cfg *config.Config, cfgPath string) *App {
 		fontSize:   14,
 		config:     cfg,
 		configPath: cfgPath,
+		clipboard:  systemClipboard{},
 	}
 
 	// Discover and reconnect to existing tmux sessions
@@ -406,6 +532,9 @@ func NewApp(cfg *config.Config, cfgPath string) *App {
 	// Periodically clear tmux scrollback to prevent history reflow artifacts
 	a.startTmuxHistoryClearer()
 
+	// Periodically save each session's terminal state for the next run
+	a.startSnapshotSaver()
+
 	return a
 }
 
@@ -501,21 +630,25 @@ func (a *App) updateAllPromptStatuses() {
 	needsInvalidate := false
 	now := time.Now()
 	for _, ref := range sessions {
-		// Skip sessions with no new data since last check (nothing changed)
+		// Skip sessions with no new data since last check (nothing changed),
+		// including data a render has already parsed
 		ref.state.pendingMu.Lock()
 		hasPending := len(ref.state.pendingData) > 0
 		ref.state.pendingMu.Unlock()
-		if !hasPending {
+		if !hasPending && !ref.state.promptDirty.Load() {
 			continue
 		}
 
 		ref.state.drainPendingData()
+		ref.state.promptDirty.Store(false)
 		ref.state.screenMu.RLock()
 		screen := ref.state.Screen()
-		newStatus := detectPromptStatus(screen)
+		newStatus := sessionPromptStatus(screen, ref.state.parser.ShellState())
+		line := int64(ref.state.scrollback.Count() + screen.Cursor().Y)
 		menuDetected := autoMenu && now.Sub(ref.state.lastAutoMenuTime) > 3*time.Second && detectClaudeMenu(screen)
 		ref.state.screenMu.RUnlock()
 
+		ref.state.notePrompt(newStatus, line)
 		old := ref.state.promptStatus.Load()
 		if old != newStatus {
 			ref.state.promptStatus.Store(newStatus)
@@ -534,29 +667,40 @@ func (a *App) updateAllPromptStatuses() {
 	}
 }
 
-// updateAllCWDs polls tmux for the current working directory of each local
-// (non-SSH) session and saves any changes to config.
+// updateAllCWDs polls tmux for the current working directory of each session
+// and saves any changes to config. SSH sessions only have one if the remote
+// shell reports it with OSC 7, which tmux keeps as the pane path.
 func (a *App) updateAllCWDs() {
 	a.mu.RLock()
-	var names []string
+	ssh := make(map[string]bool, len(a.sessions))
 	for name, state := range a.sessions {
-		if !state.IsSSH() {
-			names = append(names, name)
-		}
+		ssh[name] = state.IsSSH()
 	}
 	a.mu.RUnlock()
 
-	if len(names) == 0 || a.config == nil {
+	if len(ssh) == 0 || a.config == nil {
 		return
 	}
 
 	changed := false
-	for _, name := range names {
+	for name, isSSH := range ssh {
+		info, ok := a.config.GetSessionInfo(name)
+		if !ok {
+			continue
+		}
+		if isSSH {
+			uri, err := tmux.GetPanePath(name)
+			if host, path, ok := emulator.ParseCWD(uri); err == nil && ok && applyCWD(&info, true, host, path) {
+				a.config.SetSessionInfo(name, info)
+				changed = true
+			}
+			continue
+		}
 		cwd, err := tmux.GetPaneCurrentPath(name)
 		if err != nil || cwd == "" {
 			continue
 		}
-		if info, ok := a.config.GetSessionInfo(name); ok && info.WorkDir != cwd {
+		if info.WorkDir != cwd {
 			info.WorkDir = cwd
 			a.config.SetSessionInfo(name, info)
 			changed = true
@@ -570,6 +714,59 @@ func (a *App) updateAllCWDs() {
 	a.saveAllActivityTimes()
 }
 
+// sshCommand returns the command reconnecting an SSH session, back in the
+// remote directory its shell last reported
+func sshCommand(info config.SessionInfo) []string {
+	if info.RemoteDir == "" {
+		return []string{"ssh", info.SSHHost}
+	}
+	dir := "'" + strings.ReplaceAll(info.RemoteDir, "'", `'\''`) + "'"
+	return []string{"ssh", "-t", info.SSHHost, "cd " + dir + "; exec $SHELL -l"}
+}
+
+// updateCWD records a working directory reported by a session's shell with
+// OSC 7 as soon as it arrives, rather than at the next poll. It is called
+// from the parser with screenMu held, so the save is left to
+// saveConfigSoon. tmux keeps OSC 7 for itself unless it's wrapped in
+// passthrough, which the shell integration hooks do; without them the
+// directory is picked up by the poll.
+func (a *App) updateCWD(state *SessionState, host, path string) {
+	if a.config == nil {
+		return
+	}
+	if info, ok := a.config.GetSessionInfo(state.name); ok && applyCWD(&info, state.IsSSH(), host, path) {
+		a.config.SetSessionInfo(state.name, info)
+		a.saveConfigSoon()
+	}
+}
+
+// applyCWD records a reported working directory in info and returns whether
+// it changed. SSH sessions keep the remote host and path apart from WorkDir;
+// local sessions ignore paths on other machines (e.g. after typing ssh).
+func applyCWD(info *config.SessionInfo, ssh bool, host, path string) bool {
+	if ssh {
+		if info.RemoteHost == host && info.RemoteDir == path {
+			return false
+		}
+		info.RemoteHost, info.RemoteDir = host, path
+		return true
+	}
+	if !isLocalHost(host) || info.WorkDir == path {
+		return false
+	}
+	info.WorkDir = path
+	return true
+}
+
+// isLocalHost reports whether an OSC 7 host names this machine
+func isLocalHost(host string) bool {
+	if host == "" || strings.EqualFold(host, "localhost") {
+		return true
+	}
+	name, err := os.Hostname()
+	return err == nil && strings.EqualFold(host, name)
+}
+
 // saveAllActivityTimes persists each session's lastActivity to config.
 func (a *App) saveAllActivityTimes() {
 	if a.config == nil {
@@ -631,6 +828,17 @@ func (a *App) discoverSessions() {
 // setupSessionCallbacks connects PTY data/exit callbacks to parser and log writer.
 // Shared between reconnectSession, NewSession, and recreateSession.
 func (a *App) setupSessionCallbacks(state *SessionState, name string) {
+	// Replies to terminal queries (cursor position, device attributes, ...)
+	// go back to the application once the parse that asked for them is done.
+	state.parser.SetCellPixelSize(int(float32(a.fontSize)*0.6), int(float32(a.fontSize)*1.5))
+	state.parser.SetOnResponse(state.queueReply)
+	state.parser.SetOnClipboard(func(req emulator.ClipboardRequest) {
+		a.handleClipboard(state, req)
+	})
+	state.parser.SetOnCWD(func(host, path string) {
+		a.updateCWD(state, host, path)
+	})
+
 	state.pty.SetOnData(func(data []byte) {
 		// Trace raw PTY data
 		a.traceMu.RLock()
@@ -646,13 +854,30 @@ func (a *App) setupSessionCallbacks(state *SessionState, name string) {
 		now := time.Now()
 		state.pendingLastRecv = now
 		// Rate-limit invalidation: at most once per 8ms to avoid waking the
-		// Gio frame loop on every PTY read during bursts.
-		shouldInvalidate := now.Sub(state.pendingLastInvalid) >= 8*time.Millisecond
+		// Gio frame loop on every PTY read during bursts. Data arriving
+		// within the limit gets one invalidate when it runs out, so the
+		// last read of a burst (often a frame's end) isn't left undrawn.
+		sinceInvalid := now.Sub(state.pendingLastInvalid)
+		shouldInvalidate := sinceInvalid >= frameInterval
+		scheduleFlush := !shouldInvalidate && !state.pendingFlush
 		if shouldInvalidate {
 			state.pendingLastInvalid = now
 		}
+		if scheduleFlush {
+			state.pendingFlush = true
+		}
 		state.pendingMu.Unlock()
 
+		if scheduleFlush {
+			time.AfterFunc(frameInterval-sinceInvalid, func() {
+				state.pendingMu.Lock()
+				state.pendingFlush = false
+				state.pendingLastInvalid = time.Now()
+				state.pendingMu.Unlock()
+				a.invalidateSession(name)
+			})
+		}
+
 		// Write to PTY log immediately (persistence, not affected by buffering)
 		if state.ptyLog != nil {
 			state.ptyLog.Write(data)
@@ -666,8 +891,14 @@ func (a *App) setupSessionCallbacks(state *SessionState, name string) {
 		// PTY exited - check if tmux session still exists (detach vs death)
 		if !tmux.HasSession(name) {
 			a.mu.Lock()
-			delete(a.sessions, name)
+			closed := a.sessions[name] != state // CloseSession got here first and cleaned up
+			if !closed {
+				delete(a.sessions, name)
+			}
 			a.mu.Unlock()
+			if closed {
+				return
+			}
 
 			// startupComplete means the app is fully running: any exit is intentional
 			// (user typed 'exit'). Clean up so the session doesn't resurrect on restart.
@@ -677,6 +908,10 @@ func (a *App) setupSessionCallbacks(state *SessionState, name string) {
 
 			if shouldCleanup {
 				ptylog.DeleteLog(name)
+				state.snapMu.Lock()
+				state.snapClosed = true
+				os.Remove(emulator.SnapshotPath(name))
+				state.snapMu.Unlock()
 				if a.config != nil {
 					a.config.DeleteSessionColor(name)
 					a.config.DeleteWindowSize(name)
@@ -693,6 +928,30 @@ func (a *App) setupSessionCallbacks(state *SessionState, name string) {
 	})
 }
 
+// openScrollback opens a session's scrollback file with its retention
+// limits, falling back to memory if the file can't be opened
+func (a *App) openScrollback(name string) *emulator.Scrollback {
+	sb, err := emulator.NewScrollbackWithPath(emulator.ScrollbackPath(name))
+	if err != nil {
+		return emulator.NewScrollback()
+	}
+	sb.SetRetention(a.scrollbackRetention(name))
+	return sb
+}
+
+// scrollbackRetention returns a session's configured history limits
+func (a *App) scrollbackRetention(name string) emulator.Retention {
+	if a.config == nil {
+		return emulator.Retention{MaxBytes: config.DefaultScrollbackMaxBytes}
+	}
+	r := a.config.GetScrollbackRetention(name)
+	return emulator.Retention{
+		MaxLines: r.MaxLines,
+		MaxBytes: r.MaxBytes,
+		MaxAge:   time.Duration(r.MaxAgeDays) * 24 * time.Hour,
+	}
+}
+
 // reconnectSession connects to an existing tmux session
 func (a *App) reconnectSession(name string) error {
 	// Kill tmux scrollback BEFORE attaching. On attach, tmux dumps its
@@ -710,13 +969,13 @@ func (a *App) reconnectSession(name string) error {
 
 	// Create emulator components
 	screen := emulator.NewScreen(int(cols), int(rows))
-	sbPath := emulator.ScrollbackPath(name)
-	scrollback, err := emulator.NewScrollbackWithPath(sbPath)
-	if err != nil {
-		scrollback = emulator.NewScrollback() // fallback to in-memory
-	}
+	scrollback := a.openScrollback(name)
 	parser := emulator.NewParser(screen, scrollback)
 
+	// Restore the cursor, attributes, modes and title tmux doesn't resend
+	// on attach, and the screen it redraws over
+	a.restoreSnapshot(parser, ptySess, name)
+
 	// Truncate the ptylog — scrollback is persisted in the .scrollback file,
 	// and tmux redraws the current screen on attach, so replay is unnecessary.
 	ptylog.TruncateLog(name)
@@ -779,7 +1038,8 @@ func (a *App) reconnectSession(name string) error {
 }
 
 // recreateSession creates a new tmux session from saved config (after reboot).
-// It replays the PTY log to restore scrollback, then starts a fresh shell/ssh/claude.
+// The last screen from the session's snapshot is kept in the scrollback, then
+// a fresh shell/ssh/claude is started.
 func (a *App) recreateSession(name string, info config.SessionInfo) error {
 	cols := uint16(120)
 	rows := uint16(24)
@@ -788,7 +1048,7 @@ func (a *App) recreateSession(name string, info config.SessionInfo) error {
 	var initialCmd []string
 	workDir := info.WorkDir
 	if info.SSHHost != "" {
-		initialCmd = []string{"ssh", info.SSHHost}
+		initialCmd = sshCommand(info)
 		workDir = "" // SSH sessions don't use local workDir
 	} else if info.Type == "claude" {
 		// Claude sessions run claude --continue to resume the last conversation.
@@ -801,6 +1061,8 @@ func (a *App) recreateSession(name string, info config.SessionInfo) error {
 	} else if info.Type == "codex" {
 		// Codex sessions run codex --resume to continue the last conversation.
 		initialCmd = []string{"codex", "--resume"}
+	} else {
+		initialCmd = a.shellCommand()
 	}
 	if err := tmux.NewSession(name, workDir, cols, rows, initialCmd...); err != nil {
 		return err
@@ -810,14 +1072,15 @@ func (a *App) recreateSession(name string, info config.SessionInfo) error {
 
 	// Create emulator components
 	screen := emulator.NewScreen(int(cols), int(rows))
-	sbPath := emulator.ScrollbackPath(name)
-	scrollback, err := emulator.NewScrollbackWithPath(sbPath)
-	if err != nil {
-		scrollback = emulator.NewScrollback() // fallback to in-memory
-	}
+	scrollback := a.openScrollback(name)
 	parser := emulator.NewParser(screen, scrollback)
 
-	// Replay saved PTY log to restore screen state.
+	// The session's last screen goes into the scrollback, above the new
+	// program's output, and the terminal is reset for the new program
+	if a.restoreSnapshot(parser, ptySess, name) {
+		parser.RetireScreen()
+	}
+
 	// Truncate the ptylog — scrollback is persisted in the .scrollback file,
 	// and tmux redraws the current screen on attach, so replay is unnecessary.
 	ptylog.TruncateLog(name)
@@ -882,8 +1145,29 @@ func (a *App) resolveSessionColor(name string) render.SessionColor {
 	return render.RandomSessionColor()
 }
 
-// saveConfig writes config to disk (best-effort, logs no error)
+// configSaveDelay is how long saveConfigSoon waits, gathering changes
+const configSaveDelay = time.Second
+
+// saveConfigSoon saves config in the background after configSaveDelay, so
+// a burst of changes (a shell reporting its directory at every prompt)
+// costs one write, made without the caller's locks.
+func (a *App) saveConfigSoon() {
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+	if a.saveTimer == nil {
+		a.saveTimer = time.AfterFunc(configSaveDelay, a.saveConfig)
+	}
+}
+
+// saveConfig writes config to disk (best-effort, logs no error), taking
+// the place of any save saveConfigSoon has put off
 func (a *App) saveConfig() {
+	a.saveMu.Lock()
+	if a.saveTimer != nil {
+		a.saveTimer.Stop()
+		a.saveTimer = nil
+	}
+	a.saveMu.Unlock()
 	if a.config != nil && a.configPath != "" {
 		a.config.Save(a.configPath)
 	}
@@ -990,12 +1274,7 @@ func captureScreenText(screen *emulator.Screen, cols, rows int) string {
 	var sb strings.Builder
 	for y := 0; y < rows; y++ {
 		for x := 0; x < cols; x++ {
-			cell := screen.Cell(x, y)
-			if cell.Rune == 0 {
-				sb.WriteRune(' ')
-			} else {
-				sb.WriteRune(cell.Rune)
-			}
+			sb.WriteString(screen.Cell(x, y).Text())
 		}
 		if y < rows-1 {
 			sb.WriteRune('\n')
@@ -1009,6 +1288,16 @@ func (a *App) Colors() render.DefaultColors {
 	return a.colors
 }
 
+// shellCommand returns the command starting a new local shell: the user's
+// shell with the OSC 133 hooks when shell integration is on, otherwise nil
+// for tmux's default shell.
+func (a *App) shellCommand() []string {
+	if a.config == nil || !a.config.GetShellIntegration() {
+		return nil
+	}
+	return tmux.ShellIntegrationCommand(os.Getenv("SHELL"))
+}
+
 // FontSize returns the current font size
 func (a *App) FontSize() unit.Sp {
 	return a.fontSize
@@ -1017,6 +1306,9 @@ func (a *App) FontSize() unit.Sp {
 // NewSession creates a new session by creating a tmux session and attaching via PTY.
 // workDir sets the initial working directory (empty = tmux default).
 func (a *App) NewSession(name, sshHost, workDir string) (*SessionState, error) {
+	if ipc.IsReservedName(name) {
+		return nil, fmt.Errorf("%w: %q", ErrReservedName, name)
+	}
 	a.mu.Lock()
 	if _, exists := a.sessions[name]; exists {
 		a.mu.Unlock()
@@ -1030,6 +1322,8 @@ func (a *App) NewSession(name, sshHost, workDir string) (*SessionState, error) {
 	var initialCmd []string
 	if sshHost != "" {
 		initialCmd = []string{"ssh", sshHost}
+	} else {
+		initialCmd = a.shellCommand()
 	}
 	if err := tmux.NewSession(name, workDir, cols, rows, initialCmd...); err != nil {
 		return nil, err
@@ -1040,11 +1334,7 @@ func (a *App) NewSession(name, sshHost, workDir string) (*SessionState, error) {
 
 	// Create emulator components
 	screen := emulator.NewScreen(int(cols), int(rows))
-	sbPath := emulator.ScrollbackPath(name)
-	scrollback, err := emulator.NewScrollbackWithPath(sbPath)
-	if err != nil {
-		scrollback = emulator.NewScrollback() // fallback to in-memory
-	}
+	scrollback := a.openScrollback(name)
 	parser := emulator.NewParser(screen, scrollback)
 
 	// Start PTY log writer
@@ -1104,6 +1394,9 @@ func (a *App) NewSession(name, sshHost, workDir string) (*SessionState, error) {
 // newSessionWithCommand creates a session that runs a specific command (like claude).
 // When the command exits, tmux closes the session automatically.
 func (a *App) newSessionWithCommand(name, workDir, command string) (*SessionState, error) {
+	if ipc.IsReservedName(name) {
+		return nil, fmt.Errorf("%w: %q", ErrReservedName, name)
+	}
 	a.mu.Lock()
 	if _, exists := a.sessions[name]; exists {
 		a.mu.Unlock()
@@ -1123,11 +1416,7 @@ func (a *App) newSessionWithCommand(name, workDir, command string) (*SessionStat
 
 	// Create emulator components
 	screen := emulator.NewScreen(int(cols), int(rows))
-	sbPath2 := emulator.ScrollbackPath(name)
-	scrollback2, err2 := emulator.NewScrollbackWithPath(sbPath2)
-	if err2 != nil {
-		scrollback2 = emulator.NewScrollback() // fallback to in-memory
-	}
+	scrollback2 := a.openScrollback(name)
 	parser := emulator.NewParser(screen, scrollback2)
 
 	// Start PTY log writer
@@ -1299,6 +1588,21 @@ func (a *App) PopOutSession(name string) {
 	}()
 }
 
+// FocusSession brings a session to the front: its standalone window when
+// popped out, otherwise the control window with the session selected.
+func (a *App) FocusSession(name string) error {
+	state := a.GetSession(name)
+	if state == nil {
+		return ErrSessionNotFound
+	}
+	if win := state.window; win != nil {
+		win.BringToFront()
+	} else if a.controlWin != nil {
+		a.controlWin.Focus(state.name)
+	}
+	return nil
+}
+
 // CallBackSession closes the standalone window for a session.
 // The session remains alive in the control center.
 func (a *App) CallBackSession(name string) {
@@ -1352,7 +1656,10 @@ func (a *App) CloseSession(name string) error {
 
 	// Remove saved color, window size, session info, PTY log, and scrollback
 	ptylog.DeleteLog(actualName)
+	state.snapMu.Lock()
+	state.snapClosed = true
 	emulator.DeleteScrollback(actualName)
+	state.snapMu.Unlock()
 	if a.config != nil {
 		a.config.DeleteSessionColor(actualName)
 		a.config.DeleteWindowSize(actualName)
@@ -1367,6 +1674,9 @@ func (a *App) CloseSession(name string) error {
 
 // RenameSession renames a session
 func (a *App) RenameSession(oldName, newName string) error {
+	if ipc.IsReservedName(newName) {
+		return fmt.Errorf("%w: %q", ErrReservedName, newName)
+	}
 	a.mu.Lock()
 
 	// Check if new name already exists
@@ -1397,24 +1707,26 @@ func (a *App) RenameSession(oldName, newName string) error {
 		return err
 	}
 
-	// Close old log writer and scrollback, rename files, open new ones
+	// Close the old log writer and reopen it under the new name. The
+	// scrollback moves its own files, so the parser keeps writing to it
+	// and its lines keep their times.
 	if state.ptyLog != nil {
 		state.ptyLog.Close()
 	}
-	if state.scrollback != nil {
-		state.scrollback.Close()
-	}
 	ptylog.RenameLog(actualName, newName)
-	emulator.RenameScrollback(actualName, newName)
 	state.ptyLog, _ = ptylog.NewWriter(newName)
-	sbPath := emulator.ScrollbackPath(newName)
-	if sb, sbErr := emulator.NewScrollbackWithPath(sbPath); sbErr == nil {
-		state.scrollback = sb
+	state.snapMu.Lock()
+	if state.scrollback != nil {
+		state.scrollback.Rename(emulator.ScrollbackPath(newName))
+	} else {
+		emulator.RenameScrollback(actualName, newName)
 	}
+	state.snapshot = nil // Written again under the new name on the next save
 
 	// Move to new name
 	delete(a.sessions, actualName)
 	state.name = newName
+	state.snapMu.Unlock()
 	a.sessions[newName] = state
 
 	// Move saved color, window size, and session info mappings
@@ -1424,6 +1736,9 @@ func (a *App) RenameSession(oldName, newName string) error {
 		a.config.RenameSessionInfo(actualName, newName)
 		a.saveConfig()
 	}
+	if state.scrollback != nil {
+		state.scrollback.SetRetention(a.scrollbackRetention(newName))
+	}
 
 	// Capture window ref before releasing lock
 	win := state.window
@@ -1641,15 +1956,22 @@ func (a *App) AddCodexSession(name, dir string) error {
 	return nil
 }
 
-// FlushAllLogs flushes all PTY log writers to disk.
-// Called during graceful shutdown to prevent data loss.
+// FlushAllLogs flushes all PTY log writers to disk and saves each session's
+// terminal state. Called during graceful shutdown to prevent data loss.
 func (a *App) FlushAllLogs() {
 	a.mu.Lock()
-	defer a.mu.Unlock()
-
-	for _, state := range a.sessions {
+	var states []*SessionState
+	for name, state := range a.sessions {
 		if state.ptyLog != nil {
-			state.ptyLog.Flush()
+			if err := state.ptyLog.Flush(); err != nil {
+				fmt.Fprintf(os.Stderr, "PTY log of %s: %v\n", name, err)
+			}
 		}
+		states = append(states, state)
+	}
+	a.mu.Unlock()
+
+	for _, state := range states {
+		a.saveSnapshot(state)
 	}
 }
@@ -432,6 +432,154 @@ func TestReconnectSessionRestoresScrollback(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 }
 
+func TestReconnectSessionRestoresSnapshot(t *testing.T) {
+	app := NewApp(nil, "")
+	_, err := app.NewSession("test-reconnect-snap", "", "/tmp")
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+
+	// State tmux doesn't resend on attach
+	state := app.GetSession("test-reconnect-snap")
+	state.screenMu.Lock()
+	state.parser.Parse([]byte("\x1b]2;restored title\x07\x1b[5 q"))
+	state.screenMu.Unlock()
+	app.FlushAllLogs()
+
+	// Restart with tmux still running
+	state.pty.SetOnExit(nil)
+	state.pty.Close()
+	state.scrollback.Close()
+	app.mu.Lock()
+	delete(app.sessions, "test-reconnect-snap")
+	app.mu.Unlock()
+
+	if err := app.reconnectSession("test-reconnect-snap"); err != nil {
+		t.Fatalf("reconnectSession: %v", err)
+	}
+	defer app.CloseSession("test-reconnect-snap")
+	state2 := app.GetSession("test-reconnect-snap")
+	state2.screenMu.RLock()
+	title, cursor := state2.parser.Title(), state2.Screen().Cursor()
+	state2.screenMu.RUnlock()
+	if title != "restored title" {
+		t.Errorf("title = %q, want it restored from the snapshot", title)
+	}
+	if cursor.Style != emulator.CursorBar {
+		t.Errorf("cursor style = %d, want a bar", cursor.Style)
+	}
+	time.Sleep(100 * time.Millisecond)
+}
+
+// TestFlushAllLogsAsksForClipboard verifies a clipboard request in output
+// parsed on the way out doesn't deadlock against the app's lock.
+func TestFlushAllLogsAsksForClipboard(t *testing.T) {
+	cfg := &config.Config{Clipboard: config.ClipboardSettings{Policy: config.ClipboardAsk, MaxBytes: 64}}
+	app := NewApp(cfg, "")
+	sb := emulator.NewScrollback()
+	name := "test-flush-clipboard"
+	state := &SessionState{app: app, name: name, scrollback: sb, parser: emulator.NewParser(emulator.NewScreen(10, 2), sb)}
+	state.parser.SetOnClipboard(func(req emulator.ClipboardRequest) { app.handleClipboard(state, req) })
+	state.pendingData = []byte("\x1b]52;c;aGk=\x07")
+	app.mu.Lock()
+	app.sessions[name] = state
+	app.mu.Unlock()
+	t.Cleanup(func() { os.Remove(emulator.SnapshotPath(name)) })
+
+	done := make(chan struct{})
+	go func() {
+		app.FlushAllLogs()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("FlushAllLogs deadlocked")
+	}
+	if _, ok := state.PendingClipboard(); !ok {
+		t.Error("clipboard request not waiting for the user")
+	}
+}
+
+// TestRenameSessionSavesSnapshot verifies a renamed session's unchanged
+// state is still saved under its new name.
+func TestRenameSessionSavesSnapshot(t *testing.T) {
+	app := NewApp(nil, "")
+	if _, err := app.NewSession("test-snap-old", "", "/tmp"); err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	// Hold the screen still, so the state saved doesn't change
+	app.GetSession("test-snap-old").pty.SetOnData(func([]byte) {})
+	app.FlushAllLogs()
+	if err := app.RenameSession("test-snap-old", "test-snap-new"); err != nil {
+		t.Fatalf("RenameSession: %v", err)
+	}
+	defer app.CloseSession("test-snap-new")
+
+	os.Remove(emulator.SnapshotPath("test-snap-new"))
+	app.FlushAllLogs()
+	if _, err := os.Stat(emulator.SnapshotPath("test-snap-new")); err != nil {
+		t.Errorf("snapshot not saved under the new name: %v", err)
+	}
+	if _, err := os.Stat(emulator.SnapshotPath("test-snap-old")); !os.IsNotExist(err) {
+		t.Errorf("snapshot left under the old name: %v", err)
+	}
+}
+
+func TestRecreateSessionKeepsLastScreen(t *testing.T) {
+	cfgPath := filepath.Join(os.Getenv("HOME"), ".config", "prompt-grid", "config.json")
+	os.MkdirAll(filepath.Dir(cfgPath), 0755)
+	cfg := &config.Config{}
+
+	app := NewApp(cfg, cfgPath)
+	_, err := app.NewSession("test-recreate-snap", "", "/tmp")
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	state := app.GetSession("test-recreate-snap")
+	state.screenMu.Lock()
+	state.parser.Parse([]byte("\x1b[2J\x1b[Hlast screen before reboot\x1b[?2004h"))
+	state.screenMu.Unlock()
+	app.FlushAllLogs()
+
+	// Reboot: no exit callbacks, tmux gone
+	state.pty.SetOnExit(nil)
+	state.pty.Close()
+	state.scrollback.Close()
+	tmux.KillSession("test-recreate-snap")
+	time.Sleep(200 * time.Millisecond)
+	app.mu.Lock()
+	delete(app.sessions, "test-recreate-snap")
+	app.mu.Unlock()
+
+	app2 := NewApp(cfg, cfgPath)
+	defer app2.CloseSession("test-recreate-snap")
+	state2 := app2.GetSession("test-recreate-snap")
+	if state2 == nil {
+		t.Fatal("session should be recreated after tmux death")
+	}
+
+	found := false
+	state2.scrollback.ScanLines(state2.scrollback.Count(), func(i int, line []emulator.Cell) bool {
+		var sb strings.Builder
+		for _, c := range line {
+			sb.WriteString(c.Text())
+		}
+		found = strings.HasPrefix(sb.String(), "last screen before reboot")
+		return !found
+	})
+	state2.screenMu.RLock()
+	paste := state2.parser.BracketedPaste()
+	state2.screenMu.RUnlock()
+	if !found {
+		t.Error("the last screen should be kept in the scrollback of a recreated session")
+	}
+	if paste {
+		t.Error("the new shell shouldn't inherit the old program's modes")
+	}
+	time.Sleep(100 * time.Millisecond)
+}
+
 func TestCWDTracking(t *testing.T) {
 	cfgPath := filepath.Join(os.Getenv("HOME"), ".config", "prompt-grid", "config.json")
 	os.MkdirAll(filepath.Dir(cfgPath), 0755)
@@ -533,6 +681,104 @@ func TestClaudeRecreateUsesContinue(t *testing.T) {
 	}
 }
 
+// TestOSC7UpdatesWorkDir verifies a directory reported by the shell is
+// saved right away, without waiting for the tmux poll.
+func TestOSC7UpdatesWorkDir(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	cfg := &config.Config{}
+	driver := NewTestDriver(NewApp(cfg, cfgPath))
+	name := "test-osc7"
+	if err := driver.CreateSession(name); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	t.Cleanup(func() { driver.CloseSession(name) })
+
+	// Wrapped for tmux to pass through, like the shell integration hooks
+	driver.TypeText(name, `printf '\ePtmux;\e\e]7;file://localhost/var/my%%20dir\a\e\\'`+"\r")
+	saved := func() string {
+		driver.GetScreenText(name) // Parse pending output
+		info, _ := cfg.GetSessionInfo(name)
+		return info.WorkDir
+	}
+	if !waitFor(5*time.Second, func() bool { return saved() == "/var/my dir" }) {
+		t.Fatalf("WorkDir = %q, want /var/my dir; screen:\n%s", saved(), driver.GetScreenText(name))
+	}
+	// Saved shortly after, outside the parser's lock
+	onDisk := func() bool {
+		data, _ := os.ReadFile(cfgPath)
+		return strings.Contains(string(data), "/var/my dir")
+	}
+	if !waitFor(5*time.Second, onDisk) {
+		data, _ := os.ReadFile(cfgPath)
+		t.Errorf("config on disk doesn't have the new directory:\n%s", data)
+	}
+}
+
+func TestSaveConfigSoon(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	app := NewApp(&config.Config{}, cfgPath)
+	for i := 0; i < 3; i++ {
+		app.saveConfigSoon()
+	}
+	if _, err := os.Stat(cfgPath); !os.IsNotExist(err) {
+		t.Fatalf("saved right away: %v", err)
+	}
+	saved := func() bool {
+		_, err := os.Stat(cfgPath)
+		return err == nil
+	}
+	if !waitFor(5*time.Second, saved) {
+		t.Fatal("config never saved")
+	}
+
+	// A save now takes the place of one put off
+	os.Remove(cfgPath)
+	app.saveConfigSoon()
+	app.saveConfig()
+	os.Remove(cfgPath)
+	time.Sleep(configSaveDelay + 200*time.Millisecond)
+	if saved() {
+		t.Error("put-off save ran after saveConfig")
+	}
+}
+
+func TestApplyCWD(t *testing.T) {
+	hostname, _ := os.Hostname()
+	tests := []struct {
+		name       string
+		ssh        bool
+		host, path string
+		want       config.SessionInfo
+		changed    bool
+	}{
+		{"local", false, "", "/src", config.SessionInfo{WorkDir: "/src"}, true},
+		{"local by hostname", false, hostname, "/src", config.SessionInfo{WorkDir: "/src"}, true},
+		{"unchanged", false, "localhost", "/home", config.SessionInfo{WorkDir: "/home"}, false},
+		{"other machine", false, "elsewhere.invalid", "/srv", config.SessionInfo{WorkDir: "/home"}, false},
+		{"ssh", true, "web1", "/var/www", config.SessionInfo{WorkDir: "/home", RemoteHost: "web1", RemoteDir: "/var/www"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := config.SessionInfo{WorkDir: "/home"}
+			changed := applyCWD(&info, tt.ssh, tt.host, tt.path)
+			if info != tt.want || changed != tt.changed {
+				t.Errorf("applyCWD = %v, %+v; want %v, %+v", changed, info, tt.changed, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSHCommand(t *testing.T) {
+	if got := sshCommand(config.SessionInfo{SSHHost: "web1"}); fmt.Sprint(got) != "[ssh web1]" {
+		t.Errorf("without a remote dir: %q", got)
+	}
+	got := sshCommand(config.SessionInfo{SSHHost: "web1", RemoteDir: "/srv/it's"})
+	want := []string{"ssh", "-t", "web1", `cd '/srv/it'\''s'; exec $SHELL -l`}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("sshCommand = %q, want %q", got, want)
+	}
+}
+
 // =============================================================================
 // BDD Tests: Collapse Inactive Sessions
 // Feature file: tests/bdd/features/collapse_inactive.feature
@@ -0,0 +1,113 @@
+package gui
+
+import (
+	"os/exec"
+	"strings"
+
+	"prompt-grid/src/config"
+	"prompt-grid/src/emulator"
+)
+
+// Clipboard is the system clipboard. Programs' OSC 52 requests go through
+// the App's Clipboard; tests replace it with SetClipboard.
+type Clipboard interface {
+	ReadText() (string, error)
+	WriteText(text string) error
+}
+
+// systemClipboard is the macOS pasteboard via pbcopy/pbpaste
+type systemClipboard struct{}
+
+func (systemClipboard) ReadText() (string, error) {
+	out, err := exec.Command("pbpaste").Output()
+	return string(out), err
+}
+
+func (systemClipboard) WriteText(text string) error {
+	cmd := exec.Command("pbcopy")
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// ClipboardAnswer is the user's reply to a clipboard request that the
+// session's policy says to ask about
+type ClipboardAnswer uint8
+
+const (
+	ClipboardDeny        ClipboardAnswer = iota // Drop the request
+	ClipboardAllowOnce                          // Carry out this request
+	ClipboardAllowAlways                        // Carry it out and allow the session from now on
+)
+
+// SetClipboard replaces the clipboard used for OSC 52 requests
+func (a *App) SetClipboard(c Clipboard) {
+	a.clipboard = c
+}
+
+// clipboardPolicy returns a session's OSC 52 policy and size cap
+func (a *App) clipboardPolicy(name string) (string, int) {
+	if a.config == nil {
+		return config.ClipboardAsk, config.DefaultClipboardMaxBytes
+	}
+	return a.config.GetClipboardPolicy(name), a.config.GetClipboardMaxBytes()
+}
+
+// handleClipboard applies a session's policy to an OSC 52 request. Called
+// by the parser with screenMu held, so the clipboard is accessed in the
+// background and requests to ask about are parked for the terminal widget.
+func (a *App) handleClipboard(state *SessionState, req emulator.ClipboardRequest) {
+	policy, maxBytes := a.clipboardPolicy(state.name)
+	if len(req.Text) > maxBytes {
+		return
+	}
+	switch policy {
+	case config.ClipboardAllow:
+		go a.runClipboard(state, req, maxBytes)
+	case config.ClipboardAsk:
+		state.clipboardAsk = &req // A newer request replaces an unanswered one
+		a.invalidateSession(state.name)
+	}
+}
+
+// runClipboard copies the request's text, or answers a query with the
+// clipboard contents (if they fit under maxBytes).
+func (a *App) runClipboard(state *SessionState, req emulator.ClipboardRequest, maxBytes int) {
+	if !req.Query {
+		a.clipboard.WriteText(req.Text)
+		return
+	}
+	text, err := a.clipboard.ReadText()
+	if err != nil || len(text) > maxBytes || state.pty == nil {
+		return
+	}
+	state.pty.Write(emulator.ClipboardReply(req.Selection, text))
+}
+
+// PendingClipboard returns the clipboard request waiting for the user
+func (s *SessionState) PendingClipboard() (emulator.ClipboardRequest, bool) {
+	s.screenMu.RLock()
+	defer s.screenMu.RUnlock()
+	if s.clipboardAsk == nil {
+		return emulator.ClipboardRequest{}, false
+	}
+	return *s.clipboardAsk, true
+}
+
+// AnswerClipboard resolves the pending clipboard request
+func (s *SessionState) AnswerClipboard(answer ClipboardAnswer) {
+	s.screenMu.Lock()
+	req := s.clipboardAsk
+	s.clipboardAsk = nil
+	s.screenMu.Unlock()
+	if req == nil || answer == ClipboardDeny || s.app == nil {
+		return
+	}
+
+	a := s.app
+	if answer == ClipboardAllowAlways && a.config != nil {
+		a.config.SetSessionClipboardPolicy(s.name, config.ClipboardAllow)
+		a.saveConfig()
+	}
+	_, maxBytes := a.clipboardPolicy(s.name)
+	go a.runClipboard(s, *req, maxBytes)
+}
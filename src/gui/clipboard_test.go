@@ -0,0 +1,147 @@
+package gui
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"prompt-grid/src/config"
+	"prompt-grid/src/emulator"
+)
+
+// fakeClipboard records writes and serves reads from memory
+type fakeClipboard struct {
+	mu      sync.Mutex
+	text    string
+	written []string
+}
+
+func (f *fakeClipboard) ReadText() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.text, nil
+}
+
+func (f *fakeClipboard) WriteText(text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.text = text
+	f.written = append(f.written, text)
+	return nil
+}
+
+func (f *fakeClipboard) writes() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.written...)
+}
+
+// waitFor polls cond until it holds or timeout passes
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+// TestOSC52Clipboard drives OSC 52 copies from a shell inside tmux through
+// each clipboard policy.
+func TestOSC52Clipboard(t *testing.T) {
+	cfg := &config.Config{}
+	app := NewApp(cfg, "")
+	driver := NewTestDriver(app)
+	clipboard := &fakeClipboard{}
+	driver.SetClipboard(clipboard)
+
+	name := "test-osc52"
+	if err := driver.CreateSession(name); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	t.Cleanup(func() { driver.CloseSession(name) })
+
+	// copy emits an OSC 52 copy of text (base64 b64) followed by a marker,
+	// quoted so the command line itself doesn't match
+	copy := func(b64, marker string) {
+		t.Helper()
+		driver.TypeText(name, `printf '\033]52;c;`+b64+`\a'; echo `+marker[:1]+"''"+marker[1:]+"\r")
+		if !driver.WaitForContent(name, "\n"+marker, 5*time.Second) {
+			t.Fatalf("marker %q never appeared", marker)
+		}
+	}
+
+	// Ask (the default): nothing is copied until the user answers
+	copy("aGVsbG8=", "ASKED")
+	var req emulator.ClipboardRequest
+	var ok bool
+	// tmux may forward the clipboard after redrawing the output
+	waitFor(2*time.Second, func() bool {
+		req, ok = driver.PendingClipboard(name)
+		return ok
+	})
+	if !ok || req.Text != "hello" || req.Query {
+		t.Fatalf("PendingClipboard = %+v, %v; want a copy of \"hello\"", req, ok)
+	}
+	if got := clipboard.writes(); len(got) != 0 {
+		t.Errorf("copied %q before the user answered", got)
+	}
+	driver.AnswerClipboard(name, ClipboardAllowOnce)
+	if !waitFor(2*time.Second, func() bool { return len(clipboard.writes()) == 1 }) || clipboard.writes()[0] != "hello" {
+		t.Fatalf("after Allow, clipboard writes = %q", clipboard.writes())
+	}
+	if _, ok := driver.PendingClipboard(name); ok {
+		t.Error("request still pending after it was answered")
+	}
+
+	// Deny: requests are dropped without asking
+	cfg.SetSessionClipboardPolicy(name, config.ClipboardDeny)
+	copy("ZGVuaWVk", "DENIED")
+	if _, ok := driver.PendingClipboard(name); ok {
+		t.Error("denied session should not ask")
+	}
+
+	// Always allow switches the session's policy so later copies go straight through
+	cfg.SetSessionClipboardPolicy(name, config.ClipboardAsk)
+	copy("b25l", "ALWAYS")
+	waitFor(2*time.Second, func() bool {
+		_, ok := driver.PendingClipboard(name)
+		return ok
+	})
+	driver.AnswerClipboard(name, ClipboardAllowAlways)
+	if policy := cfg.GetClipboardPolicy(name); policy != config.ClipboardAllow {
+		t.Errorf("policy after Always = %q, want allow", policy)
+	}
+	copy("dHdv", "ALLOWED")
+	if !waitFor(2*time.Second, func() bool { return len(clipboard.writes()) == 3 }) {
+		t.Fatalf("clipboard writes = %q, want hello, one, two", clipboard.writes())
+	}
+	if got := clipboard.writes(); got[1] != "one" || got[2] != "two" {
+		t.Errorf("clipboard writes = %q, want hello, one, two", got)
+	}
+}
+
+// TestOSC52Query verifies clipboard reads are answered to the program
+// only when allowed, and that the size cap applies.
+func TestOSC52Query(t *testing.T) {
+	cfg := &config.Config{Clipboard: config.ClipboardSettings{Policy: config.ClipboardAllow, MaxBytes: 8}}
+	app := &App{config: cfg, clipboard: &fakeClipboard{text: "secret"}}
+	state := &SessionState{app: app, name: "q"}
+
+	app.handleClipboard(state, emulator.ClipboardRequest{Text: "too long for the cap"})
+	app.handleClipboard(state, emulator.ClipboardRequest{Text: "fits"})
+	clipboard := app.clipboard.(*fakeClipboard)
+	if !waitFor(time.Second, func() bool { return len(clipboard.writes()) == 1 }) || clipboard.writes()[0] != "fits" {
+		t.Errorf("clipboard writes = %q, want only \"fits\"", clipboard.writes())
+	}
+
+	cfg.Clipboard.Policy = config.ClipboardAsk
+	app.handleClipboard(state, emulator.ClipboardRequest{Selection: "c", Query: true})
+	if req, ok := state.PendingClipboard(); !ok || !req.Query {
+		t.Errorf("query should wait for the user, got %+v, %v", req, ok)
+	}
+	state.AnswerClipboard(ClipboardDeny)
+	if _, ok := state.PendingClipboard(); ok {
+		t.Error("denied query still pending")
+	}
+}
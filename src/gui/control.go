@@ -31,6 +31,7 @@ import (
 
 	"github.com/darrenoakey/daz-golang-gio/persist"
 
+	"prompt-grid/src/keyencode"
 	"prompt-grid/src/pty"
 	"prompt-grid/src/render"
 	"prompt-grid/src/tmux"
@@ -76,6 +77,10 @@ type ControlWindow struct {
 	hiddenCount       int                        // Number of sessions hidden by collapse mode (for display)
 	hiddenSessionsBtn *hiddenSessionsButton      // Persistent target for "+N inactive" click area
 	sessionsHeader    *sessionsHeaderBtn         // Persistent target for SESSIONS header click (toggle collapse)
+	historyItems      []*historyItem             // Cross-session search results (Enter in search bar)
+	historyInput      string                     // Search input the history results are for
+	historyErr        string                     // Error from the last history search
+	pendingJump       *historyJump               // History hit to scroll to once its session is laid out
 }
 
 // traceButton is a persistent target for the Trace button
@@ -183,7 +188,7 @@ func NewControlWindow(application *App) *ControlWindow {
 
 	// Initialize search editor
 	win.searchEditor.SingleLine = true
-	win.searchEditor.Submit = false
+	win.searchEditor.Submit = true // Enter searches every session's history
 
 	// Register scroll callback to bypass Gio's broken event routing on macOS 26.
 	win.window.SetScrollCallback(func(dx, dy, px, py float32) {
@@ -229,6 +234,10 @@ func NewControlWindow(application *App) *ControlWindow {
 				delta = -1
 			}
 		}
+		if widget := win.termWidgets[win.selected]; widget != nil && widget.reportsMouse(0) {
+			widget.reportWheel(delta, 0)
+			return
+		}
 		state.AdjustScrollOffset(-delta)
 	})
 
@@ -384,10 +393,13 @@ func (w *ControlWindow) layout(gtx layout.Context) {
 			if e, ok := ev.(key.Event); ok && e.State == key.Press {
 				w.searchEditor.SetText("")
 				w.searchQuery = ""
+				w.clearHistorySearch()
 				w.searchFocused = false
 				w.focusTerminal = true
 			}
 		}
+	} else if tw := w.termWidgets[w.selected]; tw != nil && tw.FindOpen() {
+		// The terminal's find bar has focus and handles its own keys
 	} else {
 		w.handleTerminalKeyboard(gtx)
 	}
@@ -620,8 +632,12 @@ func (w *ControlWindow) layoutSearchBar(gtx layout.Context) layout.Dimensions {
 		if !ok {
 			break
 		}
-		if _, ok := ev.(widget.ChangeEvent); ok {
+		switch ev.(type) {
+		case widget.ChangeEvent:
 			w.searchQuery = strings.ToLower(w.searchEditor.Text())
+			w.clearHistorySearch()
+		case widget.SubmitEvent:
+			w.runHistorySearch(w.searchEditor.Text())
 		}
 	}
 
@@ -963,7 +979,7 @@ func (w *ControlWindow) layoutSidebar(gtx layout.Context) layout.Dimensions {
 			}
 
 			// Render session list items
-			if len(sessions) == 0 && w.searchQuery != "" {
+			if len(sessions) == 0 && w.searchQuery != "" && w.historyInput == "" {
 				// Show "No sessions found" message
 				label := material.Label(w.theme, unit.Sp(12), "No sessions found")
 				label.Color = color.NRGBA{R: 136, G: 136, B: 136, A: 255}
@@ -1022,6 +1038,11 @@ func (w *ControlWindow) layoutSidebar(gtx layout.Context) layout.Dimensions {
 				offsetY += hiddenHeight
 			}
 
+			// Cross-session search results, once Enter is pressed in the search bar
+			stack := op.Offset(image.Pt(0, offsetY)).Push(gtx.Ops)
+			offsetY += w.layoutHistoryResults(gtx)
+			stack.Pop()
+
 			scrollStack.Pop()
 			clipStack.Pop()
 
@@ -1304,9 +1325,10 @@ func (w *ControlWindow) layoutTerminal(gtx layout.Context) layout.Dimensions {
 		return layout.Dimensions{Size: gtx.Constraints.Max}
 	}
 
-	// Resize emulator/PTY to fit available space (like TerminalWindow does)
+	// Resize emulator/PTY to fit available space (like TerminalWindow does),
+	// less the time gutter when it's showing
 	padding := 8
-	availW := gtx.Constraints.Max.X - padding*2
+	availW := gtx.Constraints.Max.X - padding*2 - w.app.timeGutterWidth()
 	availH := gtx.Constraints.Max.Y - padding*2
 	termSize := image.Point{X: availW, Y: availH}
 	if termSize != w.lastTermSize || w.selected != w.lastSelected {
@@ -1337,6 +1359,7 @@ func (w *ControlWindow) layoutTerminal(gtx layout.Context) layout.Dimensions {
 	// Control center handles keyboard at window level; widget handles only mouse events
 	widget.skipKeyboard = true
 	widget.requestFocus = false
+	w.applyHistoryJump(state, widget)
 
 	// Layout terminal in the available space
 	stack := op.Offset(image.Pt(padding, padding)).Push(gtx.Ops)
@@ -1383,26 +1406,6 @@ func (w *ControlWindow) layoutStatusBar(gtx layout.Context) layout.Dimensions {
 	return layout.Dimensions{Size: image.Point{X: gtx.Constraints.Max.X, Y: statusBarHeight}}
 }
 
-func shiftChar(ch byte) byte {
-	if ch >= 'A' && ch <= 'Z' {
-		return ch
-	}
-	if ch >= 'a' && ch <= 'z' {
-		return ch - 32
-	}
-	shiftMap := map[byte]byte{
-		'1': '!', '2': '@', '3': '#', '4': '$', '5': '%',
-		'6': '^', '7': '&', '8': '*', '9': '(', '0': ')',
-		'-': '_', '=': '+', '[': '{', ']': '}', '\\': '|',
-		';': ':', '\'': '"', ',': '<', '.': '>', '/': '?',
-		'`': '~',
-	}
-	if shifted, ok := shiftMap[ch]; ok {
-		return shifted
-	}
-	return ch
-}
-
 func (w *ControlWindow) showContextMenu(sessionName string, pos image.Point) {
 	w.contextMenu.visible = true
 	w.contextMenu.sessionName = sessionName
@@ -1726,7 +1729,7 @@ func (w *ControlWindow) handleRenameInput(gtx layout.Context) {
 						ch := e.Name[0]
 						var text string
 						if e.Modifiers.Contain(key.ModShift) {
-							text = string(rune(shiftChar(ch)))
+							text = string(rune(keyencode.ShiftChar(ch)))
 						} else if ch >= 'A' && ch <= 'Z' {
 							text = string(rune(ch + 32))
 						} else {
@@ -1882,7 +1885,7 @@ func (w *ControlWindow) handleNewSessionInput(gtx layout.Context) {
 						ch := e.Name[0]
 						var text string
 						if e.Modifiers.Contain(key.ModShift) {
-							text = string(rune(shiftChar(ch)))
+							text = string(rune(keyencode.ShiftChar(ch)))
 						} else if ch >= 'A' && ch <= 'Z' {
 							text = string(rune(ch + 32)) // lowercase
 						} else {
@@ -1918,11 +1921,12 @@ func (w *ControlWindow) handleTerminalKeyboard(gtx layout.Context) {
 	// Process key events — same filters as standalone TerminalWidget
 	for {
 		ev, ok := gtx.Event(
-			key.Filter{Optional: key.ModShift | key.ModCtrl | key.ModCommand},
+			key.Filter{Optional: key.ModShift | key.ModCtrl | key.ModAlt | key.ModCommand},
 			key.Filter{Name: key.NameTab},
 			key.Filter{Name: "C", Required: key.ModCommand},
 			key.Filter{Name: "V", Required: key.ModCommand},
 			key.Filter{Name: "X", Required: key.ModCommand},
+			key.Filter{Name: "F", Required: key.ModCommand},
 		)
 		if !ok {
 			break
@@ -1962,87 +1966,35 @@ func (w *ControlWindow) handleTerminalKeyboard(gtx layout.Context) {
 						}
 						state.ClearSelection()
 					}
+				} else if e.Modifiers.Contain(key.ModCommand) && e.Name == "F" {
+					// Cmd+F: open the terminal's find bar
+					if tw := w.termWidgets[w.selected]; tw != nil {
+						tw.OpenFind()
+					}
+				} else if e.Modifiers.Contain(key.ModCommand|key.ModShift) && e.Name == "T" {
+					// Cmd+Shift+T: show or hide the time gutter
+					w.app.ToggleTimestamps()
 				} else if e.Modifiers.Contain(key.ModCommand) && e.Name == "V" {
 					// Cmd+V: paste via pbpaste so any MIME type works and clipboard is never altered.
-					ptySess := state.pty
 					s := state
 					go func() {
 						out, err := exec.Command("pbpaste").Output()
-						if err == nil && len(out) > 0 {
-							s.traceEvent(trace.Event{Type: "paste", Text: string(out)})
-							ptySess.Write(out)
+						if err == nil {
+							s.Paste(out)
 						}
 					}()
 				} else {
 					state.ClearSelection()
-					w.forwardKeyToSession(state, e)
+					state.SendKey(e)
 				}
+			} else if e.State == key.Release && !e.Modifiers.Contain(key.ModCommand) {
+				// Reported when the app enabled the kitty keyboard protocol
+				state.SendKey(e)
 			}
 		}
 	}
 }
 
-// forwardKeyToSession converts a Gio key event to bytes and writes to the session PTY
-func (w *ControlWindow) forwardKeyToSession(state *SessionState, e key.Event) {
-	var data []byte
-
-	switch e.Name {
-	case key.NameReturn, key.NameEnter:
-		data = []byte{'\r'}
-	case key.NameDeleteBackward:
-		data = []byte{0x7f}
-	case key.NameTab:
-		data = []byte{'\t'}
-	case key.NameEscape:
-		data = []byte{0x1b}
-	case key.NameUpArrow:
-		data = []byte{0x1b, '[', 'A'}
-	case key.NameDownArrow:
-		data = []byte{0x1b, '[', 'B'}
-	case key.NameRightArrow:
-		data = []byte{0x1b, '[', 'C'}
-	case key.NameLeftArrow:
-		data = []byte{0x1b, '[', 'D'}
-	case key.NameHome:
-		data = []byte{0x1b, '[', 'H'}
-	case key.NameEnd:
-		data = []byte{0x1b, '[', 'F'}
-	case key.NamePageUp:
-		data = []byte{0x1b, '[', '5', '~'}
-	case key.NamePageDown:
-		data = []byte{0x1b, '[', '6', '~'}
-	case key.NameDeleteForward:
-		data = []byte{0x1b, '[', '3', '~'}
-	case key.NameSpace:
-		data = []byte{' '}
-	default:
-		if len(e.Name) == 1 {
-			ch := e.Name[0]
-			if e.Modifiers.Contain(key.ModCtrl) {
-				if ch >= 'A' && ch <= 'Z' {
-					data = []byte{ch - 'A' + 1}
-				} else if ch >= 'a' && ch <= 'z' {
-					data = []byte{ch - 'a' + 1}
-				}
-			} else if e.Modifiers.Contain(key.ModShift) {
-				data = []byte{shiftChar(ch)}
-			} else {
-				if ch >= 'A' && ch <= 'Z' {
-					data = []byte{ch + 32}
-				} else {
-					data = []byte{ch}
-				}
-			}
-		}
-	}
-
-	if len(data) > 0 {
-		state.traceEvent(trace.Event{Type: "key_press", Key: string(e.Name), Mods: e.Modifiers.String()})
-		state.pty.Write(data)
-		state.TouchActivity()
-	}
-}
-
 func (w *ControlWindow) layoutContextMenu(gtx layout.Context) {
 	if !w.contextMenu.visible {
 		return
@@ -2220,6 +2172,15 @@ func (w *ControlWindow) Close() {
 	w.window.Perform(system.ActionClose)
 }
 
+// Focus selects a session and raises the control window
+func (w *ControlWindow) Focus(name string) {
+	w.setSelected(name)
+	w.focusTerminal = true
+	w.lastTermSize = image.Point{} // Force resize for new session
+	w.window.Perform(system.ActionRaise)
+	w.window.Invalidate()
+}
+
 // Invalidate requests a redraw
 func (w *ControlWindow) Invalidate() {
 	w.window.Invalidate()
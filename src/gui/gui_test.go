@@ -2,12 +2,17 @@ package gui
 
 import (
 	"image/color"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"prompt-grid/src/config"
+	"prompt-grid/src/emulator"
+	"prompt-grid/src/pty"
 )
 
 // --- Session State Tests ---
@@ -275,6 +280,38 @@ func TestPasteReadsSystemClipboard(t *testing.T) {
 	}
 }
 
+// TestBracketedPasteMultiLine verifies a multi-line paste into bash (which
+// enables bracketed paste) lands as one edit and runs only on Enter, and
+// that an embedded end marker can't break out of the bracket.
+func TestBracketedPasteMultiLine(t *testing.T) {
+	app := NewApp(nil, "")
+	driver := NewTestDriver(app)
+
+	err := driver.CreateSession("test-bracketed-paste")
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	defer driver.CloseSession("test-bracketed-paste")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !driver.IsBracketedPaste("test-bracketed-paste") {
+		if time.Now().After(deadline) {
+			t.Skip("shell did not enable bracketed paste")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	driver.Paste("test-bracketed-paste", "echo PASTE_ONE\x1b[201~\necho PASTE_TWO")
+	if driver.WaitForPattern("test-bracketed-paste", `(?m)^PASTE_ONE`, time.Second) {
+		t.Fatal("first pasted line ran before Enter")
+	}
+
+	driver.SendEnter("test-bracketed-paste")
+	if !driver.WaitForPattern("test-bracketed-paste", `(?m)^PASTE_TWO`, 3*time.Second) {
+		t.Errorf("pasted commands did not run after Enter:\n%s", driver.GetScreenText("test-bracketed-paste"))
+	}
+}
+
 // --- Screen Content Tests ---
 
 func TestScreenContent(t *testing.T) {
@@ -799,6 +836,66 @@ func TestCellAttributes(t *testing.T) {
 	}
 }
 
+// TestStyledUnderlineThroughTmux verifies tmux passes curly, colored
+// underlines and overline through instead of downgrading them.
+func TestStyledUnderlineThroughTmux(t *testing.T) {
+	driver := NewTestDriver(NewApp(nil, ""))
+	name := "test-underline"
+	if err := driver.CreateSession(name); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	defer driver.CloseSession(name)
+
+	// The octal escape keeps the echoed command from matching the output
+	driver.TypeText(name, "printf '\\033[4:3;58:2::255:0:0;53mSQ\\121\\033[0m\\n'\r")
+	if !driver.WaitForContent(name, "SQQ", 3*time.Second) {
+		t.Fatalf("output not seen:\n%s", driver.GetScreenText(name))
+	}
+	for y, row := range strings.Split(driver.GetScreenText(name), "\n") {
+		x := strings.Index(row, "SQQ")
+		if x < 0 {
+			continue
+		}
+		c := driver.GetCell(name, x, y)
+		d := c.Decoration
+		if c.Attrs&emulator.AttrUnderline == 0 || d.UnderlineStyle() != emulator.UnderlineCurly ||
+			d.UnderlineColor() != emulator.RGBColor(255, 0, 0) || !d.Overline() {
+			t.Errorf("cell = %+v, want a red curly underline and overline", c)
+		}
+		return
+	}
+	t.Error("output row not found")
+}
+
+func TestInlineImageThroughTmux(t *testing.T) {
+	driver := NewTestDriver(NewApp(nil, ""))
+	name := "test-image"
+	if err := driver.CreateSession(name); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	defer driver.CloseSession(name)
+
+	// A red pixel sent with the kitty graphics protocol, wrapped for tmux
+	// to pass through, leaving the cursor in place (C=1). tmux forwards
+	// passthrough at once but redraws the pane later, so the sleep lets
+	// the echoed command land first.
+	driver.TypeText(name, "sleep 0.3; printf '\\033Ptmux;\\033\\033_Ga=T,f=24,s=1,v=1,C=1;/wAA\\033\\033\\\\\\033\\\\\\nIM\\107\\n'\r")
+	if !driver.WaitForContent(name, "\nIMG", 3*time.Second) {
+		t.Fatalf("output not seen:\n%s", driver.GetScreenText(name))
+	}
+	for y, row := range strings.Split(driver.GetScreenText(name), "\n") {
+		if !strings.HasPrefix(row, "IMG") {
+			continue
+		}
+		c := driver.GetCell(name, 0, y-1)
+		if _, _, _, ok := c.ImageTile(); !ok {
+			t.Errorf("cell above the output = %+v, want an image tile", c)
+		}
+		return
+	}
+	t.Error("output row not found")
+}
+
 // --- Color Persistence Tests ---
 
 func TestColorPersistence(t *testing.T) {
@@ -1070,3 +1167,97 @@ func TestWindowSizeConfig(t *testing.T) {
 		t.Error("Should be gone after delete")
 	}
 }
+
+// TestDrainHoldsSynchronizedUpdate verifies the screen only shows a
+// synchronized update once it ends, or once it times out.
+func TestDrainHoldsSynchronizedUpdate(t *testing.T) {
+	sb := emulator.NewScrollback()
+	state := &SessionState{
+		parser:     emulator.NewParser(emulator.NewScreen(10, 2), sb),
+		scrollback: sb,
+	}
+	feed := func(data string) string {
+		state.pendingMu.Lock()
+		state.pendingData = append(state.pendingData, data...)
+		state.pendingMu.Unlock()
+		state.drainPendingData()
+		return strings.TrimRight(state.Screen().Cell(0, 0).Text()+state.Screen().Cell(1, 0).Text(), " ")
+	}
+
+	if got := feed("\x1b[?2026ha"); got != "" {
+		t.Errorf("screen = %q mid-update", got)
+	}
+	if state.SyncDeadline().IsZero() {
+		t.Error("no deadline for the held update")
+	}
+	if got := feed("b\x1b[?2026l"); got != "ab" || !state.SyncDeadline().IsZero() {
+		t.Errorf("screen = %q, deadline %v after the update ended", got, state.SyncDeadline())
+	}
+
+	// An update that never ends shows once it times out, with no new data
+	feed("\x1b[?2026h\rx")
+	time.Sleep(time.Until(state.SyncDeadline()))
+	if got := feed(""); got != "xb" {
+		t.Errorf("screen = %q after the timeout, want %q", got, "xb")
+	}
+}
+
+// TestDrainWritesReplies verifies replies to queries reach the program,
+// in order, once the parse that asked for them is done.
+func TestDrainWritesReplies(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "replies")
+	ready := make(chan struct{})
+	var once sync.Once
+	sess := pty.NewSession("test-replies")
+	sess.SetOnData(func(data []byte) {
+		if strings.Contains(string(data), "ready") {
+			once.Do(func() { close(ready) })
+		}
+	})
+	if err := sess.StartCommand("sh", []string{"-c", "stty raw -echo; echo ready; head -c 10 > " + out}); err != nil {
+		t.Fatalf("StartCommand: %v", err)
+	}
+	defer sess.Close()
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("program never started")
+	}
+
+	sb := emulator.NewScrollback()
+	state := &SessionState{
+		parser:     emulator.NewParser(emulator.NewScreen(10, 2), sb),
+		scrollback: sb,
+		pty:        sess,
+	}
+	state.parser.SetOnResponse(state.queueReply)
+	state.pendingData = []byte("\x1b[6n\x1b[5n")
+	state.drainPendingData()
+
+	want := "\x1b[1;1R\x1b[0n"
+	got := func() string {
+		data, _ := os.ReadFile(out)
+		return string(data)
+	}
+	if !waitFor(5*time.Second, func() bool { return got() == want }) {
+		t.Errorf("program read %q, want %q", got(), want)
+	}
+}
+
+// TestGetSelectedTextJoinsWrappedRows verifies a selection across a
+// soft-wrapped line copies it without the wrap's line break.
+func TestGetSelectedTextJoinsWrappedRows(t *testing.T) {
+	state := &SessionState{
+		parser: emulator.NewParser(emulator.NewScreen(5, 3), emulator.NewScrollback()),
+	}
+	state.parser.Parse([]byte("abcdefg\r\nxyz"))
+
+	state.StartSelection(0, 0)
+	state.UpdateSelection(2, 2)
+	state.EndSelection()
+
+	want := "abcdefg   \nxyz"
+	if got := state.GetSelectedText(); got != want {
+		t.Errorf("GetSelectedText() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,189 @@
+package gui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+
+	"gioui.org/font"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+
+	"prompt-grid/src/emulator"
+)
+
+// historyItem is a persistent click target for one cross-session search hit
+type historyItem struct {
+	hit     emulator.SessionHit
+	hovered bool
+}
+
+// historyJump is a history hit waiting for its session's terminal widget
+// to be laid out (and resized) before scrolling to it.
+type historyJump struct {
+	session string
+	input   string // Raw search input, "/pattern/" for a regex
+	line    int
+}
+
+// runHistorySearch searches every session's persisted scrollback for the
+// sidebar search input (Enter in the search bar).
+func (w *ControlWindow) runHistorySearch(input string) {
+	w.historyItems = nil
+	w.historyErr = ""
+	w.historyInput = input
+	if input == "" {
+		return
+	}
+	pattern, isRegex := ParseSearchQuery(input)
+	hits, err := w.app.SearchHistory(pattern, isRegex)
+	if err != nil {
+		w.historyErr = "bad regex"
+		return
+	}
+	w.historyItems = make([]*historyItem, len(hits))
+	for i, hit := range hits {
+		w.historyItems[i] = &historyItem{hit: hit}
+	}
+	w.tabScrollOffset = 0
+}
+
+// clearHistorySearch drops the history results from the sidebar
+func (w *ControlWindow) clearHistorySearch() {
+	w.historyItems = nil
+	w.historyErr = ""
+	w.historyInput = ""
+}
+
+// openHistoryHit selects the hit's session, reattaching it if it isn't
+// running, and queues a jump to the matching line.
+func (w *ControlWindow) openHistoryHit(hit emulator.SessionHit) {
+	name := hit.Session
+	if state := w.app.GetSession(name); state != nil {
+		name = state.name
+	} else if err := w.app.AddSession(name, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reopen session %s: %v\n", name, err)
+		return
+	}
+	w.setSelected(name)
+	w.pendingJump = &historyJump{session: name, input: w.historyInput, line: hit.Line}
+	w.searchFocused = false
+	w.lastTermSize = image.Point{} // Force resize for new session
+	w.contextMenu.visible = false
+	w.settingsMenu.visible = false
+}
+
+// applyHistoryJump scrolls the selected session to a queued history hit
+// and shows the query in its find bar. Runs after the resize so the match
+// is located in the reflowed scrollback.
+func (w *ControlWindow) applyHistoryJump(state *SessionState, tw *TerminalWidget) {
+	jump := w.pendingJump
+	if jump == nil || jump.session != w.selected {
+		return
+	}
+	w.pendingJump = nil
+	pattern, isRegex := ParseSearchQuery(jump.input)
+	if _, err := state.SearchAt(pattern, isRegex, jump.line); err != nil {
+		return
+	}
+	tw.ShowFind(jump.input)
+}
+
+// layoutHistoryResults draws the cross-session search results below the
+// session list and returns their height.
+func (w *ControlWindow) layoutHistoryResults(gtx layout.Context) int {
+	if w.historyInput == "" {
+		return 0
+	}
+	gtx.Constraints.Min.Y = 0 // Centre labels horizontally only
+	dimColor := color.NRGBA{R: 136, G: 136, B: 136, A: 255}
+	offsetY := 0
+
+	headerText := fmt.Sprintf("HISTORY (%d)", len(w.historyItems))
+	if w.historyErr != "" {
+		headerText = "HISTORY (" + w.historyErr + ")"
+	}
+	header := material.Label(w.theme, unit.Sp(10), headerText)
+	header.Color = dimColor
+	stack := op.Offset(image.Pt(0, offsetY+12)).Push(gtx.Ops)
+	layout.Center.Layout(gtx, header.Layout)
+	stack.Pop()
+	offsetY += 36
+
+	if len(w.historyItems) == 0 && w.historyErr == "" {
+		label := material.Label(w.theme, unit.Sp(12), "No matches")
+		label.Color = dimColor
+		stack := op.Offset(image.Pt(0, offsetY)).Push(gtx.Ops)
+		layout.Center.Layout(gtx, label.Layout)
+		stack.Pop()
+		return offsetY + 28
+	}
+
+	for _, item := range w.historyItems {
+		stack := op.Offset(image.Pt(0, offsetY)).Push(gtx.Ops)
+		offsetY += w.layoutHistoryItem(gtx, item)
+		stack.Pop()
+	}
+	return offsetY
+}
+
+// layoutHistoryItem draws one hit as its session and line over a snippet
+func (w *ControlWindow) layoutHistoryItem(gtx layout.Context, item *historyItem) int {
+	itemHeight := 40
+
+	area := clip.Rect{Max: image.Point{X: sidebarWidth, Y: itemHeight}}.Push(gtx.Ops)
+	event.Op(gtx.Ops, item)
+	for {
+		ev, ok := gtx.Event(
+			pointer.Filter{Target: item, Kinds: pointer.Press | pointer.Enter | pointer.Leave},
+		)
+		if !ok {
+			break
+		}
+		if e, ok := ev.(pointer.Event); ok {
+			switch e.Kind {
+			case pointer.Enter:
+				item.hovered = true
+			case pointer.Leave:
+				item.hovered = false
+			case pointer.Press:
+				w.openHistoryHit(item.hit)
+			}
+		}
+	}
+	area.Pop()
+
+	if item.hovered {
+		rect := clip.Rect{Max: image.Point{X: sidebarWidth, Y: itemHeight}}.Op()
+		paint.FillShape(gtx.Ops, color.NRGBA{R: 45, G: 45, B: 45, A: 255}, rect)
+	}
+
+	labelGtx := gtx
+	labelGtx.Constraints = layout.Constraints{
+		Max: image.Point{X: sidebarWidth - 24, Y: itemHeight / 2},
+	}
+
+	title := material.Label(w.theme, unit.Sp(12), fmt.Sprintf("%s:%d", item.hit.Session, item.hit.Line+1))
+	title.Color = color.NRGBA{R: 224, G: 224, B: 224, A: 255}
+	title.Font.Weight = font.Bold
+	title.MaxLines = 1
+	stack := op.Offset(image.Pt(12, 4)).Push(gtx.Ops)
+	title.Layout(labelGtx)
+	stack.Pop()
+
+	snippet := material.Label(w.theme, unit.Sp(11), item.hit.Snippet)
+	snippet.Color = color.NRGBA{R: 136, G: 136, B: 136, A: 255}
+	snippet.MaxLines = 1
+	stack = op.Offset(image.Pt(12, 4+itemHeight/2)).Push(gtx.Ops)
+	snippet.Layout(labelGtx)
+	stack.Pop()
+
+	return itemHeight
+}
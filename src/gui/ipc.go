@@ -0,0 +1,261 @@
+package gui
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"prompt-grid/src/emulator"
+	"prompt-grid/src/ipc"
+	"prompt-grid/src/render"
+	"prompt-grid/src/tmux"
+)
+
+// ipcHandler carries out IPC commands against the app (see ipc.Handler)
+type ipcHandler struct {
+	app *App
+}
+
+// IPCHandler returns the handler the daemon serves its IPC socket with
+func (a *App) IPCHandler() ipc.Handler {
+	return &ipcHandler{app: a}
+}
+
+// session looks a session up, failing with CodeNotFound
+func (h *ipcHandler) session(name string) (*SessionState, error) {
+	state := h.app.GetSession(name)
+	if state == nil {
+		return nil, ipc.Errorf(ipc.CodeNotFound, "session %q not found", name)
+	}
+	return state, nil
+}
+
+// checkFree fails with CodeExists if a session is already called name,
+// and with CodeBadRequest if no session may be
+func (h *ipcHandler) checkFree(name string) error {
+	if ipc.IsReservedName(name) {
+		return ipc.Errorf(ipc.CodeBadRequest, "%q is a prompt-grid command and can't name a session", name)
+	}
+	if h.app.GetSession(name) != nil {
+		return ipc.Errorf(ipc.CodeExists, "session %q already exists", name)
+	}
+	return nil
+}
+
+func (h *ipcHandler) Open(args ipc.OpenArgs) error {
+	if h.app.GetSession(args.Name) != nil {
+		return h.app.FocusSession(args.Name)
+	}
+	return h.app.AddSession(args.Name, args.SSHHost)
+}
+
+func (h *ipcHandler) List() ([]ipc.SessionInfo, error) {
+	var sessions []ipc.SessionInfo
+	for _, name := range h.app.ListSessions() {
+		state := h.app.GetSession(name)
+		if state == nil {
+			continue // Closed since ListSessions
+		}
+		sessions = append(sessions, h.app.sessionInfo(state))
+	}
+	return sessions, nil
+}
+
+func (h *ipcHandler) New(args ipc.NewArgs) error {
+	if err := h.checkFree(args.Name); err != nil {
+		return err
+	}
+	workDir := args.WorkDir
+	if workDir == "" && args.Type != ipc.TypeSSH {
+		home, _ := os.UserHomeDir()
+		workDir = filepath.Join(home, "src")
+	}
+
+	var err error
+	switch args.Type {
+	case "", ipc.TypeShell:
+		_, err = h.app.NewSession(args.Name, "", workDir)
+	case ipc.TypeSSH:
+		if args.SSHHost == "" {
+			return ipc.Errorf(ipc.CodeBadRequest, "ssh sessions need a host")
+		}
+		_, err = h.app.NewSession(args.Name, args.SSHHost, workDir)
+	case ipc.TypeClaude:
+		err = h.app.AddClaudeSession(args.Name, workDir)
+	case ipc.TypeCodex:
+		err = h.app.AddCodexSession(args.Name, workDir)
+	default:
+		return ipc.Errorf(ipc.CodeBadRequest, "unknown session type %q", args.Type)
+	}
+	if err != nil {
+		return err
+	}
+	if h.app.controlWin != nil {
+		h.app.controlWin.Invalidate()
+	}
+	return nil
+}
+
+func (h *ipcHandler) Close(args ipc.SessionArgs) error {
+	err := h.app.CloseSession(args.Name)
+	if errors.Is(err, ErrSessionNotFound) {
+		return ipc.Errorf(ipc.CodeNotFound, "session %q not found", args.Name)
+	}
+	return err
+}
+
+func (h *ipcHandler) Rename(args ipc.RenameArgs) error {
+	if _, err := h.session(args.Name); err != nil {
+		return err
+	}
+	if err := h.checkFree(args.NewName); err != nil {
+		return err
+	}
+	return h.app.RenameSession(args.Name, args.NewName)
+}
+
+func (h *ipcHandler) Recolor(args ipc.SessionArgs) error {
+	state, err := h.session(args.Name)
+	if err != nil {
+		return err
+	}
+	h.app.RecolorSession(state.name)
+	return nil
+}
+
+func (h *ipcHandler) SendKeys(args ipc.SendKeysArgs) error {
+	state, err := h.session(args.Name)
+	if err != nil {
+		return err
+	}
+	if len(args.Keys) == 0 {
+		return ipc.Errorf(ipc.CodeBadRequest, "no keys to send")
+	}
+	state.TouchActivity()
+	state.ExpectPrompt()
+	return tmux.SendKeys(state.name, args.Keys...)
+}
+
+func (h *ipcHandler) SendText(args ipc.SendTextArgs) error {
+	state, err := h.session(args.Name)
+	if err != nil {
+		return err
+	}
+	data := []byte(args.Text)
+	if args.Enter {
+		data = append(data, '\r')
+	}
+	state.TouchActivity()
+	state.ExpectPrompt()
+	_, err = state.pty.Write(data)
+	return err
+}
+
+func (h *ipcHandler) Capture(args ipc.CaptureArgs) (*ipc.CaptureResult, error) {
+	state, err := h.session(args.Name)
+	if err != nil {
+		return nil, err
+	}
+	state.drainPendingData()
+
+	state.screenMu.RLock()
+	defer state.screenMu.RUnlock()
+	screen := state.Screen()
+	cols, rows := screen.Size()
+	res := &ipc.CaptureResult{Format: args.Format, Cols: cols, Rows: rows}
+
+	// Text captures can include the newest scrollback above the screen,
+	// or the scrollback that arrived in a time range
+	var lines [][]emulator.Cell
+	start, end := 0, state.scrollback.Count()
+	ranged := args.Since != nil || args.Until != nil
+	if args.Since != nil {
+		start = state.scrollback.LineAt(*args.Since)
+	}
+	if args.Until != nil {
+		end = state.scrollback.LineAt(*args.Until)
+	}
+	if n := args.Scrollback; n > 0 {
+		start = max(start, end-n)
+	} else if !ranged {
+		start = end
+	}
+	lines = state.scrollback.Lines(start, end)
+	if args.Until == nil {
+		for y := 0; y < rows; y++ {
+			lines = append(lines, screen.Line(y))
+		}
+	}
+
+	switch args.Format {
+	case ipc.FormatANSI:
+		res.Text = render.LinesANSI(lines)
+	case ipc.FormatPNG:
+		renderer, err := render.NewImageRenderer(cols, rows, 14)
+		if err != nil {
+			return nil, err
+		}
+		render.RenderScreen(renderer, screen, h.app.Colors())
+		var buf bytes.Buffer
+		if err := renderer.WritePNG(&buf); err != nil {
+			return nil, err
+		}
+		res.PNG = buf.Bytes()
+	default:
+		res.Text = render.LinesText(lines)
+	}
+	return res, nil
+}
+
+func (h *ipcHandler) PopOut(args ipc.SessionArgs) error {
+	state, err := h.session(args.Name)
+	if err != nil {
+		return err
+	}
+	h.app.PopOutSession(state.name)
+	return nil
+}
+
+func (h *ipcHandler) Focus(args ipc.SessionArgs) error {
+	if _, err := h.session(args.Name); err != nil {
+		return err
+	}
+	return h.app.FocusSession(args.Name)
+}
+
+// sessionInfo describes a session for IPC listings
+func (a *App) sessionInfo(state *SessionState) ipc.SessionInfo {
+	info := ipc.SessionInfo{
+		Name:         state.name,
+		Type:         ipc.TypeShell,
+		SSHHost:      state.sshHost,
+		LastActivity: state.LastActivity(),
+		PoppedOut:    state.window != nil,
+	}
+	if state.IsSSH() {
+		info.Type = ipc.TypeSSH
+	}
+	if a.config != nil {
+		if saved, ok := a.config.GetSessionInfo(state.name); ok {
+			if saved.Type != "" {
+				info.Type = saved.Type
+			}
+			info.WorkDir = saved.WorkDir
+			if state.IsSSH() {
+				info.WorkDir = saved.RemoteDir
+			}
+		}
+	}
+	switch state.PromptStatus() {
+	case PromptShell:
+		info.Prompt = "shell"
+	case PromptClaude:
+		info.Prompt = "claude"
+	}
+	info.InputPending = state.AwaitingPrompt()
+	state.screenMu.RLock()
+	info.Cols, info.Rows = state.Screen().Size()
+	state.screenMu.RUnlock()
+	return info
+}
@@ -0,0 +1,170 @@
+package gui
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"prompt-grid/src/ipc"
+)
+
+// TestIPCCommands drives a realm-isolated app through the IPC socket
+func TestIPCCommands(t *testing.T) {
+	app := NewApp(nil, "")
+	server, err := ipc.NewServer(app.IPCHandler())
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	go server.Run()
+	defer server.Close()
+	c := ipc.NewClient()
+
+	code := func(err error) string {
+		var ipcErr *ipc.Error
+		if errors.As(err, &ipcErr) {
+			return ipcErr.Code
+		}
+		return ""
+	}
+
+	if err := c.New(ipc.NewArgs{Name: "ipc-a", WorkDir: t.TempDir()}); err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer app.CloseSession("ipc-b")
+	defer app.CloseSession("ipc-a")
+
+	if err := c.New(ipc.NewArgs{Name: "ipc-a"}); code(err) != ipc.CodeExists {
+		t.Errorf("duplicate New error = %v, want %s", err, ipc.CodeExists)
+	}
+	if err := c.New(ipc.NewArgs{Name: "ipc-x", Type: ipc.TypeSSH}); code(err) != ipc.CodeBadRequest {
+		t.Errorf("ssh New without host error = %v, want %s", err, ipc.CodeBadRequest)
+	}
+	if err := c.New(ipc.NewArgs{Name: "ls"}); code(err) != ipc.CodeBadRequest {
+		t.Errorf("New with a reserved name error = %v, want %s", err, ipc.CodeBadRequest)
+	}
+	if err := c.Rename("ipc-a", "send"); code(err) != ipc.CodeBadRequest {
+		t.Errorf("Rename to a reserved name error = %v, want %s", err, ipc.CodeBadRequest)
+	}
+	if _, err := app.NewSession("wait", "", ""); !errors.Is(err, ErrReservedName) {
+		t.Errorf("NewSession with a reserved name error = %v", err)
+	}
+
+	sessions, err := c.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	found := false
+	for _, s := range sessions {
+		if s.Name == "ipc-a" {
+			found = true
+			if s.Type != ipc.TypeShell || s.Cols == 0 || s.Rows == 0 {
+				t.Errorf("List entry = %+v", s)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("List = %+v, missing ipc-a", sessions)
+	}
+
+	// Typed text runs in the shell and shows up in the capture
+	if err := c.SendText("ipc-a", "echo ipc-$((40+2))", true); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	if s := listed(t, c, "ipc-a"); !s.InputPending {
+		t.Errorf("right after SendText, List entry = %+v, want input pending", s)
+	}
+	var text string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		res, err := c.Capture(ipc.CaptureArgs{Name: "ipc-a"})
+		if err != nil {
+			t.Fatalf("Capture: %v", err)
+		}
+		if text = res.Text; strings.Contains(text, "ipc-42") {
+			break
+		}
+	}
+	if !strings.Contains(text, "ipc-42") {
+		t.Fatalf("capture never showed the command output:\n%s", text)
+	}
+	// The prompt after the command answers it
+	var s ipc.SessionInfo
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		app.updateAllPromptStatuses()
+		if s = listed(t, c, "ipc-a"); s.Prompt != "" && !s.InputPending {
+			break
+		}
+	}
+	if s.Prompt == "" || s.InputPending {
+		t.Errorf("after the command, List entry = %+v, want back at a prompt", s)
+	}
+	if err := c.SendKeys("ipc-a", "C-l"); err != nil {
+		t.Errorf("SendKeys: %v", err)
+	}
+
+	png, err := c.Capture(ipc.CaptureArgs{Name: "ipc-a", Format: ipc.FormatPNG})
+	if err != nil || !bytes.HasPrefix(png.PNG, []byte("\x89PNG")) {
+		t.Errorf("PNG capture = %v, %v", png, err)
+	}
+	if _, err := c.Capture(ipc.CaptureArgs{Name: "ipc-a", Format: ipc.FormatANSI}); err != nil {
+		t.Errorf("ANSI capture: %v", err)
+	}
+	withHistory, err := c.Capture(ipc.CaptureArgs{Name: "ipc-a", Scrollback: 1000})
+	if err != nil || strings.Count(withHistory.Text, "\n") < withHistory.Rows {
+		t.Errorf("scrollback capture = %+v, %v", withHistory, err)
+	}
+	// Nothing arrived before the session started, and the screen is left out
+	hourAgo := time.Now().Add(-time.Hour)
+	before, err := c.Capture(ipc.CaptureArgs{Name: "ipc-a", Until: &hourAgo})
+	if err != nil || strings.TrimSpace(before.Text) != "" {
+		t.Errorf("capture until an hour ago = %q, %v", before.Text, err)
+	}
+
+	if err := c.Recolor("ipc-a"); err != nil {
+		t.Errorf("Recolor: %v", err)
+	}
+	if err := c.Rename("ipc-a", "ipc-b"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if app.GetSession("ipc-b") == nil {
+		t.Error("Rename should leave a session called ipc-b")
+	}
+	if err := c.Focus("ipc-b"); err != nil {
+		t.Errorf("Focus: %v", err)
+	}
+
+	for _, err := range []error{
+		c.Close("ipc-missing"),
+		c.Focus("ipc-missing"),
+		c.SendText("ipc-missing", "x", false),
+		c.Rename("ipc-missing", "y"),
+	} {
+		if code(err) != ipc.CodeNotFound {
+			t.Errorf("missing session error = %v, want %s", err, ipc.CodeNotFound)
+		}
+	}
+
+	if err := c.Close("ipc-b"); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if app.GetSession("ipc-b") != nil {
+		t.Error("Close should remove the session")
+	}
+}
+
+// listed returns the List entry of the named session
+func listed(t *testing.T, c *ipc.Client, name string) ipc.SessionInfo {
+	t.Helper()
+	sessions, err := c.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	for _, s := range sessions {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("List = %+v, missing %s", sessions, name)
+	return ipc.SessionInfo{}
+}
@@ -0,0 +1,104 @@
+package gui
+
+import (
+	"unicode/utf8"
+
+	"gioui.org/io/key"
+
+	"prompt-grid/src/keyencode"
+	"prompt-grid/src/trace"
+)
+
+// namedKeys maps Gio's special key names to encoder keys
+var namedKeys = map[key.Name]keyencode.Key{
+	key.NameReturn:         keyencode.KeyEnter,
+	key.NameEnter:          keyencode.KeyKeypadEnter,
+	key.NameDeleteBackward: keyencode.KeyBackspace,
+	key.NameTab:            keyencode.KeyTab,
+	key.NameEscape:         keyencode.KeyEscape,
+	key.NameSpace:          keyencode.KeySpace,
+	key.NameUpArrow:        keyencode.KeyUp,
+	key.NameDownArrow:      keyencode.KeyDown,
+	key.NameRightArrow:     keyencode.KeyRight,
+	key.NameLeftArrow:      keyencode.KeyLeft,
+	key.NameHome:           keyencode.KeyHome,
+	key.NameEnd:            keyencode.KeyEnd,
+	key.NamePageUp:         keyencode.KeyPageUp,
+	key.NamePageDown:       keyencode.KeyPageDown,
+	key.NameDeleteForward:  keyencode.KeyDelete,
+	key.NameF1:             keyencode.KeyF1,
+	key.NameF2:             keyencode.KeyF2,
+	key.NameF3:             keyencode.KeyF3,
+	key.NameF4:             keyencode.KeyF4,
+	key.NameF5:             keyencode.KeyF5,
+	key.NameF6:             keyencode.KeyF6,
+	key.NameF7:             keyencode.KeyF7,
+	key.NameF8:             keyencode.KeyF8,
+	key.NameF9:             keyencode.KeyF9,
+	key.NameF10:            keyencode.KeyF10,
+	key.NameF11:            keyencode.KeyF11,
+	key.NameF12:            keyencode.KeyF12,
+}
+
+// keyEventFor converts a Gio key event to an encoder event.
+// Returns false for keys the terminal doesn't handle (e.g. bare modifiers).
+func keyEventFor(e key.Event) (keyencode.Event, bool) {
+	ev := keyencode.Event{Release: e.State == key.Release}
+	if e.Modifiers.Contain(key.ModShift) {
+		ev.Mods |= keyencode.ModShift
+	}
+	if e.Modifiers.Contain(key.ModAlt) {
+		ev.Mods |= keyencode.ModAlt
+	}
+	if e.Modifiers.Contain(key.ModCtrl) {
+		ev.Mods |= keyencode.ModCtrl
+	}
+
+	if k, ok := namedKeys[e.Name]; ok {
+		ev.Key = k
+		return ev, true
+	}
+	r, size := utf8.DecodeRuneInString(string(e.Name))
+	if r == utf8.RuneError || size != len(e.Name) {
+		return ev, false
+	}
+	ev.Rune = r
+	return ev, true
+}
+
+// KeyModes returns the keyboard modes the application has set.
+func (s *SessionState) KeyModes() keyencode.Modes {
+	s.screenMu.RLock()
+	defer s.screenMu.RUnlock()
+	if s.parser == nil {
+		return keyencode.Modes{}
+	}
+	return keyencode.Modes{
+		AppCursor: s.parser.AppCursorKeys(),
+		AppKeypad: s.parser.AppKeypad(),
+		Kitty:     keyencode.KittyFlags(s.parser.KeyboardFlags()),
+
+		ModifyOtherKeys: s.parser.ModifyOtherKeys(),
+	}
+}
+
+// SendKey encodes a key press or release for the application's current
+// modes and writes it to the PTY. Releases only send anything when the
+// application asked for them through the kitty keyboard protocol.
+func (s *SessionState) SendKey(e key.Event) {
+	ev, ok := keyEventFor(e)
+	if !ok {
+		return
+	}
+	data := keyencode.Encode(ev, s.KeyModes())
+	if len(data) == 0 {
+		return
+	}
+	typ := "key_press"
+	if ev.Release {
+		typ = "key_release"
+	}
+	s.traceEvent(trace.Event{Type: typ, Key: string(e.Name), Mods: e.Modifiers.String()})
+	s.pty.Write(data)
+	s.TouchActivity()
+}
@@ -0,0 +1,108 @@
+package gui
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"prompt-grid/src/emulator"
+	"prompt-grid/src/tmux"
+)
+
+// startCommand launches an opener without waiting for it (replaced in tests)
+var startCommand = func(args []string) error {
+	return exec.Command(args[0], args[1:]...).Start()
+}
+
+// viewRow returns the cells shown on row y of the view, accounting for the
+// scroll offset, or nil above the oldest line. Caller must hold screenMu.
+func (s *SessionState) viewRow(y int) []emulator.Cell {
+	count := s.scrollback.Count()
+	line := count - s.scrollOffset + y
+	switch {
+	case line < 0:
+		return nil
+	case line < count:
+		return s.scrollback.Line(line)
+	}
+	return s.Screen().Line(line - count)
+}
+
+// LinkAt returns the link under view cell (x, y): an OSC 8 hyperlink, a
+// URL, or a file:line location that exists relative to the session's
+// working directory. File locations in SSH sessions are remote and skipped.
+func (s *SessionState) LinkAt(x, y int) (emulator.LinkSpan, bool) {
+	s.screenMu.RLock()
+	link, ok := emulator.LinkAt(s.viewRow(y), x, s.scrollback.Links())
+	s.screenMu.RUnlock()
+	if !ok || link.Kind == emulator.LinkURL {
+		return link, ok
+	}
+	if s.IsSSH() {
+		return emulator.LinkSpan{}, false
+	}
+	if _, err := os.Stat(s.resolveLinkPath(link.Target)); err != nil {
+		return emulator.LinkSpan{}, false
+	}
+	return link, true
+}
+
+// OpenLink opens a URL in the browser, or a file location in the
+// configured editor (the system default app when none is set).
+func (s *SessionState) OpenLink(link emulator.LinkSpan) error {
+	if link.Kind == emulator.LinkURL {
+		return startCommand([]string{"open", link.Target})
+	}
+	editor := ""
+	if s.app != nil && s.app.config != nil {
+		editor = s.app.config.GetEditor()
+	}
+	return startCommand(editorCommand(editor, s.resolveLinkPath(link.Target), link.Line, link.Col))
+}
+
+// resolveLinkPath expands ~ and resolves a relative path against the
+// session's working directory.
+func (s *SessionState) resolveLinkPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, rest)
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(s.workDir(), path)
+}
+
+// workDir returns the session's saved working directory, asking tmux when
+// none is saved.
+func (s *SessionState) workDir() string {
+	if s.app != nil && s.app.config != nil {
+		if info, ok := s.app.config.GetSessionInfo(s.name); ok && info.WorkDir != "" {
+			return info.WorkDir
+		}
+	}
+	dir, _ := tmux.GetPaneCurrentPath(s.name)
+	return dir
+}
+
+// editorCommand builds the command that opens path at line and col.
+// {file}, {line} and {col} in editor are substituted; the path is appended
+// when editor has no {file}. An empty editor opens the file with "open".
+func editorCommand(editor, path string, line, col int) []string {
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		return []string{"open", path}
+	}
+	r := strings.NewReplacer("{file}", path, "{line}", strconv.Itoa(max(line, 1)), "{col}", strconv.Itoa(max(col, 1)))
+	hasFile := false
+	for i, arg := range args {
+		hasFile = hasFile || strings.Contains(arg, "{file}")
+		args[i] = r.Replace(arg)
+	}
+	if !hasFile {
+		args = append(args, path)
+	}
+	return args
+}
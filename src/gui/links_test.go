@@ -0,0 +1,80 @@
+package gui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"prompt-grid/src/config"
+	"prompt-grid/src/emulator"
+)
+
+func TestEditorCommand(t *testing.T) {
+	tests := []struct {
+		editor    string
+		line, col int
+		want      []string
+	}{
+		{"", 12, 0, []string{"open", "/src/a.go"}},
+		{"code -g {file}:{line}:{col}", 12, 3, []string{"code", "-g", "/src/a.go:12:3"}},
+		{"subl {file}:{line}", 0, 0, []string{"subl", "/src/a.go:1"}},
+		{"zed", 7, 0, []string{"zed", "/src/a.go"}},
+		{"idea --line {line} {file}", 7, 0, []string{"idea", "--line", "7", "/src/a.go"}},
+	}
+	for _, tt := range tests {
+		got := editorCommand(tt.editor, "/src/a.go", tt.line, tt.col)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("editorCommand(%q) = %q, want %q", tt.editor, got, tt.want)
+		}
+	}
+}
+
+// TestSessionLinks verifies links are found in the scrolled view, file
+// locations resolve against the session's working directory and Cmd-click
+// opens them with the configured editor.
+func TestSessionLinks(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "pkg"), 0755)
+	os.WriteFile(filepath.Join(dir, "pkg", "a.go"), nil, 0644)
+
+	cfg := &config.Config{UI: config.UISettings{Editor: "code -g {file}:{line}"}}
+	cfg.SetSessionInfo("links", config.SessionInfo{Type: "shell", WorkDir: dir})
+	sb := emulator.NewScrollback()
+	state := &SessionState{
+		app:        &App{config: cfg},
+		name:       "links",
+		parser:     emulator.NewParser(emulator.NewScreen(40, 4), sb),
+		scrollback: sb,
+	}
+	state.parser.Parse([]byte("pkg/a.go:12: boom\r\nmissing.go:3: gone\r\nsee https://example.com\r\n\r\nlast"))
+
+	var opened [][]string
+	orig := startCommand
+	startCommand = func(args []string) error {
+		opened = append(opened, args)
+		return nil
+	}
+	t.Cleanup(func() { startCommand = orig })
+
+	// The file location is scrolled off; scroll back so it's on row 0
+	state.AdjustScrollOffset(1)
+	link, ok := state.LinkAt(5, 0)
+	if !ok || link.Kind != emulator.LinkFile || link.Line != 12 {
+		t.Fatalf("LinkAt(file) = %+v, %v", link, ok)
+	}
+	state.OpenLink(link)
+	if _, ok := state.LinkAt(2, 1); ok {
+		t.Error("a location that doesn't exist should not be a link")
+	}
+	link, ok = state.LinkAt(6, 2)
+	if !ok || link.Kind != emulator.LinkURL {
+		t.Fatalf("LinkAt(url) = %+v, %v", link, ok)
+	}
+	state.OpenLink(link)
+
+	want := [][]string{{"code", "-g", filepath.Join(dir, "pkg", "a.go") + ":12"}, {"open", "https://example.com"}}
+	if fmt.Sprint(opened) != fmt.Sprint(want) {
+		t.Errorf("opened %q, want %q", opened, want)
+	}
+}
@@ -61,6 +61,23 @@ func detectPromptStatus(screen *emulator.Screen) PromptStatus {
 	return PromptNone
 }
 
+// sessionPromptStatus prefers the shell's OSC 133 marks over the screen
+// heuristics: at a marked prompt the session is at the shell, and while a
+// marked command runs only Claude Code's prompt counts. Shells without
+// shell integration fall back to detectPromptStatus.
+func sessionPromptStatus(screen *emulator.Screen, shell emulator.ShellState) PromptStatus {
+	switch shell {
+	case emulator.ShellPrompt:
+		return PromptShell
+	case emulator.ShellRunning:
+		if detectPromptStatus(screen) == PromptClaude {
+			return PromptClaude
+		}
+		return PromptNone
+	}
+	return detectPromptStatus(screen)
+}
+
 // detectShell checks if the cursor line looks like a shell prompt.
 // We examine the text up to the cursor position for common prompt suffixes.
 func detectShell(cursorLine string, cursor emulator.Cursor) bool {
@@ -219,6 +236,89 @@ func detectClaudeMenu(screen *emulator.Screen) bool {
 	return hasClaudeIndicators(screen, firstNumberedLine, rows)
 }
 
+// JumpToPrompt scrolls the view so the previous (dir < 0) or next
+// (dir > 0) prompt marked by shell integration is on the top row. Returns
+// false when there is no marked prompt that way.
+func (s *SessionState) JumpToPrompt(dir int) bool {
+	s.screenMu.RLock()
+	count := s.scrollback.Count()
+	top := count - s.scrollOffset
+	screen := s.Screen()
+	_, rows := screen.Size()
+	var onScreen []int // Absolute lines of the prompts on screen
+	for y := 0; y < rows; y++ {
+		if screen.Cell(0, y).Mark.Has(emulator.MarkPrompt) {
+			onScreen = append(onScreen, count+y)
+		}
+	}
+	s.screenMu.RUnlock()
+
+	// Stream the scrollback rather than hold screenMu over a disk scan
+	target := -1
+	var shift int
+	if dir < 0 {
+		shift = s.scrollback.ScanLines(top, func(i int, line []emulator.Cell) bool {
+			if emulator.LineMark(line).Has(emulator.MarkPrompt) {
+				target = i
+			}
+			return true
+		})
+	} else {
+		shift = s.scrollback.ScanLines(count, func(i int, line []emulator.Cell) bool {
+			if i > top && emulator.LineMark(line).Has(emulator.MarkPrompt) {
+				target = i
+				return false
+			}
+			return true
+		})
+		for _, line := range onScreen {
+			if target < 0 && line > top {
+				target = line
+			}
+		}
+	}
+	if target < 0 || target < shift {
+		return false
+	}
+	target -= shift // Lines dropped by retention during the scan
+
+	// viewLine = count - offset + y, so the prompt lands on row 0 when
+	// offset = count - line (the live view if it's on screen)
+	s.screenMu.Lock()
+	s.SetScrollOffset(s.scrollback.Count() - target)
+	s.scrollMode = s.scrollOffset > 0
+	s.scrollback.SetFrozen(s.scrollMode)
+	s.screenMu.Unlock()
+	return true
+}
+
+// notePrompt counts the prompts the session arrives at from what the
+// prompt detector saw: a prompt on another line than the last one, or any
+// prompt after the session was seen busy, is a new one. Typing at a prompt
+// doesn't count. Called from the prompt detector only.
+func (s *SessionState) notePrompt(status PromptStatus, line int64) {
+	if status == PromptNone {
+		s.promptLine = 0
+		return
+	}
+	if s.promptLine != line+1 {
+		s.promptLine = line + 1
+		s.promptSeq.Add(1)
+	}
+}
+
+// ExpectPrompt notes that input was sent which the session should answer
+// with a new prompt; AwaitingPrompt reports true until it does.
+func (s *SessionState) ExpectPrompt() {
+	s.awaitSeq.Store(s.promptSeq.Load() + 1)
+}
+
+// AwaitingPrompt reports whether input sent since ExpectPrompt is still
+// waiting for a new prompt.
+func (s *SessionState) AwaitingPrompt() bool {
+	return s.promptSeq.Load() < s.awaitSeq.Load()
+}
+
 // PromptStatusValue wraps atomic.Int32 for type-safe PromptStatus access.
 type PromptStatusValue struct {
 	v atomic.Int32
@@ -1,8 +1,11 @@
 package gui
 
 import (
+	"prompt-grid/src/config"
 	"prompt-grid/src/emulator"
+	"strings"
 	"testing"
+	"time"
 )
 
 // writeString writes a string to the screen starting at the given position.
@@ -250,3 +253,144 @@ func TestDetectPromptStatus_ShellGT(t *testing.T) {
 		t.Fatalf("shell GT: got %d, want PromptShell", got)
 	}
 }
+
+func TestSessionPromptStatus(t *testing.T) {
+	shell := emulator.NewScreen(80, 24)
+	writeString(shell, 0, 0, "user@host:~$ ")
+	repl := emulator.NewScreen(80, 24)
+	writeString(repl, 0, 0, ">>> ")
+	claude := emulator.NewScreen(120, 24)
+	writeString(claude, 0, 10, "? Do you want to proceed?")
+
+	tests := []struct {
+		name   string
+		screen *emulator.Screen
+		state  emulator.ShellState
+		want   PromptStatus
+	}{
+		{"unmarked shell", shell, emulator.ShellUnknown, PromptShell},
+		{"unmarked repl", repl, emulator.ShellUnknown, PromptShell},
+		{"marked prompt", repl, emulator.ShellPrompt, PromptShell},
+		{"repl in a marked command", repl, emulator.ShellRunning, PromptNone},
+		{"claude in a marked command", claude, emulator.ShellRunning, PromptClaude},
+		{"marked prompt over old claude output", claude, emulator.ShellPrompt, PromptShell},
+	}
+	for _, tt := range tests {
+		if got := sessionPromptStatus(tt.screen, tt.state); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestJumpToPrompt verifies prompt navigation across the scrollback and screen.
+func TestJumpToPrompt(t *testing.T) {
+	sb := emulator.NewScrollback()
+	state := &SessionState{
+		parser:     emulator.NewParser(emulator.NewScreen(20, 3), sb),
+		scrollback: sb,
+	}
+	// Prompts on lines 0, 3 and 6 (the screen holds lines 5-7)
+	block := "\x1b]133;A\x07$ cmd\r\nout\r\nout"
+	state.parser.Parse([]byte(block + "\r\n" + block + "\r\n" + block))
+	if n := sb.Count(); n != 6 {
+		t.Fatalf("scrollback has %d lines, want 6", n)
+	}
+
+	steps := []struct {
+		dir    int
+		ok     bool
+		offset int
+	}{
+		{-1, true, 3},  // Line 3 at the top
+		{-1, true, 6},  // Line 0
+		{-1, false, 6}, // Nothing older
+		{1, true, 3},
+		{1, true, 0}, // Line 6 is on screen: back to the live view
+	}
+	for i, step := range steps {
+		ok := state.JumpToPrompt(step.dir)
+		if ok != step.ok || state.ScrollOffset() != step.offset {
+			t.Errorf("step %d: JumpToPrompt(%d) = %v, offset %d; want %v, offset %d", i, step.dir, ok, state.ScrollOffset(), step.ok, step.offset)
+		}
+	}
+	if state.InScrollMode() {
+		t.Error("still in scroll mode after returning to the live view")
+	}
+}
+
+func TestAwaitingPrompt(t *testing.T) {
+	// Each step is what the prompt detector saw: status and cursor line
+	steps := []struct {
+		name    string
+		send    bool
+		status  PromptStatus
+		line    int64
+		waiting bool
+	}{
+		{"first prompt", false, PromptShell, 10, false},
+		{"command sent", true, PromptShell, 10, true},
+		{"stale prompt", false, PromptShell, 10, true},
+		{"running", false, PromptNone, 11, true},
+		{"back at a prompt", false, PromptShell, 14, false},
+		{"fast command", true, PromptShell, 14, true},
+		{"finished between looks", false, PromptShell, 16, false},
+		{"Claude on the same row", true, PromptClaude, 16, true},
+		{"Claude busy", false, PromptNone, 16, true},
+		{"Claude answered", false, PromptClaude, 16, false},
+	}
+	state := &SessionState{}
+	for _, step := range steps {
+		if step.send {
+			state.ExpectPrompt()
+		}
+		state.notePrompt(step.status, step.line)
+		if got := state.AwaitingPrompt(); got != step.waiting {
+			t.Errorf("%s: AwaitingPrompt = %v, want %v", step.name, got, step.waiting)
+		}
+	}
+}
+
+// TestShellIntegration runs bash with the injected hooks inside tmux and
+// checks the marks arrive on the right rows with the exit status.
+func TestShellIntegration(t *testing.T) {
+	cfg := &config.Config{UI: config.UISettings{ShellIntegration: true}}
+	driver := NewTestDriver(NewApp(cfg, ""))
+	name := "test-shell-integration"
+	if err := driver.CreateSession(name); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	t.Cleanup(func() { driver.CloseSession(name) })
+
+	// tmux only passes marks through to an attached client, so the first
+	// prompt (drawn before attaching) has none: run a command to get one
+	if !waitFor(5*time.Second, func() bool { return strings.Contains(driver.GetScreenText(name), "#") }) {
+		t.Fatalf("no prompt; screen:\n%s", driver.GetScreenText(name))
+	}
+	driver.TypeText(name, "clear\r")
+	if !waitFor(5*time.Second, func() bool {
+		return driver.ShellState(name) == emulator.ShellPrompt && driver.GetScreenMarks(name)[0].Has(emulator.MarkPrompt)
+	}) {
+		t.Fatalf("no prompt mark; screen:\n%s", driver.GetScreenText(name))
+	}
+
+	// Send the command and Enter separately, so the echoed input doesn't
+	// share a read with the output
+	driver.TypeText(name, "echo out; false")
+	if !waitFor(5*time.Second, func() bool { return strings.Contains(driver.GetScreenText(name), "false") }) {
+		t.Fatalf("input not echoed; screen:\n%s", driver.GetScreenText(name))
+	}
+	driver.TypeText(name, "\r")
+	if !waitFor(5*time.Second, func() bool { return driver.GetScreenMarks(name)[2].Has(emulator.MarkEnd | emulator.MarkInput) }) {
+		t.Fatalf("no end mark and next prompt; screen:\n%s", driver.GetScreenText(name))
+	}
+	marks := driver.GetScreenMarks(name)
+	if !marks[0].Has(emulator.MarkPrompt|emulator.MarkInput) || marks[1] != (emulator.Mark{Flags: emulator.MarkOutput}) {
+		t.Errorf("marks = %+v, want prompt on row 0, output on 1", marks[:3])
+	}
+	if marks[2].ExitCode != 1 || !marks[2].Has(emulator.MarkPrompt) {
+		t.Errorf("end mark = %+v, want exit 1 and the next prompt", marks[2])
+	}
+	if s := driver.ShellState(name); s != emulator.ShellPrompt {
+		t.Errorf("ShellState = %d, want prompt", s)
+	}
+}
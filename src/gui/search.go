@@ -0,0 +1,184 @@
+package gui
+
+import (
+	"sort"
+	"strings"
+
+	"prompt-grid/src/emulator"
+)
+
+// searchState holds the results of a scrollback search (Cmd+F)
+type searchState struct {
+	query   string
+	matches []emulator.Match // Oldest first
+	current int              // Index of the focused match, -1 if none
+}
+
+// ParseSearchQuery splits find-bar input into a pattern and whether it's
+// a regex: "/pattern/" is a regular expression, anything else is literal.
+func ParseSearchQuery(input string) (pattern string, isRegex bool) {
+	if len(input) >= 2 && strings.HasPrefix(input, "/") && strings.HasSuffix(input, "/") {
+		return input[1 : len(input)-1], true
+	}
+	return input, false
+}
+
+// Search finds query in the scrollback and screen, focuses the newest
+// match and scrolls to it. Returns the number of matches.
+func (s *SessionState) Search(query string, isRegex bool) (int, error) {
+	searcher, err := emulator.NewSearcher(query, isRegex)
+	if err != nil {
+		return 0, err
+	}
+
+	// Snapshot the screen; the scrollback streams under its own lock
+	s.screenMu.RLock()
+	count := s.scrollback.Count()
+	screen := s.Screen()
+	_, rows := screen.Size()
+	lines := make([][]emulator.Cell, rows)
+	for y := range lines {
+		lines[y] = screen.Line(y)
+	}
+	s.screenMu.RUnlock()
+
+	matches := searcher.Search(s.scrollback, count, lines)
+
+	s.screenMu.Lock()
+	s.search = &searchState{query: query, matches: matches, current: len(matches) - 1}
+	s.scrollToMatchLocked()
+	s.screenMu.Unlock()
+	return len(matches), nil
+}
+
+// SearchAt runs Search and focuses the match nearest to absolute line,
+// for jumping to a hit found by SearchHistory. Line indices can drift
+// slightly if the scrollback was reflowed since, so the nearest match wins.
+func (s *SessionState) SearchAt(query string, isRegex bool, line int) (int, error) {
+	n, err := s.Search(query, isRegex)
+	if err != nil || n == 0 {
+		return n, err
+	}
+	s.screenMu.Lock()
+	defer s.screenMu.Unlock()
+	m := s.search.matches
+	best := sort.Search(len(m), func(i int) bool { return m[i].Line >= line })
+	if best == len(m) || (best > 0 && line-m[best-1].Line < m[best].Line-line) {
+		best--
+	}
+	s.search.current = best
+	s.scrollToMatchLocked()
+	return n, nil
+}
+
+// SearchNext moves to the next newer match (down), wrapping to the oldest.
+func (s *SessionState) SearchNext() {
+	s.stepSearch(1)
+}
+
+// SearchPrev moves to the next older match (up), wrapping to the newest.
+func (s *SessionState) SearchPrev() {
+	s.stepSearch(-1)
+}
+
+func (s *SessionState) stepSearch(dir int) {
+	s.screenMu.Lock()
+	defer s.screenMu.Unlock()
+	if s.search == nil || len(s.search.matches) == 0 {
+		return
+	}
+	n := len(s.search.matches)
+	s.search.current = (s.search.current + dir + n) % n
+	s.scrollToMatchLocked()
+}
+
+// ClearSearch drops the search results and their highlights.
+func (s *SessionState) ClearSearch() {
+	s.screenMu.Lock()
+	s.search = nil
+	s.screenMu.Unlock()
+}
+
+// SearchQuery returns the active query, or "" when not searching.
+func (s *SessionState) SearchQuery() string {
+	s.screenMu.RLock()
+	defer s.screenMu.RUnlock()
+	if s.search == nil {
+		return ""
+	}
+	return s.search.query
+}
+
+// SearchPosition returns the 1-based index of the focused match and the
+// match count (0, 0 when there are no results).
+func (s *SessionState) SearchPosition() (current, total int) {
+	s.screenMu.RLock()
+	defer s.screenMu.RUnlock()
+	if s.search == nil || len(s.search.matches) == 0 {
+		return 0, 0
+	}
+	return s.search.current + 1, len(s.search.matches)
+}
+
+// searchMatchesOn returns the matches on absolute line, and the index
+// within them of the focused match (-1 if it's elsewhere).
+// Caller must hold screenMu.
+func (s *SessionState) searchMatchesOn(line int) ([]emulator.Match, int) {
+	if s.search == nil {
+		return nil, -1
+	}
+	m := s.search.matches
+	lo := sort.Search(len(m), func(i int) bool { return m[i].Line >= line })
+	hi := lo
+	for hi < len(m) && m[hi].Line == line {
+		hi++
+	}
+	current := -1
+	if c := s.search.current; c >= lo && c < hi {
+		current = c - lo
+	}
+	return m[lo:hi], current
+}
+
+// scrollToMatchLocked scrolls the view so the focused match is visible,
+// centred when it's in the scrollback. Caller must hold screenMu.
+func (s *SessionState) scrollToMatchLocked() {
+	if s.search == nil || s.search.current < 0 {
+		return
+	}
+	line := s.search.matches[s.search.current].Line
+	count := s.scrollback.Count()
+	_, rows := s.Screen().Size()
+
+	// viewLine = count - offset + y, so the match lands on row y when
+	// offset = count - line + y
+	offset := 0
+	if line < count {
+		offset = count - line + rows/2
+	}
+	s.SetScrollOffset(offset)
+	s.scrollMode = s.scrollOffset > 0
+	s.scrollback.SetFrozen(s.scrollMode)
+}
+
+// maxHistoryHits caps the results of a cross-session search
+const maxHistoryHits = 200
+
+// SearchHistory searches the persisted scrollback of every session,
+// attached or not, returning hits ranked best first (see
+// emulator.Searcher.SearchSessions). Attached sessions are flushed first
+// so their newest lines are included.
+func (a *App) SearchHistory(query string, isRegex bool) ([]emulator.SessionHit, error) {
+	searcher, err := emulator.NewSearcher(query, isRegex)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.RLock()
+	for _, state := range a.sessions {
+		if state.scrollback != nil {
+			state.scrollback.Flush()
+		}
+	}
+	a.mu.RUnlock()
+	return searcher.SearchSessions(emulator.SessionsDir(), maxHistoryHits)
+}
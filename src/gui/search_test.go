@@ -0,0 +1,115 @@
+package gui
+
+import (
+	"fmt"
+	"testing"
+
+	"prompt-grid/src/emulator"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		input, pattern string
+		regex          bool
+	}{
+		{"foo", "foo", false},
+		{"/fo+/", "fo+", true},
+		{"/", "/", false},
+		{"/path/to", "/path/to", false},
+		{"//", "", true},
+	}
+	for _, tt := range tests {
+		pattern, regex := ParseSearchQuery(tt.input)
+		if pattern != tt.pattern || regex != tt.regex {
+			t.Errorf("ParseSearchQuery(%q) = (%q, %v), want (%q, %v)", tt.input, pattern, regex, tt.pattern, tt.regex)
+		}
+	}
+}
+
+// TestSessionSearchNavigation verifies search results scroll the view to
+// the focused match and that next/previous wrap around.
+func TestSessionSearchNavigation(t *testing.T) {
+	sb := emulator.NewScrollback()
+	state := &SessionState{
+		parser:     emulator.NewParser(emulator.NewScreen(20, 10), sb),
+		scrollback: sb,
+	}
+	for i := 0; i < 60; i++ {
+		state.parser.Parse([]byte(fmt.Sprintf("row %d\r\n", i)))
+	}
+
+	n, err := state.Search("row 1", false)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if n != 11 { // row 1, row 10..19
+		t.Fatalf("Search found %d matches, want 11", n)
+	}
+
+	// The newest match is focused and centred in the view
+	_, rows := state.Screen().Size()
+	viewLine := func() int { return sb.Count() - state.ScrollOffset() + rows/2 }
+	if cur, total := state.SearchPosition(); cur != 11 || total != 11 {
+		t.Errorf("SearchPosition = %d/%d, want 11/11", cur, total)
+	}
+	if viewLine() != 19 {
+		t.Errorf("centred line = %d, want 19", viewLine())
+	}
+	if !state.InScrollMode() {
+		t.Error("scrolling to a match should enter scroll mode")
+	}
+
+	state.SearchPrev()
+	if viewLine() != 18 {
+		t.Errorf("after SearchPrev centred line = %d, want 18", viewLine())
+	}
+	state.SearchNext()
+	state.SearchNext() // Wraps to the oldest match
+	if cur, _ := state.SearchPosition(); cur != 1 {
+		t.Errorf("after wrapping SearchPosition = %d, want 1", cur)
+	}
+
+	matches, current := state.searchMatchesOn(1)
+	if len(matches) != 1 || current != 0 || matches[0].Start != 0 || matches[0].End != 5 {
+		t.Errorf("searchMatchesOn(1) = %v, %d", matches, current)
+	}
+
+	state.ClearSearch()
+	if state.SearchQuery() != "" {
+		t.Error("ClearSearch should drop the query")
+	}
+	if matches, _ := state.searchMatchesOn(1); matches != nil {
+		t.Error("ClearSearch should drop the highlights")
+	}
+}
+
+// TestSessionSearchAt verifies a history jump focuses the match nearest
+// the requested line, even if the line has drifted.
+func TestSessionSearchAt(t *testing.T) {
+	sb := emulator.NewScrollback()
+	state := &SessionState{
+		parser:     emulator.NewParser(emulator.NewScreen(20, 10), sb),
+		scrollback: sb,
+	}
+	for i := 0; i < 60; i++ {
+		state.parser.Parse([]byte(fmt.Sprintf("row %d\r\n", i)))
+	}
+
+	tests := []struct {
+		line, want int // want is the 1-based match position
+	}{
+		{14, 6},   // Exact: row 14 is the 6th of "row 1", "row 10".."row 19"
+		{0, 1},    // Before the first match
+		{4, 1},    // Nearer row 1 than row 10
+		{6, 2},    // Nearer row 10
+		{100, 11}, // Past the last match
+	}
+	for _, tt := range tests {
+		if _, err := state.SearchAt("row 1", false, tt.line); err != nil {
+			t.Fatalf("SearchAt: %v", err)
+		}
+		if cur, _ := state.SearchPosition(); cur != tt.want {
+			t.Errorf("SearchAt(line %d) focused match %d, want %d", tt.line, cur, tt.want)
+		}
+	}
+}
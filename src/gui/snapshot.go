@@ -0,0 +1,76 @@
+package gui
+
+import (
+	"bytes"
+	"os"
+	"time"
+
+	"prompt-grid/src/emulator"
+	"prompt-grid/src/pty"
+)
+
+// snapshotInterval is how often running sessions' terminal state is saved,
+// bounding what a crash loses; a graceful shutdown saves it on the way out
+const snapshotInterval = 30 * time.Second
+
+// startSnapshotSaver starts a background goroutine that saves each
+// session's terminal state every snapshotInterval, so the next run can
+// restore it (see restoreSnapshot).
+func (a *App) startSnapshotSaver() {
+	go func() {
+		ticker := time.NewTicker(snapshotInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			a.mu.RLock()
+			states := make([]*SessionState, 0, len(a.sessions))
+			for _, state := range a.sessions {
+				states = append(states, state)
+			}
+			a.mu.RUnlock()
+			for _, state := range states {
+				a.saveSnapshot(state)
+			}
+		}
+	}()
+}
+
+// saveSnapshot writes a session's terminal state to its snapshot file,
+// parsing any output still buffered first. Unchanged state isn't
+// rewritten. Caller must not hold a.mu: parsing may call back into the
+// app (an OSC 52 prompt invalidates the window). The state's snapMu keeps
+// the session from being renamed or closed while its file is written.
+func (a *App) saveSnapshot(state *SessionState) {
+	if state.parser == nil {
+		return
+	}
+	state.drainPendingData()
+	state.screenMu.RLock()
+	data, err := state.parser.Snapshot()
+	state.screenMu.RUnlock()
+	if err != nil {
+		return
+	}
+
+	state.snapMu.Lock()
+	defer state.snapMu.Unlock()
+	if state.snapClosed || bytes.Equal(data, state.snapshot) {
+		return
+	}
+	if emulator.WriteSnapshot(emulator.SnapshotPath(state.name), data) == nil {
+		state.snapshot = data
+	}
+}
+
+// restoreSnapshot restores the terminal state a previous run saved for a
+// session and sizes its PTY to match, so tmux draws into a screen of the
+// size it was left at. Returns false if there is no snapshot this build
+// can read.
+func (a *App) restoreSnapshot(parser *emulator.Parser, ptySess *pty.Session, name string) bool {
+	data, err := os.ReadFile(emulator.SnapshotPath(name))
+	if err != nil || parser.Restore(data) != nil {
+		return false
+	}
+	cols, rows := parser.Screen().Size()
+	ptySess.Resize(pty.Size{Cols: uint16(cols), Rows: uint16(rows)})
+	return true
+}
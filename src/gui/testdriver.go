@@ -63,6 +63,15 @@ func (d *TestDriver) SendKeys(sessionName string, keys ...byte) {
 	state.pty.Write(keys)
 }
 
+// Paste sends text to a session as a clipboard paste (bracketed if enabled)
+func (d *TestDriver) Paste(sessionName, text string) {
+	state := d.app.GetSession(sessionName)
+	if state == nil || state.pty == nil {
+		return
+	}
+	state.Paste([]byte(text))
+}
+
 // SendEnter sends Enter key
 func (d *TestDriver) SendEnter(sessionName string) {
 	d.SendKeys(sessionName, '\r')
@@ -165,6 +174,71 @@ func (d *TestDriver) IsInScrollMode(sessionName string) bool {
 	return state.InScrollMode()
 }
 
+// --- Clipboard ---
+
+// SetClipboard replaces the clipboard programs' OSC 52 requests go to
+func (d *TestDriver) SetClipboard(c Clipboard) {
+	d.app.SetClipboard(c)
+}
+
+// PendingClipboard returns the OSC 52 request a session is asking about
+func (d *TestDriver) PendingClipboard(sessionName string) (emulator.ClipboardRequest, bool) {
+	state := d.app.GetSession(sessionName)
+	if state == nil {
+		return emulator.ClipboardRequest{}, false
+	}
+	state.drainPendingData()
+	return state.PendingClipboard()
+}
+
+// AnswerClipboard answers a session's pending OSC 52 request
+func (d *TestDriver) AnswerClipboard(sessionName string, answer ClipboardAnswer) {
+	if state := d.app.GetSession(sessionName); state != nil {
+		state.AnswerClipboard(answer)
+	}
+}
+
+// --- Shell Integration ---
+
+// ShellState returns a session's prompt/command state from its OSC 133 marks
+func (d *TestDriver) ShellState(sessionName string) emulator.ShellState {
+	state := d.app.GetSession(sessionName)
+	if state == nil {
+		return emulator.ShellUnknown
+	}
+	state.drainPendingData()
+	state.screenMu.RLock()
+	defer state.screenMu.RUnlock()
+	return state.parser.ShellState()
+}
+
+// GetScreenMarks returns the shell integration marks of each screen row
+func (d *TestDriver) GetScreenMarks(sessionName string) []emulator.Mark {
+	state := d.app.GetSession(sessionName)
+	if state == nil {
+		return nil
+	}
+	state.drainPendingData()
+	state.screenMu.RLock()
+	defer state.screenMu.RUnlock()
+	_, rows := state.Screen().Size()
+	marks := make([]emulator.Mark, rows)
+	for y := range marks {
+		marks[y] = state.Screen().Cell(0, y).Mark
+	}
+	return marks
+}
+
+// JumpToPrompt scrolls a session to its previous (dir < 0) or next prompt
+func (d *TestDriver) JumpToPrompt(sessionName string, dir int) bool {
+	state := d.app.GetSession(sessionName)
+	if state == nil {
+		return false
+	}
+	state.drainPendingData()
+	return state.JumpToPrompt(dir)
+}
+
 // --- State Queries ---
 
 // GetScreenContent returns the visible screen content as runes
@@ -190,6 +264,16 @@ func (d *TestDriver) GetScreenContent(sessionName string) [][]rune {
 	return content
 }
 
+// IsBracketedPaste returns whether the session's app has enabled bracketed paste
+func (d *TestDriver) IsBracketedPaste(sessionName string) bool {
+	state := d.app.GetSession(sessionName)
+	if state == nil {
+		return false
+	}
+	state.drainPendingData()
+	return state.BracketedPaste()
+}
+
 // GetScreenText returns the visible screen as a string (lines joined by newlines)
 func (d *TestDriver) GetScreenText(sessionName string) string {
 	content := d.GetScreenContent(sessionName)
@@ -0,0 +1,128 @@
+package gui
+
+import (
+	"strings"
+	"time"
+)
+
+// timeGutterCols is the width of the time gutter in cells: the longest
+// label ("Jan 02 15:04") and a space
+const timeGutterCols = 13
+
+// ShowTimestamps returns whether terminals show the time gutter
+func (a *App) ShowTimestamps() bool {
+	return a.config != nil && a.config.GetShowTimestamps()
+}
+
+// ToggleTimestamps shows or hides the time gutter in every terminal and
+// saves the choice. Windows resize their terminals to make room.
+func (a *App) ToggleTimestamps() {
+	if a.config == nil {
+		return
+	}
+	a.config.SetShowTimestamps(!a.config.GetShowTimestamps())
+	a.saveConfig()
+
+	a.mu.RLock()
+	for _, state := range a.sessions {
+		if state.window != nil {
+			state.window.Invalidate()
+		}
+	}
+	a.mu.RUnlock()
+	if a.controlWin != nil {
+		a.controlWin.Invalidate()
+	}
+}
+
+// timeGutterWidth returns the width in pixels the time gutter takes from
+// the terminal, 0 when it's hidden
+func (a *App) timeGutterWidth() int {
+	if !a.ShowTimestamps() {
+		return 0
+	}
+	return timeGutterCols * int(float32(a.FontSize())*0.6)
+}
+
+// gutterLabel is how the time gutter shows a line that arrived at t: the
+// time of day for lines from today, else the date and minute. Lines
+// without a time get no label.
+func gutterLabel(t, now time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	t = t.In(now.Location())
+	if y, m, d := t.Date(); y == now.Year() && m == now.Month() && d == now.Day() {
+		return t.Format("15:04:05")
+	}
+	return t.Format("Jan 02 15:04")
+}
+
+// Layouts ParseTime accepts
+var (
+	clockLayouts = []string{"15:04", "15:04:05"}
+	dateLayouts  = []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05", time.RFC3339}
+)
+
+// ParseTime parses a point in time as typed by a user: a time of day (the
+// latest one not after now), a local date with an optional time, an
+// RFC 3339 timestamp, or a negative duration back from now, such as
+// "03:00", "2026-10-16 03:00" or "-90m".
+func ParseTime(s string, now time.Time) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "-") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return now.Add(d), true
+	}
+	for _, layout := range clockLayouts {
+		if c, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			y, m, d := now.Date()
+			t := time.Date(y, m, d, c.Hour(), c.Minute(), c.Second(), 0, now.Location())
+			if t.After(now) {
+				t = t.AddDate(0, 0, -1)
+			}
+			return t, true
+		}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseJumpTime parses find-bar input asking to jump to a time: "@"
+// followed by anything ParseTime accepts, such as "@03:00" or "@-90m".
+func ParseJumpTime(input string, now time.Time) (time.Time, bool) {
+	s, ok := strings.CutPrefix(input, "@")
+	if !ok {
+		return time.Time{}, false
+	}
+	return ParseTime(s, now)
+}
+
+// JumpToTime scrolls the view so the first line that arrived at or after
+// t is on the top row, returning when that line arrived. Returns false
+// when no line in the scrollback arrived that late.
+func (s *SessionState) JumpToTime(t time.Time) (time.Time, bool) {
+	// The scrollback searches under its own lock, so screenMu isn't held
+	// over disk reads
+	line := s.scrollback.LineAt(t)
+	if line >= s.scrollback.Count() {
+		return time.Time{}, false
+	}
+	at := s.scrollback.Time(line)
+
+	// viewLine = count - offset + y, so the line lands on row 0 when
+	// offset = count - line
+	s.screenMu.Lock()
+	s.SetScrollOffset(s.scrollback.Count() - line)
+	s.scrollMode = s.scrollOffset > 0
+	s.scrollback.SetFrozen(s.scrollMode)
+	s.screenMu.Unlock()
+	return at, true
+}
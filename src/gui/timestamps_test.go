@@ -0,0 +1,114 @@
+package gui
+
+import (
+	"testing"
+	"time"
+
+	"prompt-grid/src/emulator"
+)
+
+func TestParseJumpTime(t *testing.T) {
+	loc := time.FixedZone("test", 2*3600)
+	now := time.Date(2026, 10, 16, 14, 30, 0, 0, loc)
+	tests := []struct {
+		input string
+		want  time.Time
+		ok    bool
+	}{
+		{"@03:00", time.Date(2026, 10, 16, 3, 0, 0, 0, loc), true},
+		{"@ 14:29:59", time.Date(2026, 10, 16, 14, 29, 59, 0, loc), true},
+		{"@22:15", time.Date(2026, 10, 15, 22, 15, 0, 0, loc), true}, // Later than now: yesterday
+		{"@2026-10-01", time.Date(2026, 10, 1, 0, 0, 0, 0, loc), true},
+		{"@2026-10-01 09:05", time.Date(2026, 10, 1, 9, 5, 0, 0, loc), true},
+		{"@2026-10-01T09:05:00Z", time.Date(2026, 10, 1, 9, 5, 0, 0, time.UTC), true},
+		{"@-90m", now.Add(-90 * time.Minute), true},
+		{"03:00", time.Time{}, false}, // A search, not a jump
+		{"@yesterday", time.Time{}, false},
+		{"@-soon", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseJumpTime(tt.input, now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("ParseJumpTime(%q) = %v, %v; want %v, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGutterLabel(t *testing.T) {
+	now := time.Date(2026, 10, 16, 14, 30, 0, 0, time.Local)
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Time{}, ""},
+		{time.Date(2026, 10, 16, 3, 4, 5, 0, time.Local), "03:04:05"},
+		{time.Date(2026, 10, 15, 23, 59, 59, 0, time.Local), "Oct 15 23:59"},
+	}
+	for _, tt := range tests {
+		if got := gutterLabel(tt.t, now); got != tt.want {
+			t.Errorf("gutterLabel(%v) = %q, want %q", tt.t, got, tt.want)
+		}
+		if w := len(gutterLabel(tt.t, now)); w >= timeGutterCols {
+			t.Errorf("label %q doesn't fit the gutter", gutterLabel(tt.t, now))
+		}
+	}
+}
+
+func TestGutterLabels(t *testing.T) {
+	now := time.Now()
+	stamp := gutterLabel(now, now)
+	tests := []struct {
+		name   string
+		pushed int
+		offset int
+		want   []string
+	}{
+		{"live view labels the screen", 0, 0, []string{stamp, "", ""}},
+		{"scrolled past the top", 1, 2, []string{"", stamp, ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := emulator.NewScrollback()
+			for i := 0; i < tt.pushed; i++ {
+				sb.Push([]emulator.Cell{emulator.DefaultCell()})
+			}
+			got := gutterLabels(sb, tt.offset, 3, now)
+			for y := range tt.want {
+				if got[y] != tt.want[y] {
+					t.Errorf("labels = %q, want %q", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+// TestJumpToTime verifies that jumping to a time puts the first line that
+// arrived then on the top row.
+func TestJumpToTime(t *testing.T) {
+	sb := emulator.NewScrollback()
+	state := &SessionState{
+		parser:     emulator.NewParser(emulator.NewScreen(20, 3), sb),
+		scrollback: sb,
+	}
+	state.parser.Parse([]byte("a\r\nb\r\nc\r\nd\r\ne"))
+	time.Sleep(20 * time.Millisecond)
+	mid := time.Now()
+	time.Sleep(20 * time.Millisecond)
+	state.parser.Parse([]byte("\r\nf\r\ng\r\nh"))
+	if n := sb.Count(); n != 5 {
+		t.Fatalf("scrollback has %d lines, want 5", n)
+	}
+
+	at, ok := state.JumpToTime(mid)
+	if !ok || state.ScrollOffset() != 3 || !state.InScrollMode() {
+		t.Fatalf("JumpToTime = %v, offset %d; want line 2 (offset 3) on top", ok, state.ScrollOffset())
+	}
+	if !at.Equal(sb.Time(2)) || at.Before(mid.Truncate(time.Millisecond)) {
+		t.Errorf("JumpToTime landed on a line from %v, before %v", at, mid)
+	}
+
+	if _, ok := state.JumpToTime(time.Now().Add(time.Hour)); ok || state.ScrollOffset() != 3 {
+		t.Errorf("jumping past the newest line = %v, offset %d; want no move", ok, state.ScrollOffset())
+	}
+}
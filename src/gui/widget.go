@@ -1,12 +1,14 @@
 package gui
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"os/exec"
 	"strings"
 	"time"
 
+	"gioui.org/f32"
 	"gioui.org/font"
 	"gioui.org/io/event"
 	"gioui.org/io/key"
@@ -17,6 +19,7 @@ import (
 	"gioui.org/op/paint"
 	"gioui.org/text"
 	"gioui.org/unit"
+	"gioui.org/widget"
 	"gioui.org/widget/material"
 
 	"prompt-grid/src/emulator"
@@ -37,8 +40,59 @@ type TerminalWidget struct {
 	skipKeyboard bool    // When true, parent handles keyboard (used in control center)
 	backToBottom bool    // Stable click target for "Current" button (Gio event routing)
 	scrollAccum  float32 // Accumulated fractional scroll for sub-line trackpad deltas
+
+	// Mouse reporting state (when the application enables mouse tracking)
+	mouseReport bool                 // Current press/drag gesture is reported to the PTY
+	mouseButton emulator.MouseButton // Button that started the reported gesture
+	mouseCell   image.Point          // Last cell under the pointer (motion dedupe, wheel position)
+
+	// Link under the pointer: underlined, opened on Cmd-click
+	hoverLink emulator.LinkSpan
+	hoverRow  int  // View row of hoverLink
+	hovering  bool // hoverLink is valid
+	linkPress bool // Current press opened a link, so it doesn't select
+
+	// Find bar (Cmd+F) over scrollback and screen
+	findOpen   bool
+	findFocus  bool          // One-shot: focus the find editor next frame
+	findEditor widget.Editor // Query input; "/pattern/" searches by regex
+	findLast   string        // Input last searched, so Enter on it steps instead
+	findErr    string        // Error for the last query (bad regex)
+	findInfo   string        // Time of the line the last "@time" input jumped to
+
+	// OSC 52 confirmation bar buttons
+	clipAllow  widget.Clickable
+	clipAlways widget.Clickable
+	clipDeny   widget.Clickable
+
+	// Inline images drawn last frame, so each is uploaded to the GPU once
+	images     map[uint32]*imageOp
+	imageFrame uint64
+}
+
+// imageOp is an inline image ready to paint
+type imageOp struct {
+	image *emulator.Image // Nil if the image is gone
+	op    paint.ImageOp
+	frame uint64 // Last frame the image was drawn in
 }
 
+// searchMark is how a cell is highlighted by the find bar
+type searchMark uint8
+
+const (
+	markNone    searchMark = iota
+	markMatch              // Any search match
+	markCurrent            // The focused match
+)
+
+// Find highlight colors
+var (
+	matchBG        = color.NRGBA{R: 150, G: 120, B: 0, A: 255}
+	currentMatchBG = color.NRGBA{R: 255, G: 150, B: 0, A: 255}
+	matchFG        = color.NRGBA{R: 12, G: 12, B: 12, A: 255}
+)
+
 // NewTerminalWidget creates a new terminal widget
 func NewTerminalWidget(state *SessionState, colors render.SessionColor, fontSize unit.Sp, shaper *text.Shaper) *TerminalWidget {
 	// Cell size based on font - monospace is typically 0.6 width ratio
@@ -48,7 +102,7 @@ func NewTerminalWidget(state *SessionState, colors render.SessionColor, fontSize
 	th := material.NewTheme()
 	th.Shaper = shaper
 
-	return &TerminalWidget{
+	tw := &TerminalWidget{
 		state:    state,
 		fontSize: fontSize,
 		shaper:   shaper,
@@ -57,6 +111,9 @@ func NewTerminalWidget(state *SessionState, colors render.SessionColor, fontSize
 		cellH:    cellH,
 		focused:  true, // Terminal widget is focused by default
 	}
+	tw.findEditor.SingleLine = true
+	tw.findEditor.Submit = true
+	return tw
 }
 
 // Layout renders the terminal widget
@@ -81,6 +138,10 @@ func (w *TerminalWidget) Layout(gtx layout.Context) layout.Dimensions {
 	} else {
 		gtx.Execute(op.InvalidateCmd{})
 	}
+	// Show a held synchronized update when it times out
+	if deadline := w.state.SyncDeadline(); !deadline.IsZero() {
+		gtx.Execute(op.InvalidateCmd{At: deadline})
+	}
 
 	// Handle input first (may modify scrollOffset via AdjustScrollOffset).
 	// Always runs even during bursts so typing stays responsive.
@@ -92,9 +153,10 @@ func (w *TerminalWidget) Layout(gtx layout.Context) layout.Dimensions {
 
 	// Calculate dimensions
 	cols, rows := w.state.Screen().Size()
+	gutter := w.gutterWidth()
 	contentWidth := cols * w.cellW
 	contentHeight := rows * w.cellH
-	width := contentWidth + padding*2
+	width := contentWidth + padding*2 + gutter
 	height := contentHeight + padding*2
 
 	// Draw background for entire area
@@ -102,8 +164,14 @@ func (w *TerminalWidget) Layout(gtx layout.Context) layout.Dimensions {
 	rect := clip.Rect{Max: size}.Op()
 	paint.FillShape(gtx.Ops, w.state.colors.Background, rect)
 
-	// Offset for padding
-	stack := op.Offset(image.Pt(padding, padding)).Push(gtx.Ops)
+	if gutter > 0 {
+		gutterStack := op.Offset(image.Pt(padding, padding)).Push(gtx.Ops)
+		w.renderTimeGutter(gtx)
+		gutterStack.Pop()
+	}
+
+	// Offset for padding and the time gutter
+	stack := op.Offset(image.Pt(padding+gutter, padding)).Push(gtx.Ops)
 
 	// Draw cells
 	w.renderCells(gtx)
@@ -126,12 +194,19 @@ func (w *TerminalWidget) Layout(gtx layout.Context) layout.Dimensions {
 	if scrollMode {
 		w.renderBackToBottom(gtx, width, height)
 	}
+	if w.findOpen {
+		w.layoutFindBar(gtx, width)
+	}
+	if req, ok := w.state.PendingClipboard(); ok {
+		w.layoutClipboardPrompt(gtx, width, req)
+	}
 
 	return layout.Dimensions{Size: size}
 }
 
 func (w *TerminalWidget) handleInput(gtx layout.Context) {
 	padding := 8
+	gutter := w.gutterWidth()
 
 	// Set up clip area for input - this defines the clickable/focusable region
 	clipMax := gtx.Constraints.Max
@@ -139,9 +214,12 @@ func (w *TerminalWidget) handleInput(gtx layout.Context) {
 
 	// Register this widget for pointer and key events
 	event.Op(gtx.Ops, w)
+	if w.hovering {
+		pointer.CursorPointer.Add(gtx.Ops)
+	}
 
 	// Request keyboard focus within the same scope as event.Op registration
-	if w.requestFocus {
+	if w.requestFocus && !w.findOpen {
 		gtx.Execute(key.FocusCmd{Tag: w})
 	}
 
@@ -155,7 +233,7 @@ func (w *TerminalWidget) handleInput(gtx layout.Context) {
 		ev, ok := gtx.Event(
 			pointer.Filter{
 				Target:  w,
-				Kinds:   pointer.Press | pointer.Drag | pointer.Release | pointer.Scroll,
+				Kinds:   pointer.Press | pointer.Drag | pointer.Release | pointer.Move | pointer.Scroll | pointer.Leave,
 				ScrollY: pointer.ScrollRange{Min: -1_000_000, Max: 1_000_000},
 			},
 		)
@@ -164,7 +242,11 @@ func (w *TerminalWidget) handleInput(gtx layout.Context) {
 		}
 		if e, ok := ev.(pointer.Event); ok {
 			switch e.Kind {
+			case pointer.Leave:
+				w.hovering = false
+
 			case pointer.Scroll:
+				w.hovering = false // The text under the pointer moves
 				// Accumulate fractional scroll (trackpad sends sub-line pixel deltas).
 				w.scrollAccum += e.Scroll.Y
 
@@ -175,6 +257,12 @@ func (w *TerminalWidget) handleInput(gtx layout.Context) {
 				}
 				w.scrollAccum -= float32(delta * 3) // Consume only what we used.
 
+				// Mouse-aware applications get the wheel, one report per line.
+				if w.reportsMouse(e.Modifiers) {
+					w.reportWheel(delta, e.Modifiers)
+					break
+				}
+
 				// Acceleration: fast scrolling covers more ground.
 				absDelta := delta
 				if absDelta < 0 {
@@ -193,9 +281,9 @@ func (w *TerminalWidget) handleInput(gtx layout.Context) {
 				// Request another frame so continued scrolling is responsive.
 				gtx.Execute(op.InvalidateCmd{})
 
-			case pointer.Press, pointer.Drag, pointer.Release:
+			case pointer.Press, pointer.Drag, pointer.Release, pointer.Move:
 				// Convert pixel position to cell coordinates
-				cellX := (int(e.Position.X) - padding) / w.cellW
+				cellX := (int(e.Position.X) - padding - gutter) / w.cellW
 				cellY := (int(e.Position.Y) - padding) / w.cellH
 
 				// Clamp to screen bounds
@@ -212,6 +300,8 @@ func (w *TerminalWidget) handleInput(gtx layout.Context) {
 				if cellY >= rows {
 					cellY = rows - 1
 				}
+				moved := w.mouseCell != image.Pt(cellX, cellY)
+				w.mouseCell = image.Pt(cellX, cellY)
 
 				switch e.Kind {
 				case pointer.Press:
@@ -221,10 +311,53 @@ func (w *TerminalWidget) handleInput(gtx layout.Context) {
 						// In control center, the parent handles keyboard focus.
 						gtx.Execute(key.FocusCmd{Tag: w})
 					}
+					// Cmd-click opens the link under the pointer
+					if e.Modifiers.Contain(key.ModCommand) {
+						if link, ok := w.state.LinkAt(cellX, cellY); ok {
+							w.linkPress = true
+							go w.state.OpenLink(link)
+							break
+						}
+					}
+					// Shift-click always selects locally, even when the app wants the mouse
+					w.mouseReport = w.reportsMouse(e.Modifiers)
+					if w.mouseReport {
+						w.mouseButton = mouseButtonFor(e.Buttons)
+						w.state.ClearSelection()
+						w.sendMouse(emulator.MousePress, w.mouseButton, e.Modifiers)
+						break
+					}
 					w.state.StartSelection(cellX, cellY)
+				case pointer.Move:
+					if moved {
+						w.hoverLink, w.hovering = w.state.LinkAt(cellX, cellY)
+						w.hoverRow = cellY
+					}
+					// Any-event tracking reports hover; other modes ignore it
+					if moved && w.reportsMouse(e.Modifiers) {
+						w.sendMouse(emulator.MouseMotion, emulator.MouseButtonNone, e.Modifiers)
+					}
 				case pointer.Drag:
+					if w.linkPress {
+						break
+					}
+					if w.mouseReport {
+						if moved {
+							w.sendMouse(emulator.MouseMotion, w.mouseButton, e.Modifiers)
+						}
+						break
+					}
 					w.state.UpdateSelection(cellX, cellY)
 				case pointer.Release:
+					if w.linkPress {
+						w.linkPress = false
+						break
+					}
+					if w.mouseReport {
+						w.mouseReport = false
+						w.sendMouse(emulator.MouseRelease, w.mouseButton, e.Modifiers)
+						break
+					}
 					w.state.EndSelection()
 					// Only auto-copy if the user dragged (not just clicked).
 					// A single click sets selStart==selEnd — auto-copying that one cell
@@ -269,11 +402,12 @@ func (w *TerminalWidget) handleInput(gtx layout.Context) {
 		// Cmd+C/V/X must be explicitly named — Gio intercepts them as system clipboard shortcuts
 		for {
 			ev, ok := gtx.Event(
-				key.Filter{Optional: key.ModShift | key.ModCtrl | key.ModCommand},
+				key.Filter{Optional: key.ModShift | key.ModCtrl | key.ModAlt | key.ModCommand},
 				key.Filter{Name: key.NameTab},
 				key.Filter{Name: "C", Required: key.ModCommand},
 				key.Filter{Name: "V", Required: key.ModCommand},
 				key.Filter{Name: "X", Required: key.ModCommand},
+				key.Filter{Name: "F", Required: key.ModCommand},
 			)
 			if !ok {
 				break
@@ -314,22 +448,36 @@ func (w *TerminalWidget) handleInput(gtx layout.Context) {
 							}
 							w.state.ClearSelection()
 						}
+					} else if e.Modifiers.Contain(key.ModCommand) && e.Name == "F" {
+						w.OpenFind()
+					} else if e.Modifiers.Contain(key.ModCommand|key.ModShift) && e.Name == "T" {
+						// Cmd+Shift+T: show or hide the time gutter
+						if w.state.app != nil {
+							w.state.app.ToggleTimestamps()
+						}
+					} else if e.Modifiers.Contain(key.ModCommand) && (e.Name == key.NameUpArrow || e.Name == key.NameDownArrow) {
+						// Cmd+Up/Down: jump between prompts marked by shell integration
+						dir := 1
+						if e.Name == key.NameUpArrow {
+							dir = -1
+						}
+						w.state.JumpToPrompt(dir)
 					} else if e.Modifiers.Contain(key.ModCommand) && e.Name == "V" {
 						// Cmd+V: paste via pbpaste so any MIME type works and clipboard is never altered.
-						ptySess := w.state.pty
 						state := w.state
 						go func() {
 							out, err := exec.Command("pbpaste").Output()
-							if err == nil && len(out) > 0 {
-								state.traceEvent(trace.Event{Type: "paste", Text: string(out)})
-								ptySess.Write(out)
-								state.TouchActivity()
+							if err == nil {
+								state.Paste(out)
 							}
 						}()
 					} else {
 						w.state.ClearSelection()
-						w.handleKeyEvent(e)
+						w.state.SendKey(e)
 					}
+				} else if e.State == key.Release && !e.Modifiers.Contain(key.ModCommand) {
+					// Reported when the app enabled the kitty keyboard protocol
+					w.state.SendKey(e)
 				}
 			}
 		}
@@ -337,68 +485,47 @@ func (w *TerminalWidget) handleInput(gtx layout.Context) {
 	}
 }
 
-func (w *TerminalWidget) handleKeyEvent(e key.Event) {
-	var data []byte
-
-	// Handle special keys
-	switch e.Name {
-	case key.NameReturn, key.NameEnter:
-		data = []byte{'\r'}
-	case key.NameDeleteBackward:
-		data = []byte{0x7f}
-	case key.NameTab:
-		data = []byte{'\t'}
-	case key.NameEscape:
-		data = []byte{0x1b}
-	case key.NameUpArrow:
-		data = []byte{0x1b, '[', 'A'}
-	case key.NameDownArrow:
-		data = []byte{0x1b, '[', 'B'}
-	case key.NameRightArrow:
-		data = []byte{0x1b, '[', 'C'}
-	case key.NameLeftArrow:
-		data = []byte{0x1b, '[', 'D'}
-	case key.NameHome:
-		data = []byte{0x1b, '[', 'H'}
-	case key.NameEnd:
-		data = []byte{0x1b, '[', 'F'}
-	case key.NamePageUp:
-		data = []byte{0x1b, '[', '5', '~'}
-	case key.NamePageDown:
-		data = []byte{0x1b, '[', '6', '~'}
-	case key.NameDeleteForward:
-		data = []byte{0x1b, '[', '3', '~'}
-	case key.NameSpace:
-		data = []byte{' '}
-	default:
-		// Handle regular character input
-		if len(e.Name) == 1 {
-			ch := e.Name[0]
-			if e.Modifiers.Contain(key.ModCtrl) {
-				// Ctrl+key: convert to control character
-				if ch >= 'A' && ch <= 'Z' {
-					data = []byte{ch - 'A' + 1}
-				} else if ch >= 'a' && ch <= 'z' {
-					data = []byte{ch - 'a' + 1}
-				}
-			} else if e.Modifiers.Contain(key.ModShift) {
-				// Shift+key: uppercase or shift symbol
-				data = []byte{shiftChar(ch)}
-			} else {
-				// Regular key: lowercase
-				if ch >= 'A' && ch <= 'Z' {
-					data = []byte{ch + 32} // Convert to lowercase
-				} else {
-					data = []byte{ch}
-				}
-			}
-		}
+// reportsMouse returns true when pointer input should be sent to the
+// application rather than drive local selection/scrolling. Holding Shift
+// forces local handling, matching xterm.
+func (w *TerminalWidget) reportsMouse(mods key.Modifiers) bool {
+	return !mods.Contain(key.ModShift) && w.state.MouseReporting()
+}
+
+// sendMouse reports a pointer event at the last known cell.
+func (w *TerminalWidget) sendMouse(kind emulator.MouseEventKind, button emulator.MouseButton, mods key.Modifiers) {
+	w.state.SendMouse(emulator.MouseEvent{
+		Kind:   kind,
+		Button: button,
+		X:      w.mouseCell.X,
+		Y:      w.mouseCell.Y,
+		Shift:  mods.Contain(key.ModShift),
+		Alt:    mods.Contain(key.ModAlt),
+		Ctrl:   mods.Contain(key.ModCtrl),
+	})
+}
+
+// reportWheel sends one wheel report per line of delta (positive = down).
+func (w *TerminalWidget) reportWheel(delta int, mods key.Modifiers) {
+	button := emulator.MouseWheelDown
+	if delta < 0 {
+		button = emulator.MouseWheelUp
+		delta = -delta
+	}
+	for i := 0; i < delta; i++ {
+		w.sendMouse(emulator.MousePress, button, mods)
 	}
+}
 
-	if len(data) > 0 {
-		w.state.traceEvent(trace.Event{Type: "key_press", Key: string(e.Name), Mods: e.Modifiers.String()})
-		w.state.pty.Write(data)
-		w.state.TouchActivity()
+// mouseButtonFor maps Gio's pressed buttons to the xterm button number.
+func mouseButtonFor(buttons pointer.Buttons) emulator.MouseButton {
+	switch {
+	case buttons.Contain(pointer.ButtonSecondary):
+		return emulator.MouseButtonRight
+	case buttons.Contain(pointer.ButtonTertiary):
+		return emulator.MouseButtonMiddle
+	default:
+		return emulator.MouseButtonLeft
 	}
 }
 
@@ -409,6 +536,7 @@ func (w *TerminalWidget) renderCells(gtx layout.Context) {
 	scrollOffset := w.state.ScrollOffset()
 	scrollbackCount := scrollback.Count()
 	hasSelection := w.state.hasSelection
+	w.imageFrame++
 
 	for y := 0; y < rows; y++ {
 		viewLine := scrollbackCount - scrollOffset + y
@@ -416,6 +544,7 @@ func (w *TerminalWidget) renderCells(gtx layout.Context) {
 		if viewLine < 0 {
 			continue // Before any content — entire row is empty
 		}
+		matches, current := w.state.searchMatchesOn(viewLine)
 
 		if viewLine < scrollbackCount {
 			// Scrollback line — fetch once for the whole row
@@ -425,7 +554,7 @@ func (w *TerminalWidget) renderCells(gtx layout.Context) {
 			}
 			for x := 0; x < cols; x++ {
 				if x < len(line) {
-					w.renderCell(gtx, w.theme, x, y, line[x], hasSelection)
+					w.renderCell(gtx, w.theme, x, y, line[x], hasSelection, markAt(x, matches, current), w.linkHovered(x, y))
 				}
 			}
 		} else {
@@ -436,20 +565,114 @@ func (w *TerminalWidget) renderCells(gtx layout.Context) {
 			}
 			for x := 0; x < cols; x++ {
 				cell := screen.Cell(x, screenY)
-				w.renderCell(gtx, w.theme, x, y, cell, hasSelection)
+				w.renderCell(gtx, w.theme, x, y, cell, hasSelection, markAt(x, matches, current), w.linkHovered(x, y))
 			}
 		}
 	}
+
+	// Drop images scrolled out of view
+	for id, img := range w.images {
+		if img.frame != w.imageFrame {
+			delete(w.images, id)
+		}
+	}
+}
+
+// gutterWidth returns the width in pixels of the time gutter left of the
+// cells, 0 when it's hidden
+func (w *TerminalWidget) gutterWidth() int {
+	if w.state.app == nil || !w.state.app.ShowTimestamps() {
+		return 0
+	}
+	return timeGutterCols * w.cellW
 }
 
-func (w *TerminalWidget) renderCell(gtx layout.Context, th *material.Theme, x, y int, cell emulator.Cell, hasSelection bool) {
+// renderTimeGutter labels the rows in view with when they arrived (see
+// gutterLabels).
+func (w *TerminalWidget) renderTimeGutter(gtx layout.Context) {
+	_, rows := w.state.Screen().Size()
+	for y, label := range gutterLabels(w.state.scrollback, w.state.ScrollOffset(), rows, time.Now()) {
+		if label == "" {
+			continue
+		}
+		labelStack := op.Offset(image.Pt(0, y*w.cellH)).Push(gtx.Ops)
+		lbl := material.Label(w.theme, w.fontSize*0.8, label)
+		lbl.Color = color.NRGBA{R: 120, G: 120, B: 120, A: 255}
+		lbl.MaxLines = 1
+		labelGtx := gtx
+		labelGtx.Constraints = layout.Exact(image.Point{X: (timeGutterCols - 1) * w.cellW, Y: w.cellH})
+		lbl.Layout(labelGtx)
+		labelStack.Pop()
+	}
+}
+
+// gutterLabels returns the time gutter's label for each of rows rows in
+// view at scroll offset offset. Scrollback rows are labeled with when they
+// arrived, and screen rows with now, the time they'll be stamped with when
+// they scroll off. A row whose label matches the one above is left blank,
+// so the gutter marks where the time moves on.
+func gutterLabels(sb *emulator.Scrollback, offset, rows int, now time.Time) []string {
+	count := sb.Count()
+	top := count - offset
+	labels := make([]string, rows)
+	last := ""
+	for y := range labels {
+		line := top + y
+		if line < 0 {
+			continue
+		}
+		t := now
+		if line < count {
+			t = sb.Time(line)
+		}
+		if label := gutterLabel(t, now); label != last {
+			labels[y], last = label, label
+		}
+	}
+	return labels
+}
+
+// markAt returns how column x is highlighted given a row's search matches
+// and the index among them of the focused match.
+func markAt(x int, matches []emulator.Match, current int) searchMark {
+	for i, m := range matches {
+		if x >= m.Start && x < m.End {
+			if i == current {
+				return markCurrent
+			}
+			return markMatch
+		}
+	}
+	return markNone
+}
+
+// linkHovered reports whether view cell (x, y) is part of the hovered link
+func (w *TerminalWidget) linkHovered(x, y int) bool {
+	return w.hovering && y == w.hoverRow && x >= w.hoverLink.Start && x < w.hoverLink.End
+}
+
+func (w *TerminalWidget) renderCell(gtx layout.Context, th *material.Theme, x, y int, cell emulator.Cell, hasSelection bool, mark searchMark, linked bool) {
 	// Fast path: completely empty cell with no selection — skip entirely
 	isEmpty := cell.Rune == 0 || cell.Rune == ' '
 	hasCustomBG := cell.BG.Type != emulator.ColorDefault
 	isReverse := cell.Attrs&emulator.AttrReverse != 0
 	isSelected := hasSelection && w.state.IsSelected(x, y)
+	isDecorated := cell.Attrs&emulator.AttrUnderline != 0 || cell.Decoration.Overline()
+
+	if id, col, row, ok := cell.ImageTile(); ok {
+		w.drawImageTile(gtx, x, y, id, col, row)
+		if isSelected || mark != markNone {
+			tint := w.state.colors.Foreground
+			if mark != markNone {
+				tint = matchBG
+			}
+			tint.A = 96
+			paint.FillShape(gtx.Ops, tint, clip.Rect(image.Rect(x*w.cellW, y*w.cellH, (x+1)*w.cellW, (y+1)*w.cellH)).Op())
+		}
+		return
+	}
 
-	if isEmpty && !hasCustomBG && !isReverse && !isSelected {
+	if isEmpty && !hasCustomBG && !isReverse && !isSelected && !isDecorated && mark == markNone {
 		return // Nothing to draw
 	}
 
@@ -469,9 +692,15 @@ func (w *TerminalWidget) renderCell(gtx layout.Context, th *material.Theme, x, y
 	if isSelected {
 		fg, bg = bg, fg
 	}
+	switch mark {
+	case markMatch:
+		fg, bg = matchFG, matchBG
+	case markCurrent:
+		fg, bg = matchFG, currentMatchBG
+	}
 
 	// Draw background if needed
-	if hasCustomBG || isSelected || isReverse {
+	if hasCustomBG || isSelected || isReverse || mark != markNone {
 		rect := clip.Rect{
 			Min: image.Point{X: px, Y: py},
 			Max: image.Point{X: px + w.cellW, Y: py + w.cellH},
@@ -479,19 +708,40 @@ func (w *TerminalWidget) renderCell(gtx layout.Context, th *material.Theme, x, y
 		paint.FillShape(gtx.Ops, bg, rect)
 	}
 
-	if isEmpty {
+	// Continuation cells only paint their background; the glyph belongs
+	// to the wide cell on their left
+	if cell.IsContinuation() || isEmpty && !isDecorated {
 		return
 	}
 
+	// Wide glyphs and their decorations span two cells
+	glyphW := w.cellW
+	if cell.IsWide() {
+		glyphW *= 2
+	}
+
 	// Draw the character using material label
-	w.drawChar(gtx, th, px, py, cell.Rune, fg, cell.Attrs)
+	if !isEmpty {
+		w.drawChar(gtx, th, px, py, glyphW, cell.Text(), fg, cell.Attrs)
+	}
+
+	// Draw underline if needed (hovered links are underlined too)
+	if cell.Attrs&emulator.AttrUnderline != 0 || linked {
+		style, ul := emulator.UnderlineSingle, fg
+		if cell.Attrs&emulator.AttrUnderline != 0 {
+			style, ul = cell.Decoration.UnderlineStyle(), render.UnderlineColor(cell, fg)
+		}
+		cellRect := image.Rect(px, py, px+glyphW, py+w.cellH)
+		for _, r := range render.UnderlineRects(style, cellRect) {
+			paint.FillShape(gtx.Ops, ul, clip.Rect(r).Op())
+		}
+	}
 
-	// Draw underline if needed
-	if cell.Attrs&emulator.AttrUnderline != 0 {
-		underlineY := py + w.cellH - 2
+	// Draw overline if needed
+	if cell.Decoration.Overline() {
 		rect := clip.Rect{
-			Min: image.Point{X: px, Y: underlineY},
-			Max: image.Point{X: px + w.cellW, Y: underlineY + 1},
+			Min: image.Point{X: px, Y: py},
+			Max: image.Point{X: px + glyphW, Y: py + 1},
 		}.Op()
 		paint.FillShape(gtx.Ops, fg, rect)
 	}
@@ -501,19 +751,47 @@ func (w *TerminalWidget) renderCell(gtx layout.Context, th *material.Theme, x, y
 		strikeY := py + w.cellH/2
 		rect := clip.Rect{
 			Min: image.Point{X: px, Y: strikeY},
-			Max: image.Point{X: px + w.cellW, Y: strikeY + 1},
+			Max: image.Point{X: px + glyphW, Y: strikeY + 1},
 		}.Op()
 		paint.FillShape(gtx.Ops, fg, rect)
 	}
 }
 
-func (w *TerminalWidget) drawChar(gtx layout.Context, th *material.Theme, px, py int, r rune, fg color.NRGBA, attrs emulator.AttrFlags) {
+// drawImageTile draws tile (col, row) of inline image id scaled into view
+// cell (x, y)
+func (w *TerminalWidget) drawImageTile(gtx layout.Context, x, y int, id uint32, col, row int) {
+	img, ok := w.images[id]
+	if !ok {
+		img = &imageOp{image: w.state.Screen().Image(id)}
+		if img.image != nil {
+			img.op = paint.NewImageOp(img.image.Pixels)
+		}
+		if w.images == nil {
+			w.images = map[uint32]*imageOp{}
+		}
+		w.images[id] = img
+	}
+	img.frame = w.imageFrame
+	if img.image == nil {
+		return
+	}
+
+	tile := img.image.TileRect(col, row)
+	scale := f32.Pt(float32(w.cellW)/float32(tile.Dx()), float32(w.cellH)/float32(tile.Dy()))
+	defer op.Offset(image.Pt(x*w.cellW, y*w.cellH)).Push(gtx.Ops).Pop()
+	defer clip.Rect{Max: image.Pt(w.cellW, w.cellH)}.Push(gtx.Ops).Pop()
+	defer op.Affine(f32.Affine2D{}.Offset(f32.Pt(-float32(tile.Min.X), -float32(tile.Min.Y))).Scale(f32.Point{}, scale)).Push(gtx.Ops).Pop()
+	img.op.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+}
+
+func (w *TerminalWidget) drawChar(gtx layout.Context, th *material.Theme, px, py, width int, glyph string, fg color.NRGBA, attrs emulator.AttrFlags) {
 	// Position the character
 	stack := op.Offset(image.Pt(px, py)).Push(gtx.Ops)
 	defer stack.Pop()
 
 	// Create label with the character
-	label := material.Label(th, w.fontSize, string(r))
+	label := material.Label(th, w.fontSize, glyph)
 	label.Color = fg
 
 	// Set font weight/style
@@ -526,7 +804,7 @@ func (w *TerminalWidget) drawChar(gtx layout.Context, th *material.Theme, px, py
 
 	// Layout the label within cell bounds
 	cellGtx := gtx
-	cellGtx.Constraints = layout.Exact(image.Point{X: w.cellW, Y: w.cellH})
+	cellGtx.Constraints = layout.Exact(image.Point{X: width, Y: w.cellH})
 	label.Layout(cellGtx)
 }
 
@@ -649,6 +927,159 @@ func (w *TerminalWidget) renderBackToBottom(gtx layout.Context, width, height in
 	btnStack.Pop()
 }
 
+// OpenFind shows the find bar and focuses its input.
+func (w *TerminalWidget) OpenFind() {
+	w.findOpen = true
+	w.findFocus = true
+}
+
+// ShowFind opens the find bar showing input, for results that were
+// already searched (e.g. a history hit); Enter then steps to older matches.
+func (w *TerminalWidget) ShowFind(input string) {
+	w.findEditor.SetText(input)
+	w.findLast = input
+	w.findErr = ""
+	w.findInfo = ""
+	w.OpenFind()
+}
+
+// FindOpen returns true while the find bar is showing (it owns keyboard focus).
+func (w *TerminalWidget) FindOpen() bool {
+	return w.findOpen
+}
+
+// closeFind hides the find bar, drops the highlights and hands keyboard
+// focus back to the terminal.
+func (w *TerminalWidget) closeFind(gtx layout.Context) {
+	w.findOpen = false
+	w.findLast = ""
+	w.findErr = ""
+	w.findInfo = ""
+	w.state.ClearSearch()
+	if !w.skipKeyboard {
+		gtx.Execute(key.FocusCmd{Tag: w})
+	}
+}
+
+// runFind searches for the find bar's input, or steps to the next older
+// match when the input hasn't changed since the last search. Input of "@"
+// and a time jumps to the first line that arrived then instead.
+func (w *TerminalWidget) runFind(input string) {
+	if input == "" {
+		return
+	}
+	if t, ok := ParseJumpTime(input, time.Now()); ok {
+		w.state.ClearSearch()
+		w.findLast, w.findErr, w.findInfo = "", "", ""
+		if at, ok := w.state.JumpToTime(t); ok {
+			w.findInfo = gutterLabel(at, time.Now())
+		} else {
+			w.findErr = "no output since"
+		}
+		w.state.traceEvent(trace.Event{Type: "search", Text: input})
+		return
+	}
+	if input == w.findLast && w.findErr == "" {
+		w.state.SearchPrev()
+		return
+	}
+	w.findLast = input
+	w.findErr = ""
+	w.findInfo = ""
+	pattern, isRegex := ParseSearchQuery(input)
+	if _, err := w.state.Search(pattern, isRegex); err != nil {
+		w.findErr = "bad regex"
+	}
+	w.state.traceEvent(trace.Event{Type: "search", Text: input})
+}
+
+// layoutFindBar draws the find bar in the top-right corner and handles its
+// keys: Enter searches (then steps to older matches), Shift+Enter steps to
+// newer ones, Cmd+G / Cmd+Shift+G do the same, Escape closes.
+func (w *TerminalWidget) layoutFindBar(gtx layout.Context, width int) {
+	for {
+		ev, ok := gtx.Event(
+			key.Filter{Focus: &w.findEditor, Name: key.NameEscape},
+			key.Filter{Focus: &w.findEditor, Name: key.NameReturn, Required: key.ModShift},
+			key.Filter{Focus: &w.findEditor, Name: "G", Required: key.ModCommand, Optional: key.ModShift},
+		)
+		if !ok {
+			break
+		}
+		e, ok := ev.(key.Event)
+		if !ok || e.State != key.Press {
+			continue
+		}
+		switch {
+		case e.Name == key.NameEscape:
+			w.closeFind(gtx)
+			return
+		case e.Modifiers.Contain(key.ModShift):
+			w.state.SearchNext()
+		default:
+			w.runFind(w.findEditor.Text())
+		}
+	}
+	for {
+		ev, ok := w.findEditor.Update(gtx)
+		if !ok {
+			break
+		}
+		if e, ok := ev.(widget.SubmitEvent); ok {
+			w.runFind(e.Text)
+		}
+	}
+
+	barW := 320
+	barH := 30
+	if barW > width-24 {
+		barW = width - 24
+	}
+	barStack := op.Offset(image.Pt(width-barW-12, 12)).Push(gtx.Ops)
+	defer barStack.Pop()
+
+	rr := clip.UniformRRect(image.Rectangle{Max: image.Point{X: barW, Y: barH}}, 4)
+	paint.FillShape(gtx.Ops, color.NRGBA{R: 40, G: 40, B: 40, A: 235}, rr.Op(gtx.Ops))
+
+	if w.findFocus {
+		w.findFocus = false
+		gtx.Execute(key.FocusCmd{Tag: &w.findEditor})
+	}
+
+	// Match counter (or error) on the right
+	status := ""
+	if w.findErr != "" {
+		status = w.findErr
+	} else if w.findInfo != "" {
+		status = w.findInfo
+	} else if w.findLast != "" {
+		if current, total := w.state.SearchPosition(); total > 0 {
+			status = fmt.Sprintf("%d/%d", current, total)
+		} else {
+			status = "no matches"
+		}
+	}
+	statusW := 90
+	labelStack := op.Offset(image.Pt(barW-statusW, 7)).Push(gtx.Ops)
+	label := material.Label(w.theme, unit.Sp(12), status)
+	label.Color = color.NRGBA{R: 160, G: 160, B: 160, A: 255}
+	label.Alignment = text.End
+	labelGtx := gtx
+	labelGtx.Constraints = layout.Exact(image.Point{X: statusW - 8, Y: barH - 8})
+	label.Layout(labelGtx)
+	labelStack.Pop()
+
+	editorStack := op.Offset(image.Pt(8, 6)).Push(gtx.Ops)
+	editor := material.Editor(w.theme, &w.findEditor, "Find (/regex/, @time)")
+	editor.Color = color.NRGBA{R: 224, G: 224, B: 224, A: 255}
+	editor.HintColor = color.NRGBA{R: 136, G: 136, B: 136, A: 255}
+	editor.TextSize = unit.Sp(13)
+	editorGtx := gtx
+	editorGtx.Constraints = layout.Exact(image.Point{X: barW - statusW - 8, Y: barH - 12})
+	editor.Layout(editorGtx)
+	editorStack.Pop()
+}
+
 // Focus sets focus on the widget
 func (w *TerminalWidget) Focus() {
 	w.focused = true
@@ -658,3 +1089,65 @@ func (w *TerminalWidget) Focus() {
 func (w *TerminalWidget) IsFocused() bool {
 	return w.focused
 }
+
+// layoutClipboardPrompt draws the bar asking whether a program may copy to
+// or read the clipboard (OSC 52) across the top of the terminal, below the
+// find bar when it's open.
+func (w *TerminalWidget) layoutClipboardPrompt(gtx layout.Context, width int, req emulator.ClipboardRequest) {
+	switch {
+	case w.clipAllow.Clicked(gtx):
+		w.state.AnswerClipboard(ClipboardAllowOnce)
+		return
+	case w.clipAlways.Clicked(gtx):
+		w.state.AnswerClipboard(ClipboardAllowAlways)
+		return
+	case w.clipDeny.Clicked(gtx):
+		w.state.AnswerClipboard(ClipboardDeny)
+		return
+	}
+
+	message := fmt.Sprintf("A program wants to copy %d bytes to the clipboard", len(req.Text))
+	if req.Query {
+		message = "A program wants to read the clipboard"
+	}
+
+	barW := width - 24
+	barH := 36
+	barY := 12
+	if w.findOpen {
+		barY += 40
+	}
+	barStack := op.Offset(image.Pt(12, barY)).Push(gtx.Ops)
+	defer barStack.Pop()
+
+	rr := clip.UniformRRect(image.Rectangle{Max: image.Point{X: barW, Y: barH}}, 4)
+	paint.FillShape(gtx.Ops, color.NRGBA{R: 40, G: 40, B: 40, A: 235}, rr.Op(gtx.Ops))
+
+	button := func(click *widget.Clickable, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Left: unit.Dp(6)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				btn := material.Button(w.theme, click, label)
+				btn.TextSize = unit.Sp(12)
+				btn.Inset = layout.UniformInset(unit.Dp(5))
+				btn.Background = color.NRGBA{R: 70, G: 70, B: 70, A: 255}
+				return btn.Layout(gtx)
+			})
+		})
+	}
+
+	barGtx := gtx
+	barGtx.Constraints = layout.Exact(image.Point{X: barW, Y: barH})
+	layout.UniformInset(unit.Dp(4)).Layout(barGtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+				label := material.Label(w.theme, unit.Sp(13), message)
+				label.Color = color.NRGBA{R: 224, G: 224, B: 224, A: 255}
+				label.MaxLines = 1
+				return layout.Inset{Left: unit.Dp(4)}.Layout(gtx, label.Layout)
+			}),
+			button(&w.clipAllow, "Allow"),
+			button(&w.clipAlways, "Always"),
+			button(&w.clipDeny, "Deny"),
+		)
+	})
+}
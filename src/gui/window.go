@@ -39,7 +39,7 @@ func NewTerminalWindow(application *App, state *SessionState) *TerminalWindow {
 	cellW := int(float32(application.FontSize()) * 0.6)
 	cellH := int(float32(application.FontSize()) * 1.5)
 	padding := 16 // 8px on each side
-	width := cols*cellW + padding
+	width := cols*cellW + padding + application.timeGutterWidth()
 	height := rows*cellH + padding
 
 	win := &TerminalWindow{
@@ -71,6 +71,10 @@ func NewTerminalWindow(application *App, state *SessionState) *TerminalWindow {
 				delta = -1
 			}
 		}
+		if win.widget.reportsMouse(0) {
+			win.widget.reportWheel(delta, 0)
+			return
+		}
 		state.AdjustScrollOffset(-delta)
 	})
 
@@ -95,9 +99,10 @@ func (w *TerminalWindow) Run() error {
 			w.lastSize = e.Size
 			gtx := app.NewContext(&w.ops, e)
 
-			// Handle resize - calculate new terminal dimensions
+			// Handle resize - calculate new terminal dimensions, leaving
+			// room for the time gutter when it's showing
 			padding := 16
-			newWidth := e.Size.X - padding
+			newWidth := e.Size.X - padding - w.app.timeGutterWidth()
 			newHeight := e.Size.Y - padding
 			if newWidth != lastWidth || newHeight != lastHeight {
 				lastWidth = newWidth
@@ -3,6 +3,7 @@ package ipc
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -18,61 +19,157 @@ func SocketPath() string {
 	return filepath.Join(tmux.GetSocketDir(), "ipc.sock")
 }
 
-// Request represents a request to create a new session
-type Request struct {
-	SessionName string `json:"session_name"`
-	SSHHost     string `json:"ssh_host,omitempty"`
+// ErrNoDaemon is returned by Client calls when no daemon is listening
+var ErrNoDaemon = errors.New("daemon not running")
+
+// readTimeout bounds how long the server waits for a request line, and
+// callTimeout how long a command (e.g. a PNG capture) may take.
+const (
+	readTimeout = 5 * time.Second
+	callTimeout = 30 * time.Second
+)
+
+// Client sends commands to the daemon over its unix socket
+type Client struct {
+	Path string
 }
 
-// Response from the primary instance
-type Response struct {
-	OK    bool   `json:"ok"`
-	Error string `json:"error,omitempty"`
+// NewClient returns a client for the daemon of the current realm
+func NewClient() *Client {
+	return &Client{Path: SocketPath()}
 }
 
-// TryConnect attempts to connect to an existing instance
-// Returns true if connected and request was sent successfully
-func TryConnect(req Request) (bool, error) {
-	conn, err := net.Dial("unix", SocketPath())
+// Running reports whether a daemon is listening on the socket
+func (c *Client) Running() bool {
+	conn, err := net.Dial("unix", c.Path)
 	if err != nil {
-		// No existing instance
-		return false, nil
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Call sends command with args and decodes the command's result into
+// result (nil to discard it). Command failures are returned as *Error.
+func (c *Client) Call(command string, args, result any) error {
+	conn, err := net.Dial("unix", c.Path)
+	if err != nil {
+		return ErrNoDaemon
 	}
 	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(callTimeout))
 
-	// Send request
-	encoder := json.NewEncoder(conn)
-	if err := encoder.Encode(req); err != nil {
-		return false, fmt.Errorf("failed to send request: %w", err)
+	req := Request{Version: ProtocolVersion, Command: command}
+	if args != nil {
+		if req.Args, err = json.Marshal(args); err != nil {
+			return fmt.Errorf("failed to encode args: %w", err)
+		}
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
 	}
 
-	// Read response
-	reader := bufio.NewReader(conn)
-	line, err := reader.ReadBytes('\n')
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
 	if err != nil {
-		return false, fmt.Errorf("failed to read response: %w", err)
+		return fmt.Errorf("failed to read response: %w", err)
 	}
-
 	var resp Response
 	if err := json.Unmarshal(line, &resp); err != nil {
-		return false, fmt.Errorf("failed to parse response: %w", err)
+		return fmt.Errorf("failed to parse response: %w", err)
 	}
-
 	if !resp.OK {
-		return true, fmt.Errorf("server error: %s", resp.Error)
+		return &Error{Code: resp.Code, Message: resp.Error}
 	}
+	if result != nil && len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("failed to parse result: %w", err)
+		}
+	}
+	return nil
+}
+
+// Open opens a session, creating it if it doesn't exist
+func (c *Client) Open(args OpenArgs) error {
+	return c.Call(CmdOpen, args, nil)
+}
 
+// List returns all sessions
+func (c *Client) List() ([]SessionInfo, error) {
+	var res ListResult
+	err := c.Call(CmdList, nil, &res)
+	return res.Sessions, err
+}
+
+// New creates a session
+func (c *Client) New(args NewArgs) error {
+	return c.Call(CmdNew, args, nil)
+}
+
+// Close closes a session
+func (c *Client) Close(name string) error {
+	return c.Call(CmdClose, SessionArgs{Name: name}, nil)
+}
+
+// Rename renames a session
+func (c *Client) Rename(name, newName string) error {
+	return c.Call(CmdRename, RenameArgs{Name: name, NewName: newName}, nil)
+}
+
+// Recolor assigns a session a new random color
+func (c *Client) Recolor(name string) error {
+	return c.Call(CmdRecolor, SessionArgs{Name: name}, nil)
+}
+
+// SendKeys sends tmux key names to a session
+func (c *Client) SendKeys(name string, keys ...string) error {
+	return c.Call(CmdSendKeys, SendKeysArgs{Name: name, Keys: keys}, nil)
+}
+
+// SendText types text into a session, pressing Enter after if enter is set
+func (c *Client) SendText(name, text string, enter bool) error {
+	return c.Call(CmdSendText, SendTextArgs{Name: name, Text: text, Enter: enter}, nil)
+}
+
+// Capture captures a session's screen
+func (c *Client) Capture(args CaptureArgs) (*CaptureResult, error) {
+	var res CaptureResult
+	if err := c.Call(CmdCapture, args, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// PopOut opens a session in a standalone window
+func (c *Client) PopOut(name string) error {
+	return c.Call(CmdPopOut, SessionArgs{Name: name}, nil)
+}
+
+// Focus selects a session and brings its window to the front
+func (c *Client) Focus(name string) error {
+	return c.Call(CmdFocus, SessionArgs{Name: name}, nil)
+}
+
+// TryConnect asks an existing instance to open a session.
+// Returns true if a daemon was reached (err reports a failed open).
+func TryConnect(args OpenArgs) (bool, error) {
+	err := NewClient().Open(args)
+	if errors.Is(err, ErrNoDaemon) {
+		return false, nil
+	}
+	if err != nil {
+		return true, fmt.Errorf("server error: %w", err)
+	}
 	return true, nil
 }
 
-// Server listens for incoming session requests
+// Server listens for incoming commands
 type Server struct {
-	listener  net.Listener
-	onRequest func(Request) error
+	listener net.Listener
+	handler  Handler
 }
 
 // NewServer creates a new IPC server
-func NewServer(onRequest func(Request) error) (*Server, error) {
+func NewServer(handler Handler) (*Server, error) {
 	// Ensure socket directory exists
 	sockPath := SocketPath()
 	if err := os.MkdirAll(filepath.Dir(sockPath), 0755); err != nil {
@@ -88,8 +185,8 @@ func NewServer(onRequest func(Request) error) (*Server, error) {
 	}
 
 	return &Server{
-		listener:  listener,
-		onRequest: onRequest,
+		listener: listener,
+		handler:  handler,
 	}, nil
 }
 
@@ -109,7 +206,7 @@ func (s *Server) handleConnection(conn net.Conn) {
 	defer conn.Close()
 
 	// Prevent hung connections from leaking goroutines
-	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.SetDeadline(time.Now().Add(readTimeout))
 
 	// Read request
 	reader := bufio.NewReader(conn)
@@ -120,17 +217,127 @@ func (s *Server) handleConnection(conn net.Conn) {
 
 	var req Request
 	if err := json.Unmarshal(line, &req); err != nil {
-		s.sendResponse(conn, Response{OK: false, Error: "invalid request"})
+		s.sendResponse(conn, Response{OK: false, Error: "invalid request", Code: CodeBadRequest})
 		return
 	}
 
 	// Process request
-	if err := s.onRequest(req); err != nil {
-		s.sendResponse(conn, Response{OK: false, Error: err.Error()})
+	conn.SetDeadline(time.Now().Add(callTimeout))
+	result, err := s.dispatch(req)
+	if err != nil {
+		code := CodeFailed
+		var ipcErr *Error
+		if errors.As(err, &ipcErr) {
+			code = ipcErr.Code
+		}
+		s.sendResponse(conn, Response{Version: ProtocolVersion, OK: false, Error: err.Error(), Code: code})
 		return
 	}
 
-	s.sendResponse(conn, Response{OK: true})
+	resp := Response{Version: ProtocolVersion, OK: true}
+	if result != nil {
+		resp.Result, _ = json.Marshal(result)
+	}
+	s.sendResponse(conn, resp)
+}
+
+// dispatch decodes a request's args and runs its command
+func (s *Server) dispatch(req Request) (any, error) {
+	h := s.handler
+	if req.Version == 0 && req.Command == "" {
+		// Legacy clients only send a session to open
+		if req.SessionName == "" {
+			return nil, Errorf(CodeBadRequest, "session name is required")
+		}
+		return nil, h.Open(OpenArgs{Name: req.SessionName, SSHHost: req.SSHHost})
+	}
+	if req.Version > ProtocolVersion {
+		return nil, Errorf(CodeUnsupportedVersion, "protocol version %d not supported (max %d)", req.Version, ProtocolVersion)
+	}
+
+	switch req.Command {
+	case CmdOpen:
+		return run(req.Args, h.Open, func(a OpenArgs) string { return a.Name })
+	case CmdList:
+		sessions, err := h.List()
+		if err != nil {
+			return nil, err
+		}
+		return ListResult{Sessions: sessions}, nil
+	case CmdNew:
+		return run(req.Args, h.New, func(a NewArgs) string { return a.Name })
+	case CmdClose:
+		return run(req.Args, h.Close, sessionName)
+	case CmdRename:
+		return run(req.Args, func(a RenameArgs) error {
+			if a.NewName == "" {
+				return Errorf(CodeBadRequest, "new name is required")
+			}
+			return h.Rename(a)
+		}, func(a RenameArgs) string { return a.Name })
+	case CmdRecolor:
+		return run(req.Args, h.Recolor, sessionName)
+	case CmdSendKeys:
+		return run(req.Args, h.SendKeys, func(a SendKeysArgs) string { return a.Name })
+	case CmdSendText:
+		return run(req.Args, h.SendText, func(a SendTextArgs) string { return a.Name })
+	case CmdCapture:
+		args, err := decode(req.Args, func(a CaptureArgs) string { return a.Name })
+		if err != nil {
+			return nil, err
+		}
+		switch args.Format {
+		case "":
+			args.Format = FormatText
+		case FormatText, FormatANSI, FormatPNG:
+		default:
+			return nil, Errorf(CodeBadRequest, "unknown capture format %q", args.Format)
+		}
+		if args.Scrollback < 0 || (args.Scrollback > 0 && args.Format == FormatPNG) {
+			return nil, Errorf(CodeBadRequest, "scrollback must be positive and only applies to text captures")
+		}
+		if ranged := args.Since != nil || args.Until != nil; ranged && args.Format == FormatPNG {
+			return nil, Errorf(CodeBadRequest, "a time range only applies to text captures")
+		}
+		if args.Since != nil && args.Until != nil && !args.Until.After(*args.Since) {
+			return nil, Errorf(CodeBadRequest, "capture time range ends before it starts")
+		}
+		return h.Capture(args)
+	case CmdPopOut:
+		return run(req.Args, h.PopOut, sessionName)
+	case CmdFocus:
+		return run(req.Args, h.Focus, sessionName)
+	default:
+		return nil, Errorf(CodeUnknownCommand, "unknown command %q", req.Command)
+	}
+}
+
+// run decodes args for a command that only reports success or failure
+func run[A any](raw json.RawMessage, fn func(A) error, name func(A) string) (any, error) {
+	args, err := decode(raw, name)
+	if err != nil {
+		return nil, err
+	}
+	return nil, fn(args)
+}
+
+// decode unmarshals a command's args, checking that they name a session
+func decode[A any](raw json.RawMessage, name func(A) string) (A, error) {
+	var args A
+	if len(raw) == 0 {
+		raw = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return args, Errorf(CodeBadRequest, "invalid args: %v", err)
+	}
+	if name(args) == "" {
+		return args, Errorf(CodeBadRequest, "session name is required")
+	}
+	return args, nil
+}
+
+func sessionName(a SessionArgs) string {
+	return a.Name
 }
 
 func (s *Server) sendResponse(conn net.Conn, resp Response) {
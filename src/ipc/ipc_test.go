@@ -0,0 +1,207 @@
+package ipc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"prompt-grid/src/tmux"
+)
+
+// TestMain isolates the socket in a realm of its own
+func TestMain(m *testing.M) {
+	os.Setenv(tmux.RealmEnvVar, fmt.Sprintf("ipc-test-%d-%d", os.Getpid(), time.Now().UnixNano()))
+	code := m.Run()
+	os.RemoveAll(tmux.GetSocketDir())
+	os.Exit(code)
+}
+
+// fakeHandler records the commands it receives
+type fakeHandler struct {
+	calls []string
+	err   error
+}
+
+func (f *fakeHandler) record(call string) error {
+	f.calls = append(f.calls, call)
+	return f.err
+}
+
+func (f *fakeHandler) Open(a OpenArgs) error { return f.record("open " + a.Name + " " + a.SSHHost) }
+func (f *fakeHandler) List() ([]SessionInfo, error) {
+	return []SessionInfo{{Name: "a", Type: TypeShell, Cols: 80, Rows: 24}}, f.record("list")
+}
+func (f *fakeHandler) New(a NewArgs) error       { return f.record("new " + a.Name + " " + a.Type) }
+func (f *fakeHandler) Close(a SessionArgs) error { return f.record("close " + a.Name) }
+func (f *fakeHandler) Rename(a RenameArgs) error {
+	return f.record("rename " + a.Name + " " + a.NewName)
+}
+func (f *fakeHandler) Recolor(a SessionArgs) error { return f.record("recolor " + a.Name) }
+func (f *fakeHandler) SendKeys(a SendKeysArgs) error {
+	return f.record(fmt.Sprint("send-keys ", a.Name, " ", a.Keys))
+}
+func (f *fakeHandler) SendText(a SendTextArgs) error {
+	return f.record(fmt.Sprintf("send-text %s %q %v", a.Name, a.Text, a.Enter))
+}
+func (f *fakeHandler) Capture(a CaptureArgs) (*CaptureResult, error) {
+	return &CaptureResult{Format: a.Format, Text: "screen"}, f.record("capture " + a.Name + " " + a.Format)
+}
+func (f *fakeHandler) PopOut(a SessionArgs) error { return f.record("pop-out " + a.Name) }
+func (f *fakeHandler) Focus(a SessionArgs) error  { return f.record("focus " + a.Name) }
+
+func startServer(t *testing.T, h Handler) *Client {
+	t.Helper()
+	server, err := NewServer(h)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	go server.Run()
+	t.Cleanup(func() { server.Close() })
+	return NewClient()
+}
+
+func TestClientCommands(t *testing.T) {
+	h := &fakeHandler{}
+	c := startServer(t, h)
+
+	sessions, err := c.List()
+	if err != nil || len(sessions) != 1 || sessions[0].Name != "a" || sessions[0].Cols != 80 {
+		t.Errorf("List = %v, %v", sessions, err)
+	}
+	capture, err := c.Capture(CaptureArgs{Name: "a"})
+	if err != nil || capture.Format != FormatText || capture.Text != "screen" {
+		t.Errorf("Capture = %+v, %v", capture, err)
+	}
+	steps := []func() error{
+		func() error { return c.Open(OpenArgs{Name: "a", SSHHost: "host"}) },
+		func() error { return c.New(NewArgs{Name: "b", Type: TypeClaude}) },
+		func() error { return c.Close("a") },
+		func() error { return c.Rename("a", "b") },
+		func() error { return c.Recolor("a") },
+		func() error { return c.SendKeys("a", "C-c", "Enter") },
+		func() error { return c.SendText("a", "ls", true) },
+		func() error { return c.PopOut("a") },
+		func() error { return c.Focus("a") },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Errorf("step %d: %v", i, err)
+		}
+	}
+
+	want := []string{
+		"list", "capture a text", "open a host", "new b claude", "close a", "rename a b",
+		"recolor a", "send-keys a [C-c Enter]", `send-text a "ls" true`, "pop-out a", "focus a",
+	}
+	if fmt.Sprint(h.calls) != fmt.Sprint(want) {
+		t.Errorf("calls = %q, want %q", h.calls, want)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	h := &fakeHandler{}
+	c := startServer(t, h)
+
+	at := func(sec int64) *time.Time {
+		t := time.Unix(sec, 0)
+		return &t
+	}
+	tests := []struct {
+		name    string
+		command string
+		args    any
+		err     error
+		code    string
+	}{
+		{"unknown command", "reboot", nil, nil, CodeUnknownCommand},
+		{"missing session name", CmdClose, SessionArgs{}, nil, CodeBadRequest},
+		{"missing new name", CmdRename, RenameArgs{Name: "a"}, nil, CodeBadRequest},
+		{"bad args", CmdClose, []int{1}, nil, CodeBadRequest},
+		{"bad capture format", CmdCapture, CaptureArgs{Name: "a", Format: "gif"}, nil, CodeBadRequest},
+		{"png with scrollback", CmdCapture, CaptureArgs{Name: "a", Format: FormatPNG, Scrollback: 10}, nil, CodeBadRequest},
+		{"png with time range", CmdCapture, CaptureArgs{Name: "a", Format: FormatPNG, Since: at(1)}, nil, CodeBadRequest},
+		{"time range backwards", CmdCapture, CaptureArgs{Name: "a", Since: at(2), Until: at(1)}, nil, CodeBadRequest},
+		{"handler code", CmdFocus, SessionArgs{Name: "a"}, Errorf(CodeNotFound, "session %q not found", "a"), CodeNotFound},
+		{"plain handler error", CmdFocus, SessionArgs{Name: "a"}, errors.New("boom"), CodeFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.err = tt.err
+			err := c.Call(tt.command, tt.args, nil)
+			var ipcErr *Error
+			if !errors.As(err, &ipcErr) || ipcErr.Code != tt.code {
+				t.Errorf("Call error = %v, want code %s", err, tt.code)
+			}
+		})
+	}
+}
+
+// TestServerVersions covers legacy clients and clients newer than the server
+func TestServerVersions(t *testing.T) {
+	h := &fakeHandler{}
+	startServer(t, h)
+
+	send := func(req Request) Response {
+		t.Helper()
+		conn, err := net.Dial("unix", SocketPath())
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		defer conn.Close()
+		json.NewEncoder(conn).Encode(req)
+		line, err := bufio.NewReader(conn).ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("read response: %v", err)
+		}
+		var resp Response
+		json.Unmarshal(line, &resp)
+		return resp
+	}
+
+	if resp := send(Request{SessionName: "old", SSHHost: "h"}); !resp.OK {
+		t.Errorf("legacy open failed: %+v", resp)
+	}
+	if len(h.calls) != 1 || h.calls[0] != "open old h" {
+		t.Errorf("legacy request ran %q", h.calls)
+	}
+
+	resp := send(Request{Version: ProtocolVersion + 1, Command: CmdList})
+	if resp.OK || resp.Code != CodeUnsupportedVersion {
+		t.Errorf("future version response = %+v", resp)
+	}
+}
+
+// TestCaptureArgsOpenEnds checks an open time range isn't sent as the zero
+// time
+func TestCaptureArgsOpenEnds(t *testing.T) {
+	since := time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		args CaptureArgs
+		want string
+	}{
+		{"open", CaptureArgs{Name: "a"}, `{"name":"a"}`},
+		{"since", CaptureArgs{Name: "a", Since: &since}, `{"name":"a","since":"2026-10-16T03:00:00Z"}`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.args)
+		if err != nil || string(data) != tt.want {
+			t.Errorf("%s: Marshal = %s, %v; want %s", tt.name, data, err, tt.want)
+		}
+	}
+}
+
+func TestTryConnectWithoutDaemon(t *testing.T) {
+	connected, err := TryConnect(OpenArgs{Name: "x"})
+	if connected || err != nil {
+		t.Errorf("TryConnect = %v, %v; want false, nil", connected, err)
+	}
+	if err := NewClient().Focus("x"); !errors.Is(err, ErrNoDaemon) {
+		t.Errorf("Focus error = %v, want ErrNoDaemon", err)
+	}
+}
@@ -0,0 +1,196 @@
+package ipc
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ProtocolVersion is the command protocol version spoken by this build.
+// Requests without a version are treated as legacy open requests.
+const ProtocolVersion = 1
+
+// Commands understood by the daemon
+const (
+	CmdOpen     = "open"      // Open a session, creating it if needed (OpenArgs)
+	CmdList     = "list"      // List sessions (no args, ListResult)
+	CmdNew      = "new"       // Create a session (NewArgs)
+	CmdClose    = "close"     // Close a session (SessionArgs)
+	CmdRename   = "rename"    // Rename a session (RenameArgs)
+	CmdRecolor  = "recolor"   // Assign a new random color (SessionArgs)
+	CmdSendKeys = "send-keys" // Send tmux key names (SendKeysArgs)
+	CmdSendText = "send-text" // Send literal text (SendTextArgs)
+	CmdCapture  = "capture"   // Capture the screen (CaptureArgs, CaptureResult)
+	CmdPopOut   = "pop-out"   // Open a standalone window (SessionArgs)
+	CmdFocus    = "focus"     // Select and raise a session (SessionArgs)
+)
+
+// Session types for NewArgs
+const (
+	TypeShell  = "shell"
+	TypeSSH    = "ssh"
+	TypeClaude = "claude"
+	TypeCodex  = "codex"
+)
+
+// Capture formats for CaptureArgs
+const (
+	FormatText = "text"
+	FormatANSI = "ansi"
+	FormatPNG  = "png"
+)
+
+// Error codes returned in Response.Code
+const (
+	CodeBadRequest         = "bad_request"
+	CodeUnknownCommand     = "unknown_command"
+	CodeUnsupportedVersion = "unsupported_version"
+	CodeNotFound           = "not_found"
+	CodeExists             = "exists"
+	CodeFailed             = "failed"
+)
+
+// ReservedNames are the CLI's command words. `prompt-grid <word>` runs the
+// command rather than opening a session, so no session may be named one.
+var ReservedNames = []string{
+	"help", "-h", "--help", "search", "ls", "send", "capture", "close",
+	"rename", "claude", "codex", "wait", "ssh",
+}
+
+// IsReservedName reports whether name is one of ReservedNames
+func IsReservedName(name string) bool {
+	return slices.Contains(ReservedNames, name)
+}
+
+// Request is one command sent to the daemon
+type Request struct {
+	Version int             `json:"version,omitempty"`
+	Command string          `json:"command,omitempty"`
+	Args    json.RawMessage `json:"args,omitempty"`
+
+	// Legacy (version 0) open request
+	SessionName string `json:"session_name,omitempty"`
+	SSHHost     string `json:"ssh_host,omitempty"`
+}
+
+// Response from the daemon. Result holds the command's result struct.
+type Response struct {
+	Version int             `json:"version,omitempty"`
+	OK      bool            `json:"ok"`
+	Error   string          `json:"error,omitempty"`
+	Code    string          `json:"code,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+}
+
+// OpenArgs names a session to open; it is created (over SSH when SSHHost
+// is set) if it doesn't exist.
+type OpenArgs struct {
+	Name    string `json:"name"`
+	SSHHost string `json:"ssh_host,omitempty"`
+}
+
+// NewArgs describes a session to create
+type NewArgs struct {
+	Name    string `json:"name"`
+	Type    string `json:"type,omitempty"` // TypeShell (default), TypeSSH, TypeClaude or TypeCodex
+	SSHHost string `json:"ssh_host,omitempty"`
+	WorkDir string `json:"work_dir,omitempty"`
+}
+
+// SessionArgs names the session a command applies to
+type SessionArgs struct {
+	Name string `json:"name"`
+}
+
+// RenameArgs renames a session
+type RenameArgs struct {
+	Name    string `json:"name"`
+	NewName string `json:"new_name"`
+}
+
+// SendKeysArgs sends tmux key names (e.g. "C-c", "Enter") to a session
+type SendKeysArgs struct {
+	Name string   `json:"name"`
+	Keys []string `json:"keys"`
+}
+
+// SendTextArgs types text into a session, optionally pressing Enter after
+type SendTextArgs struct {
+	Name  string `json:"name"`
+	Text  string `json:"text"`
+	Enter bool   `json:"enter,omitempty"`
+}
+
+// CaptureArgs requests a screen capture
+type CaptureArgs struct {
+	Name       string `json:"name"`
+	Format     string `json:"format,omitempty"`     // FormatText (default), FormatANSI or FormatPNG
+	Scrollback int    `json:"scrollback,omitempty"` // Scrollback lines to include above the screen (text and ANSI only)
+
+	// Since and Until keep only scrollback lines that arrived in
+	// [Since, Until); nil leaves that end open. The screen is left out
+	// when Until is set, since it shows the present.
+	Since *time.Time `json:"since,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// SessionInfo describes one session in a ListResult
+type SessionInfo struct {
+	Name         string    `json:"name"`
+	Type         string    `json:"type"`
+	SSHHost      string    `json:"ssh_host,omitempty"`
+	WorkDir      string    `json:"work_dir,omitempty"`
+	Prompt       string    `json:"prompt,omitempty"`        // "shell" or "claude" when waiting for input
+	InputPending bool      `json:"input_pending,omitempty"` // Input sent with send-text or send-keys hasn't reached a new prompt yet
+	LastActivity time.Time `json:"last_activity"`
+	PoppedOut    bool      `json:"popped_out,omitempty"`
+	Cols         int       `json:"cols"`
+	Rows         int       `json:"rows"`
+}
+
+// ListResult is the result of CmdList
+type ListResult struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+// CaptureResult is the result of CmdCapture. Text holds the screen for
+// FormatText and FormatANSI, PNG the image for FormatPNG.
+type CaptureResult struct {
+	Format string `json:"format"`
+	Cols   int    `json:"cols"`
+	Rows   int    `json:"rows"`
+	Text   string `json:"text,omitempty"`
+	PNG    []byte `json:"png,omitempty"`
+}
+
+// Error is a command failure with a machine-readable code
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errorf returns an *Error with the given code
+func Errorf(code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Handler carries out commands for the server. Returning an *Error sets
+// the response code; any other error is reported as CodeFailed.
+type Handler interface {
+	Open(OpenArgs) error
+	List() ([]SessionInfo, error)
+	New(NewArgs) error
+	Close(SessionArgs) error
+	Rename(RenameArgs) error
+	Recolor(SessionArgs) error
+	SendKeys(SendKeysArgs) error
+	SendText(SendTextArgs) error
+	Capture(CaptureArgs) (*CaptureResult, error)
+	PopOut(SessionArgs) error
+	Focus(SessionArgs) error
+}
@@ -0,0 +1,281 @@
+package keyencode
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// Key identifies a non-character key
+type Key uint8
+
+const (
+	KeyNone Key = iota // Character key - see Event.Rune
+	KeyEnter
+	KeyKeypadEnter
+	KeyBackspace
+	KeyTab
+	KeyEscape
+	KeySpace
+	KeyUp
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyPageUp
+	KeyPageDown
+	KeyInsert
+	KeyDelete
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+)
+
+// Modifiers is a set of modifier keys held during a key press
+type Modifiers uint8
+
+const (
+	ModShift Modifiers = 1 << iota
+	ModAlt
+	ModCtrl
+)
+
+// Modes holds the terminal modes that change what keys send
+type Modes struct {
+	AppCursor bool // DECCKM (?1): cursor keys send SS3 instead of CSI
+	AppKeypad bool // DECKPAM (ESC =): keypad keys send SS3 sequences
+
+	Kitty           KittyFlags // Kitty keyboard protocol enhancements (CSI > flags u)
+	ModifyOtherKeys int        // xterm modifyOtherKeys level (CSI > 4 ; level m), 0-2
+}
+
+// Event is a key press or release to encode. Character keys set Rune and
+// leave Key as KeyNone; letters may be given in either case.
+type Event struct {
+	Key     Key
+	Rune    rune
+	Mods    Modifiers
+	Release bool // Key released; only the kitty protocol reports these
+}
+
+// Encode returns the bytes an xterm sends for ev under the given modes,
+// or nil if the combination produces no input. Once an application enables
+// kitty keyboard enhancements, keys it can't tell apart otherwise are sent
+// as CSI u sequences instead.
+func Encode(ev Event, modes Modes) []byte {
+	if modes.Kitty != 0 {
+		return encodeKitty(ev, modes)
+	}
+	if ev.Release {
+		return nil
+	}
+	if b, ok := encodeModifyOtherKeys(ev, modes.ModifyOtherKeys); ok {
+		return b
+	}
+	return encodeLegacy(ev, modes)
+}
+
+// encodeLegacy encodes a key press the way xterm does.
+func encodeLegacy(ev Event, modes Modes) []byte {
+	if ev.Key == KeyNone {
+		return encodeRune(ev.Rune, ev.Mods)
+	}
+
+	param := modParam(ev.Mods)
+
+	switch ev.Key {
+	case KeyUp:
+		return cursorKey('A', param, modes)
+	case KeyDown:
+		return cursorKey('B', param, modes)
+	case KeyRight:
+		return cursorKey('C', param, modes)
+	case KeyLeft:
+		return cursorKey('D', param, modes)
+	case KeyHome:
+		return cursorKey('H', param, modes)
+	case KeyEnd:
+		return cursorKey('F', param, modes)
+	case KeyInsert:
+		return tildeKey(2, param)
+	case KeyDelete:
+		return tildeKey(3, param)
+	case KeyPageUp:
+		return tildeKey(5, param)
+	case KeyPageDown:
+		return tildeKey(6, param)
+	case KeyF1, KeyF2, KeyF3, KeyF4:
+		final := byte('P' + ev.Key - KeyF1)
+		if param == 1 {
+			return []byte{0x1b, 'O', final}
+		}
+		return csi("1;"+strconv.Itoa(param), final)
+	case KeyF5, KeyF6, KeyF7, KeyF8, KeyF9, KeyF10, KeyF11, KeyF12:
+		return tildeKey(functionKeyCodes[ev.Key-KeyF5], param)
+	case KeyTab:
+		if ev.Mods&ModShift != 0 {
+			return withAlt([]byte("\x1b[Z"), ev.Mods)
+		}
+		return withAlt([]byte{'\t'}, ev.Mods)
+	case KeyKeypadEnter:
+		if modes.AppKeypad {
+			return []byte{0x1b, 'O', 'M'}
+		}
+		return withAlt([]byte{'\r'}, ev.Mods)
+	case KeyEnter:
+		return withAlt([]byte{'\r'}, ev.Mods)
+	case KeyBackspace:
+		if ev.Mods&ModCtrl != 0 {
+			return withAlt([]byte{0x08}, ev.Mods)
+		}
+		return withAlt([]byte{0x7f}, ev.Mods)
+	case KeyEscape:
+		return withAlt([]byte{0x1b}, ev.Mods)
+	case KeySpace:
+		if ev.Mods&ModCtrl != 0 {
+			return withAlt([]byte{0x00}, ev.Mods)
+		}
+		return withAlt([]byte{' '}, ev.Mods)
+	}
+	return nil
+}
+
+// modParam returns the modifier parameter of CSI sequences: 1 + shift +
+// 2*alt + 4*ctrl
+func modParam(mods Modifiers) int {
+	param := 1
+	if mods&ModShift != 0 {
+		param += 1
+	}
+	if mods&ModAlt != 0 {
+		param += 2
+	}
+	if mods&ModCtrl != 0 {
+		param += 4
+	}
+	return param
+}
+
+// functionKeyCodes are the CSI ~ codes for F5-F12 (note the gaps at 16 and 22)
+var functionKeyCodes = [...]int{15, 17, 18, 19, 20, 21, 23, 24}
+
+// cursorKey encodes an arrow/Home/End key: SS3 in application cursor mode,
+// CSI otherwise, and CSI 1;<mods> whenever a modifier is held.
+func cursorKey(final byte, param int, modes Modes) []byte {
+	if param > 1 {
+		return csi("1;"+strconv.Itoa(param), final)
+	}
+	if modes.AppCursor {
+		return []byte{0x1b, 'O', final}
+	}
+	return []byte{0x1b, '[', final}
+}
+
+// tildeKey encodes a CSI <code> ~ key with an optional modifier parameter.
+func tildeKey(code, param int) []byte {
+	params := strconv.Itoa(code)
+	if param > 1 {
+		params += ";" + strconv.Itoa(param)
+	}
+	return csi(params, '~')
+}
+
+func csi(params string, final byte) []byte {
+	b := make([]byte, 0, len(params)+3)
+	b = append(b, 0x1b, '[')
+	b = append(b, params...)
+	return append(b, final)
+}
+
+// withAlt prefixes ESC when Alt is held (Meta sends ESC, like xterm's metaSendsEscape).
+func withAlt(b []byte, mods Modifiers) []byte {
+	if mods&ModAlt == 0 {
+		return b
+	}
+	return append([]byte{0x1b}, b...)
+}
+
+// encodeRune encodes a character key with modifiers.
+func encodeRune(r rune, mods Modifiers) []byte {
+	if r == 0 {
+		return nil
+	}
+	if r >= utf8.RuneSelf {
+		// Non-ASCII keys (other layouts) pass through as UTF-8
+		return withAlt([]byte(string(r)), mods)
+	}
+
+	ch := byte(r)
+	if mods&ModCtrl != 0 {
+		if c, ok := ctrlChar(ch); ok {
+			return withAlt([]byte{c}, mods)
+		}
+		// No control code for this key - send it as if Ctrl weren't held
+	}
+	if mods&ModShift != 0 {
+		ch = ShiftChar(ch)
+	} else if ch >= 'A' && ch <= 'Z' {
+		ch += 'a' - 'A'
+	}
+	return withAlt([]byte{ch}, mods)
+}
+
+// ctrlChar maps a key pressed with Ctrl to its control character, following
+// xterm: letters and @[\]^_ map to C0, digits 2-8 cover the same codes for
+// keyboards without easy punctuation, and / and ? alias _ and DEL.
+func ctrlChar(ch byte) (byte, bool) {
+	switch {
+	case ch >= 'a' && ch <= 'z':
+		return ch - 'a' + 1, true
+	case ch >= '@' && ch <= '_': // @, A-Z, [, \, ], ^, _
+		return ch - '@', true
+	}
+	switch ch {
+	case ' ', '2', '`':
+		return 0x00, true
+	case '3':
+		return 0x1b, true
+	case '4':
+		return 0x1c, true
+	case '5':
+		return 0x1d, true
+	case '6':
+		return 0x1e, true
+	case '7', '-', '/':
+		return 0x1f, true
+	case '8', '?':
+		return 0x7f, true
+	}
+	return 0, false
+}
+
+// ShiftChar returns the character a US keyboard produces for ch with Shift held.
+func ShiftChar(ch byte) byte {
+	if ch >= 'A' && ch <= 'Z' {
+		return ch
+	}
+	if ch >= 'a' && ch <= 'z' {
+		return ch - 32
+	}
+	if shifted, ok := shiftMap[ch]; ok {
+		return shifted
+	}
+	return ch
+}
+
+var shiftMap = map[byte]byte{
+	'1': '!', '2': '@', '3': '#', '4': '$', '5': '%',
+	'6': '^', '7': '&', '8': '*', '9': '(', '0': ')',
+	'-': '_', '=': '+', '[': '{', ']': '}', '\\': '|',
+	';': ':', '\'': '"', ',': '<', '.': '>', '/': '?',
+	'`': '~',
+}
@@ -0,0 +1,205 @@
+package keyencode
+
+import "testing"
+
+func TestEncode(t *testing.T) {
+	normal := Modes{}
+	appCursor := Modes{AppCursor: true}
+	appKeypad := Modes{AppKeypad: true}
+
+	tests := []struct {
+		name  string
+		ev    Event
+		modes Modes
+		want  string
+	}{
+		// Cursor keys
+		{"up", Event{Key: KeyUp}, normal, "\x1b[A"},
+		{"up DECCKM", Event{Key: KeyUp}, appCursor, "\x1bOA"},
+		{"left DECCKM", Event{Key: KeyLeft}, appCursor, "\x1bOD"},
+		{"home", Event{Key: KeyHome}, normal, "\x1b[H"},
+		{"end DECCKM", Event{Key: KeyEnd}, appCursor, "\x1bOF"},
+		{"ctrl+right", Event{Key: KeyRight, Mods: ModCtrl}, normal, "\x1b[1;5C"},
+		{"ctrl+right ignores DECCKM", Event{Key: KeyRight, Mods: ModCtrl}, appCursor, "\x1b[1;5C"},
+		{"shift+up", Event{Key: KeyUp, Mods: ModShift}, normal, "\x1b[1;2A"},
+		{"alt+left", Event{Key: KeyLeft, Mods: ModAlt}, normal, "\x1b[1;3D"},
+		{"ctrl+shift+alt+down", Event{Key: KeyDown, Mods: ModCtrl | ModShift | ModAlt}, normal, "\x1b[1;8B"},
+
+		// Editing keys
+		{"page up", Event{Key: KeyPageUp}, normal, "\x1b[5~"},
+		{"ctrl+page down", Event{Key: KeyPageDown, Mods: ModCtrl}, normal, "\x1b[6;5~"},
+		{"delete", Event{Key: KeyDelete}, normal, "\x1b[3~"},
+		{"shift+delete", Event{Key: KeyDelete, Mods: ModShift}, normal, "\x1b[3;2~"},
+		{"insert", Event{Key: KeyInsert}, normal, "\x1b[2~"},
+
+		// Function keys
+		{"F1", Event{Key: KeyF1}, normal, "\x1bOP"},
+		{"F4", Event{Key: KeyF4}, normal, "\x1bOS"},
+		{"shift+F1", Event{Key: KeyF1, Mods: ModShift}, normal, "\x1b[1;2P"},
+		{"F5", Event{Key: KeyF5}, normal, "\x1b[15~"},
+		{"F6", Event{Key: KeyF6}, normal, "\x1b[17~"},
+		{"F10", Event{Key: KeyF10}, normal, "\x1b[21~"},
+		{"F11", Event{Key: KeyF11}, normal, "\x1b[23~"},
+		{"ctrl+F12", Event{Key: KeyF12, Mods: ModCtrl}, normal, "\x1b[24;5~"},
+
+		// Enter, tab, backspace, escape, space
+		{"enter", Event{Key: KeyEnter}, normal, "\r"},
+		{"enter ignores DECKPAM", Event{Key: KeyEnter}, appKeypad, "\r"},
+		{"keypad enter", Event{Key: KeyKeypadEnter}, normal, "\r"},
+		{"keypad enter DECKPAM", Event{Key: KeyKeypadEnter}, appKeypad, "\x1bOM"},
+		{"alt+enter", Event{Key: KeyEnter, Mods: ModAlt}, normal, "\x1b\r"},
+		{"tab", Event{Key: KeyTab}, normal, "\t"},
+		{"shift+tab", Event{Key: KeyTab, Mods: ModShift}, normal, "\x1b[Z"},
+		{"backspace", Event{Key: KeyBackspace}, normal, "\x7f"},
+		{"ctrl+backspace", Event{Key: KeyBackspace, Mods: ModCtrl}, normal, "\x08"},
+		{"alt+backspace", Event{Key: KeyBackspace, Mods: ModAlt}, normal, "\x1b\x7f"},
+		{"escape", Event{Key: KeyEscape}, normal, "\x1b"},
+		{"space", Event{Key: KeySpace}, normal, " "},
+		{"ctrl+space", Event{Key: KeySpace, Mods: ModCtrl}, normal, "\x00"},
+
+		// Characters
+		{"letter lowercased", Event{Rune: 'A'}, normal, "a"},
+		{"shift+letter", Event{Rune: 'A', Mods: ModShift}, normal, "A"},
+		{"shift+digit", Event{Rune: '1', Mods: ModShift}, normal, "!"},
+		{"shift+slash", Event{Rune: '/', Mods: ModShift}, normal, "?"},
+		{"ctrl+c", Event{Rune: 'C', Mods: ModCtrl}, normal, "\x03"},
+		{"ctrl+shift+c", Event{Rune: 'C', Mods: ModCtrl | ModShift}, normal, "\x03"},
+		{"ctrl+[", Event{Rune: '[', Mods: ModCtrl}, normal, "\x1b"},
+		{"ctrl+backslash", Event{Rune: '\\', Mods: ModCtrl}, normal, "\x1c"},
+		{"ctrl+]", Event{Rune: ']', Mods: ModCtrl}, normal, "\x1d"},
+		{"ctrl+@", Event{Rune: '@', Mods: ModCtrl}, normal, "\x00"},
+		{"ctrl+2", Event{Rune: '2', Mods: ModCtrl}, normal, "\x00"},
+		{"ctrl+6", Event{Rune: '6', Mods: ModCtrl}, normal, "\x1e"},
+		{"ctrl+slash", Event{Rune: '/', Mods: ModCtrl}, normal, "\x1f"},
+		{"ctrl+minus", Event{Rune: '-', Mods: ModCtrl}, normal, "\x1f"},
+		{"ctrl+1 falls back", Event{Rune: '1', Mods: ModCtrl}, normal, "1"},
+		{"alt+letter", Event{Rune: 'B', Mods: ModAlt}, normal, "\x1bb"},
+		{"alt+shift+letter", Event{Rune: 'B', Mods: ModAlt | ModShift}, normal, "\x1bB"},
+		{"ctrl+alt+letter", Event{Rune: 'X', Mods: ModCtrl | ModAlt}, normal, "\x1b\x18"},
+		{"non-ascii", Event{Rune: 'é'}, normal, "é"},
+		{"no key", Event{}, normal, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Encode(tt.ev, tt.modes))
+			if got != tt.want {
+				t.Errorf("Encode(%+v, %+v) = %q, want %q", tt.ev, tt.modes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShiftChar(t *testing.T) {
+	tests := []struct {
+		in, want byte
+	}{
+		{'a', 'A'},
+		{'Z', 'Z'},
+		{'9', '('},
+		{'\'', '"'},
+		{'`', '~'},
+		{'!', '!'},
+	}
+	for _, tt := range tests {
+		if got := ShiftChar(tt.in); got != tt.want {
+			t.Errorf("ShiftChar(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeKitty(t *testing.T) {
+	disambiguate := Modes{Kitty: KittyDisambiguate}
+	events := Modes{Kitty: KittyDisambiguate | KittyEventTypes}
+	all := Modes{Kitty: KittyDisambiguate | KittyEventTypes | KittyAllKeys | KittyAssociatedText}
+
+	tests := []struct {
+		name  string
+		ev    Event
+		modes Modes
+		want  string
+	}{
+		// Disambiguate: keys legacy encodings can't tell apart
+		{"escape", Event{Key: KeyEscape}, disambiguate, "\x1b[27u"},
+		{"ctrl+i", Event{Rune: 'I', Mods: ModCtrl}, disambiguate, "\x1b[105;5u"},
+		{"alt+a", Event{Rune: 'a', Mods: ModAlt}, disambiguate, "\x1b[97;3u"},
+		{"ctrl+shift+a", Event{Rune: 'A', Mods: ModCtrl | ModShift}, disambiguate, "\x1b[97;6u"},
+		{"shift+enter", Event{Key: KeyEnter, Mods: ModShift}, disambiguate, "\x1b[13;2u"},
+		{"ctrl+space", Event{Key: KeySpace, Mods: ModCtrl}, disambiguate, "\x1b[32;5u"},
+		{"keypad enter", Event{Key: KeyKeypadEnter}, disambiguate, "\x1b[57414u"},
+		{"ctrl+F3", Event{Key: KeyF3, Mods: ModCtrl}, disambiguate, "\x1b[13;5~"},
+
+		// Unambiguous keys stay legacy
+		{"text", Event{Rune: 'a'}, disambiguate, "a"},
+		{"shifted text", Event{Rune: '1', Mods: ModShift}, disambiguate, "!"},
+		{"enter", Event{Key: KeyEnter}, disambiguate, "\r"},
+		{"backspace", Event{Key: KeyBackspace}, disambiguate, "\x7f"},
+		{"up DECCKM", Event{Key: KeyUp}, Modes{AppCursor: true, Kitty: KittyDisambiguate}, "\x1bOA"},
+		{"ctrl+up", Event{Key: KeyUp, Mods: ModCtrl}, disambiguate, "\x1b[1;5A"},
+
+		// Releases
+		{"release without event types", Event{Rune: 'a', Release: true}, disambiguate, ""},
+		{"release legacy", Event{Rune: 'a', Release: true}, Modes{}, ""},
+		{"release text", Event{Rune: 'a', Release: true}, events, "\x1b[97;1:3u"},
+		{"release ctrl+c", Event{Rune: 'c', Mods: ModCtrl, Release: true}, events, "\x1b[99;5:3u"},
+		{"release up", Event{Key: KeyUp, Release: true}, events, "\x1b[1;1:3A"},
+		{"release delete", Event{Key: KeyDelete, Release: true}, events, "\x1b[3;1:3~"},
+		{"no release for enter", Event{Key: KeyEnter, Release: true}, events, ""},
+		{"press with event types", Event{Rune: 'a'}, events, "a"},
+
+		// Alternate keys
+		{"shifted key", Event{Rune: 'a', Mods: ModShift | ModCtrl}, Modes{Kitty: KittyDisambiguate | KittyAlternateKeys}, "\x1b[97:65;6u"},
+
+		// All keys as escape codes, with their text
+		{"all text", Event{Rune: 'a'}, all, "\x1b[97;;97u"},
+		{"all shifted text", Event{Rune: 'a', Mods: ModShift}, all, "\x1b[97;2;65u"},
+		{"all enter", Event{Key: KeyEnter}, all, "\x1b[13u"},
+		{"all release enter", Event{Key: KeyEnter, Release: true}, all, "\x1b[13;1:3u"},
+		{"all up", Event{Key: KeyUp}, all, "\x1b[A"},
+		{"all ctrl text", Event{Rune: 'a', Mods: ModCtrl}, all, "\x1b[97;5u"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Encode(tt.ev, tt.modes)); got != tt.want {
+				t.Errorf("Encode = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeModifyOtherKeys(t *testing.T) {
+	one := Modes{ModifyOtherKeys: 1}
+	two := Modes{ModifyOtherKeys: 2}
+
+	tests := []struct {
+		name  string
+		ev    Event
+		modes Modes
+		want  string
+	}{
+		{"off shift+enter", Event{Key: KeyEnter, Mods: ModShift}, Modes{}, "\r"},
+		{"shift+enter", Event{Key: KeyEnter, Mods: ModShift}, one, "\x1b[27;2;13~"},
+		{"ctrl+enter", Event{Key: KeyEnter, Mods: ModCtrl}, one, "\x1b[27;5;13~"},
+		{"ctrl+tab", Event{Key: KeyTab, Mods: ModCtrl}, one, "\x1b[27;5;9~"},
+		{"shift+tab keeps CSI Z", Event{Key: KeyTab, Mods: ModShift}, one, "\x1b[Z"},
+		{"alt+enter keeps ESC", Event{Key: KeyEnter, Mods: ModAlt}, one, "\x1b\r"},
+		{"plain enter", Event{Key: KeyEnter}, one, "\r"},
+		{"ctrl+i", Event{Rune: 'i', Mods: ModCtrl}, one, "\x1b[27;5;105~"},
+		{"ctrl+m", Event{Rune: 'm', Mods: ModCtrl}, one, "\x1b[27;5;109~"},
+		{"ctrl+[", Event{Rune: '[', Mods: ModCtrl}, one, "\x1b[27;5;91~"},
+		{"ctrl+a level 1", Event{Rune: 'a', Mods: ModCtrl}, one, "\x01"},
+		{"alt+a level 1", Event{Rune: 'a', Mods: ModAlt}, one, "\x1ba"},
+		{"ctrl+a level 2", Event{Rune: 'a', Mods: ModCtrl}, two, "\x1b[27;5;97~"},
+		{"alt+a level 2", Event{Rune: 'a', Mods: ModAlt}, two, "\x1b[27;3;97~"},
+		{"ctrl+shift+a", Event{Rune: 'a', Mods: ModCtrl | ModShift}, two, "\x1b[27;6;65~"},
+		{"shift+a types A", Event{Rune: 'a', Mods: ModShift}, two, "A"},
+		{"up keeps CSI", Event{Key: KeyUp, Mods: ModCtrl}, two, "\x1b[1;5A"},
+		{"kitty wins", Event{Key: KeyEnter, Mods: ModShift}, Modes{Kitty: KittyDisambiguate, ModifyOtherKeys: 1}, "\x1b[13;2u"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Encode(tt.ev, tt.modes)); got != tt.want {
+				t.Errorf("Encode = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package keyencode
+
+import (
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KittyFlags are the kitty keyboard protocol enhancements an application
+// enables with CSI > flags u
+type KittyFlags uint8
+
+const (
+	KittyDisambiguate   KittyFlags = 1 << iota // Escape and modified keys as CSI u
+	KittyEventTypes                            // Report key releases too
+	KittyAlternateKeys                         // Add the shifted key to the key code
+	KittyAllKeys                               // Send every key as an escape code, text keys too
+	KittyAssociatedText                        // Add the text a key types (with KittyAllKeys)
+)
+
+// kittyKey is how the kitty protocol encodes a non-character key: as
+// CSI code u, CSI code ~, or CSI 1 final for the cursor keys and F1-F4
+type kittyKey struct {
+	code  int
+	final byte
+}
+
+var kittyKeys = map[Key]kittyKey{
+	KeyEnter:       {13, 'u'},
+	KeyKeypadEnter: {57414, 'u'},
+	KeyBackspace:   {127, 'u'},
+	KeyTab:         {9, 'u'},
+	KeyEscape:      {27, 'u'},
+	KeySpace:       {32, 'u'},
+	KeyUp:          {1, 'A'},
+	KeyDown:        {1, 'B'},
+	KeyRight:       {1, 'C'},
+	KeyLeft:        {1, 'D'},
+	KeyHome:        {1, 'H'},
+	KeyEnd:         {1, 'F'},
+	KeyInsert:      {2, '~'},
+	KeyDelete:      {3, '~'},
+	KeyPageUp:      {5, '~'},
+	KeyPageDown:    {6, '~'},
+	KeyF1:          {1, 'P'},
+	KeyF2:          {1, 'Q'},
+	KeyF3:          {13, '~'}, // Not CSI R, which reads as a cursor position report
+	KeyF4:          {1, 'S'},
+	KeyF5:          {15, '~'},
+	KeyF6:          {17, '~'},
+	KeyF7:          {18, '~'},
+	KeyF8:          {19, '~'},
+	KeyF9:          {20, '~'},
+	KeyF10:         {21, '~'},
+	KeyF11:         {23, '~'},
+	KeyF12:         {24, '~'},
+}
+
+// encodeKitty encodes a key press or release under the kitty keyboard
+// protocol: CSI key[:shifted] ; mods[:event] ; text final. Keys that
+// aren't ambiguous keep their legacy encoding unless KittyAllKeys is set;
+// Enter, Tab and Backspace stay legacy too so a shell left in this mode by
+// a crashed program can still run reset.
+func encodeKitty(ev Event, modes Modes) []byte {
+	flags := modes.Kitty
+	k := kittyKey{final: 'u'}
+	var text rune // Character the key types, if any
+	if ev.Key == KeyNone {
+		if ev.Rune == 0 {
+			return nil
+		}
+		k.code = int(unicode.ToLower(ev.Rune))
+		if ev.Mods&^ModShift == 0 {
+			text = typedRune(ev.Rune, ev.Mods)
+		}
+	} else {
+		var ok bool
+		if k, ok = kittyKeys[ev.Key]; !ok {
+			return nil
+		}
+		if ev.Key == KeySpace && ev.Mods&^ModShift == 0 {
+			text = ' '
+		}
+	}
+
+	all := flags&KittyAllKeys != 0
+	plain := ev.Key == KeyEnter || ev.Key == KeyTab || ev.Key == KeyBackspace
+	if ev.Release {
+		if flags&KittyEventTypes == 0 || (plain && !all) {
+			return nil
+		}
+	} else if !all {
+		legacy := flags&KittyDisambiguate == 0 || text != 0 ||
+			(ev.Mods == 0 && (plain || k.final != 'u'))
+		if legacy {
+			return encodeLegacy(ev, modes)
+		}
+	}
+
+	params := strconv.Itoa(k.code)
+	if flags&KittyAlternateKeys != 0 && ev.Key == KeyNone && ev.Mods&ModShift != 0 {
+		if shifted := typedRune(ev.Rune, ModShift); int(shifted) != k.code {
+			params += ":" + strconv.Itoa(int(shifted))
+		}
+	}
+	mods := ""
+	if param := modParam(ev.Mods); param > 1 || ev.Release {
+		mods = strconv.Itoa(param)
+		if ev.Release {
+			mods += ":3"
+		}
+	}
+	if all && flags&KittyAssociatedText != 0 && text != 0 && !ev.Release {
+		params += ";" + mods + ";" + strconv.Itoa(int(text))
+	} else if mods != "" {
+		params += ";" + mods
+	}
+	if params == "1" && k.final != 'u' {
+		params = ""
+	}
+	return csi(params, k.final)
+}
+
+// typedRune returns the character a character key types with mods held.
+func typedRune(r rune, mods Modifiers) rune {
+	if r >= utf8.RuneSelf {
+		if mods&ModShift != 0 {
+			return unicode.ToUpper(r)
+		}
+		return unicode.ToLower(r)
+	}
+	if mods&ModShift != 0 {
+		return rune(ShiftChar(byte(r)))
+	}
+	return unicode.ToLower(r)
+}
@@ -0,0 +1,65 @@
+package keyencode
+
+import "strconv"
+
+// otherKeyCodes are the codes modifyOtherKeys sends for the named keys it
+// covers; other named keys keep their CSI encodings, which carry modifiers
+var otherKeyCodes = map[Key]int{
+	KeyEnter:       '\r',
+	KeyKeypadEnter: '\r',
+	KeyTab:         '\t',
+	KeyBackspace:   0x7f,
+	KeyEscape:      0x1b,
+	KeySpace:       ' ',
+}
+
+// encodeModifyOtherKeys encodes a modified key the way xterm does under
+// modifyOtherKeys: CSI 27 ; mods ; code ~. Level 1 covers the keys whose
+// legacy encoding loses the modifier or matches another key (Shift+Enter,
+// Ctrl+Tab, Ctrl+I against Tab, Ctrl+M against Enter); level 2 covers every
+// key held with Ctrl or Alt. Shift+Tab keeps CSI Z and keys that only
+// Shift changes still type their shifted character. Returns false for keys
+// the legacy encoding handles.
+func encodeModifyOtherKeys(ev Event, level int) ([]byte, bool) {
+	if level == 0 || ev.Mods == 0 {
+		return nil, false
+	}
+	code, ok := otherKeyCodes[ev.Key]
+	switch {
+	case ok:
+		if ev.Key == KeyTab && ev.Mods == ModShift {
+			return nil, false
+		}
+		if level == 1 && ev.Mods == ModAlt {
+			// ESC prefix already tells Alt+Enter apart
+			return nil, false
+		}
+	case ev.Key != KeyNone || ev.Rune == 0 || ev.Rune >= 0x7f:
+		return nil, false
+	default:
+		code = int(ev.Rune)
+		if code >= 'A' && code <= 'Z' {
+			code += 'a' - 'A'
+		}
+		if ev.Mods&ModShift != 0 {
+			code = int(ShiftChar(byte(code)))
+		}
+		if ev.Mods == ModShift {
+			return nil, false
+		}
+		if level == 1 && !collidesWithKey(byte(code), ev.Mods) {
+			return nil, false
+		}
+	}
+	return csi("27;"+strconv.Itoa(modParam(ev.Mods))+";"+strconv.Itoa(code), '~'), true
+}
+
+// collidesWithKey reports whether a character key held with mods sends the
+// same legacy bytes as Tab, Enter, Escape or Backspace
+func collidesWithKey(ch byte, mods Modifiers) bool {
+	if mods&ModCtrl == 0 {
+		return false
+	}
+	c, ok := ctrlChar(ch)
+	return ok && (c == '\t' || c == '\r' || c == 0x1b || c == 0x08 || c == 0x7f)
+}
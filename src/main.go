@@ -43,69 +43,16 @@ func main() {
 		return
 	}
 
-	// Regular invocation - parse args and either connect or spawn daemon
+	// Regular invocation - run a subcommand, or open a session (spawning
+	// the daemon if needed)
 	args := os.Args[1:]
 
 	if len(args) == 0 {
-		printUsage()
+		printUsage(os.Stdout)
 		os.Exit(1)
 	}
 
-	// Parse command line
-	var sessionName string
-	var sshHost string
-
-	if args[0] == "ssh" {
-		if len(args) < 2 {
-			fmt.Fprintln(os.Stderr, "Error: ssh requires a host argument")
-			printUsage()
-			os.Exit(1)
-		}
-		sshHost = args[1]
-		if len(args) >= 3 {
-			sessionName = args[2]
-		} else {
-			sessionName = sshHost
-		}
-	} else {
-		sessionName = args[0]
-	}
-
-	// Try to connect to existing instance
-	req := ipc.Request{
-		SessionName: sessionName,
-		SSHHost:     sshHost,
-	}
-
-	connected, err := ipc.TryConnect(req)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-
-	if connected {
-		// Successfully sent to existing instance - exit immediately
-		os.Exit(0)
-	}
-
-	// No existing instance - spawn daemon and send request
-	spawnDaemon()
-
-	// Wait briefly for daemon to start, then connect
-	for i := 0; i < 50; i++ { // Try for up to 5 seconds
-		connected, err = ipc.TryConnect(req)
-		if connected {
-			os.Exit(0)
-		}
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-		time.Sleep(100 * time.Millisecond)
-	}
-
-	fmt.Fprintln(os.Stderr, "Error: failed to connect to daemon")
-	os.Exit(1)
+	os.Exit(runCLI(args, os.Stdout, os.Stderr))
 }
 
 func spawnDaemon() {
@@ -178,9 +125,7 @@ func runDaemon() {
 	application := gui.NewApp(cfg, cfgPath)
 
 	// Create IPC server
-	server, err := ipc.NewServer(func(req ipc.Request) error {
-		return application.AddSession(req.SessionName, req.SSHHost)
-	})
+	server, err := ipc.NewServer(application.IPCHandler())
 	if err != nil {
 		os.Exit(1)
 	}
@@ -247,12 +192,12 @@ func runDaemon() {
 		// User can reopen control via IPC or Discord commands
 	}()
 
-	// Set up graceful shutdown handler to flush logs on SIGTERM/SIGINT
+	// Set up graceful shutdown handler to flush logs and save snapshots on SIGTERM/SIGINT
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
 	go func() {
 		<-sigChan
-		// Flush all PTY logs before exit
+		// Flush all PTY logs and save terminal state before exit
 		application.FlushAllLogs()
 		os.Exit(0)
 	}()
@@ -264,19 +209,6 @@ func runDaemon() {
 	runtime.KeepAlive(lockFile)
 }
 
-func printUsage() {
-	fmt.Println(`prompt-grid - Terminal emulator with multi-view support
-
-Usage:
-  prompt-grid <session-name>              Create a new local session
-  prompt-grid ssh <host> [session-name]   Create an SSH session
-
-Examples:
-  prompt-grid "My Project"
-  prompt-grid ssh user@host "Remote Work"
-  prompt-grid ssh myserver`)
-}
-
 func findArg(name string) int {
 	for i, arg := range os.Args {
 		if arg == name {
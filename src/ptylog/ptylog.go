@@ -1,7 +1,7 @@
 package ptylog
 
 import (
-	"io"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,9 +12,12 @@ import (
 const (
 	flushInterval = 200 * time.Millisecond // Reduced from 2s to 200ms for better persistence
 	flushSize     = 64 * 1024              // 64KB
-	maxLogSize    = 10 * 1024 * 1024       // 10MB
-	truncTarget   = 5 * 1024 * 1024        // 5MB — keep last 5MB after truncation
-	replayChunk   = 32 * 1024              // 32KB chunks for replay
+	maxBuffered   = 4 * 1024 * 1024        // Buffered bytes past which Write waits for the disk
+	syncInterval  = time.Second            // Least time between fsyncs under SyncPeriodic
+	segmentSize   = 1024 * 1024            // 1MB per segment (see segment.go)
+	maxLogSize    = 10 * 1024 * 1024       // 10MB kept across all segments
+	replayChunk   = 32 * 1024              // 32KB chunks for reading or migrating a legacy log
+	logExt        = ".ptylog"
 )
 
 // Parser is the interface ptylog needs for replay (avoids circular import)
@@ -22,15 +25,21 @@ type Parser interface {
 	Parse(data []byte)
 }
 
+// replayModeSetter is implemented by parsers that must know replayed input
+// from live input (e.g. to stay silent instead of answering old queries)
+type replayModeSetter interface {
+	SetReplayMode(on bool)
+}
+
 // LogDir returns the directory where PTY logs are stored
 func LogDir() string {
 	home, _ := os.UserHomeDir()
 	return filepath.Join(home, ".config", "prompt-grid", "sessions")
 }
 
-// LogPath returns the log file path for a session name
+// LogPath returns the log directory path for a session name
 func LogPath(name string) string {
-	return filepath.Join(LogDir(), sanitize(name)+".ptylog")
+	return filepath.Join(LogDir(), sanitize(name)+logExt)
 }
 
 // sanitize replaces characters unsafe for filenames
@@ -39,163 +48,282 @@ func sanitize(name string) string {
 	return r.Replace(name)
 }
 
-// Writer buffers and writes PTY output to a log file
+// SyncPolicy says when a Writer fsyncs the live segment. Sealed segments
+// and the index are always synced, and a torn write at the end of the live
+// segment is trimmed when the log is reopened, so a crash loses at most
+// the output written since the last sync.
+type SyncPolicy uint8
+
+const (
+	SyncPeriodic SyncPolicy = iota // On a flush at least syncInterval after the last sync (the default)
+	SyncAlways                     // After every flush
+	SyncOnSeal                     // Only when a segment is sealed or the log is flushed or closed
+)
+
+// Writer buffers PTY output and appends it to a session's log, sealing
+// the live segment as it fills (see segment.go)
 type Writer struct {
-	mu     sync.Mutex
-	file   *os.File
-	buf    []byte
-	size   int64 // Current file size (approximate)
-	timer  *time.Timer
-	closed bool
-	name   string
+	// mu guards the buffer and is all Write takes, so output keeps being
+	// buffered while a flush or rotation is on disk
+	mu       sync.Mutex
+	buf      []byte
+	timer    *time.Timer
+	flushing bool // A flush of a full buffer has been started
+	closed   bool
+	name     string
+
+	// ioMu serializes flushes and guards the segments
+	ioMu     sync.Mutex
+	dir      string
+	file     *os.File      // Live segment; nil once closed or if it couldn't be created
+	fileErr  error         // Why the live segment couldn't be created, until it is
+	start    int64         // Stream offset of the live segment's first byte
+	size     int64         // Bytes in the live segment
+	fileSize int64         // Size of the live segment file, headers included
+	sealed   []segmentInfo // Sealed segments, oldest first
+	policy   SyncPolicy
+	lastSync time.Time
+	segSize  int64 // Bytes per segment
+	maxSize  int64 // Bytes kept across all segments
 }
 
-// NewWriter creates a new PTY log writer for the given session name.
-// The log file is opened in append mode.
+// NewWriter creates a new PTY log writer for the given session name,
+// appending to the session's log if it has one. A torn write left by a
+// crash is trimmed, and a log in the single-file format of earlier builds
+// is converted.
 func NewWriter(name string) (*Writer, error) {
-	dir := LogDir()
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := os.MkdirAll(LogDir(), 0755); err != nil {
 		return nil, err
 	}
-
 	path := LogPath(name)
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
+	if err := migrateLegacy(path); err != nil {
 		return nil, err
 	}
-
-	// Get current file size
-	info, err := f.Stat()
+	w, err := openWriter(path)
 	if err != nil {
-		f.Close()
 		return nil, err
 	}
+	w.name = name
+	return w, nil
+}
 
-	w := &Writer{
-		file: f,
-		size: info.Size(),
-		name: name,
+// openWriter opens the log in dir for appending, creating it if needed
+func openWriter(dir string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	w := &Writer{dir: dir, segSize: segmentSize, maxSize: maxLogSize}
+	starts := listSegments(dir)
+	if len(starts) == 0 {
+		starts = []int64{0}
+	}
+	w.sealed = sealedSegments(dir, starts, true)
+	w.start = starts[len(starts)-1]
+
+	// Trim a torn write off the live segment; one that can't be read at
+	// all is started again
+	path := filepath.Join(dir, segmentName(w.start))
+	length, fileSize, err := measureSegment(path)
+	if err == nil {
+		err = os.Truncate(path, fileSize)
+	}
+	if err == nil {
+		w.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
 	}
+	if err != nil {
+		length, fileSize = 0, segmentHeader
+		if w.file, err = createSegment(dir, w.start); err != nil {
+			return nil, err
+		}
+	}
+	w.size, w.fileSize = length, fileSize
 	return w, nil
 }
 
-// Write appends data to the buffer and schedules a flush
+// SetSyncPolicy sets when the live segment is fsynced
+func (w *Writer) SetSyncPolicy(p SyncPolicy) {
+	w.ioMu.Lock()
+	defer w.ioMu.Unlock()
+	w.policy = p
+}
+
+// Write appends data to the buffer and schedules a flush. Once flushSize
+// bytes are buffered a flush is started in the background, so Write
+// doesn't wait on the disk, or on a rotation, unless the disk has fallen
+// maxBuffered bytes behind.
 func (w *Writer) Write(data []byte) {
 	w.mu.Lock()
-	defer w.mu.Unlock()
-
 	if w.closed {
+		w.mu.Unlock()
 		return
 	}
-
 	w.buf = append(w.buf, data...)
-
-	// Flush immediately if buffer is large enough
-	if len(w.buf) >= flushSize {
-		w.flushLocked()
-		return
+	full := len(w.buf) >= flushSize
+	behind := len(w.buf) >= maxBuffered
+
+	switch {
+	case full && !w.flushing:
+		w.flushing = true
+		go w.flush()
+	case !full && w.timer == nil:
+		// Schedule a delayed flush if not already pending
+		w.timer = time.AfterFunc(flushInterval, w.flush)
 	}
+	w.mu.Unlock()
 
-	// Schedule a delayed flush if not already pending
-	if w.timer == nil {
-		w.timer = time.AfterFunc(flushInterval, func() {
-			w.mu.Lock()
-			defer w.mu.Unlock()
-			if !w.closed {
-				w.flushLocked()
-			}
-		})
+	if behind {
+		w.flush()
 	}
 }
 
-// flushLocked writes buffered data to disk. Caller must hold w.mu.
-func (w *Writer) flushLocked() {
-	if len(w.buf) == 0 {
-		return
-	}
-
-	n, _ := w.file.Write(w.buf)
-	w.size += int64(n)
-	w.buf = w.buf[:0]
+// flush takes the buffered data and appends it to the log. Only ioMu is
+// held while writing, so Write can go on buffering.
+func (w *Writer) flush() {
+	w.ioMu.Lock()
+	defer w.ioMu.Unlock()
 
+	w.mu.Lock()
+	data := w.buf
+	w.buf = nil
+	w.flushing = false
 	if w.timer != nil {
 		w.timer.Stop()
 		w.timer = nil
 	}
+	w.mu.Unlock()
 
-	// Truncate if file is too large
-	if w.size > maxLogSize {
-		w.truncateLocked()
+	w.appendLocked(data)
+	if w.file != nil && len(data) > 0 && (w.policy == SyncAlways ||
+		w.policy == SyncPeriodic && time.Since(w.lastSync) >= syncInterval) {
+		w.syncLocked()
 	}
 }
 
-// truncateLocked reduces the log file to the last truncTarget bytes.
-// Seeks to a safe boundary (newline or ESC byte) to avoid mid-sequence corruption.
-func (w *Writer) truncateLocked() {
-	w.file.Close()
+// appendLocked appends data as records, sealing the live segment each
+// time it fills. If the live segment couldn't be created it is tried
+// again; data is dropped while that keeps failing. Caller must hold
+// w.ioMu.
+func (w *Writer) appendLocked(data []byte) {
+	if len(data) > 0 && w.file == nil && w.fileErr != nil {
+		w.createLocked()
+	}
+	for len(data) > 0 && w.file != nil {
+		if w.size >= w.segSize {
+			w.rotateLocked()
+			continue
+		}
+		n := min(int64(len(data)), w.segSize-w.size)
+		if _, err := w.file.Write(appendRecord(nil, data[:n])); err != nil {
+			// Take back a partial record so later ones aren't lost behind it
+			w.file.Truncate(w.fileSize)
+			return
+		}
+		w.size += n
+		w.fileSize += recordHeader + n
+		data = data[n:]
+		if w.size >= w.segSize {
+			w.rotateLocked()
+		}
+	}
+}
 
-	path := LogPath(w.name)
-	data, err := os.ReadFile(path)
-	if err != nil {
-		// Re-open in append mode, best effort
-		w.file, _ = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		w.size = 0
-		return
+// rotateLocked seals the live segment, starts the next one and drops the
+// oldest segments the size limit no longer allows. Nothing is read back,
+// so rotating costs the same however big the log is. Caller must hold
+// w.ioMu.
+func (w *Writer) rotateLocked() {
+	w.syncLocked()
+	w.file.Close()
+	w.sealed = append(w.sealed, segmentInfo{start: w.start, length: w.size})
+	w.start += w.size
+	w.size, w.fileSize = 0, segmentHeader
+	w.createLocked()
+
+	// Keep room for the new segment to fill
+	total := w.segSize
+	for _, s := range w.sealed {
+		total += s.length
+	}
+	for len(w.sealed) > 0 && total > w.maxSize {
+		os.Remove(filepath.Join(w.dir, segmentName(w.sealed[0].start)))
+		total -= w.sealed[0].length
+		w.sealed = w.sealed[1:]
 	}
+	writeIndex(w.dir, w.sealed)
+}
 
-	if int64(len(data)) <= truncTarget {
-		w.file, _ = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		w.size = int64(len(data))
+// createLocked creates the live segment at w.start. A failure (a full
+// disk, too many open files) leaves w.file nil until a later flush manages
+// it, and is returned by Flush and Close meanwhile. Caller must hold
+// w.ioMu.
+func (w *Writer) createLocked() {
+	f, err := createSegment(w.dir, w.start)
+	if err != nil {
+		w.file, w.fileErr = nil, err
 		return
 	}
+	w.file, w.fileErr = f, nil
+}
 
-	// Cut from the front, keeping last truncTarget bytes
-	cutPoint := int64(len(data)) - truncTarget
+// syncLocked fsyncs the live segment. Caller must hold w.ioMu.
+func (w *Writer) syncLocked() {
+	w.file.Sync()
+	w.lastSync = time.Now()
+}
 
-	// Seek forward to a safe boundary: newline or ESC (0x1b)
-	for cutPoint < int64(len(data)) {
-		b := data[cutPoint]
-		if b == '\n' || b == 0x1b {
-			break
-		}
-		cutPoint++
+// closeFile syncs and closes the live segment. Caller must hold w.ioMu.
+func (w *Writer) closeFile() error {
+	if w.file == nil {
+		return nil
 	}
-
-	kept := data[cutPoint:]
-	os.WriteFile(path, kept, 0644)
-
-	w.file, _ = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	w.size = int64(len(kept))
+	err := w.file.Sync()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file, w.fileErr = nil, nil
+	return err
 }
 
-// Flush writes any buffered data to disk without closing the file.
-// Safe to call concurrently.
-func (w *Writer) Flush() {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-
-	if !w.closed {
-		w.flushLocked()
+// Flush writes any buffered data to disk and syncs it, without closing
+// the log. Safe to call concurrently. It returns why output is being
+// dropped, if the live segment couldn't be created.
+func (w *Writer) Flush() error {
+	w.flush()
+	w.ioMu.Lock()
+	defer w.ioMu.Unlock()
+	if w.file != nil {
+		w.syncLocked()
 	}
+	if w.fileErr != nil {
+		return fmt.Errorf("ptylog: dropping output: %w", w.fileErr)
+	}
+	return nil
 }
 
-// Close flushes remaining data and closes the file
-func (w *Writer) Close() {
+// Close flushes remaining data and closes the log, returning why output
+// was dropped as Flush does, or why the log couldn't be closed.
+func (w *Writer) Close() error {
 	w.mu.Lock()
-	defer w.mu.Unlock()
-
 	if w.closed {
-		return
+		w.mu.Unlock()
+		return nil
 	}
 	w.closed = true
-
 	if w.timer != nil {
 		w.timer.Stop()
 		w.timer = nil
 	}
-
-	w.flushLocked()
-	w.file.Close()
+	w.mu.Unlock()
+
+	w.flush()
+	w.ioMu.Lock()
+	defer w.ioMu.Unlock()
+	dropping := w.fileErr
+	err := w.closeFile()
+	if dropping != nil {
+		return fmt.Errorf("ptylog: dropping output: %w", dropping)
+	}
+	return err
 }
 
 // replayTailSize is the maximum number of bytes to replay from the end of the
@@ -204,63 +332,48 @@ func (w *Writer) Close() {
 // for any terminal size (e.g., 240×80 = 19200 chars).
 const replayTailSize = 128 * 1024
 
-// ReplayLog reads the tail of a session's log file and feeds it through a parser.
+// ReplayLog reads the tail of a session's log and feeds it through a parser.
 // Only the last replayTailSize bytes are replayed — scrollback history is already
 // persisted in the .scrollback file, so replaying the full log is unnecessary and
 // causes long "scroll of old content" on restart for large logs.
-// Returns nil if the log file doesn't exist.
+// Returns nil if the log doesn't exist.
 func ReplayLog(name string, parser Parser) error {
-	path := LogPath(name)
-	f, err := os.Open(path)
+	r, err := OpenReader(name)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
 		return err
 	}
-	defer f.Close()
 
-	// Seek to tail if file is larger than replayTailSize
-	info, err := f.Stat()
-	if err != nil {
-		return err
-	}
-	if info.Size() > replayTailSize {
-		f.Seek(info.Size()-replayTailSize, io.SeekStart)
+	if rm, ok := parser.(replayModeSetter); ok {
+		rm.SetReplayMode(true)
+		defer rm.SetReplayMode(false)
 	}
 
-	buf := make([]byte, replayChunk)
-	for {
-		n, err := f.Read(buf)
-		if n > 0 {
-			parser.Parse(buf[:n])
-		}
-		if err != nil {
-			if err == io.EOF {
-				return nil
-			}
-			return err
-		}
-	}
+	end := r.End()
+	return r.Replay(max(r.Start(), end-replayTailSize), end, parser)
 }
 
-// TruncateLog resets a session's log file to zero bytes.
+// TruncateLog resets a session's log to zero bytes.
 // Call after ReplayLog to prevent content duplication across restarts:
 // each restart replays the log then records tmux's screen redraw on top,
 // causing the same content to accumulate N times after N restarts.
 func TruncateLog(name string) {
-	path := LogPath(name)
-	os.Truncate(path, 0)
+	DeleteLog(name)
 }
 
-// DeleteLog removes the log file for a session
+// DeleteLog removes the log for a session
 func DeleteLog(name string) {
-	os.Remove(LogPath(name))
+	path := LogPath(name)
+	os.RemoveAll(path)
+	os.Remove(path + ".old")
 }
 
-// RenameLog renames a session's log file
+// RenameLog renames a session's log
 func RenameLog(oldName, newName string) {
 	oldPath := LogPath(oldName)
 	newPath := LogPath(newName)
 	os.Rename(oldPath, newPath)
+	os.Rename(oldPath+".old", newPath+".old")
 }
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"prompt-grid/src/emulator"
 )
 
 // mockParser records all data passed to Parse
@@ -17,6 +19,20 @@ func (p *mockParser) Parse(data []byte) {
 	p.data = append(p.data, data...)
 }
 
+// readLog returns everything a session's log keeps, nothing if it has none
+func readLog(name string) ([]byte, error) {
+	r, err := OpenReader(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &mockParser{}
+	err = r.Replay(r.Start(), r.End(), p)
+	return p.data, err
+}
+
 func TestMain(m *testing.M) {
 	// Use temp dir for HOME to avoid polluting real config
 	tmpHome, _ := os.MkdirTemp("", "ptylog-test-")
@@ -72,9 +88,9 @@ func TestWriterWriteAndFlush(t *testing.T) {
 	w.Close()
 
 	// Read back
-	data, err := os.ReadFile(LogPath("test-write-flush"))
+	data, err := readLog("test-write-flush")
 	if err != nil {
-		t.Fatalf("ReadFile: %v", err)
+		t.Fatalf("readLog: %v", err)
 	}
 	if string(data) != "hello world\nsecond line\n" {
 		t.Errorf("log content = %q, want %q", data, "hello world\nsecond line\n")
@@ -96,9 +112,9 @@ func TestWriterTimedFlush(t *testing.T) {
 	// Wait for timed flush (2s interval + margin)
 	time.Sleep(3 * time.Second)
 
-	data, err := os.ReadFile(LogPath("test-timed-flush"))
+	data, err := readLog("test-timed-flush")
 	if err != nil {
-		t.Fatalf("ReadFile: %v", err)
+		t.Fatalf("readLog: %v", err)
 	}
 	if string(data) != "timed data" {
 		t.Errorf("after timed flush: %q, want %q", data, "timed data")
@@ -122,10 +138,16 @@ func TestWriterLargeBufferFlush(t *testing.T) {
 	}
 	w.Write(bigData)
 
-	// Should have been flushed immediately
-	data, err := os.ReadFile(LogPath("test-large-flush"))
-	if err != nil {
-		t.Fatalf("ReadFile: %v", err)
+	// Should be flushed straight away in the background, without waiting
+	// for the flush timer, Flush or Close
+	var data []byte
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if data, err = readLog("test-large-flush"); err != nil {
+			t.Fatalf("readLog: %v", err)
+		}
+		if len(data) == len(bigData) {
+			break
+		}
 	}
 	if len(data) != len(bigData) {
 		t.Errorf("flushed size = %d, want %d", len(data), len(bigData))
@@ -156,6 +178,36 @@ func TestReplayLog(t *testing.T) {
 	}
 }
 
+// TestReplayLogSuppressesReplies verifies queries in a replayed log don't
+// get answered, while the same parser answers live queries afterwards.
+func TestReplayLogSuppressesReplies(t *testing.T) {
+	w, err := NewWriter("test-replay-quiet")
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	w.Write([]byte("prompt$ \x1b[6n\x1b[c"))
+	w.Close()
+	defer DeleteLog("test-replay-quiet")
+
+	parser := emulator.NewParser(emulator.NewScreen(80, 24), emulator.NewScrollback())
+	var replies []string
+	parser.SetOnResponse(func(data []byte) {
+		replies = append(replies, string(data))
+	})
+
+	if err := ReplayLog("test-replay-quiet", parser); err != nil {
+		t.Fatalf("ReplayLog: %v", err)
+	}
+	if len(replies) != 0 {
+		t.Errorf("replay produced replies %q, want none", replies)
+	}
+
+	parser.Parse([]byte("\x1b[6n"))
+	if len(replies) != 1 || replies[0] != "\x1b[1;9R" {
+		t.Errorf("live replies = %q, want [\"\\x1b[1;9R\"]", replies)
+	}
+}
+
 func TestReplayLogNonexistent(t *testing.T) {
 	parser := &mockParser{}
 	err := ReplayLog("nonexistent-session-xyz", parser)
@@ -204,9 +256,9 @@ func TestRenameLog(t *testing.T) {
 	}
 
 	// New should have the data
-	data, err := os.ReadFile(LogPath("test-rename-new"))
+	data, err := readLog("test-rename-new")
 	if err != nil {
-		t.Fatalf("ReadFile: %v", err)
+		t.Fatalf("readLog: %v", err)
 	}
 	if string(data) != "rename data" {
 		t.Errorf("renamed log data = %q, want %q", data, "rename data")
@@ -222,9 +274,9 @@ func TestTruncateLog(t *testing.T) {
 	w.Close()
 
 	// Verify data exists
-	data, err := os.ReadFile(LogPath("test-truncate-log"))
+	data, err := readLog("test-truncate-log")
 	if err != nil {
-		t.Fatalf("ReadFile before truncate: %v", err)
+		t.Fatalf("readLog before truncate: %v", err)
 	}
 	if len(data) == 0 {
 		t.Fatal("log should have data before truncate")
@@ -232,10 +284,10 @@ func TestTruncateLog(t *testing.T) {
 
 	TruncateLog("test-truncate-log")
 
-	// File should still exist but be empty
-	data, err = os.ReadFile(LogPath("test-truncate-log"))
+	// Log should be empty
+	data, err = readLog("test-truncate-log")
 	if err != nil {
-		t.Fatalf("ReadFile after truncate: %v", err)
+		t.Fatalf("readLog after truncate: %v", err)
 	}
 	if len(data) != 0 {
 		t.Errorf("log should be empty after truncate, got %d bytes", len(data))
@@ -249,9 +301,9 @@ func TestTruncateLog(t *testing.T) {
 	w2.Write([]byte("new data"))
 	w2.Close()
 
-	data, err = os.ReadFile(LogPath("test-truncate-log"))
+	data, err = readLog("test-truncate-log")
 	if err != nil {
-		t.Fatalf("ReadFile after rewrite: %v", err)
+		t.Fatalf("readLog after rewrite: %v", err)
 	}
 	if string(data) != "new data" {
 		t.Errorf("log after truncate+write = %q, want %q", data, "new data")
@@ -275,7 +327,7 @@ func TestTruncation(t *testing.T) {
 	for i := range chunk {
 		chunk[i] = byte('A' + (i % 26))
 	}
-	// Add newlines for safe boundary seeking
+	// Add newlines, as in terminal output
 	for i := 0; i < len(chunk); i += 1000 {
 		chunk[i] = '\n'
 	}
@@ -287,18 +339,18 @@ func TestTruncation(t *testing.T) {
 	// Force final flush
 	w.Close()
 
-	// Check file size is within expected range (around 5MB target)
-	data, err := os.ReadFile(LogPath("test-truncate"))
+	// Check the log size is within the expected range
+	data, err := readLog("test-truncate")
 	if err != nil {
-		t.Fatalf("ReadFile: %v", err)
+		t.Fatalf("readLog: %v", err)
 	}
 
-	// Should be roughly truncTarget (5MB) after truncation
+	// Old segments are dropped to keep the log within maxLogSize
 	if int64(len(data)) > maxLogSize {
 		t.Errorf("log size = %d, should be <= %d after truncation", len(data), maxLogSize)
 	}
-	if int64(len(data)) < truncTarget/2 {
-		t.Errorf("log size = %d, seems too small (expected ~%d)", len(data), truncTarget)
+	if int64(len(data)) < maxLogSize-segmentSize {
+		t.Errorf("log size = %d, seems too small (expected ~%d)", len(data), maxLogSize)
 	}
 }
 
@@ -0,0 +1,136 @@
+package ptylog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Reader replays any byte range of a session's log by stream offset. It
+// can be used alongside a Writer: it sees the segments there were when it
+// was opened, and the live segment as far as it has been written when it
+// is read.
+//
+// The app itself doesn't replay logs: sessions come back from their
+// snapshots and tmux's redraw, and their logs are truncated on reattach.
+// Reader and ReplayLog are for reading a log that is kept, e.g. from tools
+// and tests.
+type Reader struct {
+	dir    string
+	segs   []segmentInfo // Sealed segments then the live one, oldest first
+	legacy string        // Single-file log of an earlier build, if that is what there is
+}
+
+// OpenReader opens a session's log for reading. The error satisfies
+// os.IsNotExist if the session has no log. A log in the single-file
+// format of earlier builds is read as it is; only NewWriter converts it.
+func OpenReader(name string) (*Reader, error) {
+	path := LogPath(name)
+	// A conversion cut short leaves the whole log in the .old file
+	for _, legacy := range []string{path + ".old", path} {
+		if info, err := os.Lstat(legacy); err == nil && info.Mode().IsRegular() {
+			return &Reader{legacy: legacy, segs: []segmentInfo{{length: info.Size()}}}, nil
+		}
+	}
+	return openReader(path)
+}
+
+// openReader opens the log in dir for reading
+func openReader(dir string) (*Reader, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	starts := listSegments(dir)
+	if len(starts) == 0 {
+		return &Reader{dir: dir}, nil
+	}
+	segs := sealedSegments(dir, starts, false)
+	live := starts[len(starts)-1]
+	length, _, _ := measureSegment(filepath.Join(dir, segmentName(live)))
+	return &Reader{dir: dir, segs: append(segs, segmentInfo{start: live, length: length})}, nil
+}
+
+// Start returns the stream offset of the oldest byte the log keeps
+func (r *Reader) Start() int64 {
+	if len(r.segs) == 0 {
+		return 0
+	}
+	return r.segs[0].start
+}
+
+// End returns the stream offset just past the newest byte the log held
+// when the reader was opened
+func (r *Reader) End() int64 {
+	if len(r.segs) == 0 {
+		return 0
+	}
+	return r.segs[len(r.segs)-1].end()
+}
+
+// Replay feeds the stream's bytes in [from, to) through parser, a record
+// at a time. Bytes the log no longer keeps are skipped, as is anything
+// after a torn or corrupt record in the same segment. Parse must not keep
+// the slice it is given.
+func (r *Reader) Replay(from, to int64, parser Parser) error {
+	if r.legacy != "" {
+		return r.replayLegacy(from, to, parser)
+	}
+	for i, seg := range r.segs {
+		live := i == len(r.segs)-1
+		if seg.start >= to {
+			break
+		}
+		if seg.end() <= from && !live {
+			continue
+		}
+		f, err := os.Open(filepath.Join(r.dir, segmentName(seg.start)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Dropped by a writer since the reader was opened
+			}
+			return err
+		}
+		scanSegment(f, func(pos int64, payload []byte) bool {
+			lo := seg.start + pos
+			hi := lo + int64(len(payload))
+			if hi <= from {
+				return true
+			}
+			if lo >= to {
+				return false
+			}
+			parser.Parse(payload[max(from-lo, 0) : min(to, hi)-lo])
+			return true
+		})
+		f.Close()
+	}
+	return nil
+}
+
+// replayLegacy replays [from, to) of a single-file log, in which the
+// stream offset is the file offset
+func (r *Reader) replayLegacy(from, to int64, parser Parser) error {
+	from, to = max(from, 0), min(to, r.End())
+	if from >= to {
+		return nil
+	}
+	f, err := os.Open(r.legacy)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := make([]byte, replayChunk)
+	sr := io.NewSectionReader(f, from, to-from)
+	for {
+		n, err := sr.Read(buf)
+		if n > 0 {
+			parser.Parse(buf[:n])
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package ptylog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A session's log is a directory, <name>.ptylog/, holding the PTY output
+// stream in segments of segmentSize bytes and an index of them. The
+// stream offset counts every byte written since the log was created or
+// truncated, so an offset names the same byte after older segments are
+// dropped. Segments are named by the offset of their first byte
+// (%016x.seg), and only the newest one, the live segment, is appended to.
+//
+// Segment format:
+//
+//	header: "PGPL" magic, uint8 version, 3 reserved bytes
+//	record: uint32 payload length, uint32 CRC-32 (IEEE) of the payload,
+//	        payload; both little-endian
+//
+// Each flush appends one record, split where it would overflow the
+// segment. A record cut short or failing its checksum is a torn write from
+// a crash and ends the segment; the writer truncates it there on reopening.
+//
+// The index (index) has one 16-byte entry per sealed segment: its start
+// offset and length, little-endian uint64s. It is replaced (written to a
+// temporary file and renamed) whenever a segment is sealed or dropped. It
+// is derived data: when it doesn't match the segment files it is rebuilt
+// by scanning them.
+const (
+	segmentMagic   = "PGPL"
+	segmentVersion = 1
+	segmentHeader  = 8
+	recordHeader   = 8
+	indexEntrySize = 16
+	segmentExt     = ".seg"
+	indexName      = "index"
+
+	// maxRecordSize bounds a record read from disk, guarding against a
+	// corrupt length
+	maxRecordSize = 16 << 20
+)
+
+// errBadSegment reports a segment whose header isn't a ptylog segment's
+var errBadSegment = errors.New("ptylog: bad segment header")
+
+// segmentInfo is one segment of a log: the stream offset of its first byte
+// and how many bytes it holds
+type segmentInfo struct {
+	start  int64
+	length int64
+}
+
+func (s segmentInfo) end() int64 {
+	return s.start + s.length
+}
+
+func segmentName(start int64) string {
+	return fmt.Sprintf("%016x%s", start, segmentExt)
+}
+
+func segmentHeaderBytes() []byte {
+	h := make([]byte, segmentHeader)
+	copy(h, segmentMagic)
+	h[len(segmentMagic)] = segmentVersion
+	return h
+}
+
+// listSegments returns the start offsets of the segments in dir, oldest
+// first
+func listSegments(dir string) []int64 {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var starts []int64
+	for _, e := range entries {
+		var start int64
+		if n, _ := fmt.Sscanf(e.Name(), "%x"+segmentExt, &start); n != 1 || e.Name() != segmentName(start) {
+			continue
+		}
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	return starts
+}
+
+// createSegment creates an empty segment starting at start
+func createSegment(dir string, start int64) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, segmentName(start)), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(segmentHeaderBytes()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// appendRecord appends payload's record to b
+func appendRecord(b, payload []byte) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(payload)))
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(payload))
+	return append(b, payload...)
+}
+
+// scanSegment walks a segment's records from the start, calling fn with
+// each record's stream position within the segment and its payload. A nil
+// fn only measures. Returns the bytes of whole, valid records and the file
+// size they take; the scan stops at the first torn or corrupt record. fn
+// returning false stops the scan early.
+func scanSegment(r io.Reader, fn func(pos int64, payload []byte) bool) (length, fileSize int64, err error) {
+	br := bufio.NewReader(r)
+	h := make([]byte, segmentHeader)
+	if _, err := io.ReadFull(br, h); err != nil || !bytes.Equal(h[:len(segmentMagic)], []byte(segmentMagic)) {
+		return 0, 0, errBadSegment
+	}
+	if h[len(segmentMagic)] > segmentVersion {
+		// Written by a newer build: nothing this one can read
+		return 0, 0, errBadSegment
+	}
+	fileSize = segmentHeader
+	var rh [recordHeader]byte
+	var payload []byte
+	for {
+		if _, err := io.ReadFull(br, rh[:]); err != nil {
+			return length, fileSize, nil
+		}
+		n := binary.LittleEndian.Uint32(rh[:4])
+		if n > maxRecordSize {
+			return length, fileSize, nil
+		}
+		if cap(payload) < int(n) {
+			payload = make([]byte, n)
+		}
+		payload = payload[:n]
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return length, fileSize, nil
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(rh[4:]) {
+			return length, fileSize, nil
+		}
+		if fn != nil && !fn(length, payload) {
+			return length, fileSize, nil
+		}
+		length += int64(n)
+		fileSize += recordHeader + int64(n)
+	}
+}
+
+// measureSegment returns the valid length and file size of a segment file
+func measureSegment(path string) (length, fileSize int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	return scanSegment(f, nil)
+}
+
+// readIndex returns the segments the index lists
+func readIndex(dir string) []segmentInfo {
+	data, err := os.ReadFile(filepath.Join(dir, indexName))
+	if err != nil || len(data)%indexEntrySize != 0 {
+		return nil
+	}
+	segs := make([]segmentInfo, 0, len(data)/indexEntrySize)
+	for i := 0; i < len(data); i += indexEntrySize {
+		segs = append(segs, segmentInfo{
+			start:  int64(binary.LittleEndian.Uint64(data[i:])),
+			length: int64(binary.LittleEndian.Uint64(data[i+8:])),
+		})
+	}
+	return segs
+}
+
+// writeIndex replaces the index with one listing segs
+func writeIndex(dir string, segs []segmentInfo) error {
+	data := make([]byte, 0, len(segs)*indexEntrySize)
+	for _, s := range segs {
+		data = binary.LittleEndian.AppendUint64(data, uint64(s.start))
+		data = binary.LittleEndian.AppendUint64(data, uint64(s.length))
+	}
+	path := filepath.Join(dir, indexName)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if serr := f.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// sealedSegments returns the sealed segments among starts (all but the
+// last, live one) with their lengths, from the index when it matches the
+// files and by scanning them when it doesn't. A rebuilt index is saved
+// if save is set.
+func sealedSegments(dir string, starts []int64, save bool) []segmentInfo {
+	if len(starts) == 0 {
+		return nil
+	}
+	segs := readIndex(dir)
+	valid := len(segs) == len(starts)-1
+	for i := 0; valid && i < len(segs); i++ {
+		// A segment can't hold more than the stream up to the next one
+		valid = segs[i].start == starts[i] && segs[i].length >= 0 && segs[i].end() <= starts[i+1]
+	}
+	if valid {
+		return segs
+	}
+
+	segs = segs[:0]
+	for _, start := range starts[:len(starts)-1] {
+		length, _, err := measureSegment(filepath.Join(dir, segmentName(start)))
+		if err != nil {
+			continue
+		}
+		segs = append(segs, segmentInfo{start: start, length: length})
+	}
+	if save {
+		writeIndex(dir, segs)
+	}
+	return segs
+}
+
+// migrateLegacy converts a log from the single-file format of earlier
+// builds, <name>.ptylog holding the raw stream, into segments. The file is
+// moved aside first and only removed once its tail is copied, so a crash
+// mid-way is picked up again on the next open.
+func migrateLegacy(path string) error {
+	old := path + ".old"
+	if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+		if err := os.Rename(path, old); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(old)
+	if err != nil {
+		return nil // Nothing to migrate
+	}
+	defer f.Close()
+
+	os.RemoveAll(path) // A migration cut short
+	if info, err := f.Stat(); err == nil && info.Size() > maxLogSize {
+		f.Seek(info.Size()-maxLogSize, io.SeekStart)
+	}
+	w, err := openWriter(path)
+	if err != nil {
+		return err
+	}
+	w.ioMu.Lock()
+	defer w.ioMu.Unlock()
+	buf := make([]byte, replayChunk)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			w.appendLocked(buf[:n])
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+	return os.Remove(old)
+}
@@ -0,0 +1,364 @@
+package ptylog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smallWriter opens a log in a temp dir with small segments so tests
+// rotate often
+func smallWriter(t *testing.T, segSize, maxSize int64) (*Writer, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "s"+logExt)
+	w, err := openWriter(dir)
+	if err != nil {
+		t.Fatalf("openWriter: %v", err)
+	}
+	w.segSize, w.maxSize = segSize, maxSize
+	return w, dir
+}
+
+// replayRange returns bytes [from, to) of the log in dir
+func replayRange(t *testing.T, dir string, from, to int64) []byte {
+	t.Helper()
+	r, err := openReader(dir)
+	if err != nil {
+		t.Fatalf("openReader: %v", err)
+	}
+	p := &mockParser{}
+	if err := r.Replay(from, to, p); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	return p.data
+}
+
+// pattern returns n bytes of a stream in which every byte depends on its
+// offset, so misplaced bytes show up
+func pattern(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('a' + i%23)
+	}
+	return b
+}
+
+func TestWriterRotation(t *testing.T) {
+	w, dir := smallWriter(t, 1000, 5000)
+	stream := pattern(12345)
+	for rest := stream; len(rest) > 0; {
+		n := min(len(rest), 777)
+		w.Write(rest[:n])
+		w.Flush()
+		rest = rest[n:]
+	}
+	w.Close()
+
+	// Whole segments dropped from the front, the rest exactly segSize
+	starts := listSegments(dir)
+	segs := readIndex(dir)
+	if len(segs) != len(starts)-1 {
+		t.Fatalf("index lists %d segments for %d files", len(segs), len(starts))
+	}
+	for i, s := range segs {
+		if s.start != starts[i] || s.length != 1000 || s.end() != starts[i+1] {
+			t.Errorf("segment %d = %+v, want 1000 bytes from %d", i, s, starts[i])
+		}
+	}
+
+	r, _ := openReader(dir)
+	if r.End() != int64(len(stream)) {
+		t.Errorf("End = %d, want %d", r.End(), len(stream))
+	}
+	if kept := r.End() - r.Start(); kept > 5000 || kept < 4000 {
+		t.Errorf("%d bytes kept, want between 4000 and 5000", kept)
+	}
+
+	tests := []struct {
+		name     string
+		from, to int64
+	}{
+		{"all kept", r.Start(), r.End()},
+		{"within a record", r.Start() + 10, r.Start() + 20},
+		{"across segments", r.Start() + 990, r.Start() + 2010},
+		{"tail", r.End() - 1, r.End()},
+		{"before the start", 0, r.Start() + 5},
+		{"past the end", r.End() - 5, r.End() + 100},
+		{"empty", r.Start() + 100, r.Start() + 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := max(tt.from, r.Start()), min(tt.to, r.End())
+			got := replayRange(t, dir, tt.from, tt.to)
+			if want := stream[from:max(from, to)]; !bytes.Equal(got, want) {
+				t.Errorf("Replay(%d, %d) = %d bytes %.20q, want %d bytes %.20q", tt.from, tt.to, len(got), got, len(want), want)
+			}
+		})
+	}
+}
+
+func TestWriterConcurrentRotation(t *testing.T) {
+	const writers, lines = 8, 2000
+	w, dir := smallWriter(t, 4096, 1<<30)
+
+	var wg sync.WaitGroup
+	for g := 0; g < writers; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				w.Write([]byte(fmt.Sprintf("w%d %06d %s\n", g, i, strings.Repeat("x", i%50))))
+				if i%300 == 0 {
+					w.Flush()
+				}
+			}
+		}(g)
+	}
+	// Readers replay while segments are sealed under them
+	stop := make(chan struct{})
+	var rwg sync.WaitGroup
+	rwg.Add(1)
+	go func() {
+		defer rwg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if r, err := openReader(dir); err == nil {
+				r.Replay(r.Start(), r.End(), &mockParser{})
+			}
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	rwg.Wait()
+	w.Close()
+
+	if n := len(listSegments(dir)); n < 10 {
+		t.Fatalf("%d segments; want the writes to have rotated many times", n)
+	}
+
+	// Every Write arrives whole and each writer's lines in order
+	data := replayRange(t, dir, 0, 1<<40)
+	next := make([]int, writers)
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var g, i int
+		if n, _ := fmt.Sscanf(line, "w%d %d", &g, &i); n != 2 || g < 0 || g >= writers {
+			t.Fatalf("garbled line %q", line)
+		}
+		if want := fmt.Sprintf("w%d %06d %s", g, i, strings.Repeat("x", i%50)); line != want || i != next[g] {
+			t.Fatalf("line %q, want %q", line, fmt.Sprintf("w%d %06d", g, next[g]))
+		}
+		next[g]++
+	}
+	for g, n := range next {
+		if n != lines {
+			t.Errorf("writer %d: %d lines in the log, want %d", g, n, lines)
+		}
+	}
+}
+
+func TestWriteDoesNotWaitForDisk(t *testing.T) {
+	w, dir := smallWriter(t, 1000, 1<<20)
+
+	// A flush or rotation holds ioMu while it is on disk
+	w.ioMu.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 4; i++ {
+			w.Write(pattern(flushSize))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write waited for the disk")
+	}
+	w.ioMu.Unlock()
+
+	w.Close()
+	if got := replayRange(t, dir, 0, 1<<20); len(got) != 4*flushSize {
+		t.Errorf("log has %d bytes, want %d", len(got), 4*flushSize)
+	}
+}
+
+func TestWriterRetriesSegment(t *testing.T) {
+	w, dir := smallWriter(t, 100, 1<<20)
+
+	// Something in the way of the next segment stops it being created
+	blocker := filepath.Join(dir, segmentName(100))
+	os.Mkdir(blocker, 0755)
+	w.Write(pattern(150))
+	if err := w.Flush(); err == nil || w.file != nil {
+		t.Fatalf("the next segment was created through a directory (Flush = %v)", err)
+	}
+
+	// Later output gets logged once it can be
+	os.Remove(blocker)
+	w.Write([]byte("after"))
+	if err := w.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+	if got, want := replayRange(t, dir, 0, 1<<20), string(pattern(100))+"after"; string(got) != want {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+}
+
+func TestWriterTornWriteRecovery(t *testing.T) {
+	tests := []struct {
+		name string
+		torn func(rec []byte) []byte // What the crash left of a record
+	}{
+		{"cut in the header", func(rec []byte) []byte { return rec[:5] }},
+		{"cut in the payload", func(rec []byte) []byte { return rec[:len(rec)-3] }},
+		{"bad checksum", func(rec []byte) []byte { rec[len(rec)-1] ^= 0xff; return rec }},
+		{"garbage", func(rec []byte) []byte { return bytes.Repeat([]byte{0xff}, 40) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, dir := smallWriter(t, 1000, 1<<20)
+			w.Write([]byte(strings.Repeat("before ", 200)))
+			w.Close()
+
+			// A crash tears the last record
+			live := filepath.Join(dir, segmentName(listSegments(dir)[len(listSegments(dir))-1]))
+			f, _ := os.OpenFile(live, os.O_WRONLY|os.O_APPEND, 0644)
+			f.Write(tt.torn(appendRecord(nil, []byte("lost in the crash"))))
+			f.Close()
+
+			// Readers stop before the torn record
+			if got := replayRange(t, dir, 0, 1<<20); string(got) != strings.Repeat("before ", 200) {
+				t.Errorf("before reopening, log = %.30q... (%d bytes)", got, len(got))
+			}
+
+			// The writer trims it and carries on
+			w, err := openWriter(dir)
+			if err != nil {
+				t.Fatalf("openWriter: %v", err)
+			}
+			w.segSize = 1000
+			w.Write([]byte(" after"))
+			w.Close()
+			if got := replayRange(t, dir, 0, 1<<20); string(got) != strings.Repeat("before ", 200)+" after" {
+				t.Errorf("after reopening, log ends %q (%d bytes)", got[max(0, len(got)-20):], len(got))
+			}
+		})
+	}
+}
+
+func TestIndexRebuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(dir string)
+	}{
+		{"missing", func(dir string) { os.Remove(filepath.Join(dir, indexName)) }},
+		{"truncated", func(dir string) { os.Truncate(filepath.Join(dir, indexName), 7) }},
+		{"stale", func(dir string) { writeIndex(dir, []segmentInfo{{start: 0, length: 5}}) }},
+		{"overlapping", func(dir string) {
+			segs := readIndex(dir)
+			segs[0].length = 5000
+			writeIndex(dir, segs)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, dir := smallWriter(t, 1000, 1<<20)
+			stream := pattern(3500)
+			w.Write(stream)
+			w.Close()
+			want := readIndex(dir)
+			tt.corrupt(dir)
+
+			if got := replayRange(t, dir, 0, 3500); !bytes.Equal(got, stream) {
+				t.Errorf("Replay read %d bytes, want the whole stream", len(got))
+			}
+			w, err := openWriter(dir)
+			if err != nil {
+				t.Fatalf("openWriter: %v", err)
+			}
+			w.Close()
+			if got := readIndex(dir); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("rebuilt index = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMigrateLegacyLog(t *testing.T) {
+	path := LogPath("test-legacy")
+	defer DeleteLog("test-legacy")
+	os.MkdirAll(LogDir(), 0755)
+	os.WriteFile(path, []byte("from an old build\n"), 0644)
+
+	w, err := NewWriter("test-legacy")
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	w.Write([]byte("from this one\n"))
+	w.Close()
+
+	data, err := readLog("test-legacy")
+	if err != nil || string(data) != "from an old build\nfrom this one\n" {
+		t.Errorf("readLog = %q, %v", data, err)
+	}
+	if _, err := os.Stat(path + ".old"); !os.IsNotExist(err) {
+		t.Error("the old log file should be removed once converted")
+	}
+}
+
+func TestReadLegacyLog(t *testing.T) {
+	tests := []struct {
+		name string
+		file string // Where the old build's log is
+	}{
+		{"not converted", ""},
+		{"conversion cut short", ".old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := LogPath("test-legacy-read")
+			defer DeleteLog("test-legacy-read")
+			os.MkdirAll(LogDir(), 0755)
+			if tt.file != "" {
+				os.MkdirAll(path, 0755) // What the conversion got done
+			}
+			os.WriteFile(path+tt.file, []byte("from an old build\n"), 0644)
+
+			r, err := OpenReader("test-legacy-read")
+			if err != nil {
+				t.Fatalf("OpenReader: %v", err)
+			}
+			p := &mockParser{}
+			r.Replay(5, r.End(), p)
+			if string(p.data) != "an old build\n" {
+				t.Errorf("Replay = %q", p.data)
+			}
+			// Reading leaves the log as it was
+			if info, err := os.Stat(path + tt.file); err != nil || !info.Mode().IsRegular() {
+				t.Errorf("old log file = %v, %v; want it left alone", info, err)
+			}
+		})
+	}
+}
+
+func TestSyncPolicies(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncPeriodic, SyncAlways, SyncOnSeal} {
+		w, dir := smallWriter(t, 100, 1<<20)
+		w.SetSyncPolicy(policy)
+		stream := pattern(1234)
+		w.Write(stream)
+		w.Flush()
+		if got := replayRange(t, dir, 0, 1<<20); !bytes.Equal(got, stream) {
+			t.Errorf("policy %d: log has %d bytes, want %d", policy, len(got), len(stream))
+		}
+		w.Close()
+	}
+}
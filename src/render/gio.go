@@ -4,6 +4,7 @@ import (
 	"image"
 	"image/color"
 
+	"gioui.org/f32"
 	"gioui.org/font"
 	"gioui.org/font/opentype"
 	"gioui.org/op"
@@ -12,6 +13,8 @@ import (
 	"gioui.org/text"
 	"gioui.org/unit"
 	"golang.org/x/image/math/fixed"
+
+	"prompt-grid/src/emulator"
 )
 
 // GioRenderer renders terminal output using Gio
@@ -191,7 +194,7 @@ func (r *GioRenderer) FillRect(rect image.Rectangle, c color.NRGBA) {
 }
 
 // DrawGlyph draws a character
-func (r *GioRenderer) DrawGlyph(cellX, cellY int, ch rune, fg color.NRGBA, style CellStyle) {
+func (r *GioRenderer) DrawGlyph(cellX, cellY int, glyph string, fg color.NRGBA, style CellStyle) {
 	x := float32(cellX * r.cellW)
 	y := float32(cellY*r.cellH + r.baseline)
 
@@ -211,7 +214,7 @@ func (r *GioRenderer) DrawGlyph(cellX, cellY int, ch rune, fg color.NRGBA, style
 		MaxLines: 1,
 	}
 
-	r.shaper.LayoutString(params, string(ch))
+	r.shaper.LayoutString(params, glyph)
 
 	// Position and draw
 	stack := op.Offset(image.Pt(int(x), int(y))).Push(r.ops)
@@ -243,14 +246,37 @@ func (r *GioRenderer) DrawCursor(cellX, cellY int, style CursorStyle, fg, bg col
 	r.FillRect(rect, fg)
 }
 
-// DrawUnderline draws an underline
-func (r *GioRenderer) DrawUnderline(cellX, cellY int, fg color.NRGBA) {
+// DrawUnderline draws an underline in the given style
+func (r *GioRenderer) DrawUnderline(cellX, cellY int, style emulator.UnderlineStyle, c color.NRGBA) {
+	x := cellX * r.cellW
+	y := cellY * r.cellH
+	for _, rect := range UnderlineRects(style, image.Rect(x, y, x+r.cellW, y+r.cellH)) {
+		r.FillRect(rect, c)
+	}
+}
+
+// DrawOverline draws an overline
+func (r *GioRenderer) DrawOverline(cellX, cellY int, fg color.NRGBA) {
 	x := cellX * r.cellW
-	y := cellY*r.cellH + r.cellH - 2
+	y := cellY * r.cellH
 	rect := image.Rect(x, y, x+r.cellW, y+1)
 	r.FillRect(rect, fg)
 }
 
+// DrawImage draws part of an image scaled into a cell
+func (r *GioRenderer) DrawImage(cellX, cellY int, img image.Image, src image.Rectangle) {
+	if src.Empty() {
+		return
+	}
+	defer op.Offset(image.Pt(cellX*r.cellW, cellY*r.cellH)).Push(r.ops).Pop()
+	defer clip.Rect{Max: image.Pt(r.cellW, r.cellH)}.Push(r.ops).Pop()
+	scale := f32.Pt(float32(r.cellW)/float32(src.Dx()), float32(r.cellH)/float32(src.Dy()))
+	origin := img.Bounds().Min.Sub(src.Min)
+	defer op.Affine(f32.Affine2D{}.Offset(f32.Pt(float32(origin.X), float32(origin.Y))).Scale(f32.Point{}, scale)).Push(r.ops).Pop()
+	paint.NewImageOp(img).Add(r.ops)
+	paint.PaintOp{}.Add(r.ops)
+}
+
 // DrawStrikethrough draws a strikethrough
 func (r *GioRenderer) DrawStrikethrough(cellX, cellY int, fg color.NRGBA) {
 	x := cellX * r.cellW
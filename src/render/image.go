@@ -9,9 +9,12 @@ import (
 	"io"
 	"sync"
 
+	xdraw "golang.org/x/image/draw"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/math/fixed"
+
+	"prompt-grid/src/emulator"
 )
 
 //go:embed fonts/*.ttf
@@ -180,7 +183,7 @@ func (r *ImageRenderer) FillRect(rect image.Rectangle, c color.NRGBA) {
 }
 
 // DrawGlyph draws a character at the given cell position
-func (r *ImageRenderer) DrawGlyph(cellX, cellY int, ch rune, fg color.NRGBA, style CellStyle) {
+func (r *ImageRenderer) DrawGlyph(cellX, cellY int, glyph string, fg color.NRGBA, style CellStyle) {
 	face := r.getFace(style)
 
 	x := cellX * r.cellW
@@ -192,7 +195,7 @@ func (r *ImageRenderer) DrawGlyph(cellX, cellY int, ch rune, fg color.NRGBA, sty
 		Face: face,
 		Dot:  fixed.P(x, y),
 	}
-	d.DrawString(string(ch))
+	d.DrawString(glyph)
 }
 
 // DrawCursor draws the cursor
@@ -213,10 +216,19 @@ func (r *ImageRenderer) DrawCursor(cellX, cellY int, style CursorStyle, fg, bg c
 	}
 }
 
-// DrawUnderline draws an underline
-func (r *ImageRenderer) DrawUnderline(cellX, cellY int, fg color.NRGBA) {
+// DrawUnderline draws an underline in the given style
+func (r *ImageRenderer) DrawUnderline(cellX, cellY int, style emulator.UnderlineStyle, c color.NRGBA) {
+	x := cellX * r.cellW
+	y := cellY * r.cellH
+	for _, rect := range UnderlineRects(style, image.Rect(x, y, x+r.cellW, y+r.cellH)) {
+		r.FillRect(rect, c)
+	}
+}
+
+// DrawOverline draws an overline
+func (r *ImageRenderer) DrawOverline(cellX, cellY int, fg color.NRGBA) {
 	x := cellX * r.cellW
-	y := cellY*r.cellH + r.cellH - 2
+	y := cellY * r.cellH
 	rect := image.Rect(x, y, x+r.cellW, y+1)
 	r.FillRect(rect, fg)
 }
@@ -229,6 +241,18 @@ func (r *ImageRenderer) DrawStrikethrough(cellX, cellY int, fg color.NRGBA) {
 	r.FillRect(rect, fg)
 }
 
+// DrawImage draws part of an image scaled into a cell
+func (r *ImageRenderer) DrawImage(cellX, cellY int, img image.Image, src image.Rectangle) {
+	x := cellX * r.cellW
+	y := cellY * r.cellH
+	rect := image.Rect(x, y, x+r.cellW, y+r.cellH)
+	if rect.Size() == src.Size() {
+		draw.Draw(r.img, rect, img, src.Min, draw.Over)
+		return
+	}
+	xdraw.ApproxBiLinear.Scale(r.img, rect, img, src, draw.Over, nil)
+}
+
 // Image returns the underlying image
 func (r *ImageRenderer) Image() *image.RGBA {
 	return r.img
@@ -48,17 +48,24 @@ type Renderer interface {
 	// FillRect fills a rectangle with a color
 	FillRect(r image.Rectangle, c color.NRGBA)
 
-	// DrawGlyph draws a single character at the given cell position
-	DrawGlyph(cellX, cellY int, r rune, fg color.NRGBA, style CellStyle)
+	// DrawGlyph draws a grapheme (a rune plus any combining marks) at the
+	// given cell position. Wide glyphs spill into the following cell.
+	DrawGlyph(cellX, cellY int, glyph string, fg color.NRGBA, style CellStyle)
 
 	// DrawCursor draws the cursor at the given cell position
 	DrawCursor(cellX, cellY int, style CursorStyle, fg, bg color.NRGBA)
 
-	// DrawUnderline draws an underline at the given cell position
-	DrawUnderline(cellX, cellY int, fg color.NRGBA)
+	// DrawUnderline draws an underline of the given style at the given cell position
+	DrawUnderline(cellX, cellY int, style emulator.UnderlineStyle, c color.NRGBA)
+
+	// DrawOverline draws a line along the top of the given cell position
+	DrawOverline(cellX, cellY int, fg color.NRGBA)
 
 	// DrawStrikethrough draws a strikethrough at the given cell position
 	DrawStrikethrough(cellX, cellY int, fg color.NRGBA)
+
+	// DrawImage draws the src part of img scaled to fill the given cell position
+	DrawImage(cellX, cellY int, img image.Image, src image.Rectangle)
 }
 
 // DefaultColors contains default terminal colors
@@ -89,7 +96,28 @@ func RenderScreen(r Renderer, screen *emulator.Screen, colors DefaultColors) {
 	for y := 0; y < rows; y++ {
 		for x := 0; x < cols; x++ {
 			cell := screen.Cell(x, y)
-			if cell.Rune == 0 || cell.Rune == ' ' {
+			if cell.IsContinuation() {
+				// Painted along with the wide cell to its left
+				continue
+			}
+			if id, col, row, ok := cell.ImageTile(); ok {
+				if img := screen.Image(id); img != nil {
+					r.DrawImage(x, y, img.Pixels, img.TileRect(col, row))
+				}
+				continue
+			}
+			span := 1
+			if cell.IsWide() {
+				span = 2
+			}
+			rect := image.Rect(
+				x*cellSize.X, y*cellSize.Y,
+				(x+span)*cellSize.X, (y+1)*cellSize.Y,
+			)
+
+			empty := cell.Rune == 0 || cell.Rune == ' '
+			decorated := cell.Attrs&emulator.AttrUnderline != 0 || cell.Decoration.Overline()
+			if empty && !decorated {
 				// Skip empty cells unless they have a background
 				if cell.BG.Type == emulator.ColorDefault {
 					continue
@@ -98,16 +126,11 @@ func RenderScreen(r Renderer, screen *emulator.Screen, colors DefaultColors) {
 
 			// Draw background if not default
 			if cell.BG.Type != emulator.ColorDefault {
-				bg := cell.BG.ToNRGBA(colors.Background)
-				rect := image.Rect(
-					x*cellSize.X, y*cellSize.Y,
-					(x+1)*cellSize.X, (y+1)*cellSize.Y,
-				)
-				r.FillRect(rect, bg)
+				r.FillRect(rect, cell.BG.ToNRGBA(colors.Background))
 			}
 
-			// Skip drawing space character
-			if cell.Rune == 0 || cell.Rune == ' ' {
+			// Skip drawing space character (but not its underline)
+			if empty && !decorated {
 				continue
 			}
 
@@ -118,23 +141,27 @@ func RenderScreen(r Renderer, screen *emulator.Screen, colors DefaultColors) {
 			if cell.Attrs&emulator.AttrReverse != 0 {
 				bg := cell.BG.ToNRGBA(colors.Background)
 				fg, bg = bg, fg
-				rect := image.Rect(
-					x*cellSize.X, y*cellSize.Y,
-					(x+1)*cellSize.X, (y+1)*cellSize.Y,
-				)
 				r.FillRect(rect, bg)
 			}
 
 			// Draw glyph
 			style := CellStyleFromAttrs(cell.Attrs)
-			r.DrawGlyph(x, y, cell.Rune, fg, style)
+			if !empty {
+				r.DrawGlyph(x, y, cell.Text(), fg, style)
+			}
 
 			// Draw decorations
-			if style.Underline {
-				r.DrawUnderline(x, y, fg)
-			}
-			if style.Strikethrough {
-				r.DrawStrikethrough(x, y, fg)
+			ul := UnderlineColor(cell, fg)
+			for i := 0; i < span; i++ {
+				if style.Underline {
+					r.DrawUnderline(x+i, y, cell.Decoration.UnderlineStyle(), ul)
+				}
+				if cell.Decoration.Overline() {
+					r.DrawOverline(x+i, y, fg)
+				}
+				if style.Strikethrough {
+					r.DrawStrikethrough(x+i, y, fg)
+				}
 			}
 		}
 	}
@@ -152,3 +179,55 @@ func RenderScreen(r Renderer, screen *emulator.Screen, colors DefaultColors) {
 		r.DrawCursor(cursor.X, cursor.Y, cursorStyle, colors.Cursor, colors.Background)
 	}
 }
+
+// UnderlineColor returns the color of cell's underline: its SGR 58 color,
+// or the text color fg when it has none.
+func UnderlineColor(cell emulator.Cell, fg color.NRGBA) color.NRGBA {
+	return cell.Decoration.UnderlineColor().ToNRGBA(fg)
+}
+
+// UnderlineRects returns the rectangles that draw an underline of the given
+// style along the bottom of rect, so every renderer draws the same shapes.
+// Patterns are phased on the absolute x position so they run on unbroken
+// across neighboring cells.
+func UnderlineRects(style emulator.UnderlineStyle, rect image.Rectangle) []image.Rectangle {
+	y := rect.Max.Y - 2
+	dot := func(x, y int) image.Rectangle { return image.Rect(x, y, x+1, y+1) }
+	var rects []image.Rectangle
+	switch style {
+	case emulator.UnderlineDouble:
+		rects = append(rects,
+			image.Rect(rect.Min.X, y-2, rect.Max.X, y-1),
+			image.Rect(rect.Min.X, y, rect.Max.X, y+1))
+	case emulator.UnderlineCurly:
+		// A triangle wave rising 2*amp pixels above the underline
+		amp := max(1, rect.Dy()/10)
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			phase := x % (4 * amp)
+			rects = append(rects, dot(x, y-abs(phase-2*amp)))
+		}
+	case emulator.UnderlineDotted:
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if x%2 == 0 {
+				rects = append(rects, dot(x, y))
+			}
+		}
+	case emulator.UnderlineDashed:
+		unit := max(1, rect.Dy()/8) // Dashes of three units, gaps of two
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if x%(5*unit) < 3*unit {
+				rects = append(rects, dot(x, y))
+			}
+		}
+	default:
+		rects = append(rects, image.Rect(rect.Min.X, y, rect.Max.X, y+1))
+	}
+	return rects
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -203,7 +203,7 @@ func TestImageRendererDrawGlyph(t *testing.T) {
 	r.Clear(bg)
 
 	fg := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
-	r.DrawGlyph(0, 0, 'A', fg, CellStyle{})
+	r.DrawGlyph(0, 0, "A", fg, CellStyle{})
 
 	// Just verify no panic and something was drawn
 	// (checking specific pixels is fragile due to font rendering)
@@ -250,6 +250,93 @@ func TestImageRendererWritePNG(t *testing.T) {
 	}
 }
 
+func TestUnderlineRects(t *testing.T) {
+	cell := image.Rect(10, 20, 20, 40)
+	tests := []struct {
+		style  emulator.UnderlineStyle
+		rects  int // Number of rectangles
+		pixels int // Pixels covered
+		rows   int // Distinct rows touched
+	}{
+		{emulator.UnderlineSingle, 1, 10, 1},
+		{emulator.UnderlineDouble, 2, 20, 2},
+		{emulator.UnderlineCurly, 10, 10, 5},
+		{emulator.UnderlineDotted, 5, 5, 1},
+		{emulator.UnderlineDashed, 6, 6, 1},
+	}
+	for _, tt := range tests {
+		rects := UnderlineRects(tt.style, cell)
+		pixels := 0
+		rows := map[int]bool{}
+		for _, r := range rects {
+			if !r.In(cell) {
+				t.Errorf("style %d: %v outside the cell", tt.style, r)
+			}
+			pixels += r.Dx() * r.Dy()
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				rows[y] = true
+			}
+		}
+		if len(rects) != tt.rects || pixels != tt.pixels || len(rows) != tt.rows {
+			t.Errorf("style %d: %d rects, %d pixels, %d rows; want %d, %d, %d",
+				tt.style, len(rects), pixels, len(rows), tt.rects, tt.pixels, tt.rows)
+		}
+	}
+}
+
+// TestRenderScreenUnderlineColor verifies a curly underline is drawn in
+// its SGR 58 color, under blanks too.
+func TestRenderScreenUnderlineColor(t *testing.T) {
+	screen := emulator.NewScreen(10, 2)
+	emulator.NewParser(screen, emulator.NewScrollback()).Parse([]byte("\x1b[4:3;58:2::255:0:0mx "))
+
+	r, err := NewImageRenderer(10, 2, 14)
+	if err != nil {
+		t.Fatalf("NewImageRenderer() error = %v", err)
+	}
+	RenderScreen(r, screen, DefaultColorScheme())
+
+	red := color.RGBAModel.Convert(color.NRGBA{R: 255, A: 255})
+	cell := r.CellSize()
+	for x := 0; x < 2; x++ {
+		found := false
+		for _, rect := range UnderlineRects(emulator.UnderlineCurly, image.Rect(x*cell.X, 0, (x+1)*cell.X, cell.Y)) {
+			if r.Image().At(rect.Min.X, rect.Min.Y) == red {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("cell %d has no red underline", x)
+		}
+	}
+}
+
+// TestRenderScreenImage verifies an inline image's tiles are drawn scaled
+// into their cells.
+func TestRenderScreenImage(t *testing.T) {
+	screen := emulator.NewScreen(10, 2)
+	p := emulator.NewParser(screen, emulator.NewScrollback())
+	p.SetCellPixelSize(2, 6)
+	// A 4x6 image over two cells: red left half, blue right half
+	p.Parse([]byte("x\x1bPq#1;2;100;0;0#2;2;0;0;100#1!2~$#2??!2~\x1b\\"))
+
+	r, err := NewImageRenderer(10, 2, 14)
+	if err != nil {
+		t.Fatalf("NewImageRenderer() error = %v", err)
+	}
+	RenderScreen(r, screen, DefaultColorScheme())
+
+	cell := r.CellSize()
+	red := color.RGBAModel.Convert(color.NRGBA{R: 255, A: 255})
+	blue := color.RGBAModel.Convert(color.NRGBA{B: 255, A: 255})
+	if got := r.Image().At(cell.X+cell.X/2, cell.Y/2); got != red {
+		t.Errorf("first tile = %v, want red", got)
+	}
+	if got := r.Image().At(2*cell.X+cell.X/2, cell.Y/2); got != blue {
+		t.Errorf("second tile = %v, want blue", got)
+	}
+}
+
 func TestRenderScreen(t *testing.T) {
 	screen := emulator.NewScreen(10, 5)
 
@@ -295,25 +382,69 @@ func TestRenderScreenWithColors(t *testing.T) {
 	// Just verify no panic - color verification is visual
 }
 
-func TestRenderScreenWithCursor(t *testing.T) {
+func TestRenderScreenWideCell(t *testing.T) {
 	screen := emulator.NewScreen(10, 5)
 
-	// Cursor should be visible by default at 0,0
+	// Wide glyph on a blue background should paint both of its cells
+	attrs := emulator.DefaultCell()
+	attrs.BG = emulator.RGBColor(0, 0, 255)
+	screen.SetAttrs(attrs)
+	screen.SetCursor(2, 1)
+	screen.Write('日')
+
 	r, err := NewImageRenderer(10, 5, 14)
 	if err != nil {
 		t.Fatalf("NewImageRenderer() error = %v", err)
 	}
+	RenderScreen(r, screen, DefaultColorScheme())
 
-	colors := DefaultColorScheme()
-	RenderScreen(r, screen, colors)
-
-	// Check that something was drawn at cursor position
 	img := r.Image()
 	cellSize := r.CellSize()
+	for _, x := range []int{2, 3} {
+		_, _, b, _ := img.At(x*cellSize.X+1, cellSize.Y+1).RGBA()
+		if b>>8 != 255 {
+			t.Errorf("cell %d background blue = %d, want 255", x, b>>8)
+		}
+	}
+}
 
-	// The cursor block should have the cursor color
-	cursorPixel := img.At(cellSize.X/2, cellSize.Y/2)
-	if cursorPixel == colors.Background {
-		t.Error("Cursor should be visible")
+func TestRenderScreenWithCursor(t *testing.T) {
+	colors := DefaultColorScheme()
+	tests := []struct {
+		name    string
+		visible bool
+		want    color.NRGBA
+	}{
+		{"visible", true, colors.Cursor},
+		{"hidden", false, colors.Background},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			screen := emulator.NewScreen(10, 5)
+			screen.SetCursor(3, 2)
+			screen.SetCursorVisible(tt.visible)
+
+			r, err := NewImageRenderer(10, 5, 14)
+			if err != nil {
+				t.Fatalf("NewImageRenderer() error = %v", err)
+			}
+			RenderScreen(r, screen, colors)
+
+			// The block cursor fills its cell with the cursor color
+			img := r.Image()
+			cellSize := r.CellSize()
+			for _, p := range []image.Point{
+				{3*cellSize.X + 1, 2*cellSize.Y + 1},
+				{4*cellSize.X - 2, 3*cellSize.Y - 2},
+			} {
+				if got := color.NRGBAModel.Convert(img.At(p.X, p.Y)); got != tt.want {
+					t.Errorf("pixel %v = %v, want %v", p, got, tt.want)
+				}
+			}
+			// Neighbouring cells are left alone
+			if got := color.NRGBAModel.Convert(img.At(2*cellSize.X+1, 2*cellSize.Y+1)); got != colors.Background {
+				t.Errorf("cell left of the cursor = %v, want the background", got)
+			}
+		})
 	}
 }
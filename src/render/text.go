@@ -0,0 +1,146 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"prompt-grid/src/emulator"
+)
+
+// sgrAttrs maps attributes to their SGR parameters
+var sgrAttrs = []struct {
+	attr  emulator.AttrFlags
+	param string
+}{
+	{emulator.AttrBold, "1"},
+	{emulator.AttrDim, "2"},
+	{emulator.AttrItalic, "3"},
+	{emulator.AttrUnderline, "4"},
+	{emulator.AttrBlink, "5"},
+	{emulator.AttrReverse, "7"},
+	{emulator.AttrHidden, "8"},
+	{emulator.AttrStrikethrough, "9"},
+}
+
+// ScreenText returns the screen as plain text, one line per row with
+// trailing spaces trimmed.
+func ScreenText(screen *emulator.Screen) string {
+	return LinesText(screenRows(screen))
+}
+
+// ScreenANSI returns the screen as text with SGR escape sequences for
+// colors and attributes, so it can be replayed in another terminal.
+func ScreenANSI(screen *emulator.Screen) string {
+	return LinesANSI(screenRows(screen))
+}
+
+// LinesText returns rows of cells (e.g. scrollback and screen) as plain text
+func LinesText(lines [][]emulator.Cell) string {
+	return formatLines(lines, false)
+}
+
+// LinesANSI returns rows of cells as text with SGR escape sequences
+func LinesANSI(lines [][]emulator.Cell) string {
+	return formatLines(lines, true)
+}
+
+// screenRows returns copies of the screen's rows
+func screenRows(screen *emulator.Screen) [][]emulator.Cell {
+	_, rows := screen.Size()
+	lines := make([][]emulator.Cell, rows)
+	for y := range lines {
+		lines[y] = screen.Line(y)
+	}
+	return lines
+}
+
+func formatLines(lines [][]emulator.Cell, styled bool) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		end := len(line)
+		for end > 0 && isPlainBlank(line[end-1], styled) {
+			end--
+		}
+		sgr := "0" // Each row starts from a reset
+		for _, c := range line[:end] {
+			if c.IsContinuation() {
+				continue
+			}
+			if styled {
+				if s := cellSGR(c); s != sgr {
+					sb.WriteString("\x1b[" + s + "m")
+					sgr = s
+				}
+			}
+			sb.WriteString(c.Text())
+		}
+		if sgr != "0" {
+			sb.WriteString("\x1b[0m")
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// isPlainBlank reports whether c is a space that can be trimmed from the
+// end of a row: any space in plain text, an unstyled one in ANSI output.
+func isPlainBlank(c emulator.Cell, styled bool) bool {
+	if c.Rune != ' ' && c.Rune != 0 {
+		return false
+	}
+	return !styled || cellSGR(c) == "0"
+}
+
+// cellSGR returns the SGR parameters that select c's style from a reset
+func cellSGR(c emulator.Cell) string {
+	params := []string{"0"}
+	for _, a := range sgrAttrs {
+		if c.Attrs&a.attr == 0 {
+			continue
+		}
+		param := a.param
+		if style := c.Decoration.UnderlineStyle(); a.attr == emulator.AttrUnderline && style != emulator.UnderlineSingle {
+			param = fmt.Sprintf("4:%d", style+1)
+		}
+		params = append(params, param)
+	}
+	if c.Decoration.Overline() {
+		params = append(params, "53")
+	}
+	if p := colorSGR(c.FG, 30, 90, 38); p != "" {
+		params = append(params, p)
+	}
+	if p := colorSGR(c.BG, 40, 100, 48); p != "" {
+		params = append(params, p)
+	}
+	if p := extendedColorSGR(c.Decoration.UnderlineColor(), 58); p != "" {
+		params = append(params, p)
+	}
+	return strings.Join(params, ";")
+}
+
+// colorSGR returns the SGR parameter for col given the bases of the
+// normal, bright and extended color forms
+func colorSGR(col emulator.Color, normal, bright, extended int) string {
+	if col.Type == emulator.ColorIndexed {
+		switch {
+		case col.Index < 8:
+			return fmt.Sprint(normal + int(col.Index))
+		case col.Index < 16:
+			return fmt.Sprint(bright + int(col.Index) - 8)
+		}
+	}
+	return extendedColorSGR(col, extended)
+}
+
+// extendedColorSGR returns the 256-color or RGB form of the SGR parameter
+// for col, e.g. 38;5;n or 58;2;r;g;b
+func extendedColorSGR(col emulator.Color, extended int) string {
+	switch col.Type {
+	case emulator.ColorIndexed:
+		return fmt.Sprintf("%d;5;%d", extended, col.Index)
+	case emulator.ColorRGB:
+		return fmt.Sprintf("%d;2;%d;%d;%d", extended, col.R, col.G, col.B)
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package render
+
+import (
+	"testing"
+
+	"prompt-grid/src/emulator"
+)
+
+func TestScreenTextAndANSI(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		text  string
+		ansi  string
+	}{
+		{"plain", "hi  ", "hi\n\n", "hi\n\n"},
+		{"bold red", "a\x1b[1;31mb\x1b[0mc", "abc\n\n", "a\x1b[0;1;31mb\x1b[0mc\n\n"},
+		{"bright and 256 colors", "\x1b[92mg\x1b[38;5;200mp", "gp\n\n", "\x1b[0;92mg\x1b[0;38;5;200mp\x1b[0m\n\n"},
+		{"truecolor background kept at row end", "x\x1b[48;2;1;2;3m  ", "x\n\n", "x\x1b[0;48;2;1;2;3m  \x1b[0m\n\n"},
+		{"wide glyph", "日本", "日本\n\n", "日本\n\n"},
+		{"curly colored underline and overline", "\x1b[4:3;53;58:5:9mx\x1b[4:1mu", "xu\n\n", "\x1b[0;4:3;53;58;5;9mx\x1b[0;4;53;58;5;9mu\x1b[0m\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			screen := emulator.NewScreen(10, 2)
+			emulator.NewParser(screen, emulator.NewScrollback()).Parse([]byte(tt.input))
+			if got := ScreenText(screen); got != tt.text {
+				t.Errorf("ScreenText = %q, want %q", got, tt.text)
+			}
+			if got := ScreenANSI(screen); got != tt.ansi {
+				t.Errorf("ScreenANSI = %q, want %q", got, tt.ansi)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package tmux
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// integrationScripts hold the OSC 133 shell integration hooks
+//
+//go:embed shell/integration.*
+var integrationScripts embed.FS
+
+// zshForwarder is a startup file in prompt-grid's ZDOTDIR that sources the
+// user's own copy of file (%[1]s), following any ZDOTDIR change it makes.
+// .zshrc then loads the hooks and hands ZDOTDIR back for .zlogin.
+const zshForwarder = `# prompt-grid: source the user's %[1]s
+ZDOTDIR=${PROMPT_GRID_ZDOTDIR:-$HOME}
+[[ -r $ZDOTDIR/%[1]s ]] && source $ZDOTDIR/%[1]s
+PROMPT_GRID_ZDOTDIR=$ZDOTDIR
+%[2]s
+`
+
+// ShellIntegrationDir returns the directory the shell hooks are written to
+func ShellIntegrationDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "prompt-grid", "shell")
+}
+
+// ShellIntegrationCommand returns the command that starts shell (a path
+// such as $SHELL) as a login shell with prompt-grid's OSC 133 hooks loaded
+// after the user's startup files. Returns nil for shells without hooks
+// (bash, zsh and fish are supported) or if the hooks can't be written.
+func ShellIntegrationCommand(shell string) []string {
+	dir := ShellIntegrationDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil
+	}
+	script := func(name string) string {
+		path := filepath.Join(dir, name)
+		data, _ := integrationScripts.ReadFile("shell/" + name)
+		if os.WriteFile(path, data, 0644) != nil {
+			return ""
+		}
+		return path
+	}
+
+	switch filepath.Base(shell) {
+	case "bash":
+		if rc := script("integration.bash"); rc != "" {
+			return []string{shell, "--rcfile", rc, "-i"}
+		}
+	case "zsh":
+		hooks := script("integration.zsh")
+		zdotdir := filepath.Join(dir, "zsh")
+		if hooks == "" || os.MkdirAll(zdotdir, 0755) != nil {
+			return nil
+		}
+		files := map[string]string{
+			".zshenv":   "ZDOTDIR='" + zdotdir + "'",
+			".zprofile": "ZDOTDIR='" + zdotdir + "'",
+			".zshrc":    "source '" + hooks + "'",
+		}
+		for name, next := range files {
+			if os.WriteFile(filepath.Join(zdotdir, name), []byte(fmt.Sprintf(zshForwarder, name, next)), 0644) != nil {
+				return nil
+			}
+		}
+		user := os.Getenv("ZDOTDIR")
+		if user == "" {
+			user, _ = os.UserHomeDir()
+		}
+		return []string{"env", "ZDOTDIR=" + zdotdir, "PROMPT_GRID_ZDOTDIR=" + user, shell, "-l"}
+	case "fish":
+		if init := script("integration.fish"); init != "" {
+			return []string{shell, "-l", "-C", "source '" + init + "'"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+# prompt-grid shell integration for bash. Started with --rcfile, so the
+# usual login startup files are sourced here first.
+if [ -r /etc/profile ]; then . /etc/profile; fi
+if [ -r ~/.bash_profile ]; then . ~/.bash_profile
+elif [ -r ~/.bash_login ]; then . ~/.bash_login
+elif [ -r ~/.profile ]; then . ~/.profile
+fi
+
+# OSC sequences, wrapped for tmux to pass through to prompt-grid
+__prompt_grid_osc() {
+	if [ -n "$TMUX" ]; then
+		printf '\ePtmux;\e\e]%s\a\e\\' "$1"
+	else
+		printf '\e]%s\a' "$1"
+	fi
+}
+
+# OSC 133 shell integration marks. tmux passes a mark on as soon as it
+# reads it but draws the text before it later, so under tmux a blank is
+# inserted and deleted first: that makes tmux draw the text and put the
+# cursor on the shell's row before the mark, which belongs to that row.
+__prompt_grid_mark() {
+	if [ -n "$TMUX" ]; then
+		printf '\e[@\e[P'
+	fi
+	__prompt_grid_osc "133;$1"
+}
+
+# OSC 7 working directory, so prompt-grid follows cd right away
+__prompt_grid_cwd() {
+	local path=${PWD//\%/%25}
+	path=${path// /%20}
+	path=${path//#/%23}
+	path=${path//\?/%3F}
+	__prompt_grid_osc "7;file://$HOSTNAME$path"
+}
+
+__prompt_grid_precmd() {
+	if [ -n "$__prompt_grid_ran" ]; then
+		__prompt_grid_mark "D;$__prompt_grid_status"
+	fi
+	__prompt_grid_ran=1
+	__prompt_grid_cwd
+	# Re-wrap the prompt in case a prompt framework replaced it
+	case $PS1 in
+	*__prompt_grid_mark*) ;;
+	*) PS1='\[$(__prompt_grid_mark A)\]'"$PS1"'\[$(__prompt_grid_mark B)\]' ;;
+	esac
+}
+
+__prompt_grid_nl=$'\n'
+PROMPT_COMMAND="__prompt_grid_status=\$?$__prompt_grid_nl${PROMPT_COMMAND:+$PROMPT_COMMAND$__prompt_grid_nl}__prompt_grid_precmd"
+PS0="$PS0"'$(__prompt_grid_mark C)'
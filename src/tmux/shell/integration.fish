@@ -0,0 +1,42 @@
+# prompt-grid shell integration for fish, run with --init-command
+
+# OSC sequences, wrapped for tmux to pass through to prompt-grid
+function __prompt_grid_osc
+    if set -q TMUX
+        printf '\ePtmux;\e\e]%s\a\e\\' $argv[1]
+    else
+        printf '\e]%s\a' $argv[1]
+    end
+end
+
+# OSC 133 shell integration marks. tmux passes a mark on as soon as it
+# reads it but draws the text before it later, so under tmux a blank is
+# inserted and deleted first: that makes tmux draw the text and put the
+# cursor on the shell's row before the mark, which belongs to that row.
+function __prompt_grid_mark
+    if set -q TMUX
+        printf '\e[@\e[P'
+    end
+    __prompt_grid_osc "133;$argv[1]"
+end
+
+# OSC 7 working directory, so prompt-grid follows cd right away
+function __prompt_grid_cwd --on-variable PWD
+    __prompt_grid_osc "7;file://"(prompt_hostname)(string escape --style=url -- $PWD)
+end
+__prompt_grid_cwd
+
+function __prompt_grid_preexec --on-event fish_preexec
+    __prompt_grid_mark C
+end
+
+function __prompt_grid_postexec --on-event fish_postexec
+    __prompt_grid_mark "D;$status"
+end
+
+functions -q fish_prompt; and functions -c fish_prompt __prompt_grid_fish_prompt
+function fish_prompt
+    __prompt_grid_mark A
+    functions -q __prompt_grid_fish_prompt; and __prompt_grid_fish_prompt
+    __prompt_grid_mark B
+end
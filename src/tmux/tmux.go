@@ -5,7 +5,6 @@ import (
 	"os"
 	"os/exec"
 	"strings"
-	"sync"
 )
 
 const (
@@ -42,21 +41,35 @@ func EnsureInstalled() error {
 	return nil
 }
 
-// serverConfigOnce ensures global tmux server options are set exactly once.
-var serverConfigOnce sync.Once
-
-// ConfigureServer sets global options on the tmux server (status off, prefix disabled).
-// Safe to call multiple times — uses sync.Once internally.
+// ConfigureServer sets global options on the tmux server (status off, prefix
+// disabled, no alternate screen, underline styles, synchronized updates,
+// extended keys, OSC 52 clipboard and OSC 133 mark passthrough).
+// Safe to call multiple times. The server exits with its last session, so
+// this runs for every new session rather than once per process.
 func ConfigureServer() {
-	serverConfigOnce.Do(func() {
-		cmd := exec.Command("tmux",
-			"-L", ServerName(),
-			"set-option", "-g", "status", "off", ";",
-			"set-option", "-g", "prefix", "None", ";",
-			"set-option", "-g", "history-limit", "1",
-		)
-		cmd.Run() // best-effort
-	})
+	cmd := exec.Command("tmux",
+		"-L", ServerName(),
+		"set-option", "-g", "status", "off", ";",
+		"set-option", "-g", "prefix", "None", ";",
+		"set-option", "-g", "history-limit", "1", ";",
+		// Draw on prompt-grid's main screen rather than its alternate
+		// screen, so lines scrolling off tmux's pane reach the scrollback
+		"set-option", "-s", "terminal-overrides[90]", "*:smcup@:rmcup@", ";",
+		// Pass styled and colored underlines and overline through rather
+		// than downgrading them to a plain underline, and wrap each redraw
+		// in a synchronized update so it shows as one frame
+		"set-option", "-s", "terminal-features[90]", "*:usstyle:overline:sync", ";",
+		// Have tmux turn on modifyOtherKeys in prompt-grid and pass keys
+		// like Shift+Enter and Ctrl+I on to programs that ask for them;
+		// tmux doesn't forward the kitty keyboard protocol
+		"set-option", "-s", "extended-keys", "on", ";",
+		"set-option", "-s", "terminal-features[91]", "*:extkeys", ";",
+		// Pass programs' OSC 52 clipboard requests through to prompt-grid
+		"set-option", "-s", "set-clipboard", "on", ";",
+		// Let the shell integration hooks send OSC 133 marks through
+		"set-option", "-g", "allow-passthrough", "on",
+	)
+	cmd.Run() // best-effort
 }
 
 // NewSession creates a new tmux session with the given name and size.
@@ -89,7 +102,7 @@ func NewSession(name, workDir string, cols, rows uint16, cmd ...string) error {
 		return fmt.Errorf("tmux new-session failed: %w: %s", err, out)
 	}
 
-	// Set global server options once (status off, prefix disabled).
+	// Set global server options (status off, prefix disabled).
 	ConfigureServer()
 
 	return nil
@@ -191,6 +204,18 @@ func GetPaneCurrentPath(name string) (string, error) {
 	return strings.TrimSpace(string(out)), nil
 }
 
+// GetPanePath returns the working directory URL the program in a session's
+// active pane last reported with OSC 7 (e.g. "file://host/path"), which tmux
+// keeps rather than passing on. Empty if it never reported one.
+func GetPanePath(name string) (string, error) {
+	cmd := exec.Command("tmux", "-L", ServerName(), "display-message", "-p", "-t", name, "#{pane_path}")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("tmux display-message failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 // KillServer kills the entire tmux server (for test cleanup)
 func KillServer() error {
 	cmd := exec.Command("tmux", "-L", ServerName(), "kill-server")